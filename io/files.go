@@ -23,19 +23,14 @@ func CreateFileFolders(path string, perm os.FileMode) error {
 		return errors.New("Empty file name unsupported!!")
 
 	}
-	info, err := os.Stat(path)
+	_, err := os.Stat(path)
 	if err != nil {
-		if !info.IsDir() {
-			folder := filepath.Dir(path)
-			_, err = os.Stat(folder)
-			if err != nil {
-				return os.MkdirAll(folder, perm)
-			} else {
-				return errors.New(fmt.Sprintf("Folder %s already exists!!", path))
-			}
-		} else {
-			return os.MkdirAll(path, perm)
+		folder := filepath.Dir(path)
+		_, err = os.Stat(folder)
+		if err != nil {
+			return os.MkdirAll(folder, perm)
 		}
+		return nil
 	} else {
 		err = errors.New(fmt.Sprintf("File %s already exists!!", path))
 	}
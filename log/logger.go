@@ -192,7 +192,7 @@ func (l *logger) Fatal(in ...interface{}) {
 func (l *logger) Printf(format string, in ...interface{}) {
 	var buf []byte = []byte(fmt.Sprintf(format, in...))
 	if l.onScreen {
-		color.LightWhite.Printf(string(buf))
+		color.LightWhite.Print(string(buf))
 	} else {
 		if l.IsAffiliated() {
 			l.mainLogger.AffiliateWrite(l.prefix, buf)
@@ -205,7 +205,7 @@ func (l *logger) Printf(format string, in ...interface{}) {
 func (l *logger) Println(in ...interface{}) {
 	var buf []byte = []byte(fmt.Sprint(in...) + "\n")
 	if l.onScreen {
-		color.LightWhite.Printf(string(buf))
+		color.LightWhite.Print(string(buf))
 	} else {
 		if l.IsAffiliated() {
 			l.mainLogger.AffiliateWrite(l.prefix, buf)
@@ -381,7 +381,7 @@ func (l *logger) outputLogger(prefix string, color color.Color, calldepth int, s
 		l.buf = append(l.buf, '\n')
 	}
 	if l.onScreen {
-		color.Printf(string(l.buf))
+		color.Print(string(l.buf))
 		return nil
 	} else {
 		_, err := l.out.Write(l.buf)
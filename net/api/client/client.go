@@ -1,7 +1,7 @@
 package client
 
 import (
-	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
@@ -9,6 +9,8 @@ import (
 	"github.com/hellgate75/go-tcp-common/log"
 	common2 "github.com/hellgate75/go-tcp-common/net/api/common"
 	"github.com/hellgate75/go-tcp-common/net/common"
+	rcom "github.com/hellgate75/go-tcp-common/net/rest/common"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -22,6 +24,9 @@ type apiClient struct{
 	Port            int64
 	client          *http.Client
 	connTls         *tls.Conn
+	policy          rcom.TLSPolicy
+	resilience      rcom.ResiliencePolicy
+	certificates    rcom.CertificateManager
 }
 
 func (cli *apiClient) Connect(ipAddress string, port int64) error {
@@ -37,10 +42,13 @@ func (cli *apiClient) Connect(ipAddress string, port int64) error {
 		return errors.New("Client already cinnected!!")
 	}
 	var config *tls.Config = &tls.Config{}
+	if err := cli.policy.Apply(config); err != nil {
+		return err
+	}
 	cli.client = &http.Client{
-		Transport: &http.Transport{
+		Transport: rcom.NewResilientTransport(&http.Transport{
 			TLSClientConfig: config,
-		},
+		}, cli.resilience),
 	}
 	service := fmt.Sprintf("%s:%v", cli.IpAddress, cli.Port)
 	cli.logger.Debugf("Connecting to service: %s", service)
@@ -66,6 +74,9 @@ func (cli *apiClient) ConnectTSL(ipAddress string, port int64, baseConfig *commo
 	}
 
 	var config *tls.Config = &tls.Config{}
+	if err := cli.policy.Apply(config); err != nil {
+		return err
+	}
 	if "" != baseConfig.CaCertificate {
 		cli.logger.Debugf("client: using ca cert: <%s>", baseConfig.CaCertificate)
 		caCert, err := ioutil.ReadFile(baseConfig.CaCertificate)
@@ -82,23 +93,20 @@ func (cli *apiClient) ConnectTSL(ipAddress string, port int64, baseConfig *commo
 			}
 		}
 	}
-	if baseConfig.Certificates != nil && len(baseConfig.Certificates) > 0 {
-		if "" != baseConfig.Certificates[0].Key &&  "" != baseConfig.Certificates[0].Cert {
-			cli.logger.Debugf("client: using client key: <%s>, cert: <%s> ", baseConfig.Certificates[0].Key, baseConfig.Certificates[0].Cert)
-			cli.logger.Debugf("client: using client key: <%s>, cert: <%s> ", baseConfig.Certificates[0].Key, baseConfig.Certificates[0].Cert)
-			cert, err := tls.LoadX509KeyPair(baseConfig.Certificates[0].Cert, baseConfig.Certificates[0].Key)
-			if err != nil {
-				cli.logger.Errorf("client: Unable to load key : %s and certificate: %s", baseConfig.Certificates[0].Key, baseConfig.Certificates[0].Cert)
-				cli.logger.Fatalf("client: loadkeys: %s", err.Error())
-			} else {
-				config.Certificates=[]tls.Certificate{cert}
-			}
+	if len(baseConfig.Certificates) > 0 {
+		cli.logger.Debugf("client: using client certificates: %v", baseConfig.Certificates)
+		certificates := rcom.NewCertificateManager(baseConfig.Certificates, "", rcom.DEFAULT_CERTIFICATE_WATCH_INTERVAL, cli.logger)
+		if err := certificates.Start(); err != nil {
+			cli.logger.Errorf("client: Unable to load the client certificates: %v", baseConfig.Certificates)
+			return err
 		}
+		cli.certificates = certificates
+		config = certificates.ClientConfig(config)
 	}
 	cli.client = &http.Client{
-		Transport: &http.Transport{
+		Transport: rcom.NewResilientTransport(&http.Transport{
 			TLSClientConfig: config,
-		},
+		}, cli.resilience),
 	}
 	service := fmt.Sprintf("%s:%v", cli.IpAddress, cli.Port)
 	cli.logger.Debugf("Connecting to service: %s", service)
@@ -127,6 +135,10 @@ func (cli *apiClient) ConnectTSL(ipAddress string, port int64, baseConfig *commo
 }
 
 func (cli *apiClient) Close() error {
+	if cli.certificates != nil {
+		cli.certificates.Stop()
+		cli.certificates = nil
+	}
 	if cli.client != nil {
 		cli.client.CloseIdleConnections()
 		cli.client = nil
@@ -139,50 +151,94 @@ func (cli *apiClient) Close() error {
 	}
 	return nil
 }
+func (cli *apiClient) SetTLSPolicy(policy rcom.TLSPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	cli.policy = policy
+	return nil
+}
+func (cli *apiClient) SetResiliencePolicy(policy rcom.ResiliencePolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	cli.resilience = policy
+	return nil
+}
 func (cli *apiClient) GetApi(protocol common.RestProtocol, path string, method *common.RestMethod, produces *common.MimeType, consumes *common.MimeType, body *[]byte, values *url.Values) (int, []byte, error) {
-	var html *http.Response = nil
-	var err error = nil
-	var status int = 0
-	defer func(){
-		if r := recover(); r != nil {
-			err = errors.New(fmt.Sprintf("RestClient.Connect, Error: %s", r))
-			status = 500
-		}
-	}()
-	requestUrl := fmt.Sprintf("%s://%s:%v%s", string(protocol), cli.IpAddress, cli.Port, path)
-	if common.REST_METHOD_GET == *method {
-		html,err = cli.client.Get(requestUrl)
-	} else if common.REST_METHOD_POST == *method {
-		html,err = cli.client.Post(requestUrl, string(*consumes), bytes.NewBuffer(*body))
-	} else if common.REST_METHOD_POST_FORM == *method {
-		html,err = cli.client.PostForm(requestUrl, *values)
-	} else if common.REST_METHOD_HEAD == *method {
-		html,err = cli.client.Head(requestUrl)
-	} else {
-		return status, []byte{}, errors.New(fmt.Sprintf("Unavailable Method: %v!!", method))
-	}
-	if err!=nil {
-		return status, []byte{}, err
-	}
-	if html.StatusCode != 200 {
-		return html.StatusCode, []byte{}, errors.New(fmt.Sprintf("Status Code: %v, Message: %s", html.StatusCode, html.Status))
-	}
-	defer html.Body.Close()
-	output, err := ioutil.ReadAll(html.Body)
+	return cli.GetApiContext(context.Background(), protocol, path, method, produces, consumes, body, values)
+}
+
+func (cli *apiClient) GetApiContext(ctx context.Context, protocol common.RestProtocol, path string, method *common.RestMethod, produces *common.MimeType, consumes *common.MimeType, body *[]byte, values *url.Values) (int, []byte, error) {
+	request := cli.request(ctx, protocol, path, method, produces, consumes, values)
+	if body != nil {
+		request.Body(*body)
+	}
+	response, err := request.Do()
 	if err != nil {
-		cli.logger.Errorf("Status: %v", html.StatusCode)
-		cli.logger.Errorf("Error reading body: %v", err)
-		return status, []byte{}, err
-	}
-	cli.logger.Debugf("Status: %v", html.StatusCode)
-	status = html.StatusCode
-	return status, output, err
-	return 0, []byte{}, nil
+		return 0, []byte{}, err
+	}
+	cli.logger.Debugf("Status: %v", response.StatusCode)
+	if !response.IsSuccess() {
+		return response.StatusCode, response.Body, errors.New(fmt.Sprintf("Status Code: %v, Message: %s", response.StatusCode, response.Status))
+	}
+	return response.StatusCode, response.Body, nil
+}
+
+func (cli *apiClient) GetApiStream(ctx context.Context, protocol common.RestProtocol, path string, method *common.RestMethod, produces *common.MimeType, consumes *common.MimeType, body io.Reader, size int64, values *url.Values) (int, io.ReadCloser, error) {
+	request := cli.request(ctx, protocol, path, method, produces, consumes, values)
+	if body != nil {
+		request.BodyReader(body, size)
+	}
+	response, err := request.Stream()
+	if err != nil {
+		return 0, nil, err
+	}
+	cli.logger.Debugf("Status: %v", response.StatusCode)
+	if !response.IsSuccess() {
+		return response.StatusCode, response.Body, errors.New(fmt.Sprintf("Status Code: %v, Message: %s", response.StatusCode, response.Status))
+	}
+	return response.StatusCode, response.Body, nil
+}
+
+// Creates the request builder of the GetApi calls, the values are sent as form for POST_FORM and as query otherwise
+func (cli *apiClient) request(ctx context.Context, protocol common.RestProtocol, path string, method *common.RestMethod, produces *common.MimeType, consumes *common.MimeType, values *url.Values) rcom.Request {
+	request := cli.NewRequest(path).Context(ctx).Protocol(protocol)
+	if method != nil {
+		request.Method(*method)
+	}
+	if produces != nil {
+		request.Accept(*produces)
+	}
+	if consumes != nil {
+		request.ContentType(*consumes)
+	}
+	if values != nil {
+		if method != nil && common.REST_METHOD_POST_FORM == *method {
+			request.Form(*values)
+		} else {
+			for name, list := range *values {
+				for _, value := range list {
+					request.Query(name, value)
+				}
+			}
+		}
+	}
+	return request
+}
+
+func (cli *apiClient) NewRequest(path string) rcom.Request {
+	var doer rcom.Doer = nil
+	if cli.client != nil {
+		doer = cli.client
+	}
+	return rcom.NewRequest(doer, fmt.Sprintf("%s:%v", cli.IpAddress, cli.Port), path)
 }
 
 func NewApiClient(logger log.Logger) common2.APIClient {
 	return &apiClient{
 		logger: logger,
 		client: nil,
+		policy: rcom.DEFAULT_TLS_POLICY,
 	}
 }
\ No newline at end of file
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"github.com/hellgate75/go-tcp-common/log"
+	common2 "github.com/hellgate75/go-tcp-common/net/api/common"
+	"github.com/hellgate75/go-tcp-common/net/common"
+	"github.com/hellgate75/go-tcp-common/net/pki"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestApiClientRequest(t *testing.T) {
+	ca, _ := pki.NewCertificateAuthority(pki.CertificateRequest{Subject: pkix.Name{CommonName: "Test Root CA"}})
+	cert, _ := ca.IssueServer(pki.CertificateRequest{Hosts: []string{"127.0.0.1"}})
+	pair, _ := cert.TLSCertificate()
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	ca.Certificate().WriteFiles(caFile, "")
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		w.Header().Set("Location", "/items/1")
+		w.Header().Set("X-Accept", req.Header.Get("Accept"))
+		w.Header().Set("X-Content-Type", req.Header.Get("Content-Type"))
+		w.Header().Set("X-Custom", req.Header.Get("X-Custom"))
+		switch req.Method {
+		case http.MethodPut:
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusConflict)
+		}
+		w.Write(append([]byte(req.Method+" "+req.URL.RawQuery+" "), body...))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{pair}}
+	server.StartTLS()
+	defer server.Close()
+	host, portText, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.ParseInt(portText, 10, 64)
+
+	client := NewApiClient(log.NewLogger("api-client-test", log.FATAL))
+	if _, err := client.NewRequest("/items").Do(); err == nil {
+		t.Fatalf("TestApiClientRequest - ApiClient.NewRequest - Expected: %v but Given: %v", "not connected error", err)
+	}
+	if err := client.ConnectTSL(host, port, &common2.TLSConfig{CaCertificate: caFile}); err != nil {
+		t.Fatalf("TestApiClientRequest - ApiClient.ConnectTSL - Expected: %v but Given: %v", nil, err)
+	}
+	defer client.Close()
+
+	method := common.REST_METHOD_PUT
+	mime := common.JSON_MIME_TYPE
+	body := []byte("{}")
+	values := url.Values{"id": []string{"1"}}
+	status, answer, err := client.GetApi(common.REST_PROTOCOL_HTTPS, "/items", &method, &mime, &mime, &body, &values)
+	if err != nil || status != http.StatusCreated || string(answer) != "PUT id=1 {}" {
+		t.Fatalf("TestApiClientRequest - ApiClient.GetApi - Expected: %v %q but Given: %v %q (%v)", http.StatusCreated, "PUT id=1 {}", status, answer, err)
+	}
+	method = common.REST_METHOD_DELETE
+	status, answer, err = client.GetApi(common.REST_PROTOCOL_HTTPS, "/items/1", &method, nil, nil, nil, nil)
+	if err == nil || status != http.StatusConflict || string(answer) != "DELETE  " {
+		t.Fatalf("TestApiClientRequest - ApiClient.GetApi - Expected: %v %q but Given: %v %q (%v)", http.StatusConflict, "DELETE  ", status, answer, err)
+	}
+
+	response, err := client.NewRequest("/items").Method(common.REST_METHOD_PUT).Protocol(common.REST_PROTOCOL_HTTPS).
+		Header("X-Custom", "custom").Accept(common.PLAIN_TEXT_MIME_TYPE).ContentType(common.JSON_MIME_TYPE).Body(body).Do()
+	if err != nil || response.StatusCode != http.StatusCreated || response.Header.Get("Location") != "/items/1" {
+		t.Fatalf("TestApiClientRequest - ApiClient.NewRequest - Expected: %v %v but Given: %+v (%v)", http.StatusCreated, "/items/1", response, err)
+	}
+	if response.Header.Get("X-Custom") != "custom" || response.Header.Get("X-Accept") != string(common.PLAIN_TEXT_MIME_TYPE) ||
+		response.Header.Get("X-Content-Type") != string(common.JSON_MIME_TYPE) {
+		t.Fatalf("TestApiClientRequest - Request.Header - Expected: %v but Given: %v", "request headers", response.Header)
+	}
+	response, err = client.NewRequest("/items/1").Method(common.REST_METHOD_DELETE).Protocol(common.REST_PROTOCOL_HTTPS).Do()
+	if err != nil || response.StatusCode != http.StatusConflict || response.IsSuccess() || string(response.Body) != "DELETE  " {
+		t.Fatalf("TestApiClientRequest - ApiClient.NewRequest - Expected: %v but Given: %+v (%v)", http.StatusConflict, response, err)
+	}
+}
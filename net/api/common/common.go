@@ -1,17 +1,23 @@
 package common
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/hellgate75/go-tcp-common/io/streams"
 	"github.com/hellgate75/go-tcp-common/net/common"
 	common2 "github.com/hellgate75/go-tcp-common/net/rest/common"
+	"io"
 	"net/url"
 )
 
+// Api Server and Client TLS material. The CA certificate verifies the client certificates
+// on the server side, according to the ClientAuth mode
 type TLSConfig struct {
 	CaCertificate   string
 	Certificates    []common2.CertificateKeyPair
 	UseInsecure     bool
+	ClientAuth      common.ClientAuthMode
 }
 
 type ApiServer interface{
@@ -22,13 +28,41 @@ type ApiServer interface{
 	IsRunning() bool
 	AddApiAction(path string, action common.ApiAction, hasInternalAnswer bool, method *common.RestMethod, produces *common.MimeType, consumes *common.MimeType) bool
 	AddApiStream(path string, stream streams.DataStream, method *common.RestMethod, produces *common.MimeType, consumes *common.MimeType) bool
+	// Allows one more web method on a registered path, served by the same action or stream
+	AllowMethod(path string, method *common.RestMethod) bool
+	// Appends middleware executed around every request, the first one is the outermost
+	Use(middleware ...common.Middleware)
+	// Appends middleware executed around the action or stream of a registered path
+	UsePath(path string, middleware ...common.Middleware) bool
+	// Sets the http server timeouts, zero values keep the defaults
+	SetTimeouts(timeouts common.ServerTimeouts)
+	// Sets the http server header and body size limits
+	SetLimits(limits common.ServerLimits)
+	// Sets the base TLS configuration (versions, cipher suites, curves), the certificates are loaded on TLS start
+	ConfigureTLS(config *tls.Config)
+	// Sets the base TLS configuration from a TLS policy, the certificates are loaded on TLS start
+	SetTLSPolicy(policy common2.TLSPolicy) error
 }
 
 type APIClient interface {
 	Connect(ipAddress string, port int64) error
 	ConnectTSL(ipAddress string, port int64, config *TLSConfig) error
 	Close() error
+	// Sets the TLS policy (versions, cipher suites, curves, ALPN) used by the next connection
+	SetTLSPolicy(policy common2.TLSPolicy) error
+	// Sets the retry, backoff and circuit breaker policy of the API calls, used by the next connection
+	SetResiliencePolicy(policy common2.ResiliencePolicy) error
+	// Calls an API of the connected server, produces is sent as Accept header and consumes as body Content-Type.
+	// Non 2xx status codes are returned with the response body and an error
 	GetApi(protocol common.RestProtocol,path string, method *common.RestMethod, produces *common.MimeType, consumes *common.MimeType, body *[]byte, values *url.Values) (int, []byte, error)
+	// Calls an API as GetApi does, bounded by the context deadline and cancellation
+	GetApiContext(ctx context.Context, protocol common.RestProtocol,path string, method *common.RestMethod, produces *common.MimeType, consumes *common.MimeType, body *[]byte, values *url.Values) (int, []byte, error)
+	// Calls an API as GetApiContext does, with a body stream of size bytes (zero or negative for chunked transfer)
+	// and returning the response body as stream, to be closed by the caller when not nil
+	GetApiStream(ctx context.Context, protocol common.RestProtocol,path string, method *common.RestMethod, produces *common.MimeType, consumes *common.MimeType, body io.Reader, size int64, values *url.Values) (int, io.ReadCloser, error)
+	// Creates a request builder for the path of the connected server, allowing any web method, headers and
+	// query values, and returning status code, headers and body of the response
+	NewRequest(path string) common2.Request
 }
 
 type HandlerRef struct{
@@ -39,11 +73,20 @@ type HandlerRef struct{
 	Method      *common.RestMethod
 	Consumes     *common.MimeType
 	Produces    *common.MimeType
+	Middleware  []common.Middleware
 }
 
 func (ha *HandlerRef) String() string {
 	return fmt.Sprintf("HandlerRef{Path: \"%s\", Action: %v, Stream: %v, Method: %v, Produces: %v, Consumes: %v}",
-		ha.Path, ha.Action != nil, ha.Stream != nil, *ha.Method, *ha.Produces, *ha.Consumes)
+		ha.Path, ha.Action != nil, ha.Stream != nil, *ha.Method, mimeTypeOf(ha.Produces), mimeTypeOf(ha.Consumes))
+}
+
+// Mime Type of an optional reference, empty when nil
+func mimeTypeOf(mimeType *common.MimeType) common.MimeType {
+	if mimeType == nil {
+		return ""
+	}
+	return *mimeType
 }
 
 func (ha *HandlerRef) IsAction() bool {
@@ -3,9 +3,7 @@ package server
 import (
 	"bytes"
 	"context"
-	"crypto/rand"
 	"crypto/tls"
-	"crypto/x509"
 	"errors"
 	"fmt"
 	"github.com/gorilla/mux"
@@ -14,8 +12,8 @@ import (
 	"github.com/hellgate75/go-tcp-common/log"
 	"github.com/hellgate75/go-tcp-common/net/api/common"
 	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	rcom "github.com/hellgate75/go-tcp-common/net/rest/common"
 	"github.com/satori/go.uuid"
-	"io/ioutil"
 	"net"
 	"net/http"
 	"sync"
@@ -29,6 +27,12 @@ type apiServer struct{
 	server  *http.Server
 	Routes  map[string]*common.HandlerRef
 	TlsMode bool
+	settingsLock sync.RWMutex
+	middleware   []ncom.Middleware
+	timeouts     ncom.ServerTimeouts
+	limits       ncom.ServerLimits
+	tlsConfig    *tls.Config
+	certificates rcom.CertificateManager
 }
 var (
 	DEFAULT_HEADER_READ_TIMEOUT time.Duration = 60 * time.Second
@@ -56,70 +60,32 @@ func (as *apiServer) StartTLS(ipAddress string, port int64, config *common.TLSCo
 	}()
 	as.Lock()
 	locked = true
-	if as.server != nil {
+	if as.httpServer() != nil {
 		return errors.New("Server already running!!")
 	}
-	tlsCfg := &tls.Config{
-		MinVersion:               tls.VersionTLS12,
-		CurvePreferences:         []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
-		PreferServerCipherSuites: true,
-		CipherSuites: []uint16{
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-		},
-		ClientSessionCache: tls.NewLRUClientSessionCache(256),
-		Rand: rand.Reader,
-		InsecureSkipVerify: config.UseInsecure,
-		Renegotiation: tls.RenegotiateNever,
+	tlsCfg := as.serverTLSConfig()
+	tlsCfg.InsecureSkipVerify = config.UseInsecure
+	tlsCfg.ClientAuth, err = config.ClientAuth.TLSClientAuth()
+	if err != nil {
+		return err
 	}
 	as.logger.Debugf("api: server: using insecure: <%v>", config.UseInsecure)
-	if "" != config.CaCertificate {
-		if as.logger != nil {
-			as.logger.Debugf("api: server: using ca cert: <%s>", config.CaCertificate)
-		}
-		caCert, err := ioutil.ReadFile(config.CaCertificate)
-		if err != nil {
-			if as.logger != nil {
-				as.logger.Errorf("api: server: using ca cert: <%s>, details: %s", caCert, err.Error())
-			}
-		} else {
-			caCertPool := x509.NewCertPool()
-			if ok := caCertPool.AppendCertsFromPEM(caCert); !ok {
-				if as.logger != nil {
-					as.logger.Error("No certs appended, using system certificates only")
-					as.logger.Fatalf("api: server: loadkeys: %s", err.Error())
-				}
-			} else {
-				tlsCfg.RootCAs= caCertPool
-			}
-		}
+	if as.logger != nil {
+		as.logger.Debugf("api: server: using ca cert: <%s>, certificates: %v", config.CaCertificate, config.Certificates)
 	}
-	var cert string
-	var key string
-	if config.Certificates != nil && len(config.Certificates) > 0 {
-		cert = config.Certificates[0].Cert
-		key = config.Certificates[0].Key
-		var certificates []tls.Certificate = make([]tls.Certificate, 0)
-		for _, config := range config.Certificates {
-			if "" != config.Key &&  "" != config.Cert {
-				as.logger.Debugf("api: server: using server key: <%s>, cert: <%s> ", config.Key, config.Cert)
-				cert, err := tls.LoadX509KeyPair(config.Cert, config.Key)
-				if err != nil {
-					as.logger.Errorf("api: server: Unable to load key : %s and certificate: %s", config.Key, config.Cert)
-					as.logger.Fatalf("api: server: loadkeys: %s", err.Error())
-				} else {
-				}
-				certificates = append(certificates, cert)
-			}
-		}
-		tlsCfg.Certificates=certificates
+	certificates := rcom.NewCertificateManager(config.Certificates, config.CaCertificate, rcom.DEFAULT_CERTIFICATE_WATCH_INTERVAL, as.logger)
+	if err = certificates.Start(); err != nil {
+		return err
 	}
-	as.server = &http.Server{
+	as.settingsLock.Lock()
+	as.certificates = certificates
+	as.settingsLock.Unlock()
+	tlsCfg = certificates.ServerConfig(tlsCfg)
+	timeouts, limits := as.settings()
+	srv := &http.Server{
 		Addr: fmt.Sprintf("%s:%v", ipAddress, port),
 		TLSConfig: tlsCfg,
-		Handler: as.Router,
+		Handler: as,
 		ConnContext: func(ctx context.Context, c net.Conn) context.Context{
 			ctx = context.WithValue(ctx, ncom.ContextRemoteAddress, c.RemoteAddr())
 			sessionKey, err := uuid.NewV4()
@@ -133,15 +99,18 @@ func (as *apiServer) StartTLS(ipAddress string, port int64, config *common.TLSCo
 			ctx = context.WithValue(ctx, ncom.ContextKeyAuthtoken, ncom.GenerateSecureToken(64))
 			return ctx
 		},
-		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
-		ReadTimeout: DEFAULT_READ_TIMEOUT,
-		ReadHeaderTimeout: DEFAULT_HEADER_READ_TIMEOUT,
-		WriteTimeout: DEFAULT_WRITE_TIMEOUT,
-		IdleTimeout: DEFAULT_IDLE_TIMEOUT,
+		TLSNextProto: rcom.TLSNextProto(tlsCfg),
+		ReadTimeout: timeouts.Read,
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		WriteTimeout: timeouts.Write,
+		IdleTimeout: timeouts.Idle,
+		MaxHeaderBytes: limits.MaxHeaderBytes,
 	}
-	as.logger.Debugf("Starting tls with Certificate file : <%s> and Key file: <%s>", cert, key)
-	err = as.server.ListenAndServeTLS(cert, key)
-	if err != nil {
+	as.setServer(srv)
+	as.logger.Debugf("Starting tls with Certificates: %v", config.Certificates)
+	err = srv.ListenAndServeTLS("", "")
+	as.stopCertificates()
+	if err != nil && err != http.ErrServerClosed {
 		as.logger.Errorf("server: start : tls: Error: %s", err)
 	}
 	as.Unlock()
@@ -167,12 +136,13 @@ func (as *apiServer) Start(ipAddress string, port int64) error {
 	}()
 	as.Lock()
 	locked = true
-	if as.server != nil {
+	if as.httpServer() != nil {
 		return errors.New("Server already running!!")
 	}
-	as.server = &http.Server{
+	timeouts, limits := as.settings()
+	srv := &http.Server{
 		Addr: fmt.Sprintf("%s:%v", ipAddress, port),
-		Handler: as.Router,
+		Handler: as,
 		ConnContext: func(ctx context.Context, c net.Conn) context.Context{
 			ctx = context.WithValue(ctx, ncom.ContextRemoteAddress, c.RemoteAddr())
 			sessionKey, err := uuid.NewV4()
@@ -187,13 +157,15 @@ func (as *apiServer) Start(ipAddress string, port int64) error {
 			return ctx
 		},
 		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
-		ReadTimeout: DEFAULT_READ_TIMEOUT,
-		ReadHeaderTimeout: DEFAULT_HEADER_READ_TIMEOUT,
-		WriteTimeout: DEFAULT_WRITE_TIMEOUT,
-		IdleTimeout: DEFAULT_IDLE_TIMEOUT,
+		ReadTimeout: timeouts.Read,
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		WriteTimeout: timeouts.Write,
+		IdleTimeout: timeouts.Idle,
+		MaxHeaderBytes: limits.MaxHeaderBytes,
 	}
-	err = as.server.ListenAndServe()
-	if err != nil {
+	as.setServer(srv)
+	err = srv.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
 		as.logger.Errorf("server: start : simple: Error: %s", err)
 	}
 	as.Unlock()
@@ -201,179 +173,262 @@ func (as *apiServer) Start(ipAddress string, port int64) error {
 	return err
 }
 func (as *apiServer) Shutdown() error {
-	if as.server == nil {
+	srv := as.httpServer()
+	if srv == nil {
 		return nil
 	}
-	defer func() {
-		as.server = nil
-	}()
-	return as.server.Shutdown(context.Background())
+	as.stopCertificates()
+	defer as.setServer(nil)
+	return srv.Shutdown(context.Background())
 }
 
 func (as *apiServer) Stop() error {
-	if as.server == nil {
+	srv := as.httpServer()
+	if srv == nil {
 		return nil
 	}
-	defer func() {
-		as.server = nil
-	}()
-	return as.server.Close()
+	as.stopCertificates()
+	defer as.setServer(nil)
+	return srv.Close()
+}
+// Running http server, guarded by the settings lock because Start holds the server lock while serving
+func (as *apiServer) httpServer() *http.Server {
+	as.settingsLock.RLock()
+	defer as.settingsLock.RUnlock()
+	return as.server
+}
+func (as *apiServer) setServer(srv *http.Server) {
+	as.settingsLock.Lock()
+	as.server = srv
+	as.settingsLock.Unlock()
+}
+// Stops watching the certificate files of the TLS server
+func (as *apiServer) stopCertificates() {
+	as.settingsLock.Lock()
+	if as.certificates != nil {
+		as.certificates.Stop()
+		as.certificates = nil
+	}
+	as.settingsLock.Unlock()
 }
 func (as *apiServer) IsRunning() bool {
-	return as.server != nil
+	return as.httpServer() != nil
+}
+// Appends global middleware, executed in the given order around every request
+func (as *apiServer) Use(middleware ...ncom.Middleware) {
+	as.settingsLock.Lock()
+	var chain = make([]ncom.Middleware, 0, len(as.middleware)+len(middleware))
+	as.middleware = append(append(chain, as.middleware...), middleware...)
+	as.settingsLock.Unlock()
+}
+
+// Appends middleware executed, after the global ones, around the action or stream of the given path
+func (as *apiServer) UsePath(path string, middleware ...ncom.Middleware) bool {
+	as.settingsLock.Lock()
+	defer as.settingsLock.Unlock()
+	handlerRef, ok := as.Routes[path]
+	if !ok {
+		if as.logger != nil {
+			as.logger.Warnf("api: server: use-path: Unavailable path: %s", path)
+		}
+		return false
+	}
+	var chain = make([]ncom.Middleware, 0, len(handlerRef.Middleware)+len(middleware))
+	handlerRef.Middleware = append(append(chain, handlerRef.Middleware...), middleware...)
+	return true
+}
+
+// Allows one more web method on a registered path, served by the same action or stream
+func (as *apiServer) AllowMethod(path string, method *ncom.RestMethod) bool {
+	if method == nil {
+		return false
+	}
+	as.settingsLock.Lock()
+	defer as.settingsLock.Unlock()
+	if _, ok := as.Routes[path]; !ok {
+		if as.logger != nil {
+			as.logger.Warnf("api: server: allow-method: Unavailable path: %s", path)
+		}
+		return false
+	}
+	as.Router.HandleFunc(path, as.handle).Methods(string(*method))
+	return true
+}
+
+// Sets the http server timeouts, applied on the next server start
+func (as *apiServer) SetTimeouts(timeouts ncom.ServerTimeouts) {
+	as.settingsLock.Lock()
+	as.timeouts = timeouts
+	as.settingsLock.Unlock()
+}
+
+// Sets the http server limits, the body size limit applies immediately
+func (as *apiServer) SetLimits(limits ncom.ServerLimits) {
+	as.settingsLock.Lock()
+	as.limits = limits
+	as.settingsLock.Unlock()
+}
+
+// Sets the base TLS configuration (versions, cipher suites, curves) completed with the
+// certificates on the next TLS server start, nil restores the default configuration
+func (as *apiServer) ConfigureTLS(config *tls.Config) {
+	as.settingsLock.Lock()
+	if config != nil {
+		config = config.Clone()
+	}
+	as.tlsConfig = config
+	as.settingsLock.Unlock()
+}
+
+// Sets the base TLS configuration from the TLS policy, completed with the certificates on the next TLS server start
+func (as *apiServer) SetTLSPolicy(policy rcom.TLSPolicy) error {
+	config, err := policy.Config()
+	if err != nil {
+		return err
+	}
+	as.ConfigureTLS(config)
+	return nil
 }
-func (as *apiServer) handle(w http.ResponseWriter, req *http.Request)(){
-	path := req.URL.Path
-	//method := ncom.RestMethod(req.Method)
-	if handlerStruct, ok := as.Routes[path]; ok {
 
-		var requiredWebMethod string = req.Method
+// Returns the timeouts, replacing the zero values with the package defaults, and the limits
+func (as *apiServer) settings() (ncom.ServerTimeouts, ncom.ServerLimits) {
+	as.settingsLock.RLock()
+	timeouts, limits := as.timeouts, as.limits
+	as.settingsLock.RUnlock()
+	if timeouts.ReadHeader <= 0 {
+		timeouts.ReadHeader = DEFAULT_HEADER_READ_TIMEOUT
+	}
+	if timeouts.Read <= 0 {
+		timeouts.Read = DEFAULT_READ_TIMEOUT
+	}
+	if timeouts.Write <= 0 {
+		timeouts.Write = DEFAULT_WRITE_TIMEOUT
+	}
+	if timeouts.Idle <= 0 {
+		timeouts.Idle = DEFAULT_IDLE_TIMEOUT
+	}
+	return timeouts, limits
+}
+
+// Returns a copy of the configured base TLS configuration, or the default one
+func (as *apiServer) serverTLSConfig() *tls.Config {
+	as.settingsLock.RLock()
+	defer as.settingsLock.RUnlock()
+	if as.tlsConfig != nil {
+		return as.tlsConfig.Clone()
+	}
+	config, _ := rcom.DEFAULT_TLS_POLICY.Config()
+	return config
+}
 
-		as.logger.Debugf("api: server: exec-path: Requested Method: %s", requiredWebMethod)
-		as.logger.Debugf("api: server: exec-path: Available Path %s Handler: %s", path, handlerStruct)
+// Executes the global middleware chain around the router
+func (as *apiServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	as.settingsLock.RLock()
+	middleware := as.middleware
+	maxBodyBytes := as.limits.MaxBodyBytes
+	as.settingsLock.RUnlock()
+	if maxBodyBytes > 0 && req.Body != nil {
+		req.Body = http.MaxBytesReader(w, req.Body, maxBodyBytes)
+	}
+	ncom.Chain(as.Router, middleware...).ServeHTTP(w, ncom.WithPeerIdentity(req))
+}
 
-		//if handlerStruct.Produces == nil || handlerStruct.Produces !=
-		as.logger.Warnf("api: server: exec-path: Calling path: %s, func: %v", path, handlerStruct != nil)
-		if handlerStruct.IsAction() {
-			err := handlerStruct.Action.Run(req, w, requiredWebMethod, *handlerStruct.Consumes, *handlerStruct.Produces)
-			var code int = http.StatusOK
-			var status string = ""
-			var answerToClient bool = true
+// Executes the path middleware chain around the action or stream
+func (as *apiServer) handle(w http.ResponseWriter, req *http.Request) {
+	var middleware []ncom.Middleware
+	as.settingsLock.RLock()
+	if handlerRef, ok := as.Routes[req.URL.Path]; ok {
+		middleware = handlerRef.Middleware
+	}
+	as.settingsLock.RUnlock()
+	ncom.Chain(http.HandlerFunc(as.execute), middleware...).ServeHTTP(w, req)
+}
+
+// Status answer of the actions without internal answer
+type apiStatus struct {
+	Status  string `yaml:"status" json:"status" xml:"status"`
+	Message string `yaml:"message" json:"message" xml:"message"`
+}
+
+// Submits the success status in the negotiated Mime Type, as plain text for the non structured ones
+func (as *apiServer) submitSuccess(w http.ResponseWriter, message string, mimeType ncom.MimeType) {
+	if _, ok := ncom.MimeTypeFormat(mimeType); ok {
+		err := ncom.SubmitData(w, http.StatusOK, apiStatus{Status: "OK", Message: message}, mimeType)
+		if err == nil {
+			return
+		}
+		if as.logger != nil {
+			as.logger.Errorf("api: server: exec-path: Unable to encode status, details: %s", err)
+		}
+	}
+	ncom.SubmitSuccess(w, fmt.Sprintf("status: %s\nmessage: %s", "OK", message))
+}
+
+func (as *apiServer) execute(w http.ResponseWriter, req *http.Request)(){
+	path := req.URL.Path
+	handlerStruct, ok := as.Routes[path]
+	if !ok {
+		notFound(w, req)
+		return
+	}
+	var requiredWebMethod string = req.Method
+	as.logger.Debugf("api: server: exec-path: Requested Method: %s", requiredWebMethod)
+	as.logger.Debugf("api: server: exec-path: Available Path %s Handler: %s", path, handlerStruct)
+	var consumes, produces ncom.MimeType
+	if handlerStruct.Consumes != nil {
+		consumes = *handlerStruct.Consumes
+	}
+	if handlerStruct.Produces != nil {
+		produces = *handlerStruct.Produces
+	}
+	contentType, ok := ncom.SupportsContentType(req.Header.Get("Content-Type"), consumes)
+	if !ok {
+		ncom.SubmitError(w, req, ncom.NewApiError(http.StatusUnsupportedMediaType, fmt.Sprintf("Unsupported content type: %s, available: %s", req.Header.Get("Content-Type"), consumes)), "")
+		return
+	}
+	if "" != produces {
+		available := ncom.ProducibleMimeTypes(produces)
+		if produces, ok = ncom.NegotiateMimeType(req.Header.Get("Accept"), available); !ok {
+			ncom.SubmitError(w, req, ncom.NewApiError(http.StatusNotAcceptable, fmt.Sprintf("Not acceptable: %s, available: %v", req.Header.Get("Accept"), available)), "")
+			return
+		}
+		w.Header().Set("Content-Type", string(produces))
+	}
+	as.logger.Debugf("api: server: exec-path: Calling path: %s, consumes: %s, produces: %s", path, contentType, produces)
+	if handlerStruct.IsAction() {
+		err := handlerStruct.Action.Run(req, w, requiredWebMethod, contentType, produces)
+		if err != nil {
+			as.logger.Errorf("api: server: exec-path: Calling path: %s, details: %s", path, err)
+			ncom.SubmitError(w, req, err, produces)
+		} else if ! handlerStruct.HasAnswer {
+			message := fmt.Sprintf("api: server: exec-path: Calling path: %s, status: %s", path, "OK")
+			as.submitSuccess(w, message, produces)
+		}
+	} else if handlerStruct.IsStream() {
+		if handlerStruct.Stream.CanFetch() {
+			handlerStruct.Stream.Fetch()
+		}
+		streamFormat, okStream := ncom.MimeTypeFormat(consumes)
+		format, okFormat := ncom.MimeTypeFormat(produces)
+		if okStream && okFormat {
+			list := handlerStruct.Stream.ToModel(streamFormat).GetAll()
+			data, err := io.Marshall(list, format)
 			if err != nil {
-				code = http.StatusInternalServerError
-				message:=fmt.Sprintf("api: server: exec-path: Calling path: %s, details: %s", path, err)
-				status = "status: KO\n"+message
-				if string(*handlerStruct.Produces) == string(ncom.XML_MIME_TYPE) {
-					status = "<status>KO</status>\n<message>"+message+"<message>"
-				} else if string(*handlerStruct.Produces) == string(ncom.YAML_MIME_TYPE) {
-					status = "status: KO\nmessage: "+message
-				} else if string(*handlerStruct.Produces) == string(ncom.JSON_MIME_TYPE) {
-					status = "{\n\"status\": \"KO\"\n\"message\": \""+message+"\"\n}"
-				}
-				ncom.SubmitFaiure(w, http.StatusInternalServerError, status)
+				ncom.SubmitError(w, req, ncom.WrapApiError(http.StatusInternalServerError, fmt.Sprintf("Unable to encode stream (%s/%s)", streamFormat, format), err), produces)
 			} else {
-				if ! handlerStruct.HasAnswer {
-					message:=fmt.Sprintf("api: server: exec-path: Calling path: %s, status: %s", path, "OK")
-					status = "status: KO\n"+message
-					if string(*handlerStruct.Produces) == string(ncom.XML_MIME_TYPE) {
-						status = "<status>OK</status>\n<message>"+message+"<message>"
-					} else if string(*handlerStruct.Produces) == string(ncom.YAML_MIME_TYPE) {
-						status = "status: OK\nmessage: "+message
-					} else if string(*handlerStruct.Produces) == string(ncom.JSON_MIME_TYPE) {
-						status = "{\n\"status\": \"OK\"\n\"message\": \""+message+"\"\n}"
-					}
-				} else {
-					answerToClient = false
-				}
-			}
-			if answerToClient {
-				ncom.SubmitFaiure(w, code, status)
+				ncom.SubmitSuccess(w, string(data))
 			}
-		} else if handlerStruct.IsStream() {
-			if handlerStruct.Stream.CanFetch() {
-				handlerStruct.Stream.Fetch()
-			}
-			if string(*handlerStruct.Consumes) == string(ncom.XML_MIME_TYPE) {
-				model := handlerStruct.Stream.ToModel(io.ParserFormatXml)
-				if string(*handlerStruct.Produces) == string(ncom.XML_MIME_TYPE) {
-					list := model.GetAll()
-					data, err := io.Marshall(list, io.ParserFormatXml)
-					if err != nil {
-						ncom.SubmitFaiure(w,http.StatusInternalServerError, fmt.Sprintf("Errors path: %s (XML/XML), Details: %s", path, err))
-					} else {
-						ncom.SubmitSuccess(w, string(data))
-					}
-				} else if string(*handlerStruct.Produces) == string(ncom.YAML_MIME_TYPE) {
-					list := model.GetAll()
-					data, err := io.Marshall(list, io.ParserFormatYaml)
-					if err != nil {
-						ncom.SubmitFaiure(w,http.StatusInternalServerError, fmt.Sprintf("Errors path: %s (XML/YAML), Details: %s", path, err))
-					} else {
-						ncom.SubmitSuccess(w, string(data))
-					}
-				} else if string(*handlerStruct.Produces) == string(ncom.JSON_MIME_TYPE) {
-					list := model.GetAll()
-					data, err := io.Marshall(list, io.ParserFormatJson)
-					if err != nil {
-						ncom.SubmitFaiure(w,http.StatusInternalServerError, fmt.Sprintf("Errors path: %s (XML/JSON), Details: %s", path, err))
-					} else {
-						ncom.SubmitSuccess(w, string(data))
-					}
-				} else {
-					ncom.SubmitFaiure(w,http.StatusInternalServerError, fmt.Sprintf("Errors path: %s, Unknown format: %s", path, string(*handlerStruct.Produces)))
-				}
-			} else if string(*handlerStruct.Consumes) == string(ncom.YAML_MIME_TYPE) {
-				model := handlerStruct.Stream.ToModel(io.ParserFormatYaml)
-				if string(*handlerStruct.Produces) == string(ncom.XML_MIME_TYPE) {
-					list := model.GetAll()
-					data, err := io.Marshall(list, io.ParserFormatXml)
-					if err != nil {
-						ncom.SubmitFaiure(w,http.StatusInternalServerError, fmt.Sprintf("Errors path: %s (YAML/XML), Details: %s", path, err))
-					} else {
-						ncom.SubmitSuccess(w, string(data))
-					}
-				} else if string(*handlerStruct.Produces) == string(ncom.YAML_MIME_TYPE) {
-					list := model.GetAll()
-					data, err := io.Marshall(list, io.ParserFormatYaml)
-					if err != nil {
-						ncom.SubmitFaiure(w,http.StatusInternalServerError, fmt.Sprintf("Errors path: %s (YAML/YAML), Details: %s", path, err))
-					} else {
-						ncom.SubmitSuccess(w, string(data))
-					}
-				} else if string(*handlerStruct.Produces) == string(ncom.JSON_MIME_TYPE) {
-					list := model.GetAll()
-					data, err := io.Marshall(list, io.ParserFormatJson)
-					if err != nil {
-						ncom.SubmitFaiure(w,http.StatusInternalServerError, fmt.Sprintf("Errors path: %s (YAML/JSON), Details: %s", path, err))
-					} else {
-						ncom.SubmitSuccess(w, string(data))
-					}
-				} else {
-					ncom.SubmitFaiure(w,http.StatusInternalServerError, fmt.Sprintf("Errors path: %s, Unknown format: %s", path, string(*handlerStruct.Produces)))
-				}
-			} else if string(*handlerStruct.Consumes) == string(ncom.JSON_MIME_TYPE) {
-				model := handlerStruct.Stream.ToModel(io.ParserFormatJson)
-				if string(*handlerStruct.Produces) == string(ncom.XML_MIME_TYPE) {
-					list := model.GetAll()
-					data, err := io.Marshall(list, io.ParserFormatXml)
-					if err != nil {
-						ncom.SubmitFaiure(w,http.StatusInternalServerError, fmt.Sprintf("Errors path: %s (JSON/XML), Details: %s", path, err))
-					} else {
-						ncom.SubmitSuccess(w, string(data))
-					}
-				} else if string(*handlerStruct.Produces) == string(ncom.YAML_MIME_TYPE) {
-					list := model.GetAll()
-					data, err := io.Marshall(list, io.ParserFormatYaml)
-					if err != nil {
-						ncom.SubmitFaiure(w,http.StatusInternalServerError, fmt.Sprintf("Errors path: %s (JSON/YAML), Details: %s", path, err))
-					} else {
-						ncom.SubmitSuccess(w, string(data))
-					}
-				} else if string(*handlerStruct.Produces) == string(ncom.JSON_MIME_TYPE) {
-					list := model.GetAll()
-					data, err := io.Marshall(list, io.ParserFormatJson)
-					if err != nil {
-						ncom.SubmitFaiure(w,http.StatusInternalServerError, fmt.Sprintf("Errors path: %s (JSON/JSON), Details: %s", path, err))
-					} else {
-						ncom.SubmitSuccess(w, string(data))
-					}
-				} else {
-					ncom.SubmitFaiure(w,http.StatusInternalServerError, fmt.Sprintf("Errors path: %s, Unknown format: %s", path, string(*handlerStruct.Produces)))
-				}
+		} else {
+			buff := bytes.NewBuffer([]byte{})
+			err := handlerStruct.Stream.Output(buff)
+			if err != nil {
+				ncom.SubmitError(w, req, ncom.WrapApiError(http.StatusInternalServerError, "Unable to read stream", err), produces)
 			} else {
-				buff := bytes.NewBuffer([]byte{})
-				err := handlerStruct.Stream.Output(buff)
-				if err != nil {
-					ncom.SubmitFaiure(w,http.StatusInternalServerError, fmt.Sprintf("Errors path: %s, Details: %s", path, err))
-				} else {
-					ncom.SubmitSuccess(w, buff.String())
-				}
+				ncom.SubmitSuccess(w, buff.String())
 			}
-		} else {
-			as.logger.Error("api: server: exec-path: No Action nor Stream available for execution")
 		}
 	} else {
-		ncom.SubmitFaiure(w, http.StatusNotFound, "NOT_FOUND")
+		as.logger.Error("api: server: exec-path: No Action nor Stream available for execution")
 	}
 }
 func (as *apiServer) AddApiAction(path string, action ncom.ApiAction, hasInternalAnswer bool, method *ncom.RestMethod, produces *ncom.MimeType, consumes *ncom.MimeType) bool {
@@ -389,7 +444,7 @@ func (as *apiServer) AddApiAction(path string, action ncom.ApiAction, hasInterna
 			Consumes: consumes,
 		}
 		as.Router.HandleFunc(path, as.handle).Methods(string(*method))
-		ok = true
+		out = true
 	}
 	
 	return out
@@ -406,15 +461,26 @@ func (as *apiServer) AddApiStream(path string, stream streams.DataStream, method
 			Consumes: consumes,
 		}
 		as.Router.HandleFunc(path, as.handle).Methods(string(*method))
-		ok = true
+		out = true
 	}
 	
 	return out
 }
 
+func notFound(w http.ResponseWriter, req *http.Request) {
+	ncom.SubmitError(w, req, ncom.NewApiError(http.StatusNotFound, fmt.Sprintf("Path not found: %s", req.URL.Path)), "")
+}
+
+func methodNotAllowed(w http.ResponseWriter, req *http.Request) {
+	ncom.SubmitError(w, req, ncom.NewApiError(http.StatusMethodNotAllowed, fmt.Sprintf("Method not allowed: %s", req.Method)), "")
+}
+
 func NewApiServer(logger log.Logger) common.ApiServer {
+	router := mux.NewRouter().StrictSlash(true)
+	router.NotFoundHandler = http.HandlerFunc(notFound)
+	router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowed)
 	return &apiServer{
-		Router: mux.NewRouter().StrictSlash(true),
+		Router: router,
 		logger: logger,
 		Routes: make(map[string]*common.HandlerRef),
 	}
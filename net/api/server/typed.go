@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/io"
+	"github.com/hellgate75/go-tcp-common/net/api/common"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Typed Api handler, it receives the decoded request body and returns the answer to encode
+type TypedHandler[In any, Out any] func(ctx context.Context, in In) (Out, error)
+
+// Api Action decoding the request body into In and encoding the Out answer
+type typedAction[In any, Out any] struct {
+	handler TypedHandler[In, Out]
+}
+
+// Run the action, it receives the server arguments: req, w, method, consumes, produces
+func (ta *typedAction[In, Out]) Run(Args ...interface{}) error {
+	if len(Args) < 5 {
+		return errors.New(fmt.Sprintf("TypedAction.Run - Expected 5 arguments but Given: %v", len(Args)))
+	}
+	req, okR := Args[0].(*http.Request)
+	w, okW := Args[1].(http.ResponseWriter)
+	consumes, okC := Args[3].(ncom.MimeType)
+	produces, okP := Args[4].(ncom.MimeType)
+	if !okR || !okW || !okC || !okP {
+		return errors.New(fmt.Sprintf("TypedAction.Run - Invalid arguments types: %T, %T, %T, %T", Args[0], Args[1], Args[3], Args[4]))
+	}
+	in, err := decodeBody[In](req, consumes)
+	if err != nil {
+		return err
+	}
+	out, err := ta.handler(req.Context(), in)
+	if err != nil {
+		return err
+	}
+	return encodeAnswer(w, out, produces)
+}
+
+// Decodes the request body with the io parser of the body Mime Type, or as JSON when the Mime Type is unknown.
+// An empty body is decoded as the zero value
+func decodeBody[In any](req *http.Request, consumes ncom.MimeType) (In, error) {
+	var in In
+	if req.Body == nil {
+		return in, nil
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return in, ncom.WrapApiError(http.StatusBadRequest, "Unable to read request body", err)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return in, nil
+	}
+	if "" == consumes {
+		consumes = ncom.JSON_MIME_TYPE
+	}
+	format, ok := ncom.MimeTypeFormat(consumes)
+	if !ok {
+		return in, ncom.NewApiError(http.StatusUnsupportedMediaType, fmt.Sprintf("Unable to decode request body of type: %s", consumes))
+	}
+	if _, err := io.Unmashall(data, &in, format); err != nil {
+		return in, ncom.WrapApiError(http.StatusBadRequest, "Invalid request body", err)
+	}
+	return in, nil
+}
+
+// Encodes the answer in the negotiated Mime Type, text and bytes answers are sent as they are
+// for the not structured Mime Types. Other answers of actions without produces are encoded as JSON
+func encodeAnswer(w http.ResponseWriter, out interface{}, produces ncom.MimeType) error {
+	if _, ok := ncom.MimeTypeFormat(produces); !ok {
+		switch value := out.(type) {
+		case string:
+			ncom.SubmitSuccess(w, value)
+			return nil
+		case []byte:
+			ncom.SubmitSuccess(w, string(value))
+			return nil
+		}
+		if "" == produces {
+			produces = ncom.JSON_MIME_TYPE
+		}
+	}
+	if err := ncom.SubmitData(w, http.StatusOK, out, produces); err != nil {
+		return ncom.WrapApiError(http.StatusInternalServerError, "Unable to encode answer", err)
+	}
+	return nil
+}
+
+// Adds a typed action to the Api Server: the request body is decoded into In according to the request
+// Content-Type and the handler answer is encoded in the negotiated Mime Type, or as JSON when produces is nil.
+// Handler errors are answered with the ApiError code, or with an internal server error
+func AddTypedAction[In any, Out any](server common.ApiServer, path string, handler TypedHandler[In, Out], method *ncom.RestMethod, produces *ncom.MimeType, consumes *ncom.MimeType) bool {
+	return server.AddApiAction(path, &typedAction[In, Out]{handler: handler}, true, method, produces, consumes)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"github.com/hellgate75/go-tcp-common/log"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type greeting struct {
+	Name string `yaml:"name" json:"name" xml:"name"`
+}
+
+type greetingAnswer struct {
+	Message string `yaml:"message" json:"message" xml:"message"`
+}
+
+func TestTypedAction(t *testing.T) {
+	server := NewApiServer(log.NewLogger("typed-test", log.FATAL))
+	method := ncom.REST_METHOD_POST
+	mimeType := ncom.JSON_MIME_TYPE
+	ok := AddTypedAction(server, "/greet", func(ctx context.Context, in greeting) (greetingAnswer, error) {
+		if "" == in.Name {
+			return greetingAnswer{}, ncom.NewApiError(http.StatusUnprocessableEntity, "missing name")
+		}
+		return greetingAnswer{Message: "Hello " + in.Name}, nil
+	}, &method, &mimeType, &mimeType)
+	if !ok {
+		t.Fatalf("TestTypedAction - server.AddTypedAction - Expected: %v but Given: %v", true, ok)
+	}
+	var cases = []struct {
+		body        string
+		contentType string
+		accept      string
+		status      int
+		answer      string
+	}{
+		{"{\"name\": \"node\"}", "application/json", "", http.StatusOK, "{\"message\":\"Hello node\"}"},
+		{"{\"name\": \"node\"}", "application/json", "text/yaml", http.StatusOK, "message: Hello node"},
+		{"", "", "", http.StatusUnprocessableEntity, "missing name"},
+		{"{\"name\": ", "application/json", "", http.StatusBadRequest, "Invalid request body"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/greet", strings.NewReader(c.body))
+		if "" != c.contentType {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		if "" != c.accept {
+			req.Header.Set("Accept", c.accept)
+		}
+		recorder := httptest.NewRecorder()
+		server.(http.Handler).ServeHTTP(recorder, req)
+		if recorder.Code != c.status || !strings.Contains(recorder.Body.String(), c.answer) {
+			t.Fatalf("TestTypedAction - apiServer.ServeHTTP(%q) - Expected: %v %q but Given: %v %q", c.body, c.status, c.answer, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	AddTypedAction(server, "/any", func(ctx context.Context, in greeting) (greetingAnswer, error) {
+		return greetingAnswer{Message: "Hello " + in.Name}, nil
+	}, &method, nil, nil)
+	recorder := httptest.NewRecorder()
+	server.(http.Handler).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/any", strings.NewReader("{\"name\": \"node\"}")))
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != string(ncom.JSON_MIME_TYPE) || strings.TrimSpace(recorder.Body.String()) != "{\"message\":\"Hello node\"}" {
+		t.Fatalf("TestTypedAction - apiServer.ServeHTTP - Expected: %v but Given: %v %v %q", "json answer", recorder.Code, recorder.Header(), recorder.Body.String())
+	}
+
+	if description := server.(*apiServer).Routes["/any"].String(); !strings.Contains(description, "Produces: ,") {
+		t.Fatalf("TestTypedAction - HandlerRef.String - Expected: %v but Given: %v", "empty produces", description)
+	}
+
+	recorder = httptest.NewRecorder()
+	server.(http.Handler).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if recorder.Code != http.StatusNotFound || recorder.Header().Get("Content-Type") != string(ncom.PROBLEM_JSON_MIME_TYPE) {
+		t.Fatalf("TestTypedAction - apiServer.ServeHTTP - Expected: %v but Given: %v %v", http.StatusNotFound, recorder.Code, recorder.Header())
+	}
+}
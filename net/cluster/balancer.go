@@ -0,0 +1,358 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/cluster/discovery"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	rcom "github.com/hellgate75/go-tcp-common/net/rest/common"
+	"hash/crc32"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// Default time a node failing a request or a ping is skipped by the balancing client
+	DEFAULT_UNREACHABLE_TIMEOUT time.Duration = 30 * time.Second
+	// Number of points of each node on the consistent hash ring
+	CONSISTENT_HASH_REPLICAS int = 64
+)
+
+func (bs BalancingStrategy) String() string {
+	switch bs {
+	case BALANCING_ROUND_ROBIN:
+		return "Round Robin"
+	case BALANCING_LEAST_LATENCY:
+		return "Least Latency"
+	case BALANCING_CONSISTENT_HASH:
+		return "Consistent Hash"
+	default:
+		return "Unknown"
+	}
+}
+
+type balancingClient struct {
+	sync.Mutex
+	registry    ClusterRegistry
+	config      BalancerConfig
+	client      *http.Client
+	next        uint64
+	latency     map[string]time.Duration
+	unreachable map[string]time.Time
+	stop        chan struct{}
+	logger      log.Logger
+}
+
+func (bc *balancingClient) Targets(key string) []types.Node {
+	var nodes = bc.query()
+	bc.Lock()
+	defer bc.Unlock()
+	now := time.Now()
+	var out = make([]types.Node, 0)
+	for _, node := range nodes {
+		if until, ok := bc.unreachable[node.Name]; ok && now.Before(until) {
+			continue
+		}
+		out = append(out, node)
+	}
+	if len(out) == 0 {
+		return out
+	}
+	switch bc.config.Strategy {
+	case BALANCING_LEAST_LATENCY:
+		sort.SliceStable(out, func(i, j int) bool {
+			latencyI, knownI := bc.latency[out[i].Name]
+			latencyJ, knownJ := bc.latency[out[j].Name]
+			if knownI != knownJ {
+				return knownI
+			}
+			return latencyI < latencyJ
+		})
+	case BALANCING_CONSISTENT_HASH:
+		out = consistentHashOrder(out, key)
+	default:
+		sort.SliceStable(out, func(i, j int) bool {
+			return out[i].Name < out[j].Name
+		})
+		start := int(bc.next % uint64(len(out)))
+		bc.next++
+		out = append(out[start:], out[:start]...)
+	}
+	return out
+}
+
+func (bc *balancingClient) Pick(key string) (*types.Node, error) {
+	targets := bc.Targets(key)
+	if len(targets) == 0 {
+		return nil, errors.New(fmt.Sprintf("BalancingClient.Pick - No reachable node matches the query: %+v", bc.config.Query))
+	}
+	return &targets[0], nil
+}
+
+func (bc *balancingClient) NewRequest(key string, path string) rcom.Request {
+	var protocol = ncom.REST_PROTOCOL_HTTP
+	if bc.config.TLSConfig != nil {
+		protocol = ncom.REST_PROTOCOL_HTTPS
+	}
+	return rcom.NewRequest(&balancedDoer{client: bc, key: key}, "balanced", path).Protocol(protocol)
+}
+
+func (bc *balancingClient) Refresh(ctx context.Context) error {
+	nodes := bc.query()
+	var requests = make([]types.NodeRequest, 0)
+	for _, node := range nodes {
+		requests = append(requests, types.NodeRequest{IpAddress: node.IpAddress, Ports: []int32{node.Port}})
+	}
+	answers, err := discovery.PingNodesListContext(ctx, requests, bc.config.Timeout, "tcp", bc.config.TLSConfig)
+	if err != nil {
+		return errors.New(fmt.Sprintf("BalancingClient.Refresh - Error: %s", err))
+	}
+	var answered = make(map[string]time.Duration)
+	for _, answer := range answers {
+		answered[discovery.NodeAddress(answer.IpAddress, answer.Port)] = answer.Answer
+	}
+	for _, node := range nodes {
+		if latency, ok := answered[discovery.NodeAddress(node.IpAddress, node.Port)]; ok {
+			bc.Lock()
+			bc.latency[node.Name] = latency
+			delete(bc.unreachable, node.Name)
+			bc.Unlock()
+		} else {
+			bc.markUnreachable(node, errors.New("ping not answered"))
+		}
+	}
+	return nil
+}
+
+func (bc *balancingClient) Close() {
+	bc.Lock()
+	if bc.stop != nil {
+		close(bc.stop)
+		bc.stop = nil
+	}
+	bc.Unlock()
+	bc.client.CloseIdleConnections()
+}
+
+// Refreshes the targets state every refresh interval, until stop is closed
+func (bc *balancingClient) refreshLoop(stop chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	ticker := time.NewTicker(bc.config.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := bc.Refresh(ctx); err != nil && ctx.Err() == nil && bc.logger != nil {
+				bc.logger.Warnf("BalancingClient - Refresh failed, Details: %s", err)
+			}
+		}
+	}
+}
+
+// Returns the active, not unreachable, registry nodes matching the query
+func (bc *balancingClient) query() []types.Node {
+	var nodes []types.Node
+	if "" != bc.config.Query.Command {
+		recovered, err := bc.registry.Recover("services.commands.Name", NodeNameFilter(bc.config.Query.Command))
+		if err != nil {
+			if bc.logger != nil {
+				bc.logger.Errorf("BalancingClient - Unable to query the registry, Details: %s", err)
+			}
+			return []types.Node{}
+		}
+		for _, node := range recovered {
+			nodes = append(nodes, *node)
+		}
+	} else {
+		nodes = append(nodes, bc.registry.List()...)
+	}
+	var out = make([]types.Node, 0)
+	for _, node := range nodes {
+		if !node.Active || node.State == types.NODE_STATE_UNRACJABLE {
+			continue
+		}
+		if len(bc.config.Query.Roles) > 0 && !containsRole(bc.config.Query.Roles, node.Role) {
+			continue
+		}
+		if len(bc.config.Query.States) > 0 && !containsState(bc.config.Query.States, node.State) {
+			continue
+		}
+		out = append(out, node)
+	}
+	return out
+}
+
+func (bc *balancingClient) markUnreachable(node types.Node, err error) {
+	bc.Lock()
+	bc.unreachable[node.Name] = time.Now().Add(bc.config.UnreachableTimeout)
+	bc.Unlock()
+	if bc.logger != nil {
+		bc.logger.Warnf("BalancingClient - Node %s unreachable, skipped for %s, Details: %s", node.Name, bc.config.UnreachableTimeout, err)
+	}
+}
+
+// Request executor sending the requests to the targets of a key, in the strategy order
+type balancedDoer struct {
+	client *balancingClient
+	key    string
+}
+
+func (bd *balancedDoer) Do(req *http.Request) (*http.Response, error) {
+	targets := bd.client.Targets(bd.key)
+	if len(targets) == 0 {
+		return nil, errors.New(fmt.Sprintf("BalancingClient.Do - No reachable node matches the query: %+v", bd.client.config.Query))
+	}
+	var err error
+	for idx, node := range targets {
+		outgoing := req.Clone(req.Context())
+		if idx > 0 && req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				break
+			}
+			body, errB := req.GetBody()
+			if errB != nil {
+				break
+			}
+			outgoing.Body = body
+		}
+		outgoing.URL.Host = discovery.NodeAddress(node.IpAddress, node.Port)
+		outgoing.Host = ""
+		var resp *http.Response
+		resp, err = bd.client.client.Do(outgoing)
+		if err == nil {
+			return resp, nil
+		}
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+		bd.client.markUnreachable(node, err)
+		if !bd.failover(req, err) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// Checks a failed request can be sent to the next target: requests never sent (dial error or open circuit) always
+// can, the sent ones only when the method is retried by the resilience policy, as it could be already executed
+func (bd *balancedDoer) failover(req *http.Request, err error) bool {
+	var opErr *net.OpError
+	if errors.Is(err, rcom.ErrCircuitOpen) || (errors.As(err, &opErr) && "dial" == opErr.Op) {
+		return true
+	}
+	var methods = rcom.DEFAULT_RETRY_METHODS
+	if retry := bd.client.config.Resilience.Retry; retry != nil && retry.Methods != nil {
+		methods = retry.Methods
+	}
+	for _, method := range methods {
+		if string(method) == req.Method || (ncom.REST_METHOD_POST_FORM == method && http.MethodPost == req.Method) {
+			return true
+		}
+	}
+	return false
+}
+
+type hashPoint struct {
+	hash uint32
+	node int
+}
+
+// Orders the nodes as they are met on the hash ring, starting from the key position
+func consistentHashOrder(nodes []types.Node, key string) []types.Node {
+	var ring = make([]hashPoint, 0, len(nodes)*CONSISTENT_HASH_REPLICAS)
+	for idx, node := range nodes {
+		for replica := 0; replica < CONSISTENT_HASH_REPLICAS; replica++ {
+			ring = append(ring, hashPoint{hash: crc32.ChecksumIEEE([]byte(node.Name + "#" + strconv.Itoa(replica))), node: idx})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+	hash := crc32.ChecksumIEEE([]byte(key))
+	start := sort.Search(len(ring), func(i int) bool {
+		return ring[i].hash >= hash
+	})
+	var out = make([]types.Node, 0, len(nodes))
+	var seen = make(map[int]bool)
+	for i := 0; i < len(ring) && len(out) < len(nodes); i++ {
+		point := ring[(start+i)%len(ring)]
+		if !seen[point.node] {
+			seen[point.node] = true
+			out = append(out, nodes[point.node])
+		}
+	}
+	return out
+}
+
+func containsRole(roles []types.NodeType, role types.NodeType) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func containsState(states []types.NodeState, state types.NodeState) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// Creates a client balancing the requests across the registry nodes matching the configuration query,
+// zero configuration values are replaced by the package defaults
+func NewBalancingClient(registry ClusterRegistry, config BalancerConfig, logger log.Logger) (BalancingClient, error) {
+	if registry == nil {
+		return nil, errors.New("cluster.NewBalancingClient - Nil registry reference")
+	}
+	if config.Strategy == 0 {
+		config.Strategy = BALANCING_ROUND_ROBIN
+	}
+	if config.Strategy > BALANCING_CONSISTENT_HASH {
+		return nil, errors.New(fmt.Sprintf("cluster.NewBalancingClient - Unknown balancing strategy: %v", config.Strategy))
+	}
+	if err := config.Resilience.Validate(); err != nil {
+		return nil, errors.New(fmt.Sprintf("cluster.NewBalancingClient - Invalid resilience policy, Details: %s", err))
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DEFAULT_NODE_TIMEOUT
+	}
+	if config.UnreachableTimeout <= 0 {
+		config.UnreachableTimeout = DEFAULT_UNREACHABLE_TIMEOUT
+	}
+	var bc = &balancingClient{
+		registry: registry,
+		config:   config,
+		client: &http.Client{
+			Transport: rcom.NewResilientTransport(&http.Transport{
+				TLSClientConfig: config.TLSConfig,
+			}, config.Resilience),
+			Timeout: config.Timeout,
+		},
+		latency:     make(map[string]time.Duration),
+		unreachable: make(map[string]time.Time),
+		logger:      logger,
+	}
+	if config.RefreshInterval > 0 {
+		bc.stop = make(chan struct{})
+		go bc.refreshLoop(bc.stop)
+	}
+	return bc, nil
+}
@@ -0,0 +1,143 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func balancedServer(name string, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		if r.URL.Path == "/ping" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(fmt.Sprintf(`{"role":%d,"state":%d,"active":true}`, types.ROLE_SLAVE, types.NODE_STATE_RUNNING)))
+			return
+		}
+		w.Write([]byte(name))
+	}))
+}
+
+func balancedNode(t *testing.T, name string, address string, command string) *types.Node {
+	ip, port := hostPort(t, address)
+	return &types.Node{Name: name, IpAddress: ip, Port: port, Role: types.ROLE_SLAVE, Active: true,
+		State: types.NODE_STATE_RUNNING, Services: []types.Service{
+			{Port: types.Port{Port: port}, Commands: []types.Command{{Name: command}}},
+		}}
+}
+
+func TestBalancingClientStrategies(t *testing.T) {
+	fast := balancedServer("fast", 0)
+	defer fast.Close()
+	slow := balancedServer("slow", 20*time.Millisecond)
+	defer slow.Close()
+	registry := NewInMemoryClusterRegistry()
+	registry.Register(balancedNode(t, "fast", fast.Listener.Addr().String(), "status"))
+	registry.Register(balancedNode(t, "slow", slow.Listener.Addr().String(), "status"))
+	registry.Register(&types.Node{Name: "master", IpAddress: "127.0.0.1", Port: 1, Role: types.ROLE_MASTER, Active: true})
+	registry.Register(&types.Node{Name: "inactive", IpAddress: "127.0.0.1", Port: 1, Role: types.ROLE_SLAVE})
+
+	if _, err := NewBalancingClient(registry, BalancerConfig{Strategy: BalancingStrategy(99)}, nil); err == nil {
+		t.Fatalf("TestBalancingClientStrategies - cluster.NewBalancingClient - Expected: %v but Given: %v", "error", err)
+	}
+
+	roundRobin, _ := NewBalancingClient(registry, BalancerConfig{Query: NodeQuery{Roles: []types.NodeType{types.ROLE_SLAVE}}}, nil)
+	defer roundRobin.Close()
+	var picked = make([]string, 0)
+	for i := 0; i < 4; i++ {
+		response, err := roundRobin.NewRequest("", "/").Do()
+		if err != nil {
+			t.Fatalf("TestBalancingClientStrategies - BalancingClient.NewRequest - Expected: %v but Given: %v", nil, err)
+		}
+		picked = append(picked, string(response.Body))
+	}
+	if expected := "[fast slow fast slow]"; fmt.Sprint(picked) != expected {
+		t.Fatalf("TestBalancingClientStrategies - Round Robin - Expected: %v but Given: %v", expected, picked)
+	}
+
+	leastLatency, _ := NewBalancingClient(registry, BalancerConfig{Query: NodeQuery{Command: "status"}, Strategy: BALANCING_LEAST_LATENCY}, nil)
+	defer leastLatency.Close()
+	if err := leastLatency.Refresh(context.Background()); err != nil {
+		t.Fatalf("TestBalancingClientStrategies - BalancingClient.Refresh - Expected: %v but Given: %v", nil, err)
+	}
+	for i := 0; i < 3; i++ {
+		if node, err := leastLatency.Pick(""); err != nil || node.Name != "fast" {
+			t.Fatalf("TestBalancingClientStrategies - Least Latency - Expected: %v but Given: %v (%v)", "fast", node, err)
+		}
+	}
+
+	consistentHash, _ := NewBalancingClient(registry, BalancerConfig{Query: NodeQuery{Command: "status"}, Strategy: BALANCING_CONSISTENT_HASH}, nil)
+	defer consistentHash.Close()
+	var owners = make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		first, _ := consistentHash.Pick(key)
+		second, _ := consistentHash.Pick(key)
+		if first == nil || second == nil || first.Name != second.Name {
+			t.Fatalf("TestBalancingClientStrategies - Consistent Hash - Expected: %v but Given: %v %v", "same node", first, second)
+		}
+		owners[first.Name] = true
+	}
+	if len(owners) != 2 {
+		t.Fatalf("TestBalancingClientStrategies - Consistent Hash - Expected: %v but Given: %v", 2, owners)
+	}
+
+	none, _ := NewBalancingClient(registry, BalancerConfig{Query: NodeQuery{Command: "missing"}}, nil)
+	defer none.Close()
+	if _, err := none.Pick(""); err == nil {
+		t.Fatalf("TestBalancingClientStrategies - BalancingClient.Pick - Expected: %v but Given: %v", "error", err)
+	}
+}
+
+func TestBalancingClientFailover(t *testing.T) {
+	alive := balancedServer("alive", 0)
+	defer alive.Close()
+	dead := balancedServer("dead", 0)
+	deadAddress := dead.Listener.Addr().String()
+	dead.Close()
+	registry := NewInMemoryClusterRegistry()
+	registry.Register(balancedNode(t, "alive", alive.Listener.Addr().String(), "status"))
+	registry.Register(balancedNode(t, "dead", deadAddress, "status"))
+
+	client, _ := NewBalancingClient(registry, BalancerConfig{Timeout: time.Second, UnreachableTimeout: time.Hour}, nil)
+	defer client.Close()
+	for _, method := range []string{"PUT", "POST", "GET"} {
+		response, err := client.NewRequest("", "/").Method(ncom.RestMethod(method)).Body([]byte("{}")).Do()
+		if err != nil || string(response.Body) != "alive" {
+			t.Fatalf("TestBalancingClientFailover - BalancingClient.NewRequest - Expected: %v but Given: %+v (%v)", "alive", response, err)
+		}
+	}
+	if targets := client.Targets(""); len(targets) != 1 || targets[0].Name != "alive" {
+		t.Fatalf("TestBalancingClientFailover - BalancingClient.Targets - Expected: %v but Given: %v", "[alive]", targets)
+	}
+
+	var posted int32 = 0
+	counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posted, 1)
+		w.Write([]byte("counting"))
+	}))
+	defer counting.Close()
+	stuck := balancedServer("stuck", 500*time.Millisecond)
+	defer stuck.Close()
+	timeouts := NewInMemoryClusterRegistry()
+	timeouts.Register(balancedNode(t, "a-stuck", stuck.Listener.Addr().String(), "status"))
+	timeouts.Register(balancedNode(t, "b-counting", counting.Listener.Addr().String(), "status"))
+	timed, _ := NewBalancingClient(timeouts, BalancerConfig{Timeout: 100 * time.Millisecond}, nil)
+	defer timed.Close()
+	if _, err := timed.NewRequest("", "/").Method(ncom.REST_METHOD_POST).Body([]byte("{}")).Do(); err == nil || atomic.LoadInt32(&posted) != 0 {
+		t.Fatalf("TestBalancingClientFailover - BalancingClient.NewRequest - Expected: %v but Given: %v (%v)", "timeout without failover", atomic.LoadInt32(&posted), err)
+	}
+
+	refreshed, _ := NewBalancingClient(registry, BalancerConfig{Timeout: time.Second, RefreshInterval: 10 * time.Millisecond}, nil)
+	defer refreshed.Close()
+	time.Sleep(100 * time.Millisecond)
+	if targets := refreshed.Targets(""); len(targets) != 1 || targets[0].Name != "alive" {
+		t.Fatalf("TestBalancingClientFailover - BalancingClient.Refresh - Expected: %v but Given: %v", "[alive]", targets)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	cio "github.com/hellgate75/go-tcp-common/io"
 	"github.com/hellgate75/go-tcp-common/net/cluster/types"
-	"io"
 	"io/ioutil"
 	"os"
 	"reflect"
@@ -127,14 +126,14 @@ func (nc *nodeCache) Register(n *types.Node) error {
 			nc.Unlock()
 		}
 	}()
+	nc.Lock()
+	locked = true
 	nc.Nodes = append(nc.Nodes, *n)
 	if nc.IsPersistenceEnabled() {
-		nc.Lock()
-		locked = true
 		err = nc.save()
-		nc.Unlock()
-		locked = false
 	}
+	nc.Unlock()
+	locked = false
 	if err != nil {
 		return errors.New(fmt.Sprintf("DiscoverReporter.Register - Error: %s", err))
 	}
@@ -151,22 +150,19 @@ func (nc *nodeCache) Update(field string, filter regexp.Regexp, n types.Node) er
 			nc.Unlock()
 		}
 	}()
-	nodes, err := nc.Recover(field, filter)
-	if err != nil {
-		return nil
-	}
-	if len(nodes) > 0 {
+	nc.Lock()
+	locked = true
+	nodes, err := nc.match(field, filter)
+	if err == nil && len(nodes) > 0 {
 		for _, node := range nodes {
 			node.Update(&n)
 		}
 		if nc.IsPersistenceEnabled() {
-			nc.Lock()
-			locked = true
 			err = nc.save()
-			nc.Unlock()
-			locked = false
 		}
 	}
+	nc.Unlock()
+	locked = false
 	if err != nil {
 		return errors.New(fmt.Sprintf("DiscoverReporter.Update - Error: %s", err))
 	}
@@ -184,34 +180,50 @@ func (nc *nodeCache) Recover(field string, filter regexp.Regexp) ([]*types.Node,
 			nc.Unlock()
 		}
 	}()
+	nc.Lock()
+	locked = true
+	nodes, err := nc.match(field, filter)
+	// Copies are returned, so the callers never read nodes changed by concurrent updates
+	for _, node := range nodes {
+		copied := *node
+		out = append(out, &copied)
+	}
+	nc.Unlock()
+	locked = false
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("DiscoverReporter.Recover - Error: %s", err))
+	}
+	return out, err
+}
+
+// Returns the registry nodes whose field matches the filter, loading the registry file when persistence
+// is enabled. The lock must be held by the caller
+func (nc *nodeCache) match(field string, filter regexp.Regexp) ([]*types.Node, error) {
+	var out []*types.Node = make([]*types.Node, 0)
 	if nc.IsPersistenceEnabled() {
-		nc.Lock()
-		locked  = true
-		err = nc.load()
-		nc.Unlock()
-		locked  = false
-		if err != nil {
+		if err := nc.load(); err != nil {
 			return out, err
 		}
 	}
 	if strings.Index(field, ".") > 0{
 		if len(field) > 9 && strings.ToLower(field)[0:9] == "services." {
-			sfield := field[10:]
-			for _, node := range nc.Nodes {
+			sfield := field[9:]
+			for idx := range nc.Nodes {
+				node := &nc.Nodes[idx]
 				NodeLoop:
 				for _, service := range node.Services {
-					if len(sfield) > 5 && strings.ToLower(field)[0:5] == "port." {
-						ssfield := sfield[6:]
+					if len(sfield) > 5 && strings.ToLower(sfield)[0:5] == "port." {
+						ssfield := sfield[5:]
 						if matchInInterface(&service.Port, ssfield, filter) {
-							out = append(out, &node)
+							out = append(out, node)
 							break NodeLoop
 						}
 
-					} else if len(sfield) > 9 && strings.ToLower(field)[0:9] == "commands." {
-						ssfield := sfield[10:]
+					} else if len(sfield) > 9 && strings.ToLower(sfield)[0:9] == "commands." {
+						ssfield := sfield[9:]
 						for _, command := range service.Commands {
 							if matchInInterface(&command, ssfield, filter) {
-								out = append(out, &node)
+								out = append(out, node)
 								break NodeLoop
 							}
 						}
@@ -219,45 +231,95 @@ func (nc *nodeCache) Recover(field string, filter regexp.Regexp) ([]*types.Node,
 				}
 			}
 		} else if len(field) > 6 && strings.ToLower(field)[0:6] == "ports." {
-			sfield := field[7:]
-			for _, node := range nc.Nodes {
+			sfield := field[6:]
+			for idx := range nc.Nodes {
+				node := &nc.Nodes[idx]
 				NodeLoop2:
 				for _, port := range node.Ports {
 					if matchInInterface(&port, sfield, filter) {
-						out = append(out, &node)
+						out = append(out, node)
 						break NodeLoop2
 					}
 				}
 			}
 		} else  if len(field) > 5  && strings.ToLower(field)[0:5] == "info." {
-			sfield := field[6:]
-			for _, node := range nc.Nodes {
+			sfield := field[5:]
+			for idx := range nc.Nodes {
+				node := &nc.Nodes[idx]
 				if matchInInterface(node.Info, sfield, filter) {
-					out = append(out, &node)
+					out = append(out, node)
 				}
 			}
 		}else {
 			return out, errors.New(fmt.Sprintf("Field doesn't start with 'nodes': <%s>", field))
 		}
 	} else {
-		for _, node := range nc.Nodes {
-			if matchInInterface(&node, field, filter) {
-					out = append(out, &node)
+		for idx := range nc.Nodes {
+			node := &nc.Nodes[idx]
+			if matchInInterface(node, field, filter) {
+					out = append(out, node)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (nc *nodeCache) Remove(field string, filter regexp.Regexp) error {
+	var err error
+	var locked bool = false
+	defer func(){
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprintf("DiscoverReporter.Remove - Error: %v", r))
+		}
+		if locked {
+			nc.Unlock()
+		}
+	}()
+	nc.Lock()
+	locked = true
+	nodes, err := nc.match(field, filter)
+	if err == nil && len(nodes) > 0 {
+		var removed = make(map[*types.Node]bool)
+		for _, node := range nodes {
+			removed[node] = true
+		}
+		var kept = make([]types.Node, 0)
+		for idx := range nc.Nodes {
+			if ! removed[&nc.Nodes[idx]] {
+				kept = append(kept, nc.Nodes[idx])
 			}
 		}
+		nc.Nodes = kept
+		if nc.IsPersistenceEnabled() {
+			err = nc.save()
+		}
 	}
+	nc.Unlock()
+	locked = false
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("DiscoverReporter.Recover - Error: %s", err))
+		return errors.New(fmt.Sprintf("DiscoverReporter.Remove - Error: %s", err))
 	}
-	return out, err
+	return err
+}
+
+// Regular expression matching exactly the given node name
+func NodeNameFilter(name string) regexp.Regexp {
+	return *regexp.MustCompile("^" + regexp.QuoteMeta(name) + "$")
 }
 
 func matchInInterface(itf interface{}, field string, filter regexp.Regexp) bool {
 	var out bool = false
-	if value := fieldValue(itf, field); value.IsValid() && ! value.IsNil() {
-		return filter.Match([]byte(value.String()))
+	value := fieldValue(itf, field)
+	if ! value.IsValid() {
+		return out
+	}
+	if value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return out
+		}
+		value = value.Elem()
 	}
-	return out
+	return filter.MatchString(fmt.Sprintf("%v", value.Interface()))
 }
 
 func fieldValue(v interface{}, field string) reflect.Value {
@@ -272,7 +334,7 @@ func (nc *nodeCache) List() []types.Node {
 		nc.Unlock()
 	}()
 	nc.Lock()
-	return nc.Nodes
+	return append(make([]types.Node, 0, len(nc.Nodes)), nc.Nodes...)
 }
 
 
@@ -288,9 +350,7 @@ func (nc *nodeCache) load() error {
 	}
 	file, errF := os.Open(nc.FilePath)
 	if errF != nil{
-		if err != nil {
-			return errors.New(fmt.Sprintf("DiscoverReporter.load - Error: %s", err))
-		}
+		return errors.New(fmt.Sprintf("DiscoverReporter.load - Error: %s", errF))
 	}
 	defer func(){
 		if r := recover(); r != nil {
@@ -306,18 +366,17 @@ func (nc *nodeCache) load() error {
 		}
 	}
 	decoder := base64.NewDecoder(base64.StdEncoding, bytes.NewReader(encoded))
-	dbuf := make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
-	count, err := decoder.Read(dbuf)
-	if err != nil && err != io.EOF {
+	dbuf, err := ioutil.ReadAll(decoder)
+	if err != nil {
 		return errors.New(fmt.Sprintf("DiscoverReporter.load - Decoder Read failed: %s", err))
 	}
-	if count > 0 {
+	if len(dbuf) > 0 {
 		var out []types.Node = make([]types.Node, 0)
-		itf, err := cio.Unmashall(dbuf, out, nc.Encoding)
+		_, err := cio.Unmashall(dbuf, &out, nc.Encoding)
 		if err != nil{
 			return errors.New(fmt.Sprintf("DiscoverReporter.load - (Decoding Issues) Error: %s", err))
 		}
-		nc.Nodes = itf.([]types.Node)
+		nc.Nodes = out
 	} else {
 		nc.Nodes = make([]types.Node, 0)
 	}
@@ -351,6 +410,8 @@ func (nc *nodeCache) save() error {
 	if err != nil {
 		return errors.New(fmt.Sprintf("DiscoverReporter.save - Encoder Write failed: %s", err))
 	}
+	// Flush the last partial block before the encoded data is written
+	encoder.Close()
 	if exists {
 		cio.DeleteOrTruncateFile(nc.FilePath)
 	}
@@ -0,0 +1,55 @@
+package cluster
+
+import (
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	"sync"
+	"testing"
+)
+
+func TestClusterRegistryConcurrency(t *testing.T) {
+	registry := NewInMemoryClusterRegistry()
+	var group sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		group.Add(1)
+		go func(worker int) {
+			defer group.Done()
+			for j := 0; j < 50; j++ {
+				name := fmt.Sprintf("node-%v-%v", worker, j)
+				if err := registry.Register(&types.Node{Name: name}); err != nil {
+					t.Errorf("TestClusterRegistryConcurrency - ClusterRegistry.Register - Expected: %v but Given: %v", nil, err)
+					return
+				}
+				if err := registry.Update("Name", NodeNameFilter(name), types.Node{Name: name, Active: true}); err != nil {
+					t.Errorf("TestClusterRegistryConcurrency - ClusterRegistry.Update - Expected: %v but Given: %v", nil, err)
+					return
+				}
+				if j%2 == 0 {
+					if err := registry.Remove("Name", NodeNameFilter(name)); err != nil {
+						t.Errorf("TestClusterRegistryConcurrency - ClusterRegistry.Remove - Expected: %v but Given: %v", nil, err)
+						return
+					}
+				}
+				registry.List()
+			}
+		}(i)
+	}
+	group.Wait()
+	nodes := registry.List()
+	if len(nodes) != 8*25 {
+		t.Fatalf("TestClusterRegistryConcurrency - ClusterRegistry.List - Expected: %v but Given: %v", 8*25, len(nodes))
+	}
+	for _, node := range nodes {
+		if !node.Active {
+			t.Fatalf("TestClusterRegistryConcurrency - ClusterRegistry.Update - Expected: %v but Given: %+v", "active node", node)
+		}
+	}
+	recovered, err := registry.Recover("Name", NodeNameFilter(nodes[0].Name))
+	if err != nil || len(recovered) != 1 {
+		t.Fatalf("TestClusterRegistryConcurrency - ClusterRegistry.Recover - Expected: %v but Given: %v (%v)", 1, len(recovered), err)
+	}
+	recovered[0].Active = false
+	if again, _ := registry.Recover("Name", NodeNameFilter(nodes[0].Name)); len(again) != 1 || !again[0].Active {
+		t.Fatalf("TestClusterRegistryConcurrency - ClusterRegistry.Recover - Expected: %v but Given: %+v", "registry copy unchanged", again)
+	}
+}
@@ -1,17 +1,634 @@
 package cluster
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	cio "github.com/hellgate75/go-tcp-common/io"
 	"github.com/hellgate75/go-tcp-common/log"
 	"github.com/hellgate75/go-tcp-common/net/api/common"
+	"github.com/hellgate75/go-tcp-common/net/api/server"
+	"github.com/hellgate75/go-tcp-common/net/cluster/discovery"
+	"github.com/hellgate75/go-tcp-common/net/cluster/plugins"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	neturl "net/url"
 	"sync"
+	"time"
 )
 
+var (
+	// Default timeout used to contact remote cluster nodes
+	DEFAULT_NODE_TIMEOUT time.Duration = 5 * time.Second
+	// Prefix of the default path used to expose cluster commands
+	DEFAULT_COMMAND_PATH_PREFIX string = "/commands/"
+)
+
+type nodeCommand struct {
+	Path    string
+	Command types.Command
+	Plugin  bool
+}
+
 type clusterNode struct {
-	sync.Mutex
+	sync.Mutex							`yaml:"-" json:"-" xml:"-"`
+	Name				string				`yaml:"name,omitempty" json:"name,omitempty" xml:"name,omitempty"`
+	IpAddress			string				`yaml:"ipAddress,omitempty" json:"ipAddress,omitempty" xml:"ip-address,omitempty"`
+	Port				int32				`yaml:"port,omitempty" json:"port,omitempty" xml:"port,omitempty"`
+	Role				types.NodeType		`yaml:"role,omitempty" json:"role,omitempty" xml:"role,omitempty"`
+	Format				cio.ParserFormat	`yaml:"format,omitempty" json:"format,omitempty" xml:"format,omitempty"`
+	Timeout				time.Duration		`yaml:"timeout,omitempty" json:"timeout,omitempty" xml:"timeout,omitempty"`
 	PluginsFolder		string				`yaml:"pluginsFolder,omitempty" json:"pluginsFolder,omitempty" xml:"plugins-folder,omitempty"`
 	PluginsExtension	string				`yaml:"pluginsExtension,omitempty" json:"pluginsExtension,omitempty" xml:"plugins-extension,omitempty"`
 	PluginsEnabled		bool				`yaml:"pluginsEnabled,omitempty" json:"pluginsEnabled,omitempty" xml:"plugins-enabled,omitempty"`
-	_apiServer			common.ApiServer	`yaml:"-,omitempty" json:"-,omitempty" xml:"-,omitempty"`
-	_logger				log.Logger			`yaml:"-,omitempty" json:"-,omitempty" xml:"-,omitempty"`
+	RegistryFile		string				`yaml:"registryFile,omitempty" json:"registryFile,omitempty" xml:"registry-file,omitempty"`
+	_state				types.NodeState
+	_commands			[]nodeCommand
+	_apiServer			common.ApiServer
+	_registry			ClusterRegistry
+	_tlsConfig			*common.TLSConfig
+	_logger				log.Logger
+}
+
+// Action serving a node answer, marshalled in the node format
+type nodeAction struct {
+	node   *clusterNode
+	answer func() interface{}
+}
+
+// Run the action, it receives the server arguments: req, w, method, consumes, produces
+func (na *nodeAction) Run(Args ...interface{}) error {
+	if len(Args) < 2 {
+		return errors.New("ClusterNode.Action - Missing http response writer in arguments")
+	}
+	w, ok := Args[1].(http.ResponseWriter)
+	if !ok {
+		return errors.New(fmt.Sprintf("ClusterNode.Action - Invalid http response writer type: %T", Args[1]))
+	}
+	mimeType := ncom.FormatMimeType(na.node.Format)
+	if len(Args) > 4 {
+		if produces, ok := Args[4].(ncom.MimeType); ok && "" != produces {
+			mimeType = produces
+		}
+	}
+	if err := ncom.SubmitData(w, http.StatusOK, na.answer(), mimeType); err != nil {
+		req, _ := Args[0].(*http.Request)
+		ncom.SubmitError(w, req, ncom.NewApiError(http.StatusInternalServerError, "Unable to encode answer", err.Error()), "")
+	}
+	return nil
+}
+
+// Action proxying a plugin action, available only while plugins are enabled
+type pluginAction struct {
+	node   *clusterNode
+	action ncom.ApiAction
 }
 
+func (pa *pluginAction) Run(Args ...interface{}) error {
+	if !pa.node.pluginsEnabled() {
+		if len(Args) > 1 {
+			if w, ok := Args[1].(http.ResponseWriter); ok {
+				req, _ := Args[0].(*http.Request)
+				ncom.SubmitError(w, req, ncom.NewApiError(http.StatusServiceUnavailable, "Plugins are disabled on this node"), "")
+				return nil
+			}
+		}
+		return errors.New("ClusterNode.Plugin - Plugins are disabled on this node")
+	}
+	return pa.action.Run(Args...)
+}
+
+func (cn *clusterNode) Listen(ip string, port int32) error {
+	cn.Lock()
+	if cn._apiServer.IsRunning() {
+		cn.Unlock()
+		return errors.New(fmt.Sprintf("ClusterNode.Listen - Node %s already listening on %s:%v", cn.Name, cn.IpAddress, cn.Port))
+	}
+	cn.IpAddress = ip
+	cn.Port = port
+	if "" == cn.Name {
+		cn.Name = fmt.Sprintf("%s_%v", ip, port)
+	}
+	cn._state = types.NODE_STATE_RUNNING
+	cn.Unlock()
+	var err error
+	if cn._tlsConfig != nil {
+		err = cn._apiServer.StartTLS(ip, int64(port), cn._tlsConfig)
+	} else {
+		err = cn._apiServer.Start(ip, int64(port))
+	}
+	cn.Lock()
+	cn._state = types.NODE_STATE_UNKNOWN
+	cn.Unlock()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func (cn *clusterNode) Command(n *types.Node, command types.Command) error {
+	if n == nil {
+		return errors.New("ClusterNode.Command - Nil node reference")
+	}
+	path := command.Path
+	if "" == path {
+		path = DEFAULT_COMMAND_PATH_PREFIX + command.Name
+	}
+	method := ncom.REST_METHOD_GET
+	if len(command.Method) > 0 {
+		method = command.Method[0]
+	}
+	url := fmt.Sprintf("%s://%s%s", cn.protocol(), discovery.NodeAddress(n.IpAddress, n.Port), path)
+	request, err := http.NewRequest(string(method), url, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("ClusterNode.Command - Error: %s", err))
+	}
+	if "" != command.Produces {
+		request.Header.Set("Accept", string(command.Produces))
+	}
+	client := cn.client()
+	defer client.CloseIdleConnections()
+	response, err := client.Do(request)
+	if err != nil {
+		return errors.New(fmt.Sprintf("ClusterNode.Command - Error: %s", err))
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return errors.New(fmt.Sprintf("ClusterNode.Command - Command %s on node %s failed, Status: %s, Message: %s", command.Name, n.Name, response.Status, string(data)))
+	}
+	if cn._logger != nil {
+		cn._logger.Debugf("ClusterNode.Command - Command %s on node %s answered: %s", command.Name, n.Name, string(data))
+	}
+	return nil
+}
+
+func (cn *clusterNode) Execute(commandName string, arguments ...string) (*types.CommandResult, error) {
+	var err error = errors.New(fmt.Sprintf("ClusterNode.Execute - No active node exposes command: %s", commandName))
+	for _, node := range cn._registry.List() {
+		if !node.Active || node.State == types.NODE_STATE_UNRACJABLE {
+			continue
+		}
+		if _, ok := findCommand(&node, commandName); !ok {
+			continue
+		}
+		result, reached, errE := cn.execute(&node, commandName, arguments)
+		if reached {
+			return result, errE
+		}
+		err = errE
+		if cn._logger != nil {
+			cn._logger.Warnf("ClusterNode.Execute - Node %s unreachable, trying next node, Details: %s", node.Name, errE)
+		}
+	}
+	return nil, err
+}
+
+func (cn *clusterNode) ExecuteOn(n *types.Node, commandName string, arguments ...string) (*types.CommandResult, error) {
+	if n == nil {
+		return nil, errors.New("ClusterNode.ExecuteOn - Nil node reference")
+	}
+	result, _, err := cn.execute(n, commandName, arguments)
+	return result, err
+}
+
+// Calls a remote command, reporting whether the node has been reached
+func (cn *clusterNode) execute(n *types.Node, commandName string, arguments []string) (*types.CommandResult, bool, error) {
+	command, ok := findCommand(n, commandName)
+	if !ok {
+		return nil, false, errors.New(fmt.Sprintf("ClusterNode.Execute - Command %s not exposed by node %s", commandName, n.Name))
+	}
+	path := command.Path
+	if "" == path {
+		path = DEFAULT_COMMAND_PATH_PREFIX + command.Name
+	}
+	method := http.MethodGet
+	if len(command.Method) > 0 {
+		method = requestMethod(command.Method[0])
+	}
+	mimeType := ncom.FormatMimeType(cn.Format)
+	url := fmt.Sprintf("%s://%s%s", cn.protocol(), discovery.NodeAddress(n.IpAddress, n.Port), path)
+	var body io.Reader
+	if method == http.MethodGet || method == http.MethodHead || method == http.MethodDelete {
+		if len(arguments) > 0 {
+			url += "?" + neturl.Values{"arg": arguments}.Encode()
+		}
+	} else {
+		data, err := cio.Marshall(types.CommandRequest{Arguments: arguments}, cn.Format)
+		if err != nil {
+			return nil, false, errors.New(fmt.Sprintf("ClusterNode.Execute - Error: %s", err))
+		}
+		body = bytes.NewReader(data)
+	}
+	request, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, false, errors.New(fmt.Sprintf("ClusterNode.Execute - Error: %s", err))
+	}
+	request.Header.Set("Accept", string(mimeType))
+	if body != nil {
+		request.Header.Set("Content-Type", string(mimeType))
+	}
+	client := cn.client()
+	defer client.CloseIdleConnections()
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, false, errors.New(fmt.Sprintf("ClusterNode.Execute - Error: %s", err))
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusGatewayTimeout {
+		return nil, true, errors.New(fmt.Sprintf("ClusterNode.Execute - Command %s on node %s failed, Status: %s, Message: %s", commandName, n.Name, response.Status, string(data)))
+	}
+	var result = types.CommandResult{}
+	if _, err := cio.Unmashall(data, &result, cn.Format); err != nil {
+		return nil, true, errors.New(fmt.Sprintf("ClusterNode.Execute - Unable to decode command %s result, Error: %s", commandName, err))
+	}
+	return &result, true, nil
+}
+
+func (cn *clusterNode) Aknoledge(n *types.Node) error {
+	if n == nil {
+		return errors.New("ClusterNode.Aknoledge - Nil node reference")
+	}
+	tlsConfig := cn.clientTLSConfig()
+	pingInfo, err := discovery.PingNode(n.IpAddress, n.Port, cn.Timeout, tlsConfig)
+	if err != nil {
+		return errors.New(fmt.Sprintf("ClusterNode.Aknoledge - Node %s:%v unreachable, Details: %s", n.IpAddress, n.Port, err))
+	}
+	node, err := discovery.RequireNodeInfo(*pingInfo, cn.Timeout, tlsConfig)
+	if err != nil {
+		return errors.New(fmt.Sprintf("ClusterNode.Aknoledge - Unable to collect node %s:%v information, Details: %s", n.IpAddress, n.Port, err))
+	}
+	if "" != n.Name {
+		node.Name = n.Name
+	}
+	return cn.registerOrUpdate(node)
+}
+
+func (cn *clusterNode) Discover(network *net.IPNet, ports types.Ports) {
+	tlsConfig := cn.clientTLSConfig()
+	scanner := discovery.NewScanner(discovery.ScanConfig{Timeout: cn.Timeout, TLSConfig: tlsConfig}, cn._logger)
+	for pingInfo := range scanner.ScanNetwork(context.Background(), network, ports) {
+		if pingInfo.Port == cn.Port && cn.isLocalAddress(pingInfo.IpAddress) {
+			continue
+		}
+		node, err := discovery.RequireNodeInfo(pingInfo, cn.Timeout, tlsConfig)
+		if err != nil {
+			if cn._logger != nil {
+				cn._logger.Errorf("ClusterNode.Discover - Unable to collect node %s:%v information, Details: %s", pingInfo.IpAddress, pingInfo.Port, err)
+			}
+			continue
+		}
+		if errR := cn.registerOrUpdate(node); errR != nil && cn._logger != nil {
+			cn._logger.Errorf("ClusterNode.Discover - Unable to register node %s, Details: %s", node.Name, errR)
+		}
+	}
+}
+
+func (cn *clusterNode) Stop() error {
+	if !cn._apiServer.IsRunning() {
+		return errors.New(fmt.Sprintf("ClusterNode.Stop - Node %s is not listening", cn.Name))
+	}
+	return cn._apiServer.Stop()
+}
+
+func (cn *clusterNode) List() []types.Node {
+	return cn._registry.List()
+}
+
+func (cn *clusterNode) UsedFormat() cio.ParserFormat {
+	return cn.Format
+}
+
+func (cn *clusterNode) EnableRegistryPersistence(registryFile string) error {
+	err := cn._registry.EnablePersistence(registryFile)
+	if err == nil {
+		cn.RegistryFile = registryFile
+	}
+	return err
+}
+
+func (cn *clusterNode) DisableRegistryPersistence() error {
+	err := cn._registry.DisablePersistence()
+	if err == nil {
+		cn.RegistryFile = ""
+	}
+	return err
+}
+
+func (cn *clusterNode) EnablePlugins(pluginFolder string, pluginExtension string) error {
+	if cn.pluginsEnabled() {
+		return errors.New("ClusterNode.EnablePlugins - Plugins already enabled!!")
+	}
+	servicePlugins, err := plugins.CollectAllPlugins(pluginFolder, pluginExtension)
+	if err != nil {
+		return errors.New(fmt.Sprintf("ClusterNode.EnablePlugins - Error: %s", err))
+	}
+	cn.Lock()
+	if cn.PluginsEnabled {
+		cn.Unlock()
+		return errors.New(fmt.Sprintf("ClusterNode.EnablePlugins - Plugins already enabled from folder: %s", cn.PluginsFolder))
+	}
+	cn.PluginsFolder = pluginFolder
+	cn.PluginsExtension = pluginExtension
+	cn.PluginsEnabled = true
+	cn.Unlock()
+	for _, servicePlugin := range servicePlugins {
+		if servicePlugin == nil {
+			continue
+		}
+		path, action, command, errP := servicePlugin.GetActionAndPath()
+		if errP != nil {
+			if cn._logger != nil {
+				cn._logger.Errorf("ClusterNode.EnablePlugins - Unable to load plugin service, Details: %s", errP)
+			}
+			continue
+		}
+		if cn.hasCommandPath(path) {
+			continue
+		}
+		if errR := cn.registerCommand(path, &pluginAction{node: cn, action: action}, command, true); errR != nil && cn._logger != nil {
+			cn._logger.Errorf("ClusterNode.EnablePlugins - Unable to register plugin path: %s, Details: %s", path, errR)
+		}
+	}
+	return nil
+}
+
+func (cn *clusterNode) DisablePlugins() error {
+	cn.Lock()
+	defer cn.Unlock()
+	if !cn.PluginsEnabled {
+		return errors.New("ClusterNode.DisablePlugins - Plugins are not enabled!!")
+	}
+	cn.PluginsEnabled = false
+	return nil
+}
+
+func (cn *clusterNode) RegisterCommand(path string, action ncom.ApiAction, command *types.Command) error {
+	return cn.registerCommand(path, action, command, false)
+}
+
+func (cn *clusterNode) DumpConfigToFile(configFile string) {
+	err := cio.MarshallTo(cn, configFile, cn.Format)
+	if err != nil && cn._logger != nil {
+		cn._logger.Errorf("ClusterNode.DumpConfigToFile - Unable to dump configuration to file: %s, Details: %s", configFile, err)
+	}
+}
+
+func (cn *clusterNode) registerCommand(path string, action ncom.ApiAction, command *types.Command, plugin bool) error {
+	if action == nil || command == nil {
+		return errors.New("ClusterNode.RegisterCommand - Nil action or command reference")
+	}
+	if "" == path {
+		path = DEFAULT_COMMAND_PATH_PREFIX + command.Name
+	}
+	if cn.hasCommandPath(path) {
+		return errors.New(fmt.Sprintf("ClusterNode.RegisterCommand - Path already registered: %s", path))
+	}
+	method := ncom.REST_METHOD_GET
+	if len(command.Method) > 0 {
+		method = command.Method[0]
+	}
+	produces := command.Produces
+	if "" == produces {
+		produces = ncom.FormatMimeType(cn.Format)
+	}
+	consumes := command.Accepts
+	if "" == consumes {
+		consumes = ncom.FormatMimeType(cn.Format)
+	}
+	cn._apiServer.AddApiAction(path, action, true, &method, &produces, &consumes)
+	for idx := 1; idx < len(command.Method); idx++ {
+		cn._apiServer.AllowMethod(path, &command.Method[idx])
+	}
+	cmd := *command
+	cmd.Path = path
+	cn.Lock()
+	cn._commands = append(cn._commands, nodeCommand{
+		Path:    path,
+		Command: cmd,
+		Plugin:  plugin,
+	})
+	cn.Unlock()
+	return nil
+}
+
+func (cn *clusterNode) hasCommandPath(path string) bool {
+	cn.Lock()
+	defer cn.Unlock()
+	for _, command := range cn._commands {
+		if command.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (cn *clusterNode) ports() []types.Port {
+	return []types.Port{
+		types.Port{
+			Port:        cn.Port,
+			Description: "Cluster node api port",
+			Type:        types.PORT_TYPE_REST,
+		},
+	}
+}
+
+func (cn *clusterNode) services() []types.Service {
+	cn.Lock()
+	defer cn.Unlock()
+	var commands = make([]types.Command, 0)
+	for _, command := range cn._commands {
+		if command.Plugin && !cn.PluginsEnabled {
+			continue
+		}
+		commands = append(commands, command.Command)
+	}
+	return []types.Service{
+		types.Service{
+			Port:     cn.ports()[0],
+			Commands: commands,
+		},
+	}
+}
+
+func (cn *clusterNode) pingInfo() types.NodePingInfo {
+	cn.Lock()
+	role := cn.Role
+	state := cn._state
+	ports := cn.ports()
+	cn.Unlock()
+	return types.NodePingInfo{
+		Role:   role,
+		State:  state,
+		Active: cn._apiServer.IsRunning(),
+		Ports:  ports,
+	}
+}
+
+func (cn *clusterNode) pluginsEnabled() bool {
+	cn.Lock()
+	defer cn.Unlock()
+	return cn.PluginsEnabled
+}
+
+// Local node description
+func (cn *clusterNode) local() types.Node {
+	cn.Lock()
+	defer cn.Unlock()
+	return types.Node{
+		Name:      cn.Name,
+		IpAddress: cn.IpAddress,
+		Port:      cn.Port,
+		Role:      cn.Role,
+		State:     cn._state,
+	}
+}
+
+func (cn *clusterNode) setRole(role types.NodeType) {
+	cn.Lock()
+	cn.Role = role
+	cn.Unlock()
+}
+
+func (cn *clusterNode) registerOrUpdate(n *types.Node) error {
+	filter := NodeNameFilter(n.Name)
+	nodes, err := cn._registry.Recover("Name", filter)
+	if err != nil {
+		return err
+	}
+	if len(nodes) > 0 {
+		return cn._registry.Update("Name", filter, *n)
+	}
+	return cn._registry.Register(n)
+}
+
+func (cn *clusterNode) isLocalAddress(ip string) bool {
+	if ip == cn.IpAddress {
+		return true
+	}
+	if "" == cn.IpAddress || "0.0.0.0" == cn.IpAddress {
+		if parsed := net.ParseIP(ip); parsed != nil {
+			if parsed.IsLoopback() {
+				return true
+			}
+			addresses, err := net.InterfaceAddrs()
+			if err == nil {
+				for _, address := range addresses {
+					if ipNet, ok := address.(*net.IPNet); ok && ipNet.IP.Equal(parsed) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (cn *clusterNode) protocol() string {
+	if cn._tlsConfig != nil {
+		return string(ncom.REST_PROTOCOL_HTTPS)
+	}
+	return string(ncom.REST_PROTOCOL_HTTP)
+}
+
+func (cn *clusterNode) client() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: cn.clientTLSConfig(),
+		},
+		Timeout: cn.Timeout,
+	}
+}
+
+// Client side TLS configuration, used to contact other cluster nodes
+func (cn *clusterNode) clientTLSConfig() *tls.Config {
+	if cn._tlsConfig == nil {
+		return nil
+	}
+	config := &tls.Config{
+		InsecureSkipVerify: cn._tlsConfig.UseInsecure,
+	}
+	if "" != cn._tlsConfig.CaCertificate {
+		caCert, err := ioutil.ReadFile(cn._tlsConfig.CaCertificate)
+		if err != nil {
+			if cn._logger != nil {
+				cn._logger.Errorf("ClusterNode.TLS - Unable to read ca cert: <%s>, details: %s", cn._tlsConfig.CaCertificate, err)
+			}
+		} else {
+			caCertPool := x509.NewCertPool()
+			if ok := caCertPool.AppendCertsFromPEM(caCert); ok {
+				config.RootCAs = caCertPool
+			} else if cn._logger != nil {
+				cn._logger.Error("ClusterNode.TLS - No certs appended, using system certificates only")
+			}
+		}
+	}
+	for _, pair := range cn._tlsConfig.Certificates {
+		if "" != pair.Key && "" != pair.Cert {
+			cert, err := tls.LoadX509KeyPair(pair.Cert, pair.Key)
+			if err != nil {
+				if cn._logger != nil {
+					cn._logger.Errorf("ClusterNode.TLS - Unable to load key : %s and certificate: %s", pair.Key, pair.Cert)
+				}
+				continue
+			}
+			config.Certificates = append(config.Certificates, cert)
+		}
+	}
+	return config
+}
+
+func findCommand(n *types.Node, name string) (types.Command, bool) {
+	for _, service := range n.Services {
+		for _, command := range service.Commands {
+			if command.Name == name {
+				return command, true
+			}
+		}
+	}
+	return types.Command{}, false
+}
+
+// Creates a new Cluster Node, exposing the /ping, /info and /services endpoints via an Api Server.
+// In case registry is nil an in-memory registry is used, in case tlsConfig is nil the node communicates in plain http
+func NewClusterNode(name string, role types.NodeType, registry ClusterRegistry, format cio.ParserFormat, tlsConfig *common.TLSConfig, logger log.Logger) ClusterNode {
+	if registry == nil {
+		registry = NewInMemoryClusterRegistry()
+	}
+	if "" == format {
+		format = cio.ParserFormatJson
+	}
+	node := &clusterNode{
+		Name:         name,
+		Role:         role,
+		Format:       format,
+		Timeout:      DEFAULT_NODE_TIMEOUT,
+		RegistryFile: registry.RegistryFilePath(),
+		_state:       types.NODE_STATE_UNKNOWN,
+		_commands:    make([]nodeCommand, 0),
+		_apiServer:   server.NewApiServer(logger),
+		_registry:    registry,
+		_tlsConfig:   tlsConfig,
+		_logger:      logger,
+	}
+	method := ncom.REST_METHOD_GET
+	mimeType := ncom.FormatMimeType(format)
+	node._apiServer.AddApiAction("/ping", &nodeAction{node: node, answer: func() interface{} {
+		return node.pingInfo()
+	}}, true, &method, &mimeType, &mimeType)
+	node._apiServer.AddApiAction("/info", &nodeAction{node: node, answer: func() interface{} {
+		return types.NewNodeInfo()
+	}}, true, &method, &mimeType, &mimeType)
+	node._apiServer.AddApiAction("/services", &nodeAction{node: node, answer: func() interface{} {
+		return node.services()
+	}}, true, &method, &mimeType, &mimeType)
+	return node
+}
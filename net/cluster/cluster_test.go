@@ -0,0 +1,131 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	cio "github.com/hellgate75/go-tcp-common/io"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+type helloAction struct{}
+
+func (ha *helloAction) Run(Args ...interface{}) error {
+	if len(Args) < 2 {
+		return errors.New("missing response writer")
+	}
+	w := Args[1].(http.ResponseWriter)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("hello"))
+	return nil
+}
+
+func getNode(t *testing.T, url string, out interface{}) (int, string) {
+	response, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("getNode - http.Get - Expected: %v but Given: %v", nil, err)
+	}
+	defer response.Body.Close()
+	data, _ := ioutil.ReadAll(response.Body)
+	if out != nil {
+		if _, err := cio.Unmashall(data, out, cio.ParserFormatJson); err != nil {
+			t.Fatalf("getNode - io.Unmashall - Expected: %v but Given: %v (%s)", nil, err, data)
+		}
+	}
+	return response.StatusCode, string(data)
+}
+
+func TestClusterNode(t *testing.T) {
+	logger := log.NewLogger("cluster-test", log.FATAL)
+	port := freePort(t)
+	node := NewClusterNode("node-1", types.ROLE_SLAVE, nil, "", nil, logger)
+	hello := types.Command{Name: "hello", Method: []ncom.RestMethod{ncom.REST_METHOD_GET, ncom.REST_METHOD_POST}}
+	if err := node.RegisterCommand("", &helloAction{}, &hello); err != nil {
+		t.Fatalf("TestClusterNode - ClusterNode.RegisterCommand - Expected: %v but Given: %v", nil, err)
+	}
+	if err := node.RegisterCommand("", &helloAction{}, &hello); err == nil {
+		t.Fatalf("TestClusterNode - ClusterNode.RegisterCommand - Expected: %v but Given: %v", "path already registered", err)
+	}
+	if err := node.RegisterCommand("/nil", nil, &hello); err == nil {
+		t.Fatalf("TestClusterNode - ClusterNode.RegisterCommand - Expected: %v but Given: %v", "nil action error", err)
+	}
+	plugin := types.Command{Name: "plugin"}
+	node.(*clusterNode).registerCommand("", &pluginAction{node: node.(*clusterNode), action: &helloAction{}}, &plugin, true)
+
+	var listened = make(chan error, 1)
+	go func() {
+		listened <- node.Listen("127.0.0.1", port)
+	}()
+	waitListening(t, []types.Node{{IpAddress: "127.0.0.1", Port: port}})
+	if err := node.Listen("127.0.0.1", port); err == nil {
+		t.Fatalf("TestClusterNode - ClusterNode.Listen - Expected: %v but Given: %v", "already listening", err)
+	}
+	base := fmt.Sprintf("http://127.0.0.1:%v", port)
+
+	var pingInfo = types.NodePingInfo{}
+	if status, _ := getNode(t, base+"/ping", &pingInfo); status != http.StatusOK || pingInfo.Role != types.ROLE_SLAVE ||
+		pingInfo.State != types.NODE_STATE_RUNNING || !pingInfo.Active || len(pingInfo.Ports) != 1 || pingInfo.Ports[0].Port != port {
+		t.Fatalf("TestClusterNode - /ping - Expected: %v but Given: %v %+v", "running slave node", status, pingInfo)
+	}
+	var nodeInfo = types.NodeInfo{}
+	if status, _ := getNode(t, base+"/info", &nodeInfo); status != http.StatusOK || nodeInfo.OS != runtime.GOOS || nodeInfo.NumCPUs != runtime.NumCPU() {
+		t.Fatalf("TestClusterNode - /info - Expected: %v but Given: %v %+v", runtime.GOOS, status, nodeInfo)
+	}
+	var services = make([]types.Service, 0)
+	if status, _ := getNode(t, base+"/services", &services); status != http.StatusOK || len(services) != 1 ||
+		len(services[0].Commands) != 1 || services[0].Commands[0].Path != DEFAULT_COMMAND_PATH_PREFIX+"hello" {
+		t.Fatalf("TestClusterNode - /services - Expected: %v but Given: %v %+v", "hello command", status, services)
+	}
+	if status, body := getNode(t, base+DEFAULT_COMMAND_PATH_PREFIX+"hello", nil); status != http.StatusOK || body != "hello" {
+		t.Fatalf("TestClusterNode - /commands/hello - Expected: %v but Given: %v %q", "hello", status, body)
+	}
+	if response, err := http.Post(base+DEFAULT_COMMAND_PATH_PREFIX+"hello", "application/json", nil); err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("TestClusterNode - POST /commands/hello - Expected: %v but Given: %v (%v)", http.StatusOK, response, err)
+	} else {
+		response.Body.Close()
+	}
+	if status, _ := getNode(t, base+DEFAULT_COMMAND_PATH_PREFIX+"plugin", nil); status != http.StatusServiceUnavailable {
+		t.Fatalf("TestClusterNode - /commands/plugin - Expected: %v but Given: %v", http.StatusServiceUnavailable, status)
+	}
+
+	var group sync.WaitGroup
+	var stop = make(chan struct{})
+	group.Add(1)
+	go func() {
+		defer group.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				node.(*clusterNode).pingInfo()
+			}
+		}
+	}()
+	if err := node.Stop(); err != nil {
+		t.Fatalf("TestClusterNode - ClusterNode.Stop - Expected: %v but Given: %v", nil, err)
+	}
+	select {
+	case err := <-listened:
+		if err != nil {
+			t.Fatalf("TestClusterNode - ClusterNode.Listen - Expected: %v but Given: %v", nil, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("TestClusterNode - ClusterNode.Listen - Expected: %v but Given: %v", "returned", "still listening")
+	}
+	close(stop)
+	group.Wait()
+	if state := node.(*clusterNode).pingInfo().State; state != types.NODE_STATE_UNKNOWN {
+		t.Fatalf("TestClusterNode - ClusterNode.Stop - Expected: %v but Given: %v", types.NODE_STATE_UNKNOWN, state)
+	}
+	if err := node.Stop(); err == nil {
+		t.Fatalf("TestClusterNode - ClusterNode.Stop - Expected: %v but Given: %v", "not listening", err)
+	}
+}
@@ -0,0 +1,282 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	cio "github.com/hellgate75/go-tcp-common/io"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// Default maximum duration of a command execution
+	DEFAULT_COMMAND_TIMEOUT time.Duration = 30 * time.Second
+	// Default maximum number of bytes collected from each of the command stdout and stderr
+	DEFAULT_COMMAND_OUTPUT_SIZE int = 1024 * 1024
+	// Default time the output of a killed command is waited for, before its pipes are closed: child
+	// processes keeping the pipes open cannot block the runner past the command timeout
+	DEFAULT_COMMAND_WAIT_DELAY time.Duration = 1 * time.Second
+	// Default maximum number of bytes of the command request body
+	DEFAULT_COMMAND_REQUEST_SIZE int64 = 64 * 1024
+)
+
+// Buffer discarding the data written after its limit
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	if available := lb.limit - lb.Len(); available > 0 {
+		if len(p) > available {
+			lb.Buffer.Write(p[:available])
+		} else {
+			lb.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+type commandRunner struct {
+	node    *clusterNode
+	config  CommandRunnerConfig
+	allowed map[string][]*regexp.Regexp
+	logger  log.Logger
+}
+
+func (cr *commandRunner) Expose(services ...types.Service) error {
+	for _, service := range services {
+		for _, command := range service.Commands {
+			cmd := command
+			if err := cr.node.RegisterCommand(cmd.Path, &commandAction{runner: cr, command: cmd}, &cmd); err != nil {
+				return errors.New(fmt.Sprintf("CommandRunner.Expose - Error: %s", err))
+			}
+		}
+	}
+	return nil
+}
+
+func (cr *commandRunner) Run(ctx context.Context, command types.Command, arguments []string) (types.CommandResult, error) {
+	var result = types.CommandResult{
+		Name:     command.Name,
+		Node:     cr.node.local().Name,
+		ExitCode: -1,
+	}
+	if "" == command.Command {
+		return result, errors.New(fmt.Sprintf("CommandRunner.Run - No executable defined for command: %s", command.Name))
+	}
+	if err := cr.checkArguments(command.Name, arguments); err != nil {
+		return result, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cr.config.Timeout)
+	defer cancel()
+	var args = append(append(make([]string, 0), command.Arguments...), arguments...)
+	cmd := exec.CommandContext(ctx, command.Command, args...)
+	cmd.WaitDelay = DEFAULT_COMMAND_WAIT_DELAY
+	stdout := &limitedBuffer{limit: cr.config.MaxOutputSize}
+	stderr := &limitedBuffer{limit: cr.config.MaxOutputSize}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	start := time.Now()
+	err := cmd.Run()
+	result.Duration = time.Since(start)
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	if err == nil {
+		result.ExitCode = 0
+		return result, nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		result.TimedOut = true
+		return result, nil
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 {
+		result.ExitCode = exitErr.ExitCode()
+		return result, nil
+	}
+	return result, errors.New(fmt.Sprintf("CommandRunner.Run - Command %s failed, Error: %s", command.Name, err))
+}
+
+// Verifies each caller argument matches at least one of the allowed expressions of the command
+func (cr *commandRunner) checkArguments(name string, arguments []string) error {
+	patterns := cr.allowed[name]
+	for _, argument := range arguments {
+		var matched bool = false
+		for _, pattern := range patterns {
+			if pattern.MatchString(argument) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return errors.New(fmt.Sprintf("CommandRunner.Run - Argument not allowed for command %s: <%s>", name, argument))
+		}
+	}
+	return nil
+}
+
+// Action running a command, it answers with the command result in the negotiated mime type
+type commandAction struct {
+	runner  *commandRunner
+	command types.Command
+}
+
+func (ca *commandAction) Run(Args ...interface{}) error {
+	if len(Args) < 2 {
+		return errors.New("CommandRunner.Action - Missing http request and response writer in arguments")
+	}
+	req, ok := Args[0].(*http.Request)
+	if !ok {
+		return errors.New(fmt.Sprintf("CommandRunner.Action - Invalid http request type: %T", Args[0]))
+	}
+	w, ok := Args[1].(http.ResponseWriter)
+	if !ok {
+		return errors.New(fmt.Sprintf("CommandRunner.Action - Invalid http response writer type: %T", Args[1]))
+	}
+	if !ca.acceptsMethod(req.Method) {
+		ncom.SubmitError(w, req, ncom.NewApiError(http.StatusMethodNotAllowed, fmt.Sprintf("Method %s not allowed for command %s", req.Method, ca.command.Name)), "")
+		return nil
+	}
+	arguments, err := ca.arguments(w, req)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		ncom.SubmitError(w, req, ncom.NewApiError(http.StatusRequestEntityTooLarge, "Command request too large", err.Error()), "")
+		return nil
+	} else if err != nil {
+		ncom.SubmitError(w, req, ncom.NewApiError(http.StatusBadRequest, "Unable to decode command arguments", err.Error()), "")
+		return nil
+	}
+	if err := ca.runner.checkArguments(ca.command.Name, arguments); err != nil {
+		ncom.SubmitError(w, req, ncom.NewApiError(http.StatusForbidden, err.Error()), "")
+		return nil
+	}
+	mimeType := ca.command.Produces
+	if "" == mimeType {
+		mimeType = ncom.FormatMimeType(ca.runner.node.Format)
+	}
+	if len(Args) > 4 {
+		if produces, ok := Args[4].(ncom.MimeType); ok && "" != produces {
+			mimeType = produces
+		}
+	}
+	result, err := ca.runner.Run(req.Context(), ca.command, arguments)
+	if err != nil && ca.runner.logger != nil {
+		ca.runner.logger.Errorf("CommandRunner.Action - Error: %s", err)
+	}
+	data, errM := encodeCommandResult(result, mimeType)
+	if errM != nil {
+		ncom.SubmitError(w, req, ncom.NewApiError(http.StatusInternalServerError, "Unable to encode answer", errM.Error()), "")
+		return nil
+	}
+	w.Header().Set("Content-Type", string(mimeType))
+	if err != nil {
+		ncom.SubmitFaiure(w, http.StatusInternalServerError, string(data))
+	} else if result.TimedOut {
+		ncom.SubmitFaiure(w, http.StatusGatewayTimeout, string(data))
+	} else {
+		ncom.SubmitSuccess(w, string(data))
+	}
+	return nil
+}
+
+func (ca *commandAction) acceptsMethod(method string) bool {
+	if len(ca.command.Method) == 0 {
+		return method == http.MethodGet
+	}
+	for _, allowed := range ca.command.Method {
+		if requestMethod(allowed) == method {
+			return true
+		}
+	}
+	return false
+}
+
+// Collects the caller arguments from the "arg" query parameters and from the request body, bounded by the
+// maximum request size
+func (ca *commandAction) arguments(w http.ResponseWriter, req *http.Request) ([]string, error) {
+	var arguments = append(make([]string, 0), req.URL.Query()["arg"]...)
+	if req.Body == nil {
+		return arguments, nil
+	}
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, ca.runner.config.MaxRequestSize))
+	if err != nil {
+		return arguments, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return arguments, nil
+	}
+	consumes := ncom.MimeType(strings.TrimSpace(strings.Split(req.Header.Get("Content-Type"), ";")[0]))
+	if "" == consumes {
+		consumes = ca.command.Accepts
+	}
+	format, ok := ncom.MimeTypeFormat(consumes)
+	if !ok {
+		format = ca.runner.node.Format
+	}
+	var request = types.CommandRequest{}
+	if _, err := cio.Unmashall(data, &request, format); err != nil {
+		return arguments, err
+	}
+	return append(arguments, request.Arguments...), nil
+}
+
+func encodeCommandResult(result types.CommandResult, mimeType ncom.MimeType) ([]byte, error) {
+	format, ok := ncom.MimeTypeFormat(mimeType)
+	if !ok {
+		return []byte(fmt.Sprintf("name: %s\nnode: %s\nexit-code: %v\ntimed-out: %v\nduration: %s\nstdout:\n%s\nstderr:\n%s\n",
+			result.Name, result.Node, result.ExitCode, result.TimedOut, result.Duration, result.Stdout, result.Stderr)), nil
+	}
+	return cio.Marshall(result, format)
+}
+
+// Http method of a rest method, form posts are sent as plain posts
+func requestMethod(method ncom.RestMethod) string {
+	if method == ncom.REST_METHOD_POST_FORM {
+		return http.MethodPost
+	}
+	return string(method)
+}
+
+// Creates a runner executing the commands exposed on the given cluster node. In case config Timeout,
+// MaxOutputSize or MaxRequestSize are not positive the package defaults are used. Commands without AllowedArguments
+// accept no caller arguments, running with the definition arguments only
+func NewCommandRunner(node ClusterNode, config CommandRunnerConfig, logger log.Logger) (CommandRunner, error) {
+	cn, ok := node.(*clusterNode)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("NewCommandRunner - Unsupported cluster node type: %T", node))
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DEFAULT_COMMAND_TIMEOUT
+	}
+	if config.MaxOutputSize <= 0 {
+		config.MaxOutputSize = DEFAULT_COMMAND_OUTPUT_SIZE
+	}
+	if config.MaxRequestSize <= 0 {
+		config.MaxRequestSize = DEFAULT_COMMAND_REQUEST_SIZE
+	}
+	var allowed = make(map[string][]*regexp.Regexp)
+	for name, expressions := range config.AllowedArguments {
+		for _, expression := range expressions {
+			pattern, err := regexp.Compile("^(?:" + expression + ")$")
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("NewCommandRunner - Invalid argument expression for command %s: <%s>, Error: %s", name, expression, err))
+			}
+			allowed[name] = append(allowed[name], pattern)
+		}
+	}
+	return &commandRunner{
+		node:    cn,
+		config:  config,
+		allowed: allowed,
+		logger:  logger,
+	}, nil
+}
@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"net/http"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommandRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("TestCommandRunner - Requires posix shell commands")
+	}
+	logger := log.NewLogger("command-test", log.FATAL)
+	port := freePort(t)
+	server := NewClusterNode("runner", types.ROLE_SLAVE, nil, "", nil, logger)
+	runner, err := NewCommandRunner(server, CommandRunnerConfig{
+		Timeout:          300 * time.Millisecond,
+		AllowedArguments: map[string][]string{"echo": {"[a-z]+"}},
+		MaxRequestSize:   64,
+	}, logger)
+	if err != nil {
+		t.Fatalf("TestCommandRunner - cluster.NewCommandRunner - Expected: %v but Given: %v", nil, err)
+	}
+	err = runner.Expose(types.Service{Commands: []types.Command{
+		{Name: "echo", Command: "echo", Arguments: []string{"hello"}, Method: []ncom.RestMethod{ncom.REST_METHOD_POST}},
+		{Name: "fail", Command: "sh", Arguments: []string{"-c", "echo oops >&2; exit 3"}},
+		{Name: "slow", Command: "sh", Arguments: []string{"-c", "sleep 5; echo done"}},
+	}})
+	if err != nil {
+		t.Fatalf("TestCommandRunner - CommandRunner.Expose - Expected: %v but Given: %v", nil, err)
+	}
+	go server.Listen("127.0.0.1", port)
+	defer server.Stop()
+	waitListening(t, []types.Node{{IpAddress: "127.0.0.1", Port: port}})
+
+	registry := NewInMemoryClusterRegistry()
+	registry.Register(&types.Node{Name: "runner", IpAddress: "127.0.0.1", Port: port, Active: true, Services: server.(*clusterNode).services()})
+	client := NewClusterNode("client", types.ROLE_MASTER, registry, "", nil, logger)
+
+	result, err := client.Execute("echo", "world")
+	if err != nil || result.ExitCode != 0 || result.Stdout != "hello world\n" || result.Node != "runner" {
+		t.Fatalf("TestCommandRunner - ClusterNode.Execute - Expected: %v but Given: %v (%v)", "hello world", result, err)
+	}
+	if _, err := client.Execute("echo", "-rf"); err == nil {
+		t.Fatalf("TestCommandRunner - ClusterNode.Execute - Expected: %v but Given: %v", "argument not allowed", err)
+	}
+	response, err := http.Post(fmt.Sprintf("http://127.0.0.1:%v%secho?arg=-rf", port, DEFAULT_COMMAND_PATH_PREFIX), "application/json", nil)
+	if err != nil || response.StatusCode != http.StatusForbidden || response.Header.Get("Content-Type") != string(ncom.PROBLEM_JSON_MIME_TYPE) {
+		t.Fatalf("TestCommandRunner - CommandAction.Run - Expected: %v but Given: %v (%v)", "forbidden problem details", response, err)
+	}
+	response.Body.Close()
+	large := fmt.Sprintf(`{"arguments":["%s"]}`, strings.Repeat("a", 128))
+	response, err = http.Post(fmt.Sprintf("http://127.0.0.1:%v%secho", port, DEFAULT_COMMAND_PATH_PREFIX), "application/json", strings.NewReader(large))
+	if err != nil || response.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("TestCommandRunner - CommandAction.Run - Expected: %v but Given: %v (%v)", http.StatusRequestEntityTooLarge, response, err)
+	}
+	response.Body.Close()
+	result, err = client.Execute("fail")
+	if err != nil || result.ExitCode != 3 || result.Stderr != "oops\n" {
+		t.Fatalf("TestCommandRunner - ClusterNode.Execute - Expected: %v but Given: %v (%v)", "exit code 3", result, err)
+	}
+	result, err = client.Execute("slow")
+	if err != nil || !result.TimedOut || result.ExitCode != -1 {
+		t.Fatalf("TestCommandRunner - ClusterNode.Execute - Expected: %v but Given: %v (%v)", "timed out", result, err)
+	}
+	// the sleep child process keeps the output pipes open after the shell is killed
+	if result.Duration > 3*time.Second {
+		t.Fatalf("TestCommandRunner - ClusterNode.Execute - Expected: %v but Given: %v", "timed out within the wait delay", result.Duration)
+	}
+	if _, err := client.Execute("missing"); err == nil {
+		t.Fatalf("TestCommandRunner - ClusterNode.Execute - Expected: %v but Given: %v", "no node error", err)
+	}
+}
@@ -1,186 +1,256 @@
 package discovery
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/hellgate75/go-tcp-common/io"
 	"github.com/hellgate75/go-tcp-common/net/cluster/types"
-	"github.com/hellgate75/go-tcp-common/net/common"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"github.com/hellgate75/go-tcp-common/pool"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 )
 
+// Discover the nodes listening on the port range of the network addresses, waiting for the scan completion
 func DiscoverNodes(network *net.IPNet, timeout time.Duration, netType string, ports types.Ports, tlsConfig *tls.Config) ([]types.NodePingInfo, error) {
-	var portList = make([]int32, 0)
-	for port := ports.MinPort; port <= ports.MaxPort; port++ {
-		portList = append(portList, port)
-	}
-	addressList := common.ListAddresses(network)
-	var requests = make([]types.NodeRequest, 0)
-	for _, ip := range addressList {
-		requests = append(requests, types.NodeRequest{
-			IpAddress: ip.String(),
-			Ports:     portList,
-		})
-	}
-	return PingNodesList(requests, timeout, netType, tlsConfig)
+	return DiscoverNodesContext(context.Background(), network, timeout, netType, ports, tlsConfig)
 }
 
+// Discover the nodes as DiscoverNodes does, until the context is done: the nodes found before
+// the cancellation are returned with the context error
+func DiscoverNodesContext(ctx context.Context, network *net.IPNet, timeout time.Duration, netType string, ports types.Ports, tlsConfig *tls.Config) ([]types.NodePingInfo, error) {
+	scanner := NewScanner(ScanConfig{Timeout: timeout, NetType: netType, TLSConfig: tlsConfig}, nil)
+	return collect(scanner.ScanNetwork(ctx, network, ports)), ctx.Err()
+}
+
+// Ping the requested addresses and ports, waiting for the scan completion
 func PingNodesList(requests []types.NodeRequest, timeout time.Duration, netType string, tlsConfig *tls.Config) ([]types.NodePingInfo, error) {
+	return PingNodesListContext(context.Background(), requests, timeout, netType, tlsConfig)
+}
+
+// Ping the requested addresses and ports as PingNodesList does, until the context is done: the nodes
+// answering before the cancellation are returned with the context error
+func PingNodesListContext(ctx context.Context, requests []types.NodeRequest, timeout time.Duration, netType string, tlsConfig *tls.Config) ([]types.NodePingInfo, error) {
+	scanner := NewScanner(ScanConfig{Timeout: timeout, NetType: netType, TLSConfig: tlsConfig}, nil)
+	return collect(scanner.Scan(ctx, requests)), ctx.Err()
+}
+
+func collect(results <-chan types.NodePingInfo) []types.NodePingInfo {
 	var out = make([]types.NodePingInfo, 0)
-	if "" == netType {
-		netType = "tcp"
+	for nodePingInfo := range results {
+		out = append(out, nodePingInfo)
+	}
+	return out
+}
+
+// Ping a single node on a given ip address and port, returning the node ping information
+func PingNode(ipAddress string, port int32, timeout time.Duration, tlsConfig *tls.Config) (*types.NodePingInfo, error) {
+	return PingNodeContext(context.Background(), ipAddress, port, timeout, tlsConfig)
+}
+
+// Ping a single node as PingNode does, bounded by the context deadline and cancellation
+func PingNodeContext(ctx context.Context, ipAddress string, port int32, timeout time.Duration, tlsConfig *tls.Config) (*types.NodePingInfo, error) {
+	client := newClient(timeout, tlsConfig)
+	defer client.CloseIdleConnections()
+	return pingNode(ctx, client, ipAddress, port, tlsConfig != nil)
+}
+
+func pingNode(ctx context.Context, client *http.Client, ipAddress string, port int32, secure bool) (*types.NodePingInfo, error) {
+	url := fmt.Sprintf("%s://%s/ping", protocol(secure), NodeAddress(ipAddress, port))
+	init := time.Now()
+	response, err := get(ctx, client, url)
+	answer := time.Now().Sub(init)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New(fmt.Sprintf("net/cluster/discover.PingNode - Node %s:%v answered with status: %s", ipAddress, port, response.Status))
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
 	}
+	nodePingInfo := parseNodePingInfoWithAllFormats(data)
+	if nodePingInfo == nil {
+		return nil, errors.New(fmt.Sprintf("net/cluster/discover.PingNode - Unable to parse ping answer from node %s:%v", ipAddress, port))
+	}
+	nodePingInfo.IpAddress = ipAddress
+	nodePingInfo.Port = port
+	nodePingInfo.Time = time.Now()
+	nodePingInfo.Answer = answer
+	return nodePingInfo, nil
+}
+
+func RequireServiceInfo(nodesInfoList []types.NodePingInfo, timeout time.Duration, tlsConfig *tls.Config) ([]types.Node, error) {
+	return RequireServiceInfoContext(context.Background(), nodesInfoList, timeout, tlsConfig)
+}
+
+// Collect node information and services of the pinged nodes as RequireServiceInfo does, until the context
+// is done: the nodes collected before the cancellation are returned with the context error
+func RequireServiceInfoContext(ctx context.Context, nodesInfoList []types.NodePingInfo, timeout time.Duration, tlsConfig *tls.Config) ([]types.Node, error) {
 	var err error
 	defer func() {
 		if r := recover(); r != nil {
-			err = errors.New(fmt.Sprintf("net/cluster/discover.DiscoverNodes - Unable to discover nodes, Details: %v", r))
+			err = errors.New(fmt.Sprintf("net/cluster/discover.RequireServiceInfo - Unable to connect given nodes, Details: %v", r))
 		}
 	}()
-	client := http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: tlsConfig,
-		},
-		Timeout: timeout,
+	client := newClient(timeout, tlsConfig)
+	defer client.CloseIdleConnections()
+	var nodes = make([]*types.Node, len(nodesInfoList))
+	workers := pool.NewWorkerPool(DEFAULT_SCAN_WORKERS, nil)
+	for idx := range nodesInfoList {
+		position := idx
+		if errS := workers.Submit(ctx, func() {
+			if node, errN := requireNodeInfo(ctx, client, nodesInfoList[position], tlsConfig != nil); errN == nil {
+				nodes[position] = node
+			}
+		}); errS != nil {
+			break
+		}
+	}
+	workers.Wait()
+	var out = make([]types.Node, 0)
+	for _, node := range nodes {
+		if node != nil {
+			out = append(out, *node)
+		}
 	}
-	proto := "http"
-	if tlsConfig != nil {
-		proto = "https"
+	if err == nil {
+		err = ctx.Err()
 	}
-	defer func() {
-		time.Sleep(5 * time.Second)
-		client.CloseIdleConnections()
-	}()
-	for _, request := range requests {
-		ip := request.IpAddress
-		for _, port := range request.Ports {
-			addressPort := fmt.Sprintf("%s:%v", ip, port)
-			url := fmt.Sprintf("%s://%s/ping", proto, addressPort)
-			var nodePingInfo *types.NodePingInfo
-			init := time.Now()
-			response, err := client.Get(url)
-			answer := time.Now().Sub(init)
+	return out, err
+}
+
+// Collect node information and services of a single pinged node
+func RequireNodeInfo(nodePingInfo types.NodePingInfo, timeout time.Duration, tlsConfig *tls.Config) (*types.Node, error) {
+	return RequireNodeInfoContext(context.Background(), nodePingInfo, timeout, tlsConfig)
+}
+
+// Collect node information and services of a single pinged node, bounded by the context deadline and cancellation
+func RequireNodeInfoContext(ctx context.Context, nodePingInfo types.NodePingInfo, timeout time.Duration, tlsConfig *tls.Config) (*types.Node, error) {
+	client := newClient(timeout, tlsConfig)
+	defer client.CloseIdleConnections()
+	return requireNodeInfo(ctx, client, nodePingInfo, tlsConfig != nil)
+}
+
+func requireNodeInfo(ctx context.Context, client *http.Client, nodePingInfo types.NodePingInfo, secure bool) (*types.Node, error) {
+	service := NodeAddress(nodePingInfo.IpAddress, nodePingInfo.Port)
+	url := fmt.Sprintf("%s://%s/info", protocol(secure), service)
+	response, err := get(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+	var nodeInfo *types.NodeInfo
+	if response.StatusCode == 200 {
+		data, err := ioutil.ReadAll(response.Body)
+		if err == nil {
+			nodeInfo = parseNodeInfoWithAllFormats(data)
+		}
+	}
+	response.Body.Close()
+	var services = make([]types.Service, 0)
+	url2 := fmt.Sprintf("%s://%s/services", protocol(secure), service)
+	response2, err := get(ctx, client, url2)
+	if err == nil {
+		if response2.StatusCode == 200 {
+			data, err := ioutil.ReadAll(response2.Body)
 			if err == nil {
-				data, err := ioutil.ReadAll(response.Body)
-				if err == nil {
-					nodePingInfo = parseNodePingInfoWithAllFormats(data)
-					if nodePingInfo != nil {
-						nodePingInfo.IpAddress = ip
-						nodePingInfo.Port = port
-						nodePingInfo.Time = time.Now()
-						nodePingInfo.Answer = answer
-						out = append(out, *nodePingInfo)
-					}
-				}
+				services = append(services, parseServicesWithAllFormats(data)...)
 			}
 		}
+		response2.Body.Close()
 	}
-	return out, err
+	node := types.Node{
+		Name:      fmt.Sprintf("%s_%v", nodePingInfo.IpAddress, nodePingInfo.Port),
+		IpAddress: nodePingInfo.IpAddress,
+		Port:      nodePingInfo.Port,
+		Ports:     nodePingInfo.Ports,
+		Role:      nodePingInfo.Role,
+		Services:  services,
+		State:     nodePingInfo.State,
+		Active:    nodePingInfo.Active,
+		LastCheck: nodePingInfo.Time,
+	}
+	if nodeInfo != nil {
+		node.Info = *nodeInfo
+	}
+	return &node, nil
 }
 
-func RequireServiceInfo(nodesInfoList []types.NodePingInfo, timeout time.Duration, tlsConfig *tls.Config) ([]types.Node, error) {
-	var err error
-	var out = make([]types.Node, 0)
-	defer func() {
-		if r := recover(); r != nil {
-			err = errors.New(fmt.Sprintf("net/cluster/discover.RequireServiceInfo - Unable to connect given nodes, Details: %v", r))
-		}
-	}()
-	client := http.Client{
+// Host and port address of a node, IPv6 addresses are enclosed in square brackets
+func NodeAddress(ipAddress string, port int32) string {
+	return net.JoinHostPort(ipAddress, strconv.Itoa(int(port)))
+}
+
+// Sends a GET request bound to the context, reporting the context trace id
+func get(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	ncom.SetTraceIdHeader(request)
+	return client.Do(request)
+}
+
+func newClient(timeout time.Duration, tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
 		Transport: &http.Transport{
 			TLSClientConfig: tlsConfig,
 		},
 		Timeout: timeout,
 	}
-	defer func() {
-		time.Sleep(5 * time.Second)
-		client.CloseIdleConnections()
-	}()
-	proto := "http"
-	if tlsConfig != nil {
-		proto = "https"
-	}
-	for _, nodePingInfo := range nodesInfoList {
-		service := fmt.Sprintf("%s:%v", nodePingInfo.IpAddress, nodePingInfo.Port)
-		url := fmt.Sprintf("%s://%s/info", proto, service)
-		response, err := client.Get(url)
-		if err == nil {
-			var nodeInfo *types.NodeInfo
-			if response.StatusCode == 200 {
-				data, err := ioutil.ReadAll(response.Body)
-				if err == nil {
-					nodeInfo = parseNodeInfoWithAllFormats(data)
-				}
+}
 
-			}
-			var services = make([]types.Service, 0)
-			url2 := fmt.Sprintf("%s://%s/services", proto, service)
-			response2, err := client.Get(url2)
-			if err == nil {
-				if response2.StatusCode == 200 {
-					data, err := ioutil.ReadAll(response2.Body)
-					if err == nil {
-						services = append(services, parseServicesWithAllFormats(data)...)
-					}
-				}
-			}
-			node := types.Node{
-				Name:      fmt.Sprintf("%s_%v", nodePingInfo.IpAddress, nodePingInfo.Port),
-				IpAddress: nodePingInfo.IpAddress,
-				Port:      nodePingInfo.Port,
-				Ports:     nodePingInfo.Ports,
-				Role:      nodePingInfo.Role,
-				Services:  services,
-				State:     nodePingInfo.State,
-				Active:    nodePingInfo.Active,
-				LastCheck: nodePingInfo.Time,
-			}
-			if nodeInfo != nil {
-				node.Info = *nodeInfo
-			}
-			out = append(out, node)
-		}
+func protocol(secure bool) string {
+	if secure {
+		return "https"
 	}
-	return out, err
+	return "http"
 }
 
 var parsersCache = make([]io.FormatParser, 0)
+var parsersLock sync.Mutex
+
+// Format parser plugins, collected once and shared by the concurrent probes
+func cachedParsers() ([]io.FormatParser, error) {
+	parsersLock.Lock()
+	defer parsersLock.Unlock()
+	if len(parsersCache) > 0 {
+		return parsersCache, nil
+	}
+	parsers, err := io.CollectAllPlugins("", "")
+	if err != nil {
+		return nil, err
+	}
+	parsersCache = append(parsersCache, parsers...)
+	return parsersCache, nil
+}
 
 func parseNodePingInfoWithAllFormats(code []byte) *types.NodePingInfo {
 	var itfIn = types.NodePingInfo{}
-	itf, err := io.FromJsonCode(string(code), itfIn)
-	if err == nil {
-		var nodePingInfo = itf.(types.NodePingInfo)
-		return &nodePingInfo
+	if _, err := io.FromJsonCode(string(code), &itfIn); err == nil {
+		return &itfIn
 	}
-	itf, err = io.FromYamlCode(string(code), itfIn)
-	if err == nil {
-		var nodePingInfo = itf.(types.NodePingInfo)
-		return &nodePingInfo
+	if _, err := io.FromYamlCode(string(code), &itfIn); err == nil {
+		return &itfIn
 	}
-	itf, err = io.FromXmlCode(string(code), itfIn)
-	if err == nil {
-		var nodePingInfo = itf.(types.NodePingInfo)
-		return &nodePingInfo
+	if _, err := io.FromXmlCode(string(code), &itfIn); err == nil {
+		return &itfIn
 	}
-	var parsers = make([]io.FormatParser, 0)
-	if len(parsersCache) > 0 {
-		parsers = parsersCache
-	} else {
-		parsers, err = io.CollectAllPlugins("", "")
-		if err != nil {
-			return nil
-		}
-		parsersCache = append(parsersCache, parsers...)
+	parsers, err := cachedParsers()
+	if err != nil {
+		return nil
 	}
 	for _, parser := range parsers {
-		itf, err = parser.Unmashall(code, itfIn)
-		if err == nil {
-			var nodePingInfo = itf.(types.NodePingInfo)
-			return &nodePingInfo
+		if _, err = parser.Unmashall(code, &itfIn); err == nil {
+			return &itfIn
 		}
 	}
 	return nil
@@ -188,36 +258,22 @@ func parseNodePingInfoWithAllFormats(code []byte) *types.NodePingInfo {
 
 func parseServicesWithAllFormats(code []byte) []types.Service {
 	var itfIn = make([]types.Service, 0)
-	itf, err := io.FromJsonCode(string(code), itfIn)
-	if err == nil {
-		var services = itf.([]types.Service)
-		return services
+	if _, err := io.FromJsonCode(string(code), &itfIn); err == nil {
+		return itfIn
 	}
-	itf, err = io.FromYamlCode(string(code), itfIn)
-	if err == nil {
-		var services = itf.([]types.Service)
-		return services
+	if _, err := io.FromYamlCode(string(code), &itfIn); err == nil {
+		return itfIn
 	}
-	itf, err = io.FromXmlCode(string(code), itfIn)
-	if err == nil {
-		var services = itf.([]types.Service)
-		return services
+	if _, err := io.FromXmlCode(string(code), &itfIn); err == nil {
+		return itfIn
 	}
-	var parsers = make([]io.FormatParser, 0)
-	if len(parsersCache) > 0 {
-		parsers = parsersCache
-	} else {
-		parsers, err = io.CollectAllPlugins("", "")
-		if err != nil {
-			return nil
-		}
-		parsersCache = append(parsersCache, parsers...)
+	parsers, err := cachedParsers()
+	if err != nil {
+		return nil
 	}
 	for _, parser := range parsers {
-		itf, err = parser.Unmashall(code, itfIn)
-		if err == nil {
-			var services = itf.([]types.Service)
-			return services
+		if _, err = parser.Unmashall(code, &itfIn); err == nil {
+			return itfIn
 		}
 	}
 	return itfIn
@@ -225,36 +281,22 @@ func parseServicesWithAllFormats(code []byte) []types.Service {
 
 func parseNodeInfoWithAllFormats(code []byte) *types.NodeInfo {
 	var itfIn = types.NodeInfo{}
-	itf, err := io.FromJsonCode(string(code), itfIn)
-	if err == nil {
-		var nodeInfo = itf.(types.NodeInfo)
-		return &nodeInfo
+	if _, err := io.FromJsonCode(string(code), &itfIn); err == nil {
+		return &itfIn
 	}
-	itf, err = io.FromYamlCode(string(code), itfIn)
-	if err == nil {
-		var nodeInfo = itf.(types.NodeInfo)
-		return &nodeInfo
+	if _, err := io.FromYamlCode(string(code), &itfIn); err == nil {
+		return &itfIn
 	}
-	itf, err = io.FromXmlCode(string(code), itfIn)
-	if err == nil {
-		var nodeInfo = itf.(types.NodeInfo)
-		return &nodeInfo
+	if _, err := io.FromXmlCode(string(code), &itfIn); err == nil {
+		return &itfIn
 	}
-	var parsers = make([]io.FormatParser, 0)
-	if len(parsersCache) > 0 {
-		parsers = parsersCache
-	} else {
-		parsers, err = io.CollectAllPlugins("", "")
-		if err != nil {
-			return nil
-		}
-		parsersCache = append(parsersCache, parsers...)
+	parsers, err := cachedParsers()
+	if err != nil {
+		return nil
 	}
 	for _, parser := range parsers {
-		itf, err = parser.Unmashall(code, itfIn)
-		if err == nil {
-			var nodeInfo = itf.(types.NodeInfo)
-			return &nodeInfo
+		if _, err = parser.Unmashall(code, &itfIn); err == nil {
+			return &itfIn
 		}
 	}
 	return nil
@@ -0,0 +1,52 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryContext(t *testing.T) {
+	var traces = make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ping":
+			traces <- r.Header.Get(ncom.TRACE_ID_HEADER)
+			w.Write([]byte(fmt.Sprintf("{\"role\": %d, \"state\": %d, \"active\": true}", types.ROLE_SLAVE, types.NODE_STATE_RUNNING)))
+		case "/info":
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+	_, portText, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portText)
+
+	nodePingInfo, err := PingNodeContext(ncom.WithTraceId(context.Background(), "trace-1"), "127.0.0.1", int32(port), 10*time.Second, nil)
+	if err != nil || nodePingInfo.Role != types.ROLE_SLAVE {
+		t.Fatalf("TestDiscoveryContext - discovery.PingNodeContext - Expected: %v but Given: %v (%v)", "slave node", nodePingInfo, err)
+	}
+	if trace := <-traces; trace != "trace-1" {
+		t.Fatalf("TestDiscoveryContext - discovery.PingNodeContext - Expected: %v but Given: %v", "trace-1", trace)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := RequireNodeInfoContext(ctx, *nodePingInfo, 10*time.Second, nil); err == nil || time.Since(start) > 5*time.Second {
+		t.Fatalf("TestDiscoveryContext - discovery.RequireNodeInfoContext - Expected: %v but Given: %v after %v", "deadline error", err, time.Since(start))
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	found, err := PingNodesListContext(ctx, []types.NodeRequest{{IpAddress: "127.0.0.1", Ports: []int32{int32(port)}}}, time.Second, "tcp", nil)
+	if err != context.Canceled || len(found) != 0 {
+		t.Fatalf("TestDiscoveryContext - discovery.PingNodesListContext - Expected: %v but Given: %v (%v)", context.Canceled, found, err)
+	}
+}
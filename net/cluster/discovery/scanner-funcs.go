@@ -0,0 +1,176 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	"github.com/hellgate75/go-tcp-common/net/common"
+	"github.com/hellgate75/go-tcp-common/pool"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Spreads the probes over time, allowing at most rate probes per second
+type limiter struct {
+	sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func (l *limiter) wait(ctx context.Context) error {
+	if l.interval <= 0 {
+		return ctx.Err()
+	}
+	l.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.Unlock()
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newLimiter(rate float64) *limiter {
+	if rate <= 0 {
+		return &limiter{}
+	}
+	return &limiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+type scanner struct {
+	config ScanConfig
+	logger log.Logger
+}
+
+func (s *scanner) Scan(ctx context.Context, requests []types.NodeRequest) <-chan types.NodePingInfo {
+	var request, port int
+	return s.dispatch(ctx, func() (string, int32, bool) {
+		for request < len(requests) && port >= len(requests[request].Ports) {
+			request++
+			port = 0
+		}
+		if request >= len(requests) {
+			return "", 0, false
+		}
+		port++
+		return requests[request].IpAddress, requests[request].Ports[port-1], true
+	})
+}
+
+func (s *scanner) ScanNetwork(ctx context.Context, network *net.IPNet, ports types.Ports) <-chan types.NodePingInfo {
+	addresses := common.NewAddressIterator(network, false)
+	var ipAddress string
+	var port = ports.MaxPort
+	return s.dispatch(ctx, func() (string, int32, bool) {
+		if port >= ports.MaxPort {
+			ip, ok := addresses.Next()
+			if !ok || ports.MinPort > ports.MaxPort {
+				return "", 0, false
+			}
+			ipAddress = ip.String()
+			port = ports.MinPort - 1
+		}
+		port++
+		return ipAddress, port, true
+	})
+}
+
+// Probes the addresses returned by next using the workers pool, the addresses are produced
+// lazily so that large networks are never kept in memory
+func (s *scanner) dispatch(ctx context.Context, next func() (string, int32, bool)) <-chan types.NodePingInfo {
+	var out = make(chan types.NodePingInfo, s.config.Workers)
+	go func() {
+		defer close(out)
+		client := newClient(s.config.Timeout, s.config.TLSConfig)
+		defer client.CloseIdleConnections()
+		workers := pool.NewWorkerPool(s.config.Workers, s.logger)
+		limiter := newLimiter(s.config.Rate)
+		for ipAddress, port, ok := next(); ok; ipAddress, port, ok = next() {
+			if err := limiter.wait(ctx); err != nil {
+				break
+			}
+			nodeAddress := ipAddress
+			nodePort := port
+			err := workers.Submit(ctx, func() {
+				nodePingInfo, err := s.probe(ctx, client, nodeAddress, nodePort)
+				if err != nil {
+					if s.logger != nil {
+						s.logger.Tracef("net/cluster/discover.Scan - Address %s:%v discarded, Details: %s", nodeAddress, nodePort, err)
+					}
+					return
+				}
+				select {
+				case out <- *nodePingInfo:
+				case <-ctx.Done():
+				}
+			})
+			if err != nil {
+				break
+			}
+		}
+		workers.Wait()
+	}()
+	return out
+}
+
+// Checks the port accepts connections, before sending the ping request
+func (s *scanner) probe(ctx context.Context, client *http.Client, ipAddress string, port int32) (*types.NodePingInfo, error) {
+	dialer := &net.Dialer{Timeout: s.config.ConnectTimeout}
+	conn, err := dialer.DialContext(ctx, s.config.NetType, NodeAddress(ipAddress, port))
+	if err != nil {
+		return nil, err
+	}
+	conn.Close()
+	return pingNode(ctx, client, ipAddress, port, s.config.TLSConfig != nil)
+}
+
+// Creates a new discovery scanner
+func NewScanner(config ScanConfig, logger log.Logger) Scanner {
+	if config.Workers <= 0 {
+		config.Workers = DEFAULT_SCAN_WORKERS
+	}
+	if config.Rate == 0 {
+		config.Rate = DEFAULT_SCAN_RATE
+	}
+	if config.ConnectTimeout <= 0 {
+		config.ConnectTimeout = DEFAULT_CONNECT_TIMEOUT
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DEFAULT_PING_TIMEOUT
+	}
+	if "" == config.NetType {
+		config.NetType = "tcp"
+	}
+	if config.TLSPolicy != nil {
+		tlsConfig := &tls.Config{}
+		if config.TLSConfig != nil {
+			tlsConfig = config.TLSConfig.Clone()
+		}
+		if err := config.TLSPolicy.Apply(tlsConfig); err != nil {
+			if logger != nil {
+				logger.Errorf("Scanner - Invalid TLS policy, using the TLS configuration as is, Details: %s", err)
+			}
+		} else {
+			config.TLSConfig = tlsConfig
+		}
+	}
+	return &scanner{
+		config: config,
+		logger: logger,
+	}
+}
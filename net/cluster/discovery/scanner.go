@@ -0,0 +1,44 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	rcom "github.com/hellgate75/go-tcp-common/net/rest/common"
+	"net"
+	"time"
+)
+
+var (
+	// Default number of addresses probed together
+	DEFAULT_SCAN_WORKERS int = 64
+	// Default maximum number of probes started per second
+	DEFAULT_SCAN_RATE float64 = 500
+	// Default timeout of the tcp connection check preceding the ping request
+	DEFAULT_CONNECT_TIMEOUT time.Duration = time.Second
+	// Default timeout of the ping request
+	DEFAULT_PING_TIMEOUT time.Duration = 5 * time.Second
+)
+
+// Scanner configuration, zero values are replaced by the package defaults while
+// a negative Rate disables the rate limit. The TLSPolicy is applied to a copy of TLSConfig,
+// or to a new TLS configuration when TLSConfig is nil, and enables the https probes
+type ScanConfig struct {
+	Workers        int
+	Rate           float64
+	ConnectTimeout time.Duration
+	Timeout        time.Duration
+	NetType        string
+	TLSConfig      *tls.Config
+	TLSPolicy      *rcom.TLSPolicy
+}
+
+// Concurrent discovery of the cluster nodes: each address and port is checked with a tcp connection
+// before the ping request, answering nodes are sent on the returned channel as soon as they are found.
+// The channel is closed when the scan is complete or the context is done
+type Scanner interface {
+	// Scan the ports of the given addresses
+	Scan(ctx context.Context, requests []types.NodeRequest) <-chan types.NodePingInfo
+	// Scan a port range on all the network addresses
+	ScanNetwork(ctx context.Context, network *net.IPNet, ports types.Ports) <-chan types.NodePingInfo
+}
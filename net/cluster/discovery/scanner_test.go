@@ -0,0 +1,93 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func closedPort(t *testing.T) int32 {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return int32(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func TestScannerStreamsNodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ping" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(fmt.Sprintf("{\"role\": %d, \"state\": %d, \"active\": true}", types.ROLE_SLAVE, types.NODE_STATE_RUNNING)))
+	}))
+	defer server.Close()
+	_, portText, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portText)
+	var requests = []types.NodeRequest{
+		{IpAddress: "127.0.0.1", Ports: []int32{closedPort(t), int32(port), closedPort(t)}},
+	}
+	scanner := NewScanner(ScanConfig{Workers: 2, Rate: 100, Timeout: time.Second}, nil)
+	var found = make([]types.NodePingInfo, 0)
+	for nodePingInfo := range scanner.Scan(context.Background(), requests) {
+		found = append(found, nodePingInfo)
+	}
+	if len(found) != 1 || found[0].Port != int32(port) || found[0].Role != types.ROLE_SLAVE || !found[0].Active {
+		t.Fatalf("TestScannerStreamsNodes - Scanner.Scan - Expected: %v but Given: %v", "one slave node", found)
+	}
+}
+
+func TestScannerCancellation(t *testing.T) {
+	var ports = make([]int32, 0)
+	for i := 0; i < 1000; i++ {
+		ports = append(ports, closedPort(t))
+	}
+	scanner := NewScanner(ScanConfig{Workers: 4, Rate: 50}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	results := scanner.Scan(ctx, []types.NodeRequest{{IpAddress: "127.0.0.1", Ports: ports}})
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case _, ok := <-results:
+		if ok {
+			t.Fatalf("TestScannerCancellation - Scanner.Scan - Expected: %v but Given: %v", "no nodes", "a node")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("TestScannerCancellation - Scanner.Scan - Expected: %v but Given: %v", "closed channel", "open channel")
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	limiter := newLimiter(100)
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := limiter.wait(context.Background()); err != nil {
+			t.Fatalf("TestLimiter - limiter.wait - Expected: %v but Given: %v", nil, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("TestLimiter - limiter.wait - Expected: %v but Given: %v", ">= 50ms", elapsed)
+	}
+}
+
+func TestScannerNetwork(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fmt.Sprintf("{\"role\": %d, \"active\": true}", types.ROLE_MASTER)))
+	}))
+	defer server.Close()
+	_, portText, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(portText)
+	_, network, _ := net.ParseCIDR("127.0.0.1/32")
+	nodes, err := DiscoverNodes(network, time.Second, "", types.Ports{MinPort: int32(port), MaxPort: int32(port)}, nil)
+	if err != nil || len(nodes) != 1 || nodes[0].IpAddress != "127.0.0.1" || nodes[0].Role != types.ROLE_MASTER {
+		t.Fatalf("TestScannerNetwork - discovery.DiscoverNodes - Expected: %v but Given: %v (%v)", "one master node", nodes, err)
+	}
+}
@@ -0,0 +1,523 @@
+package cluster
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	cio "github.com/hellgate75/go-tcp-common/io"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/cluster/discovery"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	tm "github.com/hellgate75/go-tcp-common/time"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// Default interval between two leader checks
+	DEFAULT_ELECTION_INTERVAL time.Duration = 5 * time.Second
+	// Default time a bullied candidate waits for the coordinator announcement
+	DEFAULT_ELECTION_TIMEOUT time.Duration = 10 * time.Second
+	// Prefix of the paths used to exchange the election messages
+	DEFAULT_ELECTION_PATH_PREFIX string = "/election/"
+)
+
+// Election message, it describes the sender candidate or the announced leader
+type electionMessage struct {
+	Name      string         `yaml:"name,omitempty" json:"name,omitempty" xml:"name,omitempty"`
+	IpAddress string         `yaml:"ipAddress,omitempty" json:"ipAddress,omitempty" xml:"ip-address,omitempty"`
+	Port      int32          `yaml:"port,omitempty" json:"port,omitempty" xml:"port,omitempty"`
+	Role      types.NodeType `yaml:"role,omitempty" json:"role,omitempty" xml:"role,omitempty"`
+	Term      uint64         `yaml:"term,omitempty" json:"term,omitempty" xml:"term,omitempty"`
+}
+
+func (em electionMessage) node() types.Node {
+	return types.Node{
+		Name:      em.Name,
+		IpAddress: em.IpAddress,
+		Port:      em.Port,
+		Role:      em.Role,
+	}
+}
+
+// Action receiving an election message
+type electionAction struct {
+	election *election
+	receive  func(msg electionMessage) (int, string)
+}
+
+func (ea *electionAction) Run(Args ...interface{}) error {
+	if len(Args) < 2 {
+		return errors.New("Election.Action - Missing http request and response writer in arguments")
+	}
+	req, okR := Args[0].(*http.Request)
+	w, okW := Args[1].(http.ResponseWriter)
+	if !okR || !okW {
+		return errors.New(fmt.Sprintf("Election.Action - Invalid http request or response writer types: %T, %T", Args[0], Args[1]))
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		ncom.SubmitError(w, req, ncom.NewApiError(http.StatusBadRequest, "Unable to read election message", err.Error()), "")
+		return nil
+	}
+	var msg = electionMessage{}
+	if _, err = cio.Unmashall(data, &msg, ea.election.node.Format); err != nil {
+		ncom.SubmitError(w, req, ncom.NewApiError(http.StatusBadRequest, "Invalid election message", err.Error()), "")
+		return nil
+	}
+	if "" == msg.Name {
+		ncom.SubmitError(w, req, ncom.NewApiError(http.StatusBadRequest, "Invalid election message", "Missing candidate name"), "")
+		return nil
+	}
+	status, message := ea.receive(msg)
+	if status == http.StatusOK {
+		ncom.SubmitSuccess(w, message)
+	} else {
+		ncom.SubmitError(w, req, ncom.NewApiError(status, message), "")
+	}
+	return nil
+}
+
+// Bully election: a candidate challenges all eligible nodes with a higher name, and it becomes the leader
+// when none of them answers. The leader is announced to the eligible nodes as coordinator, while the other
+// nodes ask the eligible nodes for the current leader.
+type election struct {
+	sync.Mutex
+	node      *clusterNode
+	config    ElectionConfig
+	leader    *types.Node
+	term      uint64
+	electing  bool
+	announced chan struct{}
+	listeners map[string]LeadershipListener
+	tab       tm.CronTab
+	running   bool
+	logger    log.Logger
+}
+
+func (e *election) Start() error {
+	e.Lock()
+	defer e.Unlock()
+	if e.running {
+		return errors.New("Election.Start - Election already running!!")
+	}
+	e.tab = tm.NewCronTab("cluster-election", e.logger)
+	e.tab.AddJob(tm.NewCronJob("cluster-election-check", e.check, tm.CronData{
+		Interval: e.config.Interval,
+	}, e.logger))
+	if err := e.tab.Start(); err != nil {
+		return errors.New(fmt.Sprintf("Election.Start - Error: %s", err))
+	}
+	e.running = true
+	return nil
+}
+
+func (e *election) Stop() error {
+	e.Lock()
+	defer e.Unlock()
+	if !e.running {
+		return errors.New("Election.Stop - Election is not running!!")
+	}
+	e.running = false
+	e.tab.KillAllJobs()
+	if e.tab.IsRunning() {
+		return e.tab.Stop()
+	}
+	return nil
+}
+
+func (e *election) IsRunning() bool {
+	e.Lock()
+	defer e.Unlock()
+	return e.running
+}
+
+func (e *election) Elect() error {
+	e.Lock()
+	if e.electing {
+		e.Unlock()
+		return nil
+	}
+	e.electing = true
+	announced := make(chan struct{})
+	e.announced = announced
+	e.Unlock()
+	defer func() {
+		e.Lock()
+		e.electing = false
+		e.Unlock()
+	}()
+	self := e.node.local()
+	if !e.eligible(self.Role) {
+		return nil
+	}
+	var higher = make([]types.Node, 0)
+	for _, node := range e.candidates() {
+		if node.Name > self.Name {
+			higher = append(higher, node)
+		}
+	}
+	answers := e.send(higher, "elect", e.message(self))
+	if answers == 0 {
+		e.Lock()
+		e.term++
+		term := e.term
+		e.Unlock()
+		if e.accept(self, term) {
+			e.announce()
+		}
+		return nil
+	}
+	select {
+	case <-announced:
+		return nil
+	case <-time.After(e.config.ElectionTimeout):
+		return errors.New(fmt.Sprintf("Election.Elect - No coordinator announced by %v higher nodes", answers))
+	}
+}
+
+func (e *election) IsLeader() bool {
+	leader, ok := e.Leader()
+	return ok && leader.Name == e.node.local().Name
+}
+
+func (e *election) Leader() (types.Node, bool) {
+	e.Lock()
+	defer e.Unlock()
+	if e.leader == nil {
+		return types.Node{}, false
+	}
+	return *e.leader, true
+}
+
+func (e *election) Term() uint64 {
+	e.Lock()
+	defer e.Unlock()
+	return e.term
+}
+
+func (e *election) Subscribe(listener LeadershipListener) string {
+	id := ncom.GenerateSecureToken(8)
+	e.Lock()
+	e.listeners[id] = listener
+	e.Unlock()
+	return id
+}
+
+func (e *election) Unsubscribe(id string) bool {
+	e.Lock()
+	defer e.Unlock()
+	if _, ok := e.listeners[id]; ok {
+		delete(e.listeners, id)
+		return true
+	}
+	return false
+}
+
+// Verifies the current leader: the leader announces itself again, while the other nodes
+// start a new election when the leader is unknown or unreachable
+func (e *election) check() {
+	e.Lock()
+	electing := e.electing
+	e.Unlock()
+	if electing {
+		return
+	}
+	self := e.node.local()
+	leader, ok := e.Leader()
+	if ok && leader.Name == self.Name {
+		e.announce()
+		return
+	}
+	if ok {
+		_, err := discovery.PingNode(leader.IpAddress, leader.Port, e.config.Timeout, e.node.clientTLSConfig())
+		if err == nil {
+			return
+		}
+		if e.logger != nil {
+			e.logger.Warnf("Election.Check - Leader %s unreachable, Details: %s", leader.Name, err)
+		}
+	}
+	if e.eligible(self.Role) {
+		e.elect("Check")
+	} else {
+		e.follow()
+	}
+}
+
+// Announces the local node as coordinator to the other eligible nodes
+func (e *election) announce() {
+	self := e.node.local()
+	var nodes = make([]types.Node, 0)
+	for _, node := range e.candidates() {
+		if node.Name != self.Name {
+			nodes = append(nodes, node)
+		}
+	}
+	e.send(nodes, "coordinator", e.message(self))
+}
+
+// Asks the eligible nodes for the current leader, accepting the first one known
+func (e *election) follow() {
+	client := e.client()
+	defer client.CloseIdleConnections()
+	for _, node := range e.candidates() {
+		url := fmt.Sprintf("%s://%s%sleader", e.node.protocol(), discovery.NodeAddress(node.IpAddress, node.Port), DEFAULT_ELECTION_PATH_PREFIX)
+		response, err := client.Get(url)
+		if err != nil {
+			if e.logger != nil {
+				e.logger.Debugf("Election.Follow - Node %s unreachable, Details: %s", node.Name, err)
+			}
+			continue
+		}
+		data, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		var msg = electionMessage{}
+		if err != nil || response.StatusCode != http.StatusOK {
+			continue
+		}
+		if _, err = cio.Unmashall(data, &msg, e.node.Format); err != nil || "" == msg.Name {
+			continue
+		}
+		if e.accept(msg.node(), msg.Term) {
+			return
+		}
+	}
+}
+
+// Raises the local term to the given one, when it is higher
+func (e *election) observe(term uint64) {
+	e.Lock()
+	if term > e.term {
+		e.term = term
+	}
+	e.Unlock()
+}
+
+// Accepts a new leader, keeping the local node and the registry roles aligned. Leaders of a term
+// older than the local one are ignored and false is returned
+func (e *election) accept(leader types.Node, term uint64) bool {
+	leader.Role = types.ROLE_MASTER
+	e.Lock()
+	if term < e.term {
+		e.Unlock()
+		if e.logger != nil {
+			e.logger.Debugf("Election.Leader - Ignored leader %s of stale term %v, current term: %v", leader.Name, term, e.Term())
+		}
+		return false
+	}
+	e.term = term
+	previous := e.leader
+	e.leader = &leader
+	if e.announced != nil {
+		close(e.announced)
+		e.announced = nil
+	}
+	var listeners = make([]LeadershipListener, 0)
+	for _, listener := range e.listeners {
+		listeners = append(listeners, listener)
+	}
+	e.Unlock()
+	if previous != nil && previous.Name == leader.Name {
+		return true
+	}
+	self := e.node.local()
+	isLeader := leader.Name == self.Name
+	if isLeader {
+		e.node.setRole(types.ROLE_MASTER)
+	} else if self.Role == types.ROLE_MASTER {
+		e.node.setRole(types.ROLE_CCORDINATOR)
+	}
+	e.updateRole(leader.Name, types.ROLE_MASTER)
+	if previous != nil {
+		e.updateRole(previous.Name, types.ROLE_CCORDINATOR)
+	}
+	if e.logger != nil {
+		e.logger.Infof("Election.Leader - Node %s is the cluster leader, term: %v", leader.Name, term)
+	}
+	for _, listener := range listeners {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					e.logError("Election.Leader - Listener failure, Details: %v", r)
+				}
+			}()
+			listener(leader, isLeader)
+		}()
+	}
+	return true
+}
+
+func (e *election) receiveElect(msg electionMessage) (int, string) {
+	self := e.node.local()
+	if !e.eligible(self.Role) || self.Name <= msg.Name {
+		return http.StatusConflict, fmt.Sprintf("Node %s is not a higher candidate", self.Name)
+	}
+	e.observe(msg.Term)
+	go e.elect("Elect")
+	return http.StatusOK, "OK"
+}
+
+func (e *election) receiveCoordinator(msg electionMessage) (int, string) {
+	self := e.node.local()
+	if e.eligible(self.Role) && self.Name > msg.Name {
+		// the new election term must be higher than the one of the announced coordinator
+		e.observe(msg.Term)
+		go e.elect("Coordinator")
+		return http.StatusConflict, fmt.Sprintf("Node %s is a higher candidate", self.Name)
+	}
+	if !e.accept(msg.node(), msg.Term) {
+		return http.StatusConflict, fmt.Sprintf("Coordinator %s term %v is older than the current term %v", msg.Name, msg.Term, e.Term())
+	}
+	return http.StatusOK, "OK"
+}
+
+func (e *election) leaderMessage() interface{} {
+	leader, ok := e.Leader()
+	if !ok {
+		return electionMessage{}
+	}
+	return e.message(leader)
+}
+
+func (e *election) message(node types.Node) electionMessage {
+	return electionMessage{
+		Name:      node.Name,
+		IpAddress: node.IpAddress,
+		Port:      node.Port,
+		Role:      node.Role,
+		Term:      e.Term(),
+	}
+}
+
+// Eligible registry nodes not known as unreachable
+func (e *election) candidates() []types.Node {
+	var out = make([]types.Node, 0)
+	for _, node := range e.node._registry.List() {
+		if e.eligible(node.Role) && node.State != types.NODE_STATE_UNRACJABLE {
+			out = append(out, node)
+		}
+	}
+	return out
+}
+
+func (e *election) eligible(role types.NodeType) bool {
+	for _, eligible := range e.config.EligibleRoles {
+		if eligible == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Sends the message to the given nodes in parallel, returning the number of successful answers
+func (e *election) send(nodes []types.Node, operation string, msg electionMessage) int {
+	data, err := cio.Marshall(msg, e.node.Format)
+	if err != nil {
+		e.logError("Election.Send - Unable to encode message, Details: %s", err)
+		return 0
+	}
+	client := e.client()
+	defer client.CloseIdleConnections()
+	var answers int
+	var group sync.WaitGroup
+	var mutex sync.Mutex
+	for _, node := range nodes {
+		group.Add(1)
+		go func(node types.Node) {
+			defer group.Done()
+			url := fmt.Sprintf("%s://%s%s%s", e.node.protocol(), discovery.NodeAddress(node.IpAddress, node.Port), DEFAULT_ELECTION_PATH_PREFIX, operation)
+			response, errP := client.Post(url, string(ncom.FormatMimeType(e.node.Format)), bytes.NewReader(data))
+			if errP != nil {
+				if e.logger != nil {
+					e.logger.Debugf("Election.Send - Node %s unreachable, Details: %s", node.Name, errP)
+				}
+				return
+			}
+			ioutil.ReadAll(response.Body)
+			response.Body.Close()
+			if response.StatusCode == http.StatusOK {
+				mutex.Lock()
+				answers++
+				mutex.Unlock()
+			}
+		}(node)
+	}
+	group.Wait()
+	return answers
+}
+
+func (e *election) client() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: e.node.clientTLSConfig(),
+		},
+		Timeout: e.config.Timeout,
+	}
+}
+
+func (e *election) updateRole(name string, role types.NodeType) {
+	filter := NodeNameFilter(name)
+	nodes, err := e.node._registry.Recover("Name", filter)
+	if err != nil || len(nodes) == 0 {
+		return
+	}
+	node := *nodes[0]
+	node.Role = role
+	if err = e.node._registry.Update("Name", filter, node); err != nil {
+		e.logError("Election.Leader - Unable to update role of node %s, Details: %s", name, err)
+	}
+}
+
+// Runs an election, logging the failure
+func (e *election) elect(source string) {
+	if err := e.Elect(); err != nil {
+		e.logError("Election.%s - Election failed, Details: %s", source, err)
+	}
+}
+
+func (e *election) logError(format string, in ...interface{}) {
+	if e.logger != nil {
+		e.logger.Errorf(format, in...)
+	}
+}
+
+// Creates a new bully election on a node created by NewClusterNode, exposing the election endpoints
+// on the node Api Server. Zero configuration values are replaced by the package defaults
+func NewElection(node ClusterNode, config ElectionConfig, logger log.Logger) (Election, error) {
+	cn, ok := node.(*clusterNode)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Election.New - Unsupported cluster node type: %T", node))
+	}
+	if config.Interval <= 0 {
+		config.Interval = DEFAULT_ELECTION_INTERVAL
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = cn.Timeout
+	}
+	if config.ElectionTimeout <= 0 {
+		config.ElectionTimeout = DEFAULT_ELECTION_TIMEOUT
+	}
+	if len(config.EligibleRoles) == 0 {
+		config.EligibleRoles = []types.NodeType{types.ROLE_MASTER, types.ROLE_CCORDINATOR}
+	}
+	e := &election{
+		node:      cn,
+		config:    config,
+		listeners: make(map[string]LeadershipListener),
+		logger:    logger,
+	}
+	post := ncom.REST_METHOD_POST
+	get := ncom.REST_METHOD_GET
+	mimeType := ncom.FormatMimeType(cn.Format)
+	cn._apiServer.AddApiAction(DEFAULT_ELECTION_PATH_PREFIX+"elect", &electionAction{election: e, receive: e.receiveElect},
+		true, &post, &mimeType, &mimeType)
+	cn._apiServer.AddApiAction(DEFAULT_ELECTION_PATH_PREFIX+"coordinator", &electionAction{election: e, receive: e.receiveCoordinator},
+		true, &post, &mimeType, &mimeType)
+	cn._apiServer.AddApiAction(DEFAULT_ELECTION_PATH_PREFIX+"leader", &nodeAction{node: cn, answer: e.leaderMessage},
+		true, &get, &mimeType, &mimeType)
+	return e, nil
+}
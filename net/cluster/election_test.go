@@ -0,0 +1,141 @@
+package cluster
+
+import (
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func freePort(t *testing.T) int32 {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return int32(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func waitListening(t *testing.T, nodes []types.Node) {
+	for _, node := range nodes {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			conn, err := net.DialTimeout("tcp", node.IpAddress+":"+strconv.Itoa(int(node.Port)), 100*time.Millisecond)
+			if err == nil {
+				conn.Close()
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("waitListening - net.Dial - Expected: %v but Given: %v", "listening node", err)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func checkAll(elections []Election) {
+	var group sync.WaitGroup
+	for _, e := range elections {
+		group.Add(1)
+		go func(e Election) {
+			defer group.Done()
+			e.(*election).check()
+		}(e)
+	}
+	group.Wait()
+}
+
+func TestElection(t *testing.T) {
+	logger := log.NewLogger("election-test", log.FATAL)
+	var specs = []types.Node{
+		{Name: "node-a", Role: types.ROLE_MASTER},
+		{Name: "node-b", Role: types.ROLE_CCORDINATOR},
+		{Name: "node-c", Role: types.ROLE_MASTER},
+		{Name: "node-d", Role: types.ROLE_SLAVE},
+	}
+	for idx := range specs {
+		specs[idx].IpAddress = "127.0.0.1"
+		specs[idx].Port = freePort(t)
+	}
+	var nodes = make([]ClusterNode, 0)
+	var elections = make([]Election, 0)
+	for _, spec := range specs {
+		registry := NewInMemoryClusterRegistry()
+		for _, other := range specs {
+			if other.Name != spec.Name {
+				node := other
+				registry.Register(&node)
+			}
+		}
+		node := NewClusterNode(spec.Name, spec.Role, registry, "", nil, logger)
+		e, err := NewElection(node, ElectionConfig{Timeout: time.Second, ElectionTimeout: 2 * time.Second}, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go node.Listen(spec.IpAddress, spec.Port)
+		nodes = append(nodes, node)
+		elections = append(elections, e)
+	}
+	defer func() {
+		for _, node := range nodes {
+			node.Stop()
+		}
+	}()
+	waitListening(t, specs)
+	var changes = make(chan string, 16)
+	elections[0].Subscribe(func(leader types.Node, isLeader bool) {
+		changes <- leader.Name
+	})
+
+	checkAll(elections[:3])
+	// the slave node is not announced, it asks the eligible nodes for the leader
+	elections[3].(*election).check()
+	for idx, e := range elections {
+		leader, ok := e.Leader()
+		if !ok || leader.Name != "node-c" {
+			t.Fatalf("TestElection - Election.Leader - Expected: %v but Given: %v", "node-c", leader.Name)
+		}
+		if e.IsLeader() != (idx == 2) {
+			t.Fatalf("TestElection - Election.IsLeader - Expected: %v but Given: %v", idx == 2, e.IsLeader())
+		}
+	}
+	if role := nodes[0].(*clusterNode).local().Role; role != types.ROLE_CCORDINATOR {
+		t.Fatalf("TestElection - ClusterNode.Role - Expected: %v but Given: %v", types.ROLE_CCORDINATOR, role)
+	}
+
+	if status, _ := elections[0].(*election).receiveCoordinator(electionMessage{Name: "node-z", Term: 0}); status != http.StatusConflict {
+		t.Fatalf("TestElection - Election.Coordinator - Expected: %v but Given: %v", http.StatusConflict, status)
+	}
+	if leader, _ := elections[0].Leader(); leader.Name != "node-c" {
+		t.Fatalf("TestElection - Election.Leader - Expected: %v but Given: %v", "node-c", leader.Name)
+	}
+
+	nodes[2].Stop()
+	checkAll([]Election{elections[0], elections[1]})
+	elections[3].(*election).check()
+	for _, idx := range []int{0, 1, 3} {
+		leader, _ := elections[idx].Leader()
+		if leader.Name != "node-b" {
+			t.Fatalf("TestElection - Election.Leader - Expected: %v but Given: %v", "node-b", leader.Name)
+		}
+	}
+	registered, _ := nodes[0].(*clusterNode)._registry.Recover("Name", NodeNameFilter("node-b"))
+	if len(registered) != 1 || registered[0].Role != types.ROLE_MASTER {
+		t.Fatalf("TestElection - ClusterRegistry.Role - Expected: %v but Given: %v", types.ROLE_MASTER, registered)
+	}
+	registered, _ = nodes[0].(*clusterNode)._registry.Recover("Name", NodeNameFilter("node-c"))
+	if len(registered) != 1 || registered[0].Role != types.ROLE_CCORDINATOR {
+		t.Fatalf("TestElection - ClusterRegistry.Role - Expected: %v but Given: %v", types.ROLE_CCORDINATOR, registered)
+	}
+	var last string
+	for len(changes) > 0 {
+		last = <-changes
+	}
+	if last != "node-b" {
+		t.Fatalf("TestElection - Election.Subscribe - Expected: %v but Given: %v", "node-b", last)
+	}
+}
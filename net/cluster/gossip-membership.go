@@ -0,0 +1,179 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/cluster/gossip"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type gossipMembership struct {
+	sync.Mutex
+	registry     ClusterRegistry
+	gossip       gossip.Gossip
+	subscription string
+	listeners    map[string]MembershipListener
+	logger       log.Logger
+}
+
+func (m *gossipMembership) Start() error {
+	if err := m.gossip.Start(); err != nil {
+		return err
+	}
+	m.Lock()
+	m.subscription = m.gossip.Subscribe(m.onEvent)
+	m.Unlock()
+	return nil
+}
+
+func (m *gossipMembership) Stop() error {
+	m.Lock()
+	m.gossip.Unsubscribe(m.subscription)
+	m.Unlock()
+	if err := m.gossip.Leave(); err != nil {
+		return err
+	}
+	return m.gossip.Stop()
+}
+
+func (m *gossipMembership) IsRunning() bool {
+	return m.gossip.IsRunning()
+}
+
+// Reconciles the registry with the current gossip member list
+func (m *gossipMembership) Heartbeat() {
+	local := m.gossip.LocalMember().Name
+	for _, member := range m.gossip.Members() {
+		if member.Name == local {
+			continue
+		}
+		switch member.State {
+		case gossip.MEMBER_STATE_DEAD, gossip.MEMBER_STATE_LEFT:
+			m.registry.Remove("Name", NodeNameFilter(member.Name))
+		default:
+			m.store(member.Node())
+		}
+	}
+}
+
+// Joins the gossip cluster through the given seed node, listening for gossip on the same port as the local node.
+// The node Port is its API port, so seeds with a different gossip port must be joined with JoinAddress
+func (m *gossipMembership) Join(n *types.Node) error {
+	if n == nil {
+		return errors.New("GossipMembership.Join - Nil node reference")
+	}
+	return m.JoinAddress(net.JoinHostPort(n.IpAddress, strconv.Itoa(int(m.gossip.LocalMember().GossipPort))))
+}
+
+func (m *gossipMembership) JoinAddress(address string) error {
+	if _, err := m.gossip.Join(address); err != nil {
+		return errors.New(fmt.Sprintf("GossipMembership.JoinAddress - Unable to join %s, Details: %s", address, err))
+	}
+	return nil
+}
+
+func (m *gossipMembership) Address() string {
+	return m.gossip.Address()
+}
+
+// Only the local node can leave the gossip cluster, other nodes are declared dead by the failure detector
+func (m *gossipMembership) Leave(name string) error {
+	if name != m.gossip.LocalMember().Name {
+		return errors.New(fmt.Sprintf("GossipMembership.Leave - Only the local node can leave the cluster, given: %s", name))
+	}
+	return m.gossip.Leave()
+}
+
+func (m *gossipMembership) Subscribe(listener MembershipListener) string {
+	id := ncom.GenerateSecureToken(8)
+	m.Lock()
+	m.listeners[id] = listener
+	m.Unlock()
+	return id
+}
+
+func (m *gossipMembership) Unsubscribe(id string) bool {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.listeners[id]; ok {
+		delete(m.listeners, id)
+		return true
+	}
+	return false
+}
+
+func (m *gossipMembership) onEvent(event gossip.Event) {
+	node := event.Member.Node()
+	var previous = types.NODE_STATE_UNKNOWN
+	if nodes, err := m.registry.Recover("Name", NodeNameFilter(node.Name)); err == nil && len(nodes) > 0 {
+		previous = nodes[0].State
+	}
+	var eventType MembershipEventType
+	switch event.Type {
+	case gossip.EVENT_MEMBER_JOINED:
+		eventType = EVENT_NODE_JOINED
+		m.store(node)
+	case gossip.EVENT_MEMBER_FAILED, gossip.EVENT_MEMBER_LEFT:
+		eventType = EVENT_NODE_LEFT
+		if err := m.registry.Remove("Name", NodeNameFilter(node.Name)); err != nil && m.logger != nil {
+			m.logger.Errorf("GossipMembership.Event - Unable to remove node %s, Details: %s", node.Name, err)
+		}
+	default:
+		eventType = EVENT_NODE_STATE_CHANGED
+		m.store(node)
+	}
+	membershipEvent := MembershipEvent{
+		Type:          eventType,
+		Node:          node,
+		PreviousState: previous,
+		State:         node.State,
+		Time:          event.Time,
+	}
+	m.Lock()
+	var listeners = make([]MembershipListener, 0)
+	for _, listener := range m.listeners {
+		listeners = append(listeners, listener)
+	}
+	m.Unlock()
+	for _, listener := range listeners {
+		func() {
+			defer func() {
+				if r := recover(); r != nil && m.logger != nil {
+					m.logger.Errorf("GossipMembership.Event - Listener failure on event %s, Details: %v", membershipEvent, r)
+				}
+			}()
+			listener(membershipEvent)
+		}()
+	}
+}
+
+func (m *gossipMembership) store(node types.Node) {
+	filter := NodeNameFilter(node.Name)
+	var err error
+	if nodes, errR := m.registry.Recover("Name", filter); errR == nil && len(nodes) > 0 {
+		err = m.registry.Update("Name", filter, node)
+	} else {
+		node.LastCheck = time.Now()
+		err = m.registry.Register(&node)
+	}
+	if err != nil && m.logger != nil {
+		m.logger.Errorf("GossipMembership.Event - Unable to store node %s, Details: %s", node.Name, err)
+	}
+}
+
+// Creates a new gossip based membership, keeping the registry aligned with the gossip member list.
+// It is an alternative to the heartbeat membership and to the CIDR scan discovery, for larger clusters
+func NewGossipMembership(registry ClusterRegistry, config gossip.Config, logger log.Logger) GossipMembership {
+	return &gossipMembership{
+		registry:  registry,
+		gossip:    gossip.NewGossip(config, logger),
+		listeners: make(map[string]MembershipListener),
+		logger:    logger,
+	}
+}
@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/cluster/gossip"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGossipMembership(t *testing.T) {
+	var registries = make([]ClusterRegistry, 0)
+	var members = make([]GossipMembership, 0)
+	for i := 0; i < 2; i++ {
+		registry := NewInMemoryClusterRegistry()
+		m := NewGossipMembership(registry, gossip.Config{
+			Name:             fmt.Sprintf("node-%v", i),
+			BindAddress:      "127.0.0.1",
+			Port:             int32(9000 + i),
+			Role:             types.ROLE_SLAVE,
+			ProbeInterval:    100 * time.Millisecond,
+			ProbeTimeout:     40 * time.Millisecond,
+			SuspicionTimeout: 300 * time.Millisecond,
+			GossipInterval:   50 * time.Millisecond,
+		}, nil)
+		if err := m.Start(); err != nil {
+			t.Fatalf("TestGossipMembership - GossipMembership.Start - Expected: %v but Given: %v", nil, err)
+		}
+		defer func() {
+			if m.IsRunning() {
+				m.Stop()
+			}
+		}()
+		registries = append(registries, registry)
+		members = append(members, m)
+	}
+	var lock sync.Mutex
+	var events = make([]MembershipEvent, 0)
+	id := members[0].Subscribe(func(event MembershipEvent) {
+		lock.Lock()
+		events = append(events, event)
+		lock.Unlock()
+	})
+	hasEvent := func(eventType MembershipEventType) bool {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, event := range events {
+			if event.Type == eventType && event.Node.Name == "node-1" {
+				return true
+			}
+		}
+		return false
+	}
+	recovered := func() []*types.Node {
+		nodes, _ := registries[0].Recover("Name", NodeNameFilter("node-1"))
+		return nodes
+	}
+	waitFor := func(condition func() bool) bool {
+		limit := time.Now().Add(5 * time.Second)
+		for time.Now().Before(limit) {
+			if condition() {
+				return true
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return condition()
+	}
+
+	if err := members[1].Join(nil); err == nil {
+		t.Fatalf("TestGossipMembership - GossipMembership.Join - Expected: %v but Given: %v", "nil node error", err)
+	}
+	if err := members[1].JoinAddress(members[0].Address()); err != nil {
+		t.Fatalf("TestGossipMembership - GossipMembership.JoinAddress - Expected: %v but Given: %v", nil, err)
+	}
+	if !waitFor(func() bool { return hasEvent(EVENT_NODE_JOINED) && len(recovered()) == 1 }) {
+		t.Fatalf("TestGossipMembership - GossipMembership.Event - Expected: %v but Given: %v", "node-1 joined", recovered())
+	}
+	if node := recovered()[0]; node.Port != 9001 || !node.Active || node.State != types.NODE_STATE_RUNNING {
+		t.Fatalf("TestGossipMembership - GossipMembership.Event - Expected: %v but Given: %+v", "active node-1 with API port 9001", node)
+	}
+
+	registries[0].Remove("Name", NodeNameFilter("node-1"))
+	members[0].Heartbeat()
+	if nodes := recovered(); len(nodes) != 1 || nodes[0].Port != 9001 {
+		t.Fatalf("TestGossipMembership - GossipMembership.Heartbeat - Expected: %v but Given: %v", "node-1 stored again", nodes)
+	}
+	members[0].Heartbeat()
+	if nodes := registries[0].List(); len(nodes) != 1 {
+		t.Fatalf("TestGossipMembership - GossipMembership.Heartbeat - Expected: %v but Given: %v", 1, len(nodes))
+	}
+
+	if err := members[0].Leave("node-1"); err == nil {
+		t.Fatalf("TestGossipMembership - GossipMembership.Leave - Expected: %v but Given: %v", "remote node error", err)
+	}
+	if err := members[1].Stop(); err != nil {
+		t.Fatalf("TestGossipMembership - GossipMembership.Stop - Expected: %v but Given: %v", nil, err)
+	}
+	if !waitFor(func() bool { return hasEvent(EVENT_NODE_LEFT) && len(recovered()) == 0 }) {
+		t.Fatalf("TestGossipMembership - GossipMembership.Event - Expected: %v but Given: %v", "node-1 left", recovered())
+	}
+	if !members[0].Unsubscribe(id) || members[0].Unsubscribe(id) {
+		t.Fatalf("TestGossipMembership - GossipMembership.Unsubscribe - Expected: %v but Given: %v", "single unsubscription", id)
+	}
+}
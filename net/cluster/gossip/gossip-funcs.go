@@ -0,0 +1,778 @@
+package gossip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"math"
+	"math/rand"
+	"net"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type messageType byte
+
+const (
+	messagePing messageType = iota + 1
+	messagePingReq
+	messageAck
+	messageDigest
+)
+
+const (
+	// Multiplier of the log10 of the cluster size, giving the number of times an update is piggy-backed
+	retransmitMultiplier = 4
+	// Maximum number of member updates piggy-backed on a probe message
+	maxPiggybackMembers = 8
+	// First and maximum wait after a failed receive, doubled at each consecutive failure
+	minReceiveBackoff = 10 * time.Millisecond
+	maxReceiveBackoff = 1 * time.Second
+)
+
+// Wire message, Seq correlates probes with acknowledges and digest pulls with their answers
+type message struct {
+	Type          messageType `json:"type"`
+	Seq           uint64      `json:"seq,omitempty"`
+	From          string      `json:"from,omitempty"`
+	Target        string      `json:"target,omitempty"`
+	TargetAddress string      `json:"targetAddress,omitempty"`
+	Pull          bool        `json:"pull,omitempty"`
+	Members       []Member    `json:"members,omitempty"`
+}
+
+type gossip struct {
+	sync.Mutex
+	config     Config
+	local      Member
+	members    map[string]*Member
+	order      []string
+	probeIdx   int
+	broadcasts map[string]int
+	handlers   map[uint64]func()
+	seq        uint64
+	listeners  map[string]Listener
+	conn       *net.UDPConn
+	random     *rand.Rand
+	running    bool
+	stop       chan struct{}
+	group      sync.WaitGroup
+	logger     log.Logger
+}
+
+func (g *gossip) Start() error {
+	g.Lock()
+	defer g.Unlock()
+	if g.running {
+		return errors.New("Gossip.Start - Gossip protocol already running!!")
+	}
+	address, err := net.ResolveUDPAddr("udp", net.JoinHostPort(g.config.BindAddress, strconv.Itoa(int(g.config.BindPort))))
+	if err != nil {
+		return errors.New(fmt.Sprintf("Gossip.Start - Error: %s", err))
+	}
+	conn, err := net.ListenUDP("udp", address)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Gossip.Start - Error: %s", err))
+	}
+	g.conn = conn
+	g.local.IpAddress = advertiseAddress(g.config)
+	g.local.GossipPort = int32(conn.LocalAddr().(*net.UDPAddr).Port)
+	if "" == g.local.Name {
+		g.local.Name = fmt.Sprintf("%s_%v", g.local.IpAddress, g.local.GossipPort)
+	}
+	if g.local.State == MEMBER_STATE_LEFT {
+		g.local.Incarnation++
+	}
+	g.local.State = MEMBER_STATE_ALIVE
+	g.local.LastUpdate = time.Now()
+	g.queue(g.local.Name)
+	g.running = true
+	g.stop = make(chan struct{})
+	g.group.Add(3)
+	go g.receiveLoop(conn, g.stop)
+	go g.probeLoop(g.stop)
+	go g.gossipLoop(g.stop)
+	return nil
+}
+
+func (g *gossip) Stop() error {
+	g.Lock()
+	if !g.running {
+		g.Unlock()
+		return errors.New("Gossip.Stop - Gossip protocol is not running!!")
+	}
+	g.running = false
+	close(g.stop)
+	err := g.conn.Close()
+	g.Unlock()
+	g.group.Wait()
+	return err
+}
+
+func (g *gossip) IsRunning() bool {
+	g.Lock()
+	defer g.Unlock()
+	return g.running
+}
+
+func (g *gossip) Join(addresses ...string) (int, error) {
+	if !g.IsRunning() {
+		return 0, errors.New("Gossip.Join - Gossip protocol is not running!!")
+	}
+	var answers = make(chan struct{}, len(addresses))
+	var sent int
+	for _, address := range addresses {
+		seq := g.nextSeq()
+		g.addHandler(seq, func() {
+			answers <- struct{}{}
+		}, 2*g.config.ProbeTimeout)
+		if err := g.sendTo(address, message{Type: messageDigest, Seq: seq, Pull: true, Members: g.digest()}); err != nil {
+			g.logError("Gossip.Join - Unable to contact seed %s, Details: %s", address, err)
+			continue
+		}
+		sent++
+	}
+	var count int
+	timeout := time.After(2 * g.config.ProbeTimeout)
+	for count < sent {
+		select {
+		case <-answers:
+			count++
+		case <-timeout:
+			sent = count
+		}
+	}
+	if count == 0 {
+		return 0, errors.New(fmt.Sprintf("Gossip.Join - No seed answered among: %v", addresses))
+	}
+	return count, nil
+}
+
+func (g *gossip) Leave() error {
+	g.Lock()
+	if !g.running {
+		g.Unlock()
+		return errors.New("Gossip.Leave - Gossip protocol is not running!!")
+	}
+	g.local.State = MEMBER_STATE_LEFT
+	g.local.Incarnation++
+	g.local.LastUpdate = time.Now()
+	g.queue(g.local.Name)
+	local := g.local
+	peers := g.selectMembers(len(g.members), "", MEMBER_STATE_ALIVE, MEMBER_STATE_SUSPECT)
+	g.Unlock()
+	for _, peer := range peers {
+		if err := g.sendTo(peer.Address(), message{Type: messageDigest, Members: []Member{local}}); err != nil {
+			g.logError("Gossip.Leave - Unable to notify member %s, Details: %s", peer.Name, err)
+		}
+	}
+	return nil
+}
+
+func (g *gossip) Address() string {
+	g.Lock()
+	defer g.Unlock()
+	return g.local.Address()
+}
+
+func (g *gossip) LocalMember() Member {
+	g.Lock()
+	defer g.Unlock()
+	return g.local
+}
+
+func (g *gossip) Members() []Member {
+	return g.listMembers(false)
+}
+
+func (g *gossip) AliveMembers() []Member {
+	return g.listMembers(true)
+}
+
+func (g *gossip) listMembers(aliveOnly bool) []Member {
+	g.Lock()
+	defer g.Unlock()
+	var out = make([]Member, 0)
+	if !aliveOnly || g.local.State == MEMBER_STATE_ALIVE {
+		out = append(out, g.local)
+	}
+	for _, member := range g.members {
+		if !aliveOnly || member.State == MEMBER_STATE_ALIVE {
+			out = append(out, *member)
+		}
+	}
+	return out
+}
+
+func (g *gossip) UpdateServices(services []types.Service) error {
+	g.Lock()
+	defer g.Unlock()
+	if g.local.State == MEMBER_STATE_LEFT {
+		return errors.New("Gossip.UpdateServices - Local member left the cluster")
+	}
+	g.local.Services = services
+	g.local.Incarnation++
+	g.local.LastUpdate = time.Now()
+	g.queue(g.local.Name)
+	return nil
+}
+
+func (g *gossip) Subscribe(listener Listener) string {
+	id := ncom.GenerateSecureToken(8)
+	g.Lock()
+	g.listeners[id] = listener
+	g.Unlock()
+	return id
+}
+
+func (g *gossip) Unsubscribe(id string) bool {
+	g.Lock()
+	defer g.Unlock()
+	if _, ok := g.listeners[id]; ok {
+		delete(g.listeners, id)
+		return true
+	}
+	return false
+}
+
+func (g *gossip) receiveLoop(conn *net.UDPConn, stop chan struct{}) {
+	defer g.group.Done()
+	buffer := make([]byte, g.config.MaxPacketSize)
+	var backoff time.Duration = 0
+	for {
+		n, from, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if errors.Is(err, net.ErrClosed) {
+				g.logError("Gossip.Receive - Connection closed, Details: %s", err)
+				return
+			}
+			g.logError("Gossip.Receive - Error: %s", err)
+			if backoff = backoff * 2; backoff < minReceiveBackoff {
+				backoff = minReceiveBackoff
+			} else if backoff > maxReceiveBackoff {
+				backoff = maxReceiveBackoff
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+		var msg message
+		if err = json.Unmarshal(buffer[:n], &msg); err != nil {
+			g.logError("Gossip.Receive - Invalid message from %s, Details: %s", from, err)
+			continue
+		}
+		g.handle(msg, from)
+	}
+}
+
+func (g *gossip) handle(msg message, from *net.UDPAddr) {
+	defer func() {
+		if r := recover(); r != nil {
+			g.logError("Gossip.Receive - Error handling message from %s, Details: %v", from, r)
+		}
+	}()
+	g.notify(g.merge(msg.Members)...)
+	switch msg.Type {
+	case messagePing:
+		if "" == msg.Target || msg.Target == g.LocalMember().Name {
+			g.send(from, message{Type: messageAck, Seq: msg.Seq, Members: g.piggyback()})
+		}
+	case messagePingReq:
+		seq := g.nextSeq()
+		g.addHandler(seq, func() {
+			g.send(from, message{Type: messageAck, Seq: msg.Seq, Members: g.piggyback()})
+		}, g.config.ProbeTimeout)
+		if err := g.sendTo(msg.TargetAddress, message{Type: messagePing, Seq: seq, Target: msg.Target, Members: g.piggyback()}); err != nil {
+			g.logError("Gossip.Receive - Unable to probe %s on behalf of %s, Details: %s", msg.Target, msg.From, err)
+		}
+	case messageAck:
+		g.fire(msg.Seq)
+	case messageDigest:
+		if msg.Pull {
+			g.send(from, message{Type: messageDigest, Seq: msg.Seq, Members: g.digest()})
+		} else if msg.Seq != 0 {
+			g.fire(msg.Seq)
+		}
+	}
+}
+
+func (g *gossip) probeLoop(stop chan struct{}) {
+	defer g.group.Done()
+	ticker := time.NewTicker(g.config.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			g.probe(stop)
+			g.reap()
+		}
+	}
+}
+
+// Probes the next member: a direct ping first, then indirect pings through random members
+// and finally the target is suspected if no acknowledge arrived within the probe interval
+func (g *gossip) probe(stop chan struct{}) {
+	target, ok := g.nextTarget()
+	if !ok {
+		return
+	}
+	acked := make(chan struct{}, 1)
+	seq := g.nextSeq()
+	g.addHandler(seq, func() {
+		select {
+		case acked <- struct{}{}:
+		default:
+		}
+	}, g.config.ProbeInterval)
+	if err := g.sendTo(target.Address(), message{Type: messagePing, Seq: seq, Target: target.Name, Members: g.piggyback()}); err != nil {
+		g.logError("Gossip.Probe - Unable to ping member %s, Details: %s", target.Name, err)
+	}
+	select {
+	case <-acked:
+		return
+	case <-stop:
+		return
+	case <-time.After(g.config.ProbeTimeout):
+	}
+	g.Lock()
+	peers := g.selectMembers(g.config.IndirectChecks, target.Name, MEMBER_STATE_ALIVE)
+	g.Unlock()
+	for _, peer := range peers {
+		msg := message{Type: messagePingReq, Seq: seq, Target: target.Name, TargetAddress: target.Address(), Members: g.piggyback()}
+		if err := g.sendTo(peer.Address(), msg); err != nil {
+			g.logError("Gossip.Probe - Unable to require indirect ping to member %s, Details: %s", peer.Name, err)
+		}
+	}
+	wait := g.config.ProbeInterval - g.config.ProbeTimeout
+	if wait < g.config.ProbeTimeout {
+		wait = g.config.ProbeTimeout
+	}
+	select {
+	case <-acked:
+	case <-stop:
+	case <-time.After(wait):
+		g.suspect(target.Name, target.Incarnation)
+	}
+}
+
+func (g *gossip) nextTarget() (Member, bool) {
+	g.Lock()
+	defer g.Unlock()
+	for attempt := 0; attempt < 2; attempt++ {
+		for g.probeIdx < len(g.order) {
+			name := g.order[g.probeIdx]
+			g.probeIdx++
+			if member, ok := g.members[name]; ok &&
+				(member.State == MEMBER_STATE_ALIVE || member.State == MEMBER_STATE_SUSPECT) {
+				return *member, true
+			}
+		}
+		g.order = make([]string, 0)
+		for name := range g.members {
+			g.order = append(g.order, name)
+		}
+		g.random.Shuffle(len(g.order), func(i, j int) {
+			g.order[i], g.order[j] = g.order[j], g.order[i]
+		})
+		g.probeIdx = 0
+	}
+	return Member{}, false
+}
+
+func (g *gossip) suspect(name string, incarnation uint64) {
+	g.Lock()
+	member, ok := g.members[name]
+	if !ok || member.Incarnation != incarnation || member.State != MEMBER_STATE_ALIVE {
+		g.Unlock()
+		return
+	}
+	member.State = MEMBER_STATE_SUSPECT
+	member.LastUpdate = time.Now()
+	g.queue(name)
+	event := newEvent(EVENT_MEMBER_SUSPECTED, *member)
+	g.Unlock()
+	g.notify(event)
+}
+
+// Declares dead the members suspected for longer than the suspicion timeout and forgets
+// the members dead or left for longer than the reclaim timeout
+func (g *gossip) reap() {
+	var events = make([]Event, 0)
+	now := time.Now()
+	g.Lock()
+	for name, member := range g.members {
+		elapsed := now.Sub(member.LastUpdate)
+		switch member.State {
+		case MEMBER_STATE_SUSPECT:
+			if elapsed >= g.config.SuspicionTimeout {
+				member.State = MEMBER_STATE_DEAD
+				member.LastUpdate = now
+				g.queue(name)
+				events = append(events, newEvent(EVENT_MEMBER_FAILED, *member))
+			}
+		case MEMBER_STATE_DEAD, MEMBER_STATE_LEFT:
+			if elapsed >= g.config.DeadReclaimTimeout {
+				delete(g.members, name)
+				delete(g.broadcasts, name)
+			}
+		}
+	}
+	g.Unlock()
+	g.notify(events...)
+}
+
+func (g *gossip) gossipLoop(stop chan struct{}) {
+	defer g.group.Done()
+	ticker := time.NewTicker(g.config.GossipInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			g.Lock()
+			peers := g.selectMembers(g.config.GossipFanout, "", MEMBER_STATE_ALIVE, MEMBER_STATE_SUSPECT)
+			g.Unlock()
+			for _, peer := range peers {
+				if err := g.sendTo(peer.Address(), message{Type: messageDigest, Pull: true, Members: g.digest()}); err != nil {
+					g.logError("Gossip.Gossip - Unable to send digest to member %s, Details: %s", peer.Name, err)
+				}
+			}
+		}
+	}
+}
+
+// Merges the remote member updates: higher incarnations always win, while on the same
+// incarnation suspect overrides alive and dead or left override both
+func (g *gossip) merge(updates []Member) []Event {
+	var events = make([]Event, 0)
+	if len(updates) == 0 {
+		return events
+	}
+	g.Lock()
+	defer g.Unlock()
+	now := time.Now()
+	for _, update := range updates {
+		if "" == update.Name {
+			continue
+		}
+		if update.Name == g.local.Name {
+			// Refute suspicions and deaths, or stale incarnations of a previous run
+			if g.local.State == MEMBER_STATE_ALIVE && (update.Incarnation > g.local.Incarnation ||
+				(update.Incarnation == g.local.Incarnation && update.State != MEMBER_STATE_ALIVE)) {
+				g.local.Incarnation = update.Incarnation + 1
+				g.local.LastUpdate = now
+				g.queue(g.local.Name)
+			}
+			continue
+		}
+		member, ok := g.members[update.Name]
+		if !ok {
+			if update.State == MEMBER_STATE_DEAD || update.State == MEMBER_STATE_LEFT {
+				continue
+			}
+			joined := update
+			joined.LastUpdate = now
+			g.members[joined.Name] = &joined
+			g.queue(joined.Name)
+			events = append(events, newEvent(EVENT_MEMBER_JOINED, joined))
+			continue
+		}
+		if !overrides(*member, update) {
+			continue
+		}
+		previous := *member
+		update.LastUpdate = now
+		*member = update
+		g.queue(update.Name)
+		if previous.State == MEMBER_STATE_DEAD || previous.State == MEMBER_STATE_LEFT {
+			if update.State == MEMBER_STATE_ALIVE || update.State == MEMBER_STATE_SUSPECT {
+				events = append(events, newEvent(EVENT_MEMBER_JOINED, update))
+			}
+			continue
+		}
+		switch {
+		case update.State == MEMBER_STATE_SUSPECT && previous.State != MEMBER_STATE_SUSPECT:
+			events = append(events, newEvent(EVENT_MEMBER_SUSPECTED, update))
+		case update.State == MEMBER_STATE_DEAD:
+			events = append(events, newEvent(EVENT_MEMBER_FAILED, update))
+		case update.State == MEMBER_STATE_LEFT:
+			events = append(events, newEvent(EVENT_MEMBER_LEFT, update))
+		case update.State == MEMBER_STATE_ALIVE && (previous.State != MEMBER_STATE_ALIVE || changed(previous, update)):
+			events = append(events, newEvent(EVENT_MEMBER_UPDATED, update))
+		}
+	}
+	return events
+}
+
+func overrides(current Member, update Member) bool {
+	if update.Incarnation != current.Incarnation {
+		return update.Incarnation > current.Incarnation
+	}
+	return stateRank(update.State) > stateRank(current.State)
+}
+
+func stateRank(state MemberState) int {
+	switch state {
+	case MEMBER_STATE_SUSPECT:
+		return 1
+	case MEMBER_STATE_DEAD:
+		return 2
+	case MEMBER_STATE_LEFT:
+		return 3
+	default:
+		return 0
+	}
+}
+
+func changed(previous Member, update Member) bool {
+	return previous.IpAddress != update.IpAddress || previous.Port != update.Port ||
+		previous.GossipPort != update.GossipPort || previous.Role != update.Role ||
+		!reflect.DeepEqual(previous.Services, update.Services)
+}
+
+// Queues a member update for piggy-backing, it must be called holding the lock
+func (g *gossip) queue(name string) {
+	limit := retransmitMultiplier * int(math.Ceil(math.Log10(float64(len(g.members)+2))))
+	g.broadcasts[name] = limit
+}
+
+// Member updates to piggy-back on the next message
+func (g *gossip) piggyback() []Member {
+	g.Lock()
+	defer g.Unlock()
+	var out = make([]Member, 0)
+	for name, left := range g.broadcasts {
+		if len(out) >= maxPiggybackMembers {
+			break
+		}
+		if name == g.local.Name {
+			out = append(out, g.local)
+		} else if member, ok := g.members[name]; ok {
+			out = append(out, *member)
+		}
+		if left <= 1 {
+			delete(g.broadcasts, name)
+		} else {
+			g.broadcasts[name] = left - 1
+		}
+	}
+	return out
+}
+
+// Full membership digest, including the local member and the dead or left ones
+func (g *gossip) digest() []Member {
+	g.Lock()
+	defer g.Unlock()
+	var out = make([]Member, 0, len(g.members)+1)
+	out = append(out, g.local)
+	for _, member := range g.members {
+		out = append(out, *member)
+	}
+	g.random.Shuffle(len(out)-1, func(i, j int) {
+		out[i+1], out[j+1] = out[j+1], out[i+1]
+	})
+	return out
+}
+
+// Random members in the given states, it must be called holding the lock
+func (g *gossip) selectMembers(count int, exclude string, states ...MemberState) []Member {
+	var candidates = make([]Member, 0)
+	for name, member := range g.members {
+		if name == exclude {
+			continue
+		}
+		for _, state := range states {
+			if member.State == state {
+				candidates = append(candidates, *member)
+				break
+			}
+		}
+	}
+	g.random.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if len(candidates) > count {
+		candidates = candidates[:count]
+	}
+	return candidates
+}
+
+func (g *gossip) nextSeq() uint64 {
+	return atomic.AddUint64(&g.seq, 1)
+}
+
+func (g *gossip) addHandler(seq uint64, handler func(), timeout time.Duration) {
+	g.Lock()
+	g.handlers[seq] = handler
+	g.Unlock()
+	time.AfterFunc(timeout, func() {
+		g.Lock()
+		delete(g.handlers, seq)
+		g.Unlock()
+	})
+}
+
+func (g *gossip) fire(seq uint64) {
+	g.Lock()
+	handler, ok := g.handlers[seq]
+	delete(g.handlers, seq)
+	g.Unlock()
+	if ok {
+		handler()
+	}
+}
+
+func (g *gossip) sendTo(address string, msg message) error {
+	to, err := net.ResolveUDPAddr("udp", address)
+	if err != nil {
+		return err
+	}
+	return g.send(to, msg)
+}
+
+// Sends a message, dropping piggy-backed members until it fits the maximum packet size
+func (g *gossip) send(to *net.UDPAddr, msg message) error {
+	g.Lock()
+	conn := g.conn
+	msg.From = g.local.Name
+	g.Unlock()
+	if conn == nil {
+		return errors.New("Gossip.Send - Gossip protocol is not running!!")
+	}
+	data, err := json.Marshal(msg)
+	for err == nil && len(data) > g.config.MaxPacketSize && len(msg.Members) > 0 {
+		msg.Members = msg.Members[:len(msg.Members)/2]
+		data, err = json.Marshal(msg)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = conn.WriteToUDP(data, to)
+	return err
+}
+
+func (g *gossip) notify(events ...Event) {
+	if len(events) == 0 {
+		return
+	}
+	g.Lock()
+	var listeners = make([]Listener, 0)
+	for _, listener := range g.listeners {
+		listeners = append(listeners, listener)
+	}
+	g.Unlock()
+	for _, event := range events {
+		if g.logger != nil {
+			g.logger.Debugf("Gossip.Event - %s %s", event.Type, event.Member)
+		}
+		for _, listener := range listeners {
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						g.logError("Gossip.Event - Listener failure on event %s, Details: %v", event.Type, r)
+					}
+				}()
+				listener(event)
+			}()
+		}
+	}
+}
+
+func (g *gossip) logError(format string, in ...interface{}) {
+	if g.logger != nil {
+		g.logger.Errorf(format, in...)
+	}
+}
+
+func newEvent(eventType EventType, member Member) Event {
+	return Event{
+		Type:   eventType,
+		Member: member,
+		Time:   time.Now(),
+	}
+}
+
+func advertiseAddress(config Config) string {
+	if "" != config.AdvertiseAddress {
+		return config.AdvertiseAddress
+	}
+	if ip := net.ParseIP(config.BindAddress); ip != nil && !ip.IsUnspecified() {
+		return ip.String()
+	} else if ip == nil && "" != config.BindAddress {
+		return config.BindAddress
+	}
+	if addresses, err := net.InterfaceAddrs(); err == nil {
+		for _, address := range addresses {
+			if ipNet, ok := address.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && ipNet.IP.To4() != nil {
+				return ipNet.IP.String()
+			}
+		}
+	}
+	return "127.0.0.1"
+}
+
+// Creates a new gossip protocol instance, zero configuration values are replaced by the package defaults
+func NewGossip(config Config, logger log.Logger) Gossip {
+	if config.ProbeInterval <= 0 {
+		config.ProbeInterval = DEFAULT_PROBE_INTERVAL
+	}
+	if config.ProbeTimeout <= 0 {
+		config.ProbeTimeout = DEFAULT_PROBE_TIMEOUT
+	}
+	if config.ProbeTimeout > config.ProbeInterval {
+		config.ProbeTimeout = config.ProbeInterval
+	}
+	if config.IndirectChecks <= 0 {
+		config.IndirectChecks = DEFAULT_INDIRECT_CHECKS
+	}
+	if config.SuspicionTimeout <= 0 {
+		config.SuspicionTimeout = DEFAULT_SUSPICION_TIMEOUT
+	}
+	if config.GossipInterval <= 0 {
+		config.GossipInterval = DEFAULT_GOSSIP_INTERVAL
+	}
+	if config.GossipFanout <= 0 {
+		config.GossipFanout = DEFAULT_GOSSIP_FANOUT
+	}
+	if config.DeadReclaimTimeout <= 0 {
+		config.DeadReclaimTimeout = DEFAULT_DEAD_RECLAIM_TIMEOUT
+	}
+	if config.MaxPacketSize <= 0 {
+		config.MaxPacketSize = DEFAULT_MAX_PACKET_SIZE
+	}
+	return &gossip{
+		config: config,
+		local: Member{
+			Name:     config.Name,
+			Port:     config.Port,
+			Role:     config.Role,
+			State:    MEMBER_STATE_ALIVE,
+			Services: config.Services,
+		},
+		members:    make(map[string]*Member),
+		order:      make([]string, 0),
+		broadcasts: make(map[string]int),
+		handlers:   make(map[uint64]func()),
+		listeners:  make(map[string]Listener),
+		random:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		logger:     logger,
+	}
+}
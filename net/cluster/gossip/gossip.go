@@ -0,0 +1,184 @@
+package gossip
+
+import (
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Gossip member state
+type MemberState byte
+
+// Gossip event type
+type EventType byte
+
+const (
+	MEMBER_STATE_ALIVE MemberState = iota + 1
+	MEMBER_STATE_SUSPECT
+	MEMBER_STATE_DEAD
+	MEMBER_STATE_LEFT
+)
+
+const (
+	EVENT_MEMBER_JOINED EventType = iota + 1
+	EVENT_MEMBER_UPDATED
+	EVENT_MEMBER_SUSPECTED
+	EVENT_MEMBER_FAILED
+	EVENT_MEMBER_LEFT
+)
+
+var (
+	// Default interval between two failure detector probes
+	DEFAULT_PROBE_INTERVAL time.Duration = 1 * time.Second
+	// Default timeout of a direct probe, before the indirect probes are started
+	DEFAULT_PROBE_TIMEOUT time.Duration = 500 * time.Millisecond
+	// Default number of members asked to probe a target indirectly
+	DEFAULT_INDIRECT_CHECKS int = 3
+	// Default time a member stays suspect before being declared dead
+	DEFAULT_SUSPICION_TIMEOUT time.Duration = 5 * time.Second
+	// Default interval between two membership digest exchanges
+	DEFAULT_GOSSIP_INTERVAL time.Duration = 200 * time.Millisecond
+	// Default number of random peers receiving the membership digest on each gossip round
+	DEFAULT_GOSSIP_FANOUT int = 3
+	// Default time dead and left members are kept in the member list
+	DEFAULT_DEAD_RECLAIM_TIMEOUT time.Duration = 30 * time.Second
+	// Default maximum size of a single gossip UDP packet
+	DEFAULT_MAX_PACKET_SIZE int = 65000
+)
+
+// Gossip cluster member, Port is the member cluster node (API) port and GossipPort the UDP port
+// used by the gossip protocol
+type Member struct {
+	Name        string          `yaml:"name,omitempty" json:"name,omitempty" xml:"name,omitempty"`
+	IpAddress   string          `yaml:"ipAddress,omitempty" json:"ipAddress,omitempty" xml:"ip-address,omitempty"`
+	Port        int32           `yaml:"port,omitempty" json:"port,omitempty" xml:"port,omitempty"`
+	GossipPort  int32           `yaml:"gossipPort,omitempty" json:"gossipPort,omitempty" xml:"gossip-port,omitempty"`
+	Role        types.NodeType  `yaml:"role,omitempty" json:"role,omitempty" xml:"role,omitempty"`
+	State       MemberState     `yaml:"state,omitempty" json:"state,omitempty" xml:"state,omitempty"`
+	Incarnation uint64          `yaml:"incarnation,omitempty" json:"incarnation,omitempty" xml:"incarnation,omitempty"`
+	Services    []types.Service `yaml:"services,omitempty" json:"services,omitempty" xml:"serviceGroup,omitempty"`
+	LastUpdate  time.Time       `yaml:"-" json:"-" xml:"-"`
+}
+
+// Gossip membership change event
+type Event struct {
+	Type   EventType
+	Member Member
+	Time   time.Time
+}
+
+// Listener of gossip membership change events
+type Listener func(event Event)
+
+// Gossip protocol configuration, zero values are replaced by the package defaults.
+// When AdvertiseAddress is empty the BindAddress is advertised, or the first non loopback
+// interface address when BindAddress is empty or unspecified
+type Config struct {
+	Name               string
+	BindAddress        string
+	BindPort           int32
+	AdvertiseAddress   string
+	Port               int32
+	Role               types.NodeType
+	Services           []types.Service
+	ProbeInterval      time.Duration
+	ProbeTimeout       time.Duration
+	IndirectChecks     int
+	SuspicionTimeout   time.Duration
+	GossipInterval     time.Duration
+	GossipFanout       int
+	DeadReclaimTimeout time.Duration
+	MaxPacketSize      int
+}
+
+// SWIM-style gossip membership: members are probed directly and indirectly through random peers,
+// while member state and services are spread by piggy-backing on probes and by periodic digest exchanges
+type Gossip interface {
+	// Bind the UDP port and start probing and gossiping
+	Start() error
+	// Stop the protocol and release the UDP port, without notifying the other members
+	Stop() error
+	IsRunning() bool
+	// Exchange the membership digest with the given seed addresses (host:port), returning the number
+	// of seeds that answered
+	Join(addresses ...string) (int, error)
+	// Notify the other members that the local member is leaving the cluster
+	Leave() error
+	// UDP address used by the protocol
+	Address() string
+	LocalMember() Member
+	// All known members, including the local one
+	Members() []Member
+	// Alive known members, including the local one
+	AliveMembers() []Member
+	// Replace the local member services, spreading the change to the cluster
+	UpdateServices(services []types.Service) error
+	Subscribe(listener Listener) string
+	Unsubscribe(id string) bool
+}
+
+func (ms MemberState) String() string {
+	switch ms {
+	case MEMBER_STATE_ALIVE:
+		return "Alive"
+	case MEMBER_STATE_SUSPECT:
+		return "Suspect"
+	case MEMBER_STATE_DEAD:
+		return "Dead"
+	case MEMBER_STATE_LEFT:
+		return "Left"
+	default:
+		return "Unknown"
+	}
+}
+
+func (et EventType) String() string {
+	switch et {
+	case EVENT_MEMBER_JOINED:
+		return "Joined"
+	case EVENT_MEMBER_UPDATED:
+		return "Updated"
+	case EVENT_MEMBER_SUSPECTED:
+		return "Suspected"
+	case EVENT_MEMBER_FAILED:
+		return "Failed"
+	case EVENT_MEMBER_LEFT:
+		return "Left"
+	default:
+		return "Unknown"
+	}
+}
+
+// Gossip UDP address of the member
+func (m Member) Address() string {
+	return net.JoinHostPort(m.IpAddress, strconv.Itoa(int(m.GossipPort)))
+}
+
+// Converts the member in a cluster node
+func (m Member) Node() types.Node {
+	state := types.NODE_STATE_RUNNING
+	if m.State != MEMBER_STATE_ALIVE {
+		state = types.NODE_STATE_UNRACJABLE
+	}
+	services := m.Services
+	if services == nil {
+		services = make([]types.Service, 0)
+	}
+	return types.Node{
+		Name:      m.Name,
+		IpAddress: m.IpAddress,
+		Port:      m.Port,
+		Role:      m.Role,
+		Services:  services,
+		Active:    m.State == MEMBER_STATE_ALIVE,
+		LastCheck: m.LastUpdate,
+		State:     state,
+	}
+}
+
+func (m Member) String() string {
+	return fmt.Sprintf("Member{Name: \"%s\", Address: \"%s\", State: \"%s\", Incarnation: %v}",
+		m.Name, m.Address(), m.State, m.Incarnation)
+}
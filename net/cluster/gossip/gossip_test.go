@@ -0,0 +1,157 @@
+package gossip
+
+import (
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	"net"
+	"testing"
+	"time"
+)
+
+func startCluster(t *testing.T, size int) []Gossip {
+	var nodes = make([]Gossip, 0)
+	for i := 0; i < size; i++ {
+		node := NewGossip(Config{
+			Name:             fmt.Sprintf("node-%v", i),
+			BindAddress:      "127.0.0.1",
+			ProbeInterval:    100 * time.Millisecond,
+			ProbeTimeout:     40 * time.Millisecond,
+			SuspicionTimeout: 300 * time.Millisecond,
+			GossipInterval:   50 * time.Millisecond,
+		}, nil)
+		if err := node.Start(); err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, node)
+	}
+	for _, node := range nodes[1:] {
+		if _, err := node.Join(nodes[0].Address()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return nodes
+}
+
+func stopCluster(nodes []Gossip) {
+	for _, node := range nodes {
+		if node.IsRunning() {
+			node.Stop()
+		}
+	}
+}
+
+func waitFor(timeout time.Duration, condition func() bool) bool {
+	limit := time.Now().Add(timeout)
+	for time.Now().Before(limit) {
+		if condition() {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return condition()
+}
+
+func memberState(node Gossip, name string) MemberState {
+	for _, member := range node.Members() {
+		if member.Name == name {
+			return member.State
+		}
+	}
+	return 0
+}
+
+func TestGossipConvergence(t *testing.T) {
+	nodes := startCluster(t, 8)
+	defer stopCluster(nodes)
+	converged := waitFor(5*time.Second, func() bool {
+		for _, node := range nodes {
+			if len(node.AliveMembers()) != len(nodes) {
+				return false
+			}
+		}
+		return true
+	})
+	if !converged {
+		t.Fatalf("TestGossipConvergence - Gossip.AliveMembers - Expected: %v but Given: %v", len(nodes), len(nodes[len(nodes)-1].AliveMembers()))
+	}
+
+	services := []types.Service{{Port: types.Port{Port: 9999, Description: "test", Type: types.PORT_TYPE_REST}}}
+	nodes[3].UpdateServices(services)
+	spread := waitFor(5*time.Second, func() bool {
+		for _, node := range nodes {
+			for _, member := range node.Members() {
+				if member.Name == "node-3" && (len(member.Services) != 1 || member.Services[0].Port.Port != 9999) {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	if !spread {
+		t.Fatalf("TestGossipConvergence - Gossip.UpdateServices - Expected: %v but Given: %v", services, nodes[0].Members())
+	}
+}
+
+func TestGossipFailureAndLeave(t *testing.T) {
+	nodes := startCluster(t, 6)
+	defer stopCluster(nodes)
+	waitFor(5*time.Second, func() bool {
+		for _, node := range nodes {
+			if len(node.AliveMembers()) != len(nodes) {
+				return false
+			}
+		}
+		return true
+	})
+	var failed = make(chan string, 16)
+	nodes[0].Subscribe(func(event Event) {
+		if event.Type == EVENT_MEMBER_FAILED {
+			failed <- event.Member.Name
+		}
+	})
+	nodes[4].Stop()
+	nodes[5].Leave()
+	detected := waitFor(10*time.Second, func() bool {
+		for _, node := range nodes[:4] {
+			if memberState(node, "node-4") != MEMBER_STATE_DEAD || memberState(node, "node-5") != MEMBER_STATE_LEFT {
+				return false
+			}
+		}
+		return true
+	})
+	if !detected {
+		t.Fatalf("TestGossipFailureAndLeave - Gossip.Members - Expected: %v, %v but Given: %v, %v", MEMBER_STATE_DEAD,
+			MEMBER_STATE_LEFT, memberState(nodes[0], "node-4"), memberState(nodes[0], "node-5"))
+	}
+	select {
+	case name := <-failed:
+		if name != "node-4" {
+			t.Fatalf("TestGossipFailureAndLeave - Gossip.Subscribe - Expected: %v but Given: %v", "node-4", name)
+		}
+	default:
+		t.Fatalf("TestGossipFailureAndLeave - Gossip.Subscribe - Expected: %v but Given: %v", "node-4", "no event")
+	}
+	if len(nodes[0].AliveMembers()) != 4 {
+		t.Fatalf("TestGossipFailureAndLeave - Gossip.AliveMembers - Expected: %v but Given: %v", 4, len(nodes[0].AliveMembers()))
+	}
+}
+
+func TestGossipReceiveClosed(t *testing.T) {
+	node := NewGossip(Config{Name: "node-0", BindAddress: "127.0.0.1"}, nil).(*gossip)
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	var done = make(chan struct{})
+	node.group.Add(1)
+	go func() {
+		node.receiveLoop(conn, make(chan struct{}))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("TestGossipReceiveClosed - Gossip.Receive - Expected: %v but Given: %v", "returned", "still receiving")
+	}
+}
@@ -0,0 +1,317 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/cluster/discovery"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	tm "github.com/hellgate75/go-tcp-common/time"
+	"sync"
+	"time"
+)
+
+var (
+	// Default interval between two heartbeat rounds
+	DEFAULT_HEARTBEAT_INTERVAL time.Duration = 10 * time.Second
+	// Default number of consecutive failed pings before a node becomes unreachable
+	DEFAULT_SUSPICION_THRESHOLD int = 2
+	// Default number of consecutive failed pings before a node is removed from the registry
+	DEFAULT_FAILURE_THRESHOLD int = 5
+)
+
+func (et MembershipEventType) String() string {
+	switch et {
+	case EVENT_NODE_JOINED:
+		return "Joined"
+	case EVENT_NODE_LEFT:
+		return "Left"
+	case EVENT_NODE_STATE_CHANGED:
+		return "State Changed"
+	default:
+		return "Unknown"
+	}
+}
+
+func (me MembershipEvent) String() string {
+	return fmt.Sprintf("MembershipEvent{Type: \"%s\", Node: \"%s\", PreviousState: \"%s\", State: \"%s\", Time: %s}",
+		me.Type, me.Node.Name, me.PreviousState, me.State, me.Time.String())
+}
+
+type pingResult struct {
+	node     types.Node
+	pingInfo *types.NodePingInfo
+	err      error
+}
+
+type membership struct {
+	sync.Mutex
+	registry  ClusterRegistry
+	config    MembershipConfig
+	failures  map[string]int
+	known     map[string]bool
+	listeners map[string]MembershipListener
+	tab       tm.CronTab
+	running   bool
+	logger    log.Logger
+}
+
+func (m *membership) Start() error {
+	m.Lock()
+	defer m.Unlock()
+	if m.running {
+		return errors.New("Membership.Start - Membership heartbeat already running!!")
+	}
+	m.tab = tm.NewCronTab("cluster-membership", m.logger)
+	m.tab.AddJob(tm.NewCronJob("cluster-membership-heartbeat", m.Heartbeat, tm.CronData{
+		Interval: m.config.Interval,
+	}, m.logger))
+	if err := m.tab.Start(); err != nil {
+		return errors.New(fmt.Sprintf("Membership.Start - Error: %s", err))
+	}
+	m.running = true
+	return nil
+}
+
+func (m *membership) Stop() error {
+	m.Lock()
+	defer m.Unlock()
+	if !m.running {
+		return errors.New("Membership.Stop - Membership heartbeat is not running!!")
+	}
+	m.running = false
+	m.tab.KillAllJobs()
+	if m.tab.IsRunning() {
+		return m.tab.Stop()
+	}
+	return nil
+}
+
+func (m *membership) IsRunning() bool {
+	m.Lock()
+	defer m.Unlock()
+	return m.running
+}
+
+// Execute a single heartbeat round, pinging all registered nodes in parallel
+func (m *membership) Heartbeat() {
+	defer func() {
+		if r := recover(); r != nil && m.logger != nil {
+			m.logger.Errorf("Membership.Heartbeat - Error: %v", r)
+		}
+	}()
+	nodes := append([]types.Node{}, m.registry.List()...)
+	var results = make([]pingResult, len(nodes))
+	var group sync.WaitGroup
+	for idx, node := range nodes {
+		group.Add(1)
+		go func(idx int, node types.Node) {
+			defer group.Done()
+			pingInfo, err := discovery.PingNode(node.IpAddress, node.Port, m.config.Timeout, m.config.TLSConfig)
+			results[idx] = pingResult{node: node, pingInfo: pingInfo, err: err}
+		}(idx, node)
+	}
+	group.Wait()
+	var events = make([]MembershipEvent, 0)
+	for _, result := range results {
+		events = append(events, m.apply(result)...)
+	}
+	m.notify(events...)
+}
+
+func (m *membership) apply(result pingResult) []MembershipEvent {
+	var events = make([]MembershipEvent, 0)
+	node := result.node
+	filter := NodeNameFilter(node.Name)
+	m.Lock()
+	if !m.known[node.Name] {
+		m.known[node.Name] = true
+		events = append(events, newMembershipEvent(EVENT_NODE_JOINED, node, node.State))
+	}
+	if result.err == nil {
+		m.failures[node.Name] = 0
+		m.Unlock()
+		state := result.pingInfo.State
+		if state == types.NODE_STATE_UNKNOWN {
+			state = types.NODE_STATE_RUNNING
+		}
+		update := types.Node{
+			Role:      result.pingInfo.Role,
+			Active:    true,
+			LastCheck: result.pingInfo.Time,
+			State:     state,
+		}
+		if err := m.registry.Update("Name", filter, update); err != nil {
+			m.logError("Membership.Heartbeat - Unable to update node %s, Details: %s", node.Name, err)
+		}
+		if node.State != state {
+			events = append(events, newMembershipEvent(EVENT_NODE_STATE_CHANGED, node, state))
+		}
+		return events
+	}
+	m.failures[node.Name]++
+	failures := m.failures[node.Name]
+	if failures >= m.config.FailureThreshold {
+		delete(m.failures, node.Name)
+		delete(m.known, node.Name)
+		m.Unlock()
+		if err := m.registry.Remove("Name", filter); err != nil {
+			m.logError("Membership.Heartbeat - Unable to remove node %s, Details: %s", node.Name, err)
+		}
+		return append(events, newMembershipEvent(EVENT_NODE_LEFT, node, types.NODE_STATE_UNRACJABLE))
+	}
+	m.Unlock()
+	if failures >= m.config.SuspicionThreshold && node.State != types.NODE_STATE_UNRACJABLE {
+		update := types.Node{
+			Active: false,
+			State:  types.NODE_STATE_UNRACJABLE,
+		}
+		if err := m.registry.Update("Name", filter, update); err != nil {
+			m.logError("Membership.Heartbeat - Unable to update node %s, Details: %s", node.Name, err)
+		}
+		events = append(events, newMembershipEvent(EVENT_NODE_STATE_CHANGED, node, types.NODE_STATE_UNRACJABLE))
+	}
+	return events
+}
+
+func (m *membership) Join(n *types.Node) error {
+	if n == nil {
+		return errors.New("Membership.Join - Nil node reference")
+	}
+	if "" == n.Name {
+		n.Name = fmt.Sprintf("%s_%v", n.IpAddress, n.Port)
+	}
+	filter := NodeNameFilter(n.Name)
+	nodes, err := m.registry.Recover("Name", filter)
+	if err != nil {
+		return err
+	}
+	if len(nodes) > 0 {
+		err = m.registry.Update("Name", filter, *n)
+	} else {
+		err = m.registry.Register(n)
+	}
+	if err != nil {
+		return err
+	}
+	m.Lock()
+	joined := !m.known[n.Name]
+	m.known[n.Name] = true
+	m.failures[n.Name] = 0
+	m.Unlock()
+	if joined {
+		m.notify(newMembershipEvent(EVENT_NODE_JOINED, *n, n.State))
+	}
+	return nil
+}
+
+func (m *membership) Leave(name string) error {
+	filter := NodeNameFilter(name)
+	nodes, err := m.registry.Recover("Name", filter)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return errors.New(fmt.Sprintf("Membership.Leave - Unknown node: %s", name))
+	}
+	node := *nodes[0]
+	if err = m.registry.Remove("Name", filter); err != nil {
+		return err
+	}
+	m.Lock()
+	delete(m.known, name)
+	delete(m.failures, name)
+	m.Unlock()
+	m.notify(newMembershipEvent(EVENT_NODE_LEFT, node, node.State))
+	return nil
+}
+
+func (m *membership) Subscribe(listener MembershipListener) string {
+	id := ncom.GenerateSecureToken(8)
+	m.Lock()
+	m.listeners[id] = listener
+	m.Unlock()
+	return id
+}
+
+func (m *membership) Unsubscribe(id string) bool {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.listeners[id]; ok {
+		delete(m.listeners, id)
+		return true
+	}
+	return false
+}
+
+func (m *membership) notify(events ...MembershipEvent) {
+	if len(events) == 0 {
+		return
+	}
+	m.Lock()
+	var listeners = make([]MembershipListener, 0)
+	for _, listener := range m.listeners {
+		listeners = append(listeners, listener)
+	}
+	m.Unlock()
+	for _, event := range events {
+		if m.logger != nil {
+			m.logger.Debugf("Membership.Event - %s", event)
+		}
+		for _, listener := range listeners {
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						m.logError("Membership.Event - Listener failure on event %s, Details: %v", event, r)
+					}
+				}()
+				listener(event)
+			}()
+		}
+	}
+}
+
+func (m *membership) logError(format string, in ...interface{}) {
+	if m.logger != nil {
+		m.logger.Errorf(format, in...)
+	}
+}
+
+func newMembershipEvent(eventType MembershipEventType, node types.Node, state types.NodeState) MembershipEvent {
+	return MembershipEvent{
+		Type:          eventType,
+		Node:          node,
+		PreviousState: node.State,
+		State:         state,
+		Time:          time.Now(),
+	}
+}
+
+// Creates a new heartbeat based membership on the given registry, zero configuration values
+// are replaced by the package defaults
+func NewMembership(registry ClusterRegistry, config MembershipConfig, logger log.Logger) Membership {
+	if config.Interval <= 0 {
+		config.Interval = DEFAULT_HEARTBEAT_INTERVAL
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DEFAULT_NODE_TIMEOUT
+	}
+	if config.SuspicionThreshold <= 0 {
+		config.SuspicionThreshold = DEFAULT_SUSPICION_THRESHOLD
+	}
+	if config.FailureThreshold < config.SuspicionThreshold {
+		config.FailureThreshold = DEFAULT_FAILURE_THRESHOLD
+		if config.FailureThreshold < config.SuspicionThreshold {
+			config.FailureThreshold = config.SuspicionThreshold
+		}
+	}
+	return &membership{
+		registry:  registry,
+		config:    config,
+		failures:  make(map[string]int),
+		known:     make(map[string]bool),
+		listeners: make(map[string]MembershipListener),
+		logger:    logger,
+	}
+}
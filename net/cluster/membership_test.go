@@ -0,0 +1,76 @@
+package cluster
+
+import (
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func hostPort(t *testing.T, address string) (string, int32) {
+	host, portText, err := net.SplitHostPort(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := strconv.Atoi(portText)
+	return host, int32(port)
+}
+
+func TestMembershipHeartbeat(t *testing.T) {
+	alive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fmt.Sprintf(`{"role":%d,"state":%d,"active":true}`, types.ROLE_SLAVE, types.NODE_STATE_RUNNING)))
+	}))
+	defer alive.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	deadAddress := dead.Listener.Addr().String()
+	dead.Close()
+
+	registry := NewInMemoryClusterRegistry()
+	aliveIp, alivePort := hostPort(t, alive.Listener.Addr().String())
+	deadIp, deadPort := hostPort(t, deadAddress)
+	registry.Register(&types.Node{Name: "alive", IpAddress: aliveIp, Port: alivePort})
+	registry.Register(&types.Node{Name: "dead", IpAddress: deadIp, Port: deadPort, State: types.NODE_STATE_RUNNING})
+
+	m := NewMembership(registry, MembershipConfig{
+		Timeout:            time.Second,
+		SuspicionThreshold: 1,
+		FailureThreshold:   2,
+	}, nil)
+	var events = make([]MembershipEvent, 0)
+	id := m.Subscribe(func(event MembershipEvent) {
+		events = append(events, event)
+	})
+
+	m.Heartbeat()
+	nodes, _ := registry.Recover("Name", NodeNameFilter("alive"))
+	if len(nodes) != 1 || !nodes[0].Active || nodes[0].State != types.NODE_STATE_RUNNING {
+		t.Fatalf("TestMembershipHeartbeat - Membership.Heartbeat - Expected: active running node but Given: %v", nodes)
+	}
+	nodes, _ = registry.Recover("Name", NodeNameFilter("dead"))
+	if len(nodes) != 1 || nodes[0].Active || nodes[0].State != types.NODE_STATE_UNRACJABLE {
+		t.Fatalf("TestMembershipHeartbeat - Membership.Heartbeat - Expected: unreachable node but Given: %v", nodes)
+	}
+
+	m.Heartbeat()
+	nodes, _ = registry.Recover("Name", NodeNameFilter("dead"))
+	if len(nodes) != 0 {
+		t.Fatalf("TestMembershipHeartbeat - Membership.Heartbeat - Expected: %v but Given: %v", 0, len(nodes))
+	}
+	var left int
+	for _, event := range events {
+		if event.Type == EVENT_NODE_LEFT && event.Node.Name == "dead" {
+			left++
+		}
+	}
+	if left != 1 {
+		t.Fatalf("TestMembershipHeartbeat - Membership.Subscribe - Expected: %v but Given: %v", 1, left)
+	}
+	if !m.Unsubscribe(id) {
+		t.Fatalf("TestMembershipHeartbeat - Membership.Unsubscribe - Expected: %v but Given: %v", true, false)
+	}
+}
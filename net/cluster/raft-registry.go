@@ -0,0 +1,286 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	cio "github.com/hellgate75/go-tcp-common/io"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/cluster/raft"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// Default timeout of the replicated registry writes and linearizable reads
+	DEFAULT_REGISTRY_TIMEOUT time.Duration = 5 * time.Second
+)
+
+const (
+	registryOpRegister = "register"
+	registryOpUpdate   = "update"
+	registryOpRemove   = "remove"
+)
+
+// Registry change replicated through the raft log
+type registryCommand struct {
+	Op     string     `json:"op"`
+	Field  string     `json:"field,omitempty"`
+	Filter string     `json:"filter,omitempty"`
+	Node   types.Node `json:"node,omitempty"`
+}
+
+type raftRegistry struct {
+	sync.RWMutex
+	// In memory state machine, the registry file is written by the snapshots only
+	cache    *nodeCache
+	raft     raft.Raft
+	filePath string
+	encoding cio.ParserFormat
+	timeout  time.Duration
+	logger   log.Logger
+}
+
+func (rr *raftRegistry) Start() error {
+	return rr.raft.Start()
+}
+
+func (rr *raftRegistry) Stop() error {
+	return rr.raft.Stop()
+}
+
+func (rr *raftRegistry) Raft() raft.Raft {
+	return rr.raft
+}
+
+func (rr *raftRegistry) Register(n *types.Node) error {
+	if n == nil {
+		return errors.New("RaftRegistry.Register - Nil node reference")
+	}
+	if err := rr.propose(registryCommand{Op: registryOpRegister, Node: *n}); err != nil {
+		return errors.New(fmt.Sprintf("RaftRegistry.Register - Error: %s", err))
+	}
+	return nil
+}
+
+func (rr *raftRegistry) Update(field string, filter regexp.Regexp, n types.Node) error {
+	if err := rr.propose(registryCommand{Op: registryOpUpdate, Field: field, Filter: filter.String(), Node: n}); err != nil {
+		return errors.New(fmt.Sprintf("RaftRegistry.Update - Error: %s", err))
+	}
+	return nil
+}
+
+func (rr *raftRegistry) Remove(field string, filter regexp.Regexp) error {
+	if err := rr.propose(registryCommand{Op: registryOpRemove, Field: field, Filter: filter.String()}); err != nil {
+		return errors.New(fmt.Sprintf("RaftRegistry.Remove - Error: %s", err))
+	}
+	return nil
+}
+
+func (rr *raftRegistry) Recover(field string, filter regexp.Regexp) ([]*types.Node, error) {
+	if err := rr.barrier(); err != nil {
+		return make([]*types.Node, 0), errors.New(fmt.Sprintf("RaftRegistry.Recover - Error: %s", err))
+	}
+	// Matching runs on a copy, so callers cannot change the replicated state
+	snapshot := &nodeCache{Nodes: rr.nodes()}
+	return snapshot.Recover(field, filter)
+}
+
+func (rr *raftRegistry) List() []types.Node {
+	if err := rr.barrier(); err != nil {
+		if rr.logger != nil {
+			rr.logger.Errorf("RaftRegistry.List - Error: %s", err)
+		}
+		return make([]types.Node, 0)
+	}
+	return rr.nodes()
+}
+
+func (rr *raftRegistry) EnablePersistence(registryFile string) error {
+	if rr.IsPersistenceEnabled() {
+		return errors.New(fmt.Sprintf("RaftRegistry.EnablePersistence - Persistence already enabled on: %s", rr.filePath))
+	}
+	if "" == registryFile {
+		return errors.New("RaftRegistry.EnablePersistence - Invalid empty file for registry persistence!!")
+	}
+	rr.Lock()
+	defer rr.Unlock()
+	if "" == rr.encoding {
+		rr.encoding = cio.ParserFormatYaml
+	}
+	rr.filePath = registryFile
+	return rr.save()
+}
+
+func (rr *raftRegistry) DisablePersistence() error {
+	if !rr.IsPersistenceEnabled() {
+		return errors.New("RaftRegistry.DisablePersistence - Persistence is not enabled!!")
+	}
+	rr.Lock()
+	defer rr.Unlock()
+	os.Remove(rr.filePath)
+	rr.filePath = ""
+	return nil
+}
+
+func (rr *raftRegistry) IsPersistenceEnabled() bool {
+	rr.RLock()
+	defer rr.RUnlock()
+	return "" != rr.filePath
+}
+
+func (rr *raftRegistry) RegistryFilePath() string {
+	rr.RLock()
+	defer rr.RUnlock()
+	return rr.filePath
+}
+
+func (rr *raftRegistry) RegistryFileEncodingFormat() cio.ParserFormat {
+	rr.RLock()
+	defer rr.RUnlock()
+	return rr.encoding
+}
+
+func (rr *raftRegistry) ChangeEncodingFormat(encodingFormat cio.ParserFormat) error {
+	if "" == string(encodingFormat) {
+		return errors.New("RaftRegistry.ChangeEncodingFormat - Invalid empty encoding format!!")
+	}
+	rr.Lock()
+	defer rr.Unlock()
+	rr.encoding = encodingFormat
+	if "" != rr.filePath {
+		return rr.save()
+	}
+	return nil
+}
+
+// Applies a committed registry command, registering a node with a known name replaces it
+func (rr *raftRegistry) Apply(command []byte) error {
+	var cmd registryCommand
+	if err := json.Unmarshal(command, &cmd); err != nil {
+		return err
+	}
+	rr.Lock()
+	defer rr.Unlock()
+	switch cmd.Op {
+	case registryOpRegister:
+		for idx := range rr.cache.Nodes {
+			if rr.cache.Nodes[idx].Name == cmd.Node.Name {
+				rr.cache.Nodes[idx] = cmd.Node
+				return nil
+			}
+		}
+		return rr.cache.Register(&cmd.Node)
+	case registryOpUpdate, registryOpRemove:
+		filter, err := regexp.Compile(cmd.Filter)
+		if err != nil {
+			return err
+		}
+		if cmd.Op == registryOpUpdate {
+			return rr.cache.Update(cmd.Field, *filter, cmd.Node)
+		}
+		return rr.cache.Remove(cmd.Field, *filter)
+	default:
+		return errors.New(fmt.Sprintf("Unknown registry operation: <%s>", cmd.Op))
+	}
+}
+
+// Encodes the registry nodes, saving them to the registry file when persistence is enabled
+func (rr *raftRegistry) Snapshot() ([]byte, error) {
+	rr.RLock()
+	defer rr.RUnlock()
+	if "" != rr.filePath {
+		if err := rr.save(); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(rr.cache.Nodes)
+}
+
+func (rr *raftRegistry) Restore(snapshot []byte) error {
+	var nodes = make([]types.Node, 0)
+	if err := json.Unmarshal(snapshot, &nodes); err != nil {
+		return err
+	}
+	rr.Lock()
+	defer rr.Unlock()
+	rr.cache.Nodes = nodes
+	if "" != rr.filePath {
+		return rr.save()
+	}
+	return nil
+}
+
+func (rr *raftRegistry) propose(command registryCommand) error {
+	data, err := json.Marshal(command)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rr.timeout)
+	defer cancel()
+	return rr.raft.Propose(ctx, data)
+}
+
+func (rr *raftRegistry) barrier() error {
+	ctx, cancel := context.WithTimeout(context.Background(), rr.timeout)
+	defer cancel()
+	return rr.raft.ReadBarrier(ctx)
+}
+
+func (rr *raftRegistry) nodes() []types.Node {
+	rr.RLock()
+	defer rr.RUnlock()
+	return append(make([]types.Node, 0, len(rr.cache.Nodes)), rr.cache.Nodes...)
+}
+
+// Writes the registry file, it must be called holding the lock
+func (rr *raftRegistry) save() error {
+	file := &nodeCache{
+		FilePath: rr.filePath,
+		Nodes:    rr.cache.Nodes,
+		Encoding: rr.encoding,
+	}
+	return file.save()
+}
+
+// Returns the raft peer addresses (ip:raftPort) of the master and coordinator nodes
+func RaftPeers(nodes []types.Node, raftPort int32) []string {
+	var peers = make([]string, 0)
+	for _, node := range nodes {
+		if node.Role == types.ROLE_MASTER || node.Role == types.ROLE_CCORDINATOR {
+			peers = append(peers, node.IpAddress+":"+strconv.Itoa(int(raftPort)))
+		}
+	}
+	return peers
+}
+
+// Creates a cluster registry replicated through a raft node on the given transport. Register, Update and
+// Remove are committed on a quorum of peers before returning, while List and Recover wait for the local
+// registry to reflect every change committed before the call. Raft snapshots are exported to the encoded
+// registry file (when not empty), while at start-up the registry is restored from the raft snapshot and log
+// saved with the config StateFile. An empty config StateFile defaults to the registry file path with the
+// ".raft" extension
+func NewRaftClusterRegistry(config raft.Config, transport raft.Transport, registryFile string, encoding cio.ParserFormat, logger log.Logger) (ReplicatedClusterRegistry, error) {
+	if "" == encoding {
+		encoding = cio.ParserFormatYaml
+	}
+	registry := &raftRegistry{
+		cache:    &nodeCache{Nodes: make([]types.Node, 0)},
+		filePath: registryFile,
+		encoding: encoding,
+		timeout:  DEFAULT_REGISTRY_TIMEOUT,
+		logger:   logger,
+	}
+	if "" != registryFile {
+		if "" == config.StateFile {
+			config.StateFile = registryFile + ".raft"
+		}
+	}
+	registry.raft = raft.NewRaft(config, transport, registry, logger)
+	return registry, nil
+}
@@ -0,0 +1,84 @@
+package cluster
+
+import (
+	"fmt"
+	cio "github.com/hellgate75/go-tcp-common/io"
+	"github.com/hellgate75/go-tcp-common/net/cluster/raft"
+	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRaftClusterRegistry(t *testing.T) {
+	folder := t.TempDir()
+	var peers = make([]string, 0)
+	for i := 0; i < 3; i++ {
+		peers = append(peers, fmt.Sprintf("127.0.0.1:%v", freePort(t)))
+	}
+	var registries = make([]ReplicatedClusterRegistry, 0)
+	for idx, peer := range peers {
+		registry, err := NewRaftClusterRegistry(raft.Config{
+			Peers:             peers,
+			ElectionTimeout:   100 * time.Millisecond,
+			HeartbeatInterval: 20 * time.Millisecond,
+		}, raft.NewRpcTransport(peer, nil, nil), filepath.Join(folder, fmt.Sprintf("registry-%v.yaml", idx)), cio.ParserFormatYaml, nil)
+		if err != nil {
+			t.Fatalf("TestRaftClusterRegistry - NewRaftClusterRegistry - Expected: %v but Given: %v", nil, err)
+		}
+		if err := registry.Start(); err != nil {
+			t.Fatalf("TestRaftClusterRegistry - RaftRegistry.Start - Expected: %v but Given: %v", nil, err)
+		}
+		registries = append(registries, registry)
+	}
+	defer func() {
+		for _, registry := range registries {
+			registry.Stop()
+		}
+	}()
+
+	if err := registries[0].Register(&types.Node{Name: "node-1", IpAddress: "10.0.0.1", Role: types.ROLE_MASTER}); err != nil {
+		t.Fatalf("TestRaftClusterRegistry - RaftRegistry.Register - Expected: %v but Given: %v", nil, err)
+	}
+	if err := registries[1].Register(&types.Node{Name: "node-2", IpAddress: "10.0.0.2", Role: types.ROLE_SLAVE}); err != nil {
+		t.Fatalf("TestRaftClusterRegistry - RaftRegistry.Register - Expected: %v but Given: %v", nil, err)
+	}
+	if err := registries[2].Update("Name", NodeNameFilter("node-2"), types.Node{Name: "node-2", IpAddress: "10.0.0.2", Active: true, State: types.NODE_STATE_RUNNING}); err != nil {
+		t.Fatalf("TestRaftClusterRegistry - RaftRegistry.Update - Expected: %v but Given: %v", nil, err)
+	}
+	for _, registry := range registries {
+		if nodes := registry.List(); len(nodes) != 2 {
+			t.Fatalf("TestRaftClusterRegistry - RaftRegistry.List - Expected: %v but Given: %v", 2, len(nodes))
+		}
+		nodes, err := registry.Recover("Name", NodeNameFilter("node-2"))
+		if err != nil || len(nodes) != 1 || !nodes[0].Active || nodes[0].State != types.NODE_STATE_RUNNING {
+			t.Fatalf("TestRaftClusterRegistry - RaftRegistry.Recover - Expected: %v but Given: %v (%v)", "active node-2", nodes, err)
+		}
+	}
+	if err := registries[1].Remove("Name", NodeNameFilter("node-1")); err != nil {
+		t.Fatalf("TestRaftClusterRegistry - RaftRegistry.Remove - Expected: %v but Given: %v", nil, err)
+	}
+	if nodes := registries[0].List(); len(nodes) != 1 || nodes[0].Name != "node-2" {
+		t.Fatalf("TestRaftClusterRegistry - RaftRegistry.List - Expected: %v but Given: %v", "node-2", nodes)
+	}
+
+	if err := registries[0].Raft().Snapshot(); err != nil {
+		t.Fatalf("TestRaftClusterRegistry - Raft.Snapshot - Expected: %v but Given: %v", nil, err)
+	}
+	saved := NewClusterRegistryWithInternal(registries[0].RegistryFilePath(), cio.ParserFormatYaml)
+	nodes, err := saved.Recover("Name", NodeNameFilter("node-2"))
+	if err != nil || len(nodes) != 1 {
+		t.Fatalf("TestRaftClusterRegistry - RaftRegistry.Snapshot - Expected: %v but Given: %v (%v)", "node-2 in the registry file", nodes, err)
+	}
+}
+
+func TestRaftPeers(t *testing.T) {
+	peers := RaftPeers([]types.Node{
+		{Name: "a", IpAddress: "10.0.0.1", Role: types.ROLE_MASTER},
+		{Name: "b", IpAddress: "10.0.0.2", Role: types.ROLE_SLAVE},
+		{Name: "c", IpAddress: "10.0.0.3", Role: types.ROLE_CCORDINATOR},
+	}, 7000)
+	if len(peers) != 2 || peers[0] != "10.0.0.1:7000" || peers[1] != "10.0.0.3:7000" {
+		t.Fatalf("TestRaftPeers - cluster.RaftPeers - Expected: %v but Given: %v", "[10.0.0.1:7000 10.0.0.3:7000]", peers)
+	}
+}
@@ -0,0 +1,1087 @@
+package raft
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	methodVote      = "raft.vote"
+	methodAppend    = "raft.append"
+	methodSnapshot  = "raft.snapshot"
+	methodForward   = "raft.forward"
+	methodReadIndex = "raft.readindex"
+)
+
+// Log entry, no-op entries are appended by a new leader to commit the previous terms entries
+type entry struct {
+	Index   uint64 `json:"index"`
+	Term    uint64 `json:"term"`
+	Noop    bool   `json:"noop,omitempty"`
+	Command []byte `json:"command,omitempty"`
+}
+
+// Durable raft state
+type hardState struct {
+	Term     uint64 `yaml:"term,omitempty" json:"term,omitempty" xml:"term,omitempty"`
+	VotedFor string `yaml:"votedFor,omitempty" json:"votedFor,omitempty" xml:"voted-for,omitempty"`
+}
+
+type voteRequest struct {
+	Term         uint64 `json:"term"`
+	Candidate    string `json:"candidate"`
+	LastLogIndex uint64 `json:"lastLogIndex"`
+	LastLogTerm  uint64 `json:"lastLogTerm"`
+}
+
+type voteResponse struct {
+	Term    uint64 `json:"term"`
+	Granted bool   `json:"granted"`
+}
+
+type appendRequest struct {
+	Term         uint64  `json:"term"`
+	Leader       string  `json:"leader"`
+	PrevLogIndex uint64  `json:"prevLogIndex"`
+	PrevLogTerm  uint64  `json:"prevLogTerm"`
+	Entries      []entry `json:"entries,omitempty"`
+	LeaderCommit uint64  `json:"leaderCommit"`
+}
+
+type appendResponse struct {
+	Term          uint64 `json:"term"`
+	Success       bool   `json:"success"`
+	ConflictIndex uint64 `json:"conflictIndex,omitempty"`
+}
+
+type snapshotRequest struct {
+	Term      uint64 `json:"term"`
+	Leader    string `json:"leader"`
+	LastIndex uint64 `json:"lastIndex"`
+	LastTerm  uint64 `json:"lastTerm"`
+	Data      []byte `json:"data,omitempty"`
+}
+
+type snapshotResponse struct {
+	Term uint64 `json:"term"`
+}
+
+type readIndexResponse struct {
+	Index uint64 `json:"index"`
+}
+
+type waiter struct {
+	term uint64
+	done chan error
+}
+
+type raftNode struct {
+	sync.Mutex
+	// Serializes the state machine access: it is always acquired before the node lock
+	applyLock     sync.Mutex
+	config        Config
+	id            string
+	peers         []string
+	transport     Transport
+	machine       StateMachine
+	storage       *storage
+	role          Role
+	term          uint64
+	votedFor      string
+	leader        string
+	log           []entry
+	snapshotIndex uint64
+	snapshotTerm  uint64
+	commitIndex   uint64
+	lastApplied   uint64
+	nextIndex     map[string]uint64
+	matchIndex    map[string]uint64
+	inflight      map[string]bool
+	waiters       map[uint64]waiter
+	deadline      time.Time
+	lastHeartbeat time.Time
+	commitCh      chan struct{}
+	appliedCh     chan struct{}
+	leaderCh      chan struct{}
+	random        *rand.Rand
+	running       bool
+	stop          chan struct{}
+	group         sync.WaitGroup
+	logger        log.Logger
+}
+
+func (r *raftNode) Start() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.running {
+		return errors.New("Raft.Start - Raft node already running!!")
+	}
+	if err := r.load(); err != nil {
+		return errors.New(fmt.Sprintf("Raft.Start - Error: %s", err))
+	}
+	r.transport.Handle(methodVote, r.handleVote)
+	r.transport.Handle(methodAppend, r.handleAppend)
+	r.transport.Handle(methodSnapshot, r.handleSnapshot)
+	r.transport.Handle(methodForward, r.handleForward)
+	r.transport.Handle(methodReadIndex, r.handleReadIndex)
+	if err := r.transport.Start(); err != nil {
+		return errors.New(fmt.Sprintf("Raft.Start - Error: %s", err))
+	}
+	r.role = ROLE_FOLLOWER
+	r.setLeader("")
+	r.resetDeadline()
+	r.running = true
+	r.stop = make(chan struct{})
+	r.group.Add(2)
+	go r.tickLoop(r.stop)
+	go r.applyLoop(r.stop)
+	return nil
+}
+
+func (r *raftNode) Stop() error {
+	r.Lock()
+	if !r.running {
+		r.Unlock()
+		return errors.New("Raft.Stop - Raft node is not running!!")
+	}
+	r.running = false
+	r.role = ROLE_FOLLOWER
+	r.setLeader("")
+	close(r.stop)
+	r.failWaiters(ErrStopped)
+	r.Unlock()
+	err := r.transport.Close()
+	r.group.Wait()
+	return err
+}
+
+func (r *raftNode) Propose(ctx context.Context, command []byte) error {
+	leader, err := r.waitLeader(ctx)
+	if err != nil {
+		return err
+	}
+	r.Lock()
+	if r.role != ROLE_LEADER || r.leader != leader {
+		r.Unlock()
+		if leader == r.id {
+			return ErrLeadershipLost
+		}
+		_, err := r.transport.Call(ctx, leader, methodForward, command)
+		return err
+	}
+	index := r.lastIndex() + 1
+	e := entry{Index: index, Term: r.term, Command: command}
+	if err := r.appendLog([]entry{e}); err != nil {
+		r.Unlock()
+		return err
+	}
+	r.log = append(r.log, e)
+	done := make(chan error, 1)
+	r.waiters[index] = waiter{term: r.term, done: done}
+	r.advanceCommit()
+	r.Unlock()
+	r.replicateAll()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		r.Lock()
+		delete(r.waiters, index)
+		r.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (r *raftNode) ReadBarrier(ctx context.Context) error {
+	leader, err := r.waitLeader(ctx)
+	if err != nil {
+		return err
+	}
+	var index uint64
+	if leader == r.id {
+		if index, err = r.readIndex(ctx); err != nil {
+			return err
+		}
+	} else {
+		var response readIndexResponse
+		if err := r.call(ctx, leader, methodReadIndex, nil, &response); err != nil {
+			return err
+		}
+		index = response.Index
+	}
+	return r.waitApplied(ctx, index)
+}
+
+func (r *raftNode) Snapshot() error {
+	r.applyLock.Lock()
+	defer r.applyLock.Unlock()
+	return r.compact()
+}
+
+func (r *raftNode) IsLeader() bool {
+	r.Lock()
+	defer r.Unlock()
+	return r.role == ROLE_LEADER
+}
+
+func (r *raftNode) Leader() (string, bool) {
+	r.Lock()
+	defer r.Unlock()
+	return r.leader, "" != r.leader
+}
+
+func (r *raftNode) Role() Role {
+	r.Lock()
+	defer r.Unlock()
+	return r.role
+}
+
+func (r *raftNode) Term() uint64 {
+	r.Lock()
+	defer r.Unlock()
+	return r.term
+}
+
+func (r *raftNode) Id() string {
+	return r.id
+}
+
+func (r *raftNode) tickLoop(stop chan struct{}) {
+	defer r.group.Done()
+	ticker := time.NewTicker(r.config.HeartbeatInterval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			r.Lock()
+			if r.role == ROLE_LEADER {
+				heartbeat := now.Sub(r.lastHeartbeat) >= r.config.HeartbeatInterval
+				if heartbeat {
+					r.lastHeartbeat = now
+				}
+				r.Unlock()
+				if heartbeat {
+					r.replicateAll()
+				}
+			} else if now.After(r.deadline) {
+				r.Unlock()
+				r.startElection()
+			} else {
+				r.Unlock()
+			}
+		}
+	}
+}
+
+func (r *raftNode) startElection() {
+	r.Lock()
+	if !r.running {
+		r.Unlock()
+		return
+	}
+	r.role = ROLE_CANDIDATE
+	r.term++
+	r.votedFor = r.id
+	r.setLeader("")
+	r.resetDeadline()
+	if err := r.persist(); err != nil {
+		// The vote for itself is not durable, so the election cannot start
+		r.role = ROLE_FOLLOWER
+		r.Unlock()
+		return
+	}
+	term := r.term
+	request := voteRequest{
+		Term:         term,
+		Candidate:    r.id,
+		LastLogIndex: r.lastIndex(),
+		LastLogTerm:  r.termAt(r.lastIndex()),
+	}
+	peers := r.peers
+	if r.quorum() == 1 {
+		r.becomeLeader()
+		r.Unlock()
+		return
+	}
+	r.Unlock()
+	var votes = 1
+	for _, peer := range peers {
+		go func(peer string) {
+			ctx, cancel := context.WithTimeout(context.Background(), r.config.ElectionTimeout)
+			defer cancel()
+			var response voteResponse
+			if err := r.call(ctx, peer, methodVote, request, &response); err != nil {
+				return
+			}
+			r.Lock()
+			defer r.Unlock()
+			if response.Term > r.term {
+				r.stepDown(response.Term)
+				return
+			}
+			if r.role != ROLE_CANDIDATE || r.term != term || !response.Granted {
+				return
+			}
+			votes++
+			if votes >= r.quorum() {
+				r.becomeLeader()
+			}
+		}(peer)
+	}
+}
+
+// Moves the node to leader, it must be called holding the lock
+func (r *raftNode) becomeLeader() {
+	noop := entry{Index: r.lastIndex() + 1, Term: r.term, Noop: true}
+	if err := r.appendLog([]entry{noop}); err != nil {
+		r.role = ROLE_FOLLOWER
+		r.resetDeadline()
+		return
+	}
+	r.role = ROLE_LEADER
+	r.setLeader(r.id)
+	for _, peer := range r.peers {
+		r.nextIndex[peer] = r.lastIndex() + 1
+		r.matchIndex[peer] = 0
+		r.inflight[peer] = false
+	}
+	r.log = append(r.log, noop)
+	r.lastHeartbeat = time.Time{}
+	r.advanceCommit()
+	if r.logger != nil {
+		r.logger.Infof("Raft.Election - Node %s is the leader for term %v", r.id, r.term)
+	}
+}
+
+// Moves the node to follower, it must be called holding the lock. It returns the error saving the
+// new term, the node must not answer for the new term when the save fails
+func (r *raftNode) stepDown(term uint64) error {
+	var err error
+	if term > r.term {
+		r.term = term
+		r.votedFor = ""
+		err = r.persist()
+	}
+	if r.role == ROLE_LEADER {
+		r.failWaiters(ErrLeadershipLost)
+		r.setLeader("")
+	}
+	r.role = ROLE_FOLLOWER
+	r.resetDeadline()
+	return err
+}
+
+func (r *raftNode) replicateAll() {
+	r.Lock()
+	if r.role != ROLE_LEADER {
+		r.Unlock()
+		return
+	}
+	peers := r.peers
+	r.Unlock()
+	for _, peer := range peers {
+		r.replicate(peer)
+	}
+}
+
+// Sends the missing entries, or a snapshot when they are compacted, to a peer
+func (r *raftNode) replicate(peer string) {
+	r.Lock()
+	if r.role != ROLE_LEADER || r.inflight[peer] {
+		r.Unlock()
+		return
+	}
+	r.inflight[peer] = true
+	next := r.nextIndex[peer]
+	if next <= r.snapshotIndex {
+		r.Unlock()
+		go r.sendSnapshot(peer)
+		return
+	}
+	prev := next - 1
+	last := r.lastIndex()
+	if last-prev > uint64(r.config.MaxAppendEntries) {
+		last = prev + uint64(r.config.MaxAppendEntries)
+	}
+	entries := append([]entry{}, r.log[prev-r.snapshotIndex:last-r.snapshotIndex]...)
+	term := r.term
+	request := appendRequest{
+		Term:         term,
+		Leader:       r.id,
+		PrevLogIndex: prev,
+		PrevLogTerm:  r.termAt(prev),
+		Entries:      entries,
+		LeaderCommit: r.commitIndex,
+	}
+	r.Unlock()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), r.config.ElectionTimeout)
+		defer cancel()
+		var response appendResponse
+		err := r.call(ctx, peer, methodAppend, request, &response)
+		r.Lock()
+		r.inflight[peer] = false
+		if err != nil {
+			r.Unlock()
+			return
+		}
+		if response.Term > r.term {
+			r.stepDown(response.Term)
+			r.Unlock()
+			return
+		}
+		if r.role != ROLE_LEADER || r.term != term {
+			r.Unlock()
+			return
+		}
+		if response.Success {
+			match := prev + uint64(len(entries))
+			if match > r.matchIndex[peer] {
+				r.matchIndex[peer] = match
+			}
+			r.nextIndex[peer] = match + 1
+			r.advanceCommit()
+		} else {
+			next := response.ConflictIndex
+			if next < 1 {
+				next = 1
+			}
+			if next > r.lastIndex()+1 {
+				next = r.lastIndex() + 1
+			}
+			r.nextIndex[peer] = next
+		}
+		more := r.nextIndex[peer] <= r.lastIndex()
+		r.Unlock()
+		if more {
+			r.replicate(peer)
+		}
+	}()
+}
+
+func (r *raftNode) sendSnapshot(peer string) {
+	r.applyLock.Lock()
+	r.Lock()
+	if r.role != ROLE_LEADER {
+		r.inflight[peer] = false
+		r.Unlock()
+		r.applyLock.Unlock()
+		return
+	}
+	term := r.term
+	request := snapshotRequest{
+		Term:      term,
+		Leader:    r.id,
+		LastIndex: r.lastApplied,
+		LastTerm:  r.termAt(r.lastApplied),
+	}
+	r.Unlock()
+	data, err := r.machine.Snapshot()
+	r.applyLock.Unlock()
+	if err != nil {
+		r.logError("Raft.Snapshot - Unable to snapshot the state machine, Details: %s", err)
+		r.Lock()
+		r.inflight[peer] = false
+		r.Unlock()
+		return
+	}
+	request.Data = data
+	ctx, cancel := context.WithTimeout(context.Background(), 2*r.config.ElectionTimeout)
+	defer cancel()
+	var response snapshotResponse
+	err = r.call(ctx, peer, methodSnapshot, request, &response)
+	r.Lock()
+	defer r.Unlock()
+	r.inflight[peer] = false
+	if err != nil {
+		return
+	}
+	if response.Term > r.term {
+		r.stepDown(response.Term)
+		return
+	}
+	if r.role != ROLE_LEADER || r.term != term {
+		return
+	}
+	if request.LastIndex > r.matchIndex[peer] {
+		r.matchIndex[peer] = request.LastIndex
+	}
+	r.nextIndex[peer] = request.LastIndex + 1
+	r.advanceCommit()
+}
+
+// Commits the highest current term entry replicated on a quorum, it must be called holding the lock
+func (r *raftNode) advanceCommit() {
+	for index := r.lastIndex(); index > r.commitIndex && r.termAt(index) == r.term; index-- {
+		count := 1
+		for _, peer := range r.peers {
+			if r.matchIndex[peer] >= index {
+				count++
+			}
+		}
+		if count >= r.quorum() {
+			r.commitIndex = index
+			select {
+			case r.commitCh <- struct{}{}:
+			default:
+			}
+			return
+		}
+	}
+}
+
+func (r *raftNode) applyLoop(stop chan struct{}) {
+	defer r.group.Done()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-r.commitCh:
+			for r.applyBatch() {
+			}
+		}
+	}
+}
+
+// Applies a batch of committed entries, returning true when entries were applied
+func (r *raftNode) applyBatch() bool {
+	r.applyLock.Lock()
+	defer r.applyLock.Unlock()
+	r.Lock()
+	if r.lastApplied >= r.commitIndex {
+		r.Unlock()
+		return false
+	}
+	batch := append([]entry{}, r.log[r.lastApplied-r.snapshotIndex:r.commitIndex-r.snapshotIndex]...)
+	r.Unlock()
+	var results = make([]error, len(batch))
+	for idx, e := range batch {
+		if !e.Noop {
+			results[idx] = r.applyCommand(e.Command)
+		}
+	}
+	r.Lock()
+	r.lastApplied = batch[len(batch)-1].Index
+	for idx, e := range batch {
+		if w, ok := r.waiters[e.Index]; ok {
+			if w.term == e.Term {
+				w.done <- results[idx]
+			} else {
+				w.done <- ErrLeadershipLost
+			}
+			delete(r.waiters, e.Index)
+		}
+	}
+	close(r.appliedCh)
+	r.appliedCh = make(chan struct{})
+	compact := r.config.SnapshotThreshold > 0 && r.lastApplied-r.snapshotIndex >= r.config.SnapshotThreshold
+	r.Unlock()
+	if compact {
+		if err := r.compact(); err != nil {
+			r.logError("Raft.Snapshot - Error: %s", err)
+		}
+	}
+	return true
+}
+
+func (r *raftNode) applyCommand(command []byte) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = errors.New(fmt.Sprintf("Raft.Apply - Error: %v", rec))
+		}
+	}()
+	return r.machine.Apply(command)
+}
+
+// Snapshots the state machine and discards the applied log entries, it must be called holding the apply lock
+func (r *raftNode) compact() error {
+	data, err := r.machine.Snapshot()
+	if err != nil {
+		return err
+	}
+	r.Lock()
+	defer r.Unlock()
+	index := r.lastApplied
+	if index <= r.snapshotIndex {
+		return nil
+	}
+	term := r.termAt(index)
+	log := append([]entry{}, r.log[index-r.snapshotIndex:]...)
+	if err := r.saveSnapshot(snapshotState{Index: index, Term: term, Data: data}, log); err != nil {
+		return err
+	}
+	r.snapshotTerm = term
+	r.log = log
+	r.snapshotIndex = index
+	return nil
+}
+
+// Confirms the leadership with a quorum, returning the commit index the reads must wait for
+func (r *raftNode) readIndex(ctx context.Context) (uint64, error) {
+	for {
+		r.Lock()
+		if r.role != ROLE_LEADER {
+			r.Unlock()
+			return 0, ErrNoLeader
+		}
+		// The no-op entry of the current term must be committed before the commit index is reliable
+		if r.termAt(r.commitIndex) == r.term {
+			break
+		}
+		applied := r.appliedCh
+		r.Unlock()
+		select {
+		case <-applied:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	index := r.commitIndex
+	term := r.term
+	peers := r.peers
+	quorum := r.quorum()
+	request := appendRequest{
+		Term:         term,
+		Leader:       r.id,
+		LeaderCommit: r.commitIndex,
+	}
+	r.Unlock()
+	if quorum == 1 {
+		return index, nil
+	}
+	acks := make(chan bool, len(peers))
+	for _, peer := range peers {
+		go func(peer string) {
+			var response appendResponse
+			if err := r.call(ctx, peer, methodAppend, request, &response); err != nil {
+				acks <- false
+				return
+			}
+			r.Lock()
+			if response.Term > r.term {
+				r.stepDown(response.Term)
+			}
+			r.Unlock()
+			acks <- response.Term == term
+		}(peer)
+	}
+	count := 1
+	for range peers {
+		if <-acks {
+			count++
+		}
+		if count >= quorum {
+			return index, nil
+		}
+	}
+	return 0, ErrLeadershipLost
+}
+
+// Waits until a leader is known, returning its address
+func (r *raftNode) waitLeader(ctx context.Context) (string, error) {
+	for {
+		r.Lock()
+		if !r.running {
+			r.Unlock()
+			return "", ErrStopped
+		}
+		if "" != r.leader {
+			leader := r.leader
+			r.Unlock()
+			return leader, nil
+		}
+		changed := r.leaderCh
+		stop := r.stop
+		r.Unlock()
+		select {
+		case <-changed:
+		case <-stop:
+			return "", ErrStopped
+		case <-ctx.Done():
+			return "", ErrNoLeader
+		}
+	}
+}
+
+// Records the current leader, waking up the callers waiting for one. It must be called holding the lock
+func (r *raftNode) setLeader(leader string) {
+	if r.leader == leader {
+		return
+	}
+	r.leader = leader
+	close(r.leaderCh)
+	r.leaderCh = make(chan struct{})
+}
+
+func (r *raftNode) waitApplied(ctx context.Context, index uint64) error {
+	for {
+		r.Lock()
+		if !r.running {
+			r.Unlock()
+			return ErrStopped
+		}
+		if r.lastApplied >= index {
+			r.Unlock()
+			return nil
+		}
+		applied := r.appliedCh
+		stop := r.stop
+		r.Unlock()
+		select {
+		case <-applied:
+		case <-stop:
+			return ErrStopped
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (r *raftNode) handleVote(ctx context.Context, method string, payload []byte) ([]byte, error) {
+	var request voteRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return nil, err
+	}
+	r.Lock()
+	defer r.Unlock()
+	if request.Term > r.term {
+		if err := r.stepDown(request.Term); err != nil {
+			return nil, err
+		}
+	}
+	granted := false
+	lastIndex := r.lastIndex()
+	lastTerm := r.termAt(lastIndex)
+	upToDate := request.LastLogTerm > lastTerm || (request.LastLogTerm == lastTerm && request.LastLogIndex >= lastIndex)
+	if request.Term == r.term && ("" == r.votedFor || r.votedFor == request.Candidate) && upToDate {
+		previous := r.votedFor
+		r.votedFor = request.Candidate
+		if err := r.persist(); err != nil {
+			r.votedFor = previous
+			return nil, err
+		}
+		r.resetDeadline()
+		granted = true
+	}
+	return json.Marshal(voteResponse{Term: r.term, Granted: granted})
+}
+
+func (r *raftNode) handleAppend(ctx context.Context, method string, payload []byte) ([]byte, error) {
+	var request appendRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return nil, err
+	}
+	r.Lock()
+	defer r.Unlock()
+	if request.Term < r.term {
+		return json.Marshal(appendResponse{Term: r.term})
+	}
+	if request.Term > r.term || r.role != ROLE_FOLLOWER {
+		if err := r.stepDown(request.Term); err != nil {
+			return nil, err
+		}
+	}
+	r.setLeader(request.Leader)
+	r.resetDeadline()
+	prev := request.PrevLogIndex
+	prevTerm := request.PrevLogTerm
+	entries := request.Entries
+	if prev < r.snapshotIndex {
+		skip := r.snapshotIndex - prev
+		if uint64(len(entries)) <= skip {
+			entries = nil
+		} else {
+			entries = entries[skip:]
+		}
+		prev = r.snapshotIndex
+		prevTerm = r.snapshotTerm
+	}
+	if prev > r.lastIndex() {
+		return json.Marshal(appendResponse{Term: r.term, ConflictIndex: r.lastIndex() + 1})
+	}
+	if conflictTerm := r.termAt(prev); conflictTerm != prevTerm {
+		index := prev
+		for index > r.snapshotIndex+1 && r.termAt(index-1) == conflictTerm {
+			index--
+		}
+		return json.Marshal(appendResponse{Term: r.term, ConflictIndex: index})
+	}
+	// The new entries are synced to disk before the log is changed and the append acknowledged
+	for idx, e := range entries {
+		if e.Index <= r.lastIndex() {
+			if r.termAt(e.Index) == e.Term {
+				continue
+			}
+			log := append(append([]entry{}, r.log[:e.Index-r.snapshotIndex-1]...), entries[idx:]...)
+			if err := r.rewriteLog(log); err != nil {
+				return nil, err
+			}
+			r.log = log
+			break
+		}
+		if err := r.appendLog(entries[idx:]); err != nil {
+			return nil, err
+		}
+		r.log = append(r.log, entries[idx:]...)
+		break
+	}
+	lastNew := prev + uint64(len(entries))
+	if request.LeaderCommit > r.commitIndex && lastNew > r.commitIndex {
+		r.commitIndex = request.LeaderCommit
+		if lastNew < r.commitIndex {
+			r.commitIndex = lastNew
+		}
+		select {
+		case r.commitCh <- struct{}{}:
+		default:
+		}
+	}
+	return json.Marshal(appendResponse{Term: r.term, Success: true})
+}
+
+func (r *raftNode) handleSnapshot(ctx context.Context, method string, payload []byte) ([]byte, error) {
+	var request snapshotRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return nil, err
+	}
+	r.applyLock.Lock()
+	defer r.applyLock.Unlock()
+	r.Lock()
+	defer r.Unlock()
+	if request.Term < r.term {
+		return json.Marshal(snapshotResponse{Term: r.term})
+	}
+	if request.Term > r.term || r.role != ROLE_FOLLOWER {
+		if err := r.stepDown(request.Term); err != nil {
+			return nil, err
+		}
+	}
+	r.setLeader(request.Leader)
+	r.resetDeadline()
+	if request.LastIndex <= r.lastApplied {
+		return json.Marshal(snapshotResponse{Term: r.term})
+	}
+	var log = make([]entry, 0)
+	if request.LastIndex <= r.lastIndex() && r.termAt(request.LastIndex) == request.LastTerm {
+		log = append(log, r.log[request.LastIndex-r.snapshotIndex:]...)
+	}
+	if err := r.saveSnapshot(snapshotState{Index: request.LastIndex, Term: request.LastTerm, Data: request.Data}, log); err != nil {
+		return nil, err
+	}
+	if err := r.machine.Restore(request.Data); err != nil {
+		return nil, err
+	}
+	r.log = log
+	r.snapshotIndex = request.LastIndex
+	r.snapshotTerm = request.LastTerm
+	r.lastApplied = request.LastIndex
+	if r.commitIndex < request.LastIndex {
+		r.commitIndex = request.LastIndex
+	}
+	close(r.appliedCh)
+	r.appliedCh = make(chan struct{})
+	return json.Marshal(snapshotResponse{Term: r.term})
+}
+
+func (r *raftNode) handleForward(ctx context.Context, method string, payload []byte) ([]byte, error) {
+	// Forwarded commands are not forwarded again, avoiding loops on stale leader information
+	if !r.IsLeader() {
+		return nil, ErrNoLeader
+	}
+	return nil, r.Propose(ctx, payload)
+}
+
+func (r *raftNode) handleReadIndex(ctx context.Context, method string, payload []byte) ([]byte, error) {
+	index, err := r.readIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(readIndexResponse{Index: index})
+}
+
+func (r *raftNode) call(ctx context.Context, peer string, method string, request interface{}, response interface{}) error {
+	var payload []byte
+	if request != nil {
+		var err error
+		if payload, err = json.Marshal(request); err != nil {
+			return err
+		}
+	}
+	answer, err := r.transport.Call(ctx, peer, method, payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(answer, response)
+}
+
+func (r *raftNode) lastIndex() uint64 {
+	return r.snapshotIndex + uint64(len(r.log))
+}
+
+func (r *raftNode) termAt(index uint64) uint64 {
+	if index == r.snapshotIndex {
+		return r.snapshotTerm
+	}
+	if index < r.snapshotIndex || index > r.lastIndex() {
+		return 0
+	}
+	return r.log[index-r.snapshotIndex-1].Term
+}
+
+func (r *raftNode) quorum() int {
+	return (len(r.peers)+1)/2 + 1
+}
+
+func (r *raftNode) resetDeadline() {
+	timeout := r.config.ElectionTimeout + time.Duration(r.random.Int63n(int64(r.config.ElectionTimeout)))
+	r.deadline = time.Now().Add(timeout)
+}
+
+func (r *raftNode) failWaiters(err error) {
+	for index, w := range r.waiters {
+		w.done <- err
+		delete(r.waiters, index)
+	}
+}
+
+// Saves the term and the vote, it must be called holding the lock
+func (r *raftNode) persist() error {
+	if r.storage == nil {
+		return nil
+	}
+	if err := r.storage.saveState(hardState{Term: r.term, VotedFor: r.votedFor}); err != nil {
+		r.logError("Raft.Persist - Unable to save state file: %s, Details: %s", r.storage.stateFile, err)
+		return errors.New(fmt.Sprintf("Raft.Persist - Error: %s", err))
+	}
+	return nil
+}
+
+// Appends the entries to the durable log, it must be called holding the lock
+func (r *raftNode) appendLog(entries []entry) error {
+	if r.storage == nil {
+		return nil
+	}
+	if err := r.storage.appendLog(entries); err != nil {
+		r.logError("Raft.Persist - Unable to append to log file: %s, Details: %s", r.storage.logFile, err)
+		return errors.New(fmt.Sprintf("Raft.Persist - Error: %s", err))
+	}
+	return nil
+}
+
+// Replaces the durable log after a conflict, it must be called holding the lock
+func (r *raftNode) rewriteLog(entries []entry) error {
+	if r.storage == nil {
+		return nil
+	}
+	if err := r.storage.rewriteLog(entries); err != nil {
+		r.logError("Raft.Persist - Unable to write log file: %s, Details: %s", r.storage.logFile, err)
+		return errors.New(fmt.Sprintf("Raft.Persist - Error: %s", err))
+	}
+	return nil
+}
+
+// Saves the snapshot and the log entries following it, it must be called holding the lock
+func (r *raftNode) saveSnapshot(snapshot snapshotState, entries []entry) error {
+	if r.storage == nil {
+		return nil
+	}
+	if err := r.storage.saveSnapshot(snapshot); err != nil {
+		r.logError("Raft.Persist - Unable to save snapshot file: %s, Details: %s", r.storage.snapshotFile, err)
+		return errors.New(fmt.Sprintf("Raft.Persist - Error: %s", err))
+	}
+	// Entries already contained in the snapshot are skipped at load, so a failure here is harmless
+	if err := r.storage.rewriteLog(entries); err != nil {
+		r.logError("Raft.Persist - Unable to write log file: %s, Details: %s", r.storage.logFile, err)
+	}
+	return nil
+}
+
+// Loads the durable state, restoring the last snapshot in the state machine. It must be called holding the lock
+func (r *raftNode) load() error {
+	if r.storage == nil {
+		return nil
+	}
+	state, snapshot, entries, err := r.storage.load()
+	if err != nil {
+		return err
+	}
+	r.term = state.Term
+	r.votedFor = state.VotedFor
+	if snapshot != nil && snapshot.Index > r.snapshotIndex {
+		if err := r.machine.Restore(snapshot.Data); err != nil {
+			return err
+		}
+		r.snapshotIndex = snapshot.Index
+		r.snapshotTerm = snapshot.Term
+		r.commitIndex = snapshot.Index
+		r.lastApplied = snapshot.Index
+	}
+	r.log = make([]entry, 0)
+	for _, e := range entries {
+		if e.Index > r.snapshotIndex {
+			r.log = append(r.log, e)
+		}
+	}
+	return nil
+}
+
+func (r *raftNode) logError(format string, in ...interface{}) {
+	if r.logger != nil {
+		r.logger.Errorf(format, in...)
+	}
+}
+
+// Creates a new raft node on the given transport, the last snapshot saved with the config StateFile
+// is restored in the state machine at start. Zero configuration values are replaced by the package defaults
+func NewRaft(config Config, transport Transport, machine StateMachine, logger log.Logger) Raft {
+	if config.ElectionTimeout <= 0 {
+		config.ElectionTimeout = DEFAULT_ELECTION_TIMEOUT
+	}
+	if config.HeartbeatInterval <= 0 {
+		config.HeartbeatInterval = DEFAULT_HEARTBEAT_INTERVAL
+	}
+	if config.SnapshotThreshold == 0 {
+		config.SnapshotThreshold = DEFAULT_SNAPSHOT_THRESHOLD
+	}
+	if config.MaxAppendEntries <= 0 {
+		config.MaxAppendEntries = DEFAULT_MAX_APPEND_ENTRIES
+	}
+	id := transport.Address()
+	var peers = make([]string, 0)
+	for _, peer := range config.Peers {
+		if peer != id {
+			peers = append(peers, peer)
+		}
+	}
+	var store *storage
+	if "" != config.StateFile {
+		store = newStorage(config.StateFile)
+	}
+	return &raftNode{
+		config:     config,
+		storage:    store,
+		id:         id,
+		peers:      peers,
+		transport:  transport,
+		machine:    machine,
+		role:       ROLE_FOLLOWER,
+		log:        make([]entry, 0),
+		nextIndex:  make(map[string]uint64),
+		matchIndex: make(map[string]uint64),
+		inflight:   make(map[string]bool),
+		waiters:    make(map[uint64]waiter),
+		commitCh:   make(chan struct{}, 1),
+		appliedCh:  make(chan struct{}),
+		leaderCh:   make(chan struct{}),
+		random:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		logger:     logger,
+	}
+}
@@ -0,0 +1,103 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"github.com/hellgate75/go-tcp-common/net/rpc"
+	"time"
+)
+
+// Raft node role
+type Role byte
+
+const (
+	ROLE_FOLLOWER Role = iota + 1
+	ROLE_CANDIDATE
+	ROLE_LEADER
+)
+
+var (
+	// Default base election timeout, the actual timeout is randomized between this value and its double
+	DEFAULT_ELECTION_TIMEOUT time.Duration = 300 * time.Millisecond
+	// Default interval between two leader heartbeats
+	DEFAULT_HEARTBEAT_INTERVAL time.Duration = 50 * time.Millisecond
+	// Default number of applied log entries that triggers a snapshot and the log compaction
+	DEFAULT_SNAPSHOT_THRESHOLD uint64 = 1024
+	// Default maximum number of entries sent in a single append request
+	DEFAULT_MAX_APPEND_ENTRIES int = 64
+
+	// Error returned when no leader is currently known
+	ErrNoLeader = errors.New("raft: no leader available")
+	// Error returned when the node lost the leadership before the command was committed
+	ErrLeadershipLost = errors.New("raft: leadership lost")
+	// Error returned by operations on a stopped node
+	ErrStopped = errors.New("raft: node stopped")
+)
+
+// Replicated state machine, commands are applied in the same order on all nodes
+type StateMachine interface {
+	// Apply a committed command
+	Apply(command []byte) error
+	// Encode the current state, it is called holding the apply lock so no command is applied meanwhile
+	Snapshot() ([]byte, error)
+	// Replace the current state with a snapshot received from the leader
+	Restore(snapshot []byte) error
+}
+
+// Message transport between raft nodes, peers are identified by their transport addresses
+type Transport interface {
+	// Address used by the other peers to reach the local node
+	Address() string
+	// Register a handler for a method name, returns false if the method is already registered
+	Handle(method string, handler rpc.Handler) bool
+	// Call a method on a peer, waiting for the answer until the context is done
+	Call(ctx context.Context, peer string, method string, payload []byte) ([]byte, error)
+	Start() error
+	Close() error
+}
+
+// Raft configuration, Peers lists the transport addresses of all the cluster nodes (including the local one).
+// When StateFile is not empty the term and the vote are saved in it, while the log entries and the last
+// snapshot are saved in the files with the same path and the ".log" and ".snapshot" extensions. They are
+// synced to disk before a vote or an entry is acknowledged and they are loaded at start, so the node
+// survives restarts. Without StateFile the node state is kept in memory only
+type Config struct {
+	Peers             []string
+	ElectionTimeout   time.Duration
+	HeartbeatInterval time.Duration
+	SnapshotThreshold uint64
+	MaxAppendEntries  int
+	StateFile         string
+}
+
+type Raft interface {
+	Start() error
+	Stop() error
+	// Replicate a command, returning after it is applied on the leader state machine.
+	// Followers forward the command to the leader
+	Propose(ctx context.Context, command []byte) error
+	// Wait until the local state machine reflects all commands committed before the call,
+	// so that following local reads are linearizable
+	ReadBarrier(ctx context.Context) error
+	// Take a snapshot of the state machine and compact the log
+	Snapshot() error
+	IsLeader() bool
+	// Address of the current leader, if known
+	Leader() (string, bool)
+	Role() Role
+	Term() uint64
+	Id() string
+}
+
+func (r Role) String() string {
+	switch r {
+	case ROLE_FOLLOWER:
+		return "Follower"
+	case ROLE_CANDIDATE:
+		return "Candidate"
+	case ROLE_LEADER:
+		return "Leader"
+	default:
+		return "Unknown"
+	}
+}
@@ -0,0 +1,331 @@
+package raft
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/rpc"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// In memory network, peers can be isolated to simulate partitions and crashes
+type memoryNetwork struct {
+	sync.Mutex
+	handlers map[string]map[string]rpc.Handler
+	isolated map[string]bool
+}
+
+type memoryTransport struct {
+	network *memoryNetwork
+	address string
+}
+
+func (mt *memoryTransport) Address() string {
+	return mt.address
+}
+
+func (mt *memoryTransport) Handle(method string, handler rpc.Handler) bool {
+	mt.network.Lock()
+	defer mt.network.Unlock()
+	if _, ok := mt.network.handlers[mt.address][method]; ok {
+		return false
+	}
+	mt.network.handlers[mt.address][method] = handler
+	return true
+}
+
+func (mt *memoryTransport) Call(ctx context.Context, peer string, method string, payload []byte) ([]byte, error) {
+	mt.network.Lock()
+	handler, ok := mt.network.handlers[peer][method]
+	down := mt.network.isolated[peer] || mt.network.isolated[mt.address]
+	mt.network.Unlock()
+	if !ok || down {
+		return nil, errors.New("unreachable peer: " + peer)
+	}
+	return handler(ctx, method, payload)
+}
+
+func (mt *memoryTransport) Start() error {
+	return nil
+}
+
+func (mt *memoryTransport) Close() error {
+	return nil
+}
+
+func (mn *memoryNetwork) transport(address string) Transport {
+	mn.Lock()
+	defer mn.Unlock()
+	mn.handlers[address] = make(map[string]rpc.Handler)
+	return &memoryTransport{network: mn, address: address}
+}
+
+func (mn *memoryNetwork) isolate(address string, isolated bool) {
+	mn.Lock()
+	defer mn.Unlock()
+	mn.isolated[address] = isolated
+}
+
+// Key/value state machine, commands are "key=value" json pairs
+type kvMachine struct {
+	sync.Mutex
+	values map[string]string
+}
+
+func (kv *kvMachine) Apply(command []byte) error {
+	var pair [2]string
+	if err := json.Unmarshal(command, &pair); err != nil {
+		return err
+	}
+	kv.Lock()
+	defer kv.Unlock()
+	kv.values[pair[0]] = pair[1]
+	return nil
+}
+
+func (kv *kvMachine) Snapshot() ([]byte, error) {
+	kv.Lock()
+	defer kv.Unlock()
+	return json.Marshal(kv.values)
+}
+
+func (kv *kvMachine) Restore(snapshot []byte) error {
+	var values = make(map[string]string)
+	if err := json.Unmarshal(snapshot, &values); err != nil {
+		return err
+	}
+	kv.Lock()
+	defer kv.Unlock()
+	kv.values = values
+	return nil
+}
+
+func (kv *kvMachine) get(key string) string {
+	kv.Lock()
+	defer kv.Unlock()
+	return kv.values[key]
+}
+
+func newMemoryNetwork() *memoryNetwork {
+	return &memoryNetwork{
+		handlers: make(map[string]map[string]rpc.Handler),
+		isolated: make(map[string]bool),
+	}
+}
+
+func startNodes(t *testing.T, size int, threshold uint64) (*memoryNetwork, []Raft, []*kvMachine) {
+	network := newMemoryNetwork()
+	var peers = make([]string, 0)
+	for i := 0; i < size; i++ {
+		peers = append(peers, fmt.Sprintf("node-%v", i))
+	}
+	nodes, machines := startPeers(t, network, peers, threshold, "")
+	return network, nodes, machines
+}
+
+// Starts the peers nodes, saving their state in the folder when it is not empty
+func startPeers(t *testing.T, network *memoryNetwork, peers []string, threshold uint64, folder string) ([]Raft, []*kvMachine) {
+	var nodes = make([]Raft, 0)
+	var machines = make([]*kvMachine, 0)
+	for _, peer := range peers {
+		machine := &kvMachine{values: make(map[string]string)}
+		var stateFile string
+		if "" != folder {
+			stateFile = filepath.Join(folder, peer+".state")
+		}
+		node := NewRaft(Config{
+			Peers:             peers,
+			ElectionTimeout:   100 * time.Millisecond,
+			HeartbeatInterval: 20 * time.Millisecond,
+			SnapshotThreshold: threshold,
+			StateFile:         stateFile,
+		}, network.transport(peer), machine, nil)
+		if err := node.Start(); err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, node)
+		machines = append(machines, machine)
+	}
+	return nodes, machines
+}
+
+func waitLeader(t *testing.T, nodes []Raft, exclude int) int {
+	limit := time.Now().Add(5 * time.Second)
+	for time.Now().Before(limit) {
+		for idx, node := range nodes {
+			if idx != exclude && node.IsLeader() {
+				return idx
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("TestRaft - Raft.IsLeader - Expected: %v but Given: %v", "a leader", "no leader")
+	return -1
+}
+
+func set(node Raft, key string, value string) error {
+	command, _ := json.Marshal([2]string{key, value})
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return node.Propose(ctx, command)
+}
+
+func read(t *testing.T, node Raft, machine *kvMachine, key string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := node.ReadBarrier(ctx); err != nil {
+		t.Fatalf("TestRaft - Raft.ReadBarrier - Expected: %v but Given: %v", nil, err)
+	}
+	return machine.get(key)
+}
+
+func TestRaftReplicationAndFailover(t *testing.T) {
+	network, nodes, machines := startNodes(t, 3, 0)
+	defer func() {
+		for _, node := range nodes {
+			node.Stop()
+		}
+	}()
+	leader := waitLeader(t, nodes, -1)
+	follower := (leader + 1) % 3
+	if err := set(nodes[follower], "a", "1"); err != nil {
+		t.Fatalf("TestRaftReplicationAndFailover - Raft.Propose - Expected: %v but Given: %v", nil, err)
+	}
+	for idx := range nodes {
+		if value := read(t, nodes[idx], machines[idx], "a"); value != "1" {
+			t.Fatalf("TestRaftReplicationAndFailover - Raft.ReadBarrier - Expected: %v but Given: %v", "1", value)
+		}
+	}
+
+	network.isolate(nodes[leader].Id(), true)
+	newLeader := waitLeader(t, nodes, leader)
+	if err := set(nodes[newLeader], "a", "2"); err != nil {
+		t.Fatalf("TestRaftReplicationAndFailover - Raft.Propose - Expected: %v but Given: %v", nil, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := nodes[leader].ReadBarrier(ctx); err == nil {
+		t.Fatalf("TestRaftReplicationAndFailover - Raft.ReadBarrier - Expected: %v but Given: %v", "error", err)
+	}
+
+	network.isolate(nodes[leader].Id(), false)
+	limit := time.Now().Add(5 * time.Second)
+	for time.Now().Before(limit) && machines[leader].get("a") != "2" {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if value := read(t, nodes[leader], machines[leader], "a"); value != "2" {
+		t.Fatalf("TestRaftReplicationAndFailover - Raft.ReadBarrier - Expected: %v but Given: %v", "2", value)
+	}
+}
+
+func TestRaftSnapshotInstall(t *testing.T) {
+	network, nodes, machines := startNodes(t, 3, 10)
+	defer func() {
+		for _, node := range nodes {
+			node.Stop()
+		}
+	}()
+	leader := waitLeader(t, nodes, -1)
+	lagging := (leader + 1) % 3
+	network.isolate(nodes[lagging].Id(), true)
+	for i := 0; i < 35; i++ {
+		if err := set(nodes[leader], fmt.Sprintf("key-%v", i), fmt.Sprintf("%v", i)); err != nil {
+			t.Fatalf("TestRaftSnapshotInstall - Raft.Propose - Expected: %v but Given: %v", nil, err)
+		}
+	}
+	if len(nodes[leader].(*raftNode).log) >= 20 {
+		t.Fatalf("TestRaftSnapshotInstall - Raft.Snapshot - Expected: %v but Given: %v", "compacted log", len(nodes[leader].(*raftNode).log))
+	}
+	network.isolate(nodes[lagging].Id(), false)
+	if value := read(t, nodes[lagging], machines[lagging], "key-34"); value != "34" {
+		t.Fatalf("TestRaftSnapshotInstall - Raft.ReadBarrier - Expected: %v but Given: %v", "34", value)
+	}
+	if value := machines[lagging].get("key-0"); value != "0" {
+		t.Fatalf("TestRaftSnapshotInstall - Raft.Restore - Expected: %v but Given: %v", "0", value)
+	}
+}
+
+func TestRaftRestart(t *testing.T) {
+	network := newMemoryNetwork()
+	peers := []string{"node-0", "node-1", "node-2"}
+	folder := t.TempDir()
+	nodes, _ := startPeers(t, network, peers, 10, folder)
+	leader := waitLeader(t, nodes, -1)
+	for i := 0; i < 25; i++ {
+		if err := set(nodes[leader], fmt.Sprintf("key-%v", i), fmt.Sprintf("%v", i)); err != nil {
+			t.Fatalf("TestRaftRestart - Raft.Propose - Expected: %v but Given: %v", nil, err)
+		}
+	}
+	for _, node := range nodes {
+		node.Stop()
+	}
+
+	// The whole cluster restarts with empty state machines
+	nodes, machines := startPeers(t, network, peers, 10, folder)
+	defer func() {
+		for _, node := range nodes {
+			node.Stop()
+		}
+	}()
+	waitLeader(t, nodes, -1)
+	for idx := range nodes {
+		if value := read(t, nodes[idx], machines[idx], "key-24"); value != "24" {
+			t.Fatalf("TestRaftRestart - Raft.ReadBarrier - Expected: %v but Given: %v", "24", value)
+		}
+		if value := machines[idx].get("key-0"); value != "0" {
+			t.Fatalf("TestRaftRestart - Raft.Restore - Expected: %v but Given: %v", "0", value)
+		}
+	}
+}
+
+func TestRaftPersistFailure(t *testing.T) {
+	network := newMemoryNetwork()
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := ioutil.WriteFile(blocker, []byte{}, 0660); err != nil {
+		t.Fatal(err)
+	}
+	node := NewRaft(Config{
+		Peers:     []string{"node-0", "node-1", "node-2"},
+		StateFile: filepath.Join(blocker, "node-0.state"),
+	}, network.transport("node-0"), &kvMachine{values: make(map[string]string)}, nil).(*raftNode)
+
+	vote, _ := json.Marshal(voteRequest{Term: 1, Candidate: "node-1"})
+	for i := 0; i < 2; i++ {
+		if _, err := node.handleVote(context.Background(), methodVote, vote); err == nil || "" != node.votedFor {
+			t.Fatalf("TestRaftPersistFailure - Raft.Vote - Expected: %v but Given: %v (%q)", "not saved vote error", err, node.votedFor)
+		}
+	}
+	appended, _ := json.Marshal(appendRequest{Term: 1, Leader: "node-1", Entries: []entry{{Index: 1, Term: 1, Command: []byte("{}")}}})
+	if _, err := node.handleAppend(context.Background(), methodAppend, appended); err == nil || len(node.log) != 0 {
+		t.Fatalf("TestRaftPersistFailure - Raft.Append - Expected: %v but Given: %v (%v)", "not saved entry error", err, len(node.log))
+	}
+}
+
+func TestRaftStorageTornTail(t *testing.T) {
+	store := newStorage(filepath.Join(t.TempDir(), "node-0.state"))
+	if err := store.appendLog([]entry{{Index: 1, Term: 1}, {Index: 2, Term: 1}}); err != nil {
+		t.Fatalf("TestRaftStorageTornTail - storage.appendLog - Expected: %v but Given: %v", nil, err)
+	}
+	file, _ := os.OpenFile(store.logFile, os.O_WRONLY|os.O_APPEND, 0660)
+	file.Write([]byte(`{"index":3,"te`))
+	file.Close()
+	if _, _, entries, err := store.load(); err != nil || len(entries) != 2 {
+		t.Fatalf("TestRaftStorageTornTail - storage.load - Expected: %v but Given: %v (%v)", 2, len(entries), err)
+	}
+	if err := store.appendLog([]entry{{Index: 3, Term: 2}}); err != nil {
+		t.Fatalf("TestRaftStorageTornTail - storage.appendLog - Expected: %v but Given: %v", nil, err)
+	}
+	if err := store.appendLog([]entry{{Index: 4, Term: 2}}); err != nil {
+		t.Fatalf("TestRaftStorageTornTail - storage.appendLog - Expected: %v but Given: %v", nil, err)
+	}
+	_, _, entries, err := store.load()
+	if err != nil || len(entries) != 4 || entries[2].Term != 2 || entries[3].Index != 4 {
+		t.Fatalf("TestRaftStorageTornTail - storage.load - Expected: %v but Given: %+v (%v)", 4, entries, err)
+	}
+}
@@ -0,0 +1,210 @@
+package raft
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	cio "github.com/hellgate75/go-tcp-common/io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Last snapshot of the state machine, with the position of the last entry it contains
+type snapshotState struct {
+	Index uint64 `json:"index"`
+	Term  uint64 `json:"term"`
+	Data  []byte `json:"data,omitempty"`
+}
+
+// Durable raft storage: the hard state is saved in the state file, the log entries are appended to the
+// ".log" file and the last snapshot is saved in the ".snapshot" file. Every write is synced to disk
+// before returning, so that a node never acknowledges a vote or an entry it could forget
+type storage struct {
+	stateFile    string
+	logFile      string
+	snapshotFile string
+}
+
+func (s *storage) saveState(state hardState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return writeFileSync(s.stateFile, data)
+}
+
+// Appends the entries to the log file
+func (s *storage) appendLog(entries []entry) error {
+	data, err := encodeEntries(entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.logFile), os.FileMode(0775)); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0660)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err == nil {
+		err = file.Sync()
+	}
+	if errC := file.Close(); err == nil {
+		err = errC
+	}
+	return err
+}
+
+// Replaces the log file content with the given entries
+func (s *storage) rewriteLog(entries []entry) error {
+	data, err := encodeEntries(entries)
+	if err != nil {
+		return err
+	}
+	return writeFileSync(s.logFile, data)
+}
+
+func (s *storage) saveSnapshot(snapshot snapshotState) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return writeFileSync(s.snapshotFile, data)
+}
+
+// Reads the hard state, the last snapshot (nil when missing) and the log entries following it
+func (s *storage) load() (hardState, *snapshotState, []entry, error) {
+	var state = hardState{}
+	var snapshot *snapshotState
+	var entries = make([]entry, 0)
+	if cio.ExistsFile(s.stateFile) {
+		data, err := ioutil.ReadFile(s.stateFile)
+		if err != nil {
+			return state, nil, entries, err
+		}
+		if err = json.Unmarshal(data, &state); err != nil {
+			return state, nil, entries, errors.New(fmt.Sprintf("Invalid state file: %s, Details: %s", s.stateFile, err))
+		}
+	}
+	var last uint64
+	if cio.ExistsFile(s.snapshotFile) {
+		data, err := ioutil.ReadFile(s.snapshotFile)
+		if err != nil {
+			return state, nil, entries, err
+		}
+		snapshot = &snapshotState{}
+		if err = json.Unmarshal(data, snapshot); err != nil {
+			return state, nil, entries, errors.New(fmt.Sprintf("Invalid snapshot file: %s, Details: %s", s.snapshotFile, err))
+		}
+		last = snapshot.Index
+	}
+	if !cio.ExistsFile(s.logFile) {
+		return state, snapshot, entries, nil
+	}
+	data, err := ioutil.ReadFile(s.logFile)
+	if err != nil {
+		return state, snapshot, entries, err
+	}
+	first := last
+	content := bytes.TrimRight(data, "\n")
+	lines := bytes.Split(content, []byte("\n"))
+	for idx, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			// A partial last line is an append interrupted before the sync, so never acknowledged: it is cut
+			// from the file, otherwise the next append would be written on the same line
+			if idx == len(lines)-1 {
+				if err := truncateFileSync(s.logFile, int64(bytes.LastIndexByte(content, '\n')+1)); err != nil {
+					return state, snapshot, entries, err
+				}
+				break
+			}
+			return state, snapshot, entries, errors.New(fmt.Sprintf("Invalid log file: %s, line: %v, Details: %s", s.logFile, idx+1, err))
+		}
+		if e.Index <= first {
+			continue
+		}
+		if e.Index <= last {
+			entries = entries[:e.Index-first-1]
+		} else if e.Index > last+1 {
+			return state, snapshot, entries, errors.New(fmt.Sprintf("Missing log entries in file: %s, expected index: %v, given: %v", s.logFile, last+1, e.Index))
+		}
+		entries = append(entries, e)
+		last = e.Index
+	}
+	return state, snapshot, entries, nil
+}
+
+func encodeEntries(entries []entry) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := bufio.NewWriter(&buffer)
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		writer.Write(data)
+		writer.WriteByte('\n')
+	}
+	writer.Flush()
+	return buffer.Bytes(), nil
+}
+
+// Writes the file through a synced temporary file, replacing it only when the content is on disk
+func writeFileSync(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0775)); err != nil {
+		return err
+	}
+	temp := path + ".tmp"
+	file, err := os.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err == nil {
+		err = file.Sync()
+	}
+	if errC := file.Close(); err == nil {
+		err = errC
+	}
+	if err != nil {
+		os.Remove(temp)
+		return err
+	}
+	if err = os.Rename(temp, path); err != nil {
+		return err
+	}
+	if folder, errF := os.Open(filepath.Dir(path)); errF == nil {
+		folder.Sync()
+		folder.Close()
+	}
+	return nil
+}
+
+// Truncates the file to the given size, syncing it to disk
+func truncateFileSync(path string, size int64) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0660)
+	if err != nil {
+		return err
+	}
+	if err = file.Truncate(size); err == nil {
+		err = file.Sync()
+	}
+	if errC := file.Close(); err == nil {
+		err = errC
+	}
+	return err
+}
+
+func newStorage(stateFile string) *storage {
+	return &storage{
+		stateFile:    stateFile,
+		logFile:      stateFile + ".log",
+		snapshotFile: stateFile + ".snapshot",
+	}
+}
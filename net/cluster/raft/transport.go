@@ -0,0 +1,150 @@
+package raft
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/rpc"
+	"net"
+	"sync"
+)
+
+// Transport based on the multiplexed rpc layer, keeping one (TLS) connection for each peer
+type rpcTransport struct {
+	sync.Mutex
+	address   string
+	tlsConfig *tls.Config
+	server    rpc.Server
+	listener  net.Listener
+	clients   map[string]rpc.Client
+	conns     map[net.Conn]bool
+	logger    log.Logger
+}
+
+func (rt *rpcTransport) Address() string {
+	return rt.address
+}
+
+func (rt *rpcTransport) Handle(method string, handler rpc.Handler) bool {
+	return rt.server.Handle(method, handler)
+}
+
+func (rt *rpcTransport) Start() error {
+	rt.Lock()
+	defer rt.Unlock()
+	if rt.listener != nil {
+		return errors.New(fmt.Sprintf("Raft.Transport.Start - Transport already listening on: %s", rt.address))
+	}
+	var listener net.Listener
+	var err error
+	if rt.tlsConfig != nil {
+		listener, err = tls.Listen("tcp", rt.address, rt.tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", rt.address)
+	}
+	if err != nil {
+		return err
+	}
+	rt.listener = listener
+	go rt.accept(listener)
+	return nil
+}
+
+func (rt *rpcTransport) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		rt.Lock()
+		rt.conns[conn] = true
+		rt.Unlock()
+		go func(conn net.Conn) {
+			rt.server.Serve(conn)
+			rt.Lock()
+			delete(rt.conns, conn)
+			rt.Unlock()
+		}(conn)
+	}
+}
+
+func (rt *rpcTransport) Call(ctx context.Context, peer string, method string, payload []byte) ([]byte, error) {
+	client, err := rt.client(ctx, peer)
+	if err != nil {
+		return nil, err
+	}
+	answer, err := client.Call(ctx, method, payload)
+	if err != nil && client.IsClosed() {
+		rt.Lock()
+		if rt.clients[peer] == client {
+			delete(rt.clients, peer)
+		}
+		rt.Unlock()
+	}
+	return answer, err
+}
+
+func (rt *rpcTransport) client(ctx context.Context, peer string) (rpc.Client, error) {
+	rt.Lock()
+	if client, ok := rt.clients[peer]; ok && !client.IsClosed() {
+		rt.Unlock()
+		return client, nil
+	}
+	rt.Unlock()
+	var conn net.Conn
+	var err error
+	if rt.tlsConfig != nil {
+		dialer := &tls.Dialer{Config: rt.tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", peer)
+	} else {
+		dialer := &net.Dialer{}
+		conn, err = dialer.DialContext(ctx, "tcp", peer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	client := rpc.NewClient(conn, rt.logger)
+	rt.Lock()
+	defer rt.Unlock()
+	if existing, ok := rt.clients[peer]; ok && !existing.IsClosed() {
+		client.Close()
+		return existing, nil
+	}
+	rt.clients[peer] = client
+	return client, nil
+}
+
+func (rt *rpcTransport) Close() error {
+	rt.Lock()
+	defer rt.Unlock()
+	var err error
+	if rt.listener != nil {
+		err = rt.listener.Close()
+		rt.listener = nil
+	}
+	for peer, client := range rt.clients {
+		client.Close()
+		delete(rt.clients, peer)
+	}
+	for conn := range rt.conns {
+		conn.Close()
+		delete(rt.conns, conn)
+	}
+	return err
+}
+
+// Creates a new rpc transport listening on the given address (host:port), which is also the raft
+// node identifier. In case tlsConfig is nil peers communicate over plain tcp connections, otherwise
+// the same configuration is used to accept and to dial the peers connections
+func NewRpcTransport(address string, tlsConfig *tls.Config, logger log.Logger) Transport {
+	return &rpcTransport{
+		address:   address,
+		tlsConfig: tlsConfig,
+		server:    rpc.NewServer(logger),
+		clients:   make(map[string]rpc.Client),
+		conns:     make(map[net.Conn]bool),
+		logger:    logger,
+	}
+}
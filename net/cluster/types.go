@@ -1,16 +1,23 @@
 package cluster
 
 import (
+	"context"
+	"crypto/tls"
 	cio "github.com/hellgate75/go-tcp-common/io"
+	"github.com/hellgate75/go-tcp-common/net/cluster/raft"
 	"github.com/hellgate75/go-tcp-common/net/cluster/types"
+	"github.com/hellgate75/go-tcp-common/net/common"
+	rcom "github.com/hellgate75/go-tcp-common/net/rest/common"
 	"net"
 	"regexp"
+	"time"
 )
 
 type ClusterRegistry interface{
 	Register(n *types.Node) error
 	Update(field string, filter regexp.Regexp, n types.Node) error
 	Recover(field string, filter regexp.Regexp) ([]*types.Node, error)
+	Remove(field string, filter regexp.Regexp) error
 	List() []types.Node
 	EnablePersistence(registryFile string) error
 	DisablePersistence() error
@@ -32,5 +39,161 @@ type ClusterNode interface {
 	DisableRegistryPersistence() error
 	EnablePlugins(pluginFolder string, pluginExtension string) error
 	DisablePlugins() error
+	RegisterCommand(path string, action common.ApiAction, command *types.Command) error
+	// Run a command on an active registry node exposing it, trying the next node when one is unreachable
+	Execute(commandName string, arguments ...string) (*types.CommandResult, error)
+	// Run a command exposed by the given node
+	ExecuteOn(n *types.Node, commandName string, arguments ...string) (*types.CommandResult, error)
 	DumpConfigToFile(configFile string)
-}
\ No newline at end of file
+}
+type MembershipEventType byte
+
+const (
+	EVENT_NODE_JOINED MembershipEventType = iota + 1
+	EVENT_NODE_LEFT
+	EVENT_NODE_STATE_CHANGED
+)
+
+// Membership change event, reported to the subscribed listeners
+type MembershipEvent struct {
+	Type          MembershipEventType
+	Node          types.Node
+	PreviousState types.NodeState
+	State         types.NodeState
+	Time          time.Time
+}
+
+// Listener of membership change events
+type MembershipListener func(event MembershipEvent)
+
+// Heartbeat configuration: a node becomes unreachable after SuspicionThreshold consecutive failed pings
+// and it is removed from the registry after FailureThreshold consecutive failed pings
+type MembershipConfig struct {
+	Interval           time.Duration
+	Timeout            time.Duration
+	SuspicionThreshold int
+	FailureThreshold   int
+	TLSConfig          *tls.Config
+}
+
+type Membership interface {
+	Start() error
+	Stop() error
+	IsRunning() bool
+	Heartbeat()
+	Join(n *types.Node) error
+	Leave(name string) error
+	Subscribe(listener MembershipListener) string
+	Unsubscribe(id string) bool
+}
+
+// Gossip based membership. Node ports are cluster node (API) ports, so Join reaches the seed node on the
+// local gossip port, while JoinAddress joins through an explicit seed gossip UDP address
+type GossipMembership interface {
+	Membership
+	// Join the gossip cluster through the given seed gossip UDP address (host:port)
+	JoinAddress(address string) error
+	// Gossip UDP address of the local node
+	Address() string
+}
+
+// Listener of leadership changes, isLeader reports if the local node is the new leader
+type LeadershipListener func(leader types.Node, isLeader bool)
+
+// Election configuration: the leader is checked every Interval, remote nodes are contacted within Timeout
+// and a candidate waits ElectionTimeout for the coordinator announcement after being bullied.
+// Only nodes with one of the EligibleRoles take part to the election
+type ElectionConfig struct {
+	Interval        time.Duration
+	Timeout         time.Duration
+	ElectionTimeout time.Duration
+	EligibleRoles   []types.NodeType
+}
+
+type Election interface {
+	Start() error
+	Stop() error
+	IsRunning() bool
+	Elect() error
+	IsLeader() bool
+	Leader() (types.Node, bool)
+	Term() uint64
+	Subscribe(listener LeadershipListener) string
+	Unsubscribe(id string) bool
+}
+
+// Cluster registry replicated across the master and coordinator nodes through a raft consensus log
+type ReplicatedClusterRegistry interface {
+	ClusterRegistry
+	Start() error
+	Stop() error
+	Raft() raft.Raft
+}
+
+// Command runner configuration: each execution is killed after Timeout, while caller arguments are
+// accepted only if they fully match one of the AllowedArguments regular expressions of the command name.
+// Request bodies larger than MaxRequestSize bytes are refused
+type CommandRunnerConfig struct {
+	Timeout          time.Duration
+	AllowedArguments map[string][]string
+	MaxOutputSize    int
+	MaxRequestSize   int64
+}
+
+// Runs the cluster commands as local processes
+type CommandRunner interface {
+	// Expose the services commands as node endpoints
+	Expose(services ...types.Service) error
+	// Run a command with the given caller arguments
+	Run(ctx context.Context, command types.Command, arguments []string) (types.CommandResult, error)
+}
+
+// Load balancing strategy of the balancing client
+type BalancingStrategy byte
+
+const (
+	// Requests are spread in turn across the target nodes
+	BALANCING_ROUND_ROBIN BalancingStrategy = iota + 1
+	// Requests are sent to the node with the lowest ping answer time
+	BALANCING_LEAST_LATENCY
+	// Requests with the same key are sent to the same node, moving only the keys of the nodes leaving the targets
+	BALANCING_CONSISTENT_HASH
+)
+
+// Registry query of the balancing client targets, empty values match any node. Only active nodes,
+// not unreachable, are targets
+type NodeQuery struct {
+	Roles   []types.NodeType
+	States  []types.NodeState
+	// Name of a service command the nodes must expose
+	Command string
+}
+
+// Balancing client configuration: nodes are contacted in https when TLSConfig is set. Nodes failing a request
+// or a ping are skipped for UnreachableTimeout, while a positive RefreshInterval pings the targets periodically,
+// updating their latency. Zero values take the package defaults
+type BalancerConfig struct {
+	Query              NodeQuery
+	Strategy           BalancingStrategy
+	TLSConfig          *tls.Config
+	Timeout            time.Duration
+	UnreachableTimeout time.Duration
+	RefreshInterval    time.Duration
+	Resilience         rcom.ResiliencePolicy
+}
+
+// Client balancing the requests across the registry nodes matching a query
+type BalancingClient interface {
+	// Returns the nodes matching the query, excluding the unreachable ones, in the strategy order for the key
+	Targets(key string) []types.Node
+	// Returns the node the requests with the given key are sent to
+	Pick(key string) (*types.Node, error)
+	// Creates a request builder for the path, sent to the node picked for the key: when a node is unreachable
+	// the request is sent to the next target, unless it was already sent with a method the resilience policy
+	// does not retry
+	NewRequest(key string, path string) rcom.Request
+	// Pings the nodes matching the query, updating their latency and reachability
+	Refresh(ctx context.Context) error
+	// Stops the periodic refresh and closes the idle connections
+	Close()
+}
@@ -57,9 +57,12 @@ func (n *Node) Update(n1 *Node) {
 	if "" != n1.Name {
 		n.Name = n1.Name
 	}
-	if "" != n1.Name {
+	if "" != n1.IpAddress {
 		n.IpAddress = n1.IpAddress
 	}
+	if 0 != n1.Port {
+		n.Port = n1.Port
+	}
 	if nil != n1.Ports {
 		n.Ports = n1.Ports
 	}
@@ -70,12 +73,15 @@ func (n *Node) Update(n1 *Node) {
 		n.Services = n1.Services
 	}
 	n.Active = n1.Active
-	if 0 > n1.LastCheck.Unix() {
+	if ! n1.LastCheck.IsZero() {
 		n.LastCheck = n1.LastCheck
 	}
 	if 0 != n1.State {
 		n.State = n1.State
 	}
+	if "" != n1.Info.OS {
+		n.Info = n1.Info
+	}
 }
 
 func NewNodeInfo() NodeInfo {
@@ -12,7 +12,7 @@ type NodeState byte
 
 var(
 	DefaultFilePerm os.FileMode = 0664
-	DefaultFolderPerm os.FileMode = 0664
+	DefaultFolderPerm os.FileMode = 0775
 )
 
 const (
@@ -37,6 +37,7 @@ type Ports struct {
 
 type Command struct {
 	Name      string 				`yaml:"name,omitempty" json:"name,omitempty" xml:"name,omitempty"`
+	Path      string 				`yaml:"path,omitempty" json:"path,omitempty" xml:"path,omitempty"`
 	Command   string				`yaml:"command,omitempty" json:"command,omitempty" xml:"command,omitempty"`
 	Arguments []string				`yaml:"arguments,omitempty" json:"arguments,omitempty" xml:"argument,omitempty"`
 	Method    []common.RestMethod	`yaml:"methods,omitempty" json:"methods,omitempty" xml:"methods,omitempty"`
@@ -60,7 +61,7 @@ type Node struct {
 	IpAddress string 			`yaml:"ipAddress,omitempty" json:"ipAddress,omitempty" xml:"ip-address,omitempty"`
 	Port      int32				`yaml:"port,omitempty" json:"port,omitempty" xml:"port,omitempty"`
 	Ports     []Port			`yaml:"ports,omitempty" json:"ports,omitempty" xml:"portGroup,omitempty"`
-	Role      NodeType			`yaml:"ports,omitempty" json:"port,omitempty" xml:"ports,omitempty"`
+	Role      NodeType			`yaml:"role,omitempty" json:"role,omitempty" xml:"role,omitempty"`
 	Services  []Service			`yaml:"services,omitempty" json:"services,omitempty" xml:"serviceGroup,omitempty"`
 	Active    bool				`yaml:"active,omitempty" json:"active,omitempty" xml:"active,omitempty"`
 	LastCheck time.Time			`yaml:"lastCheck,omitempty" json:"lastCheck,omitempty" xml:"last-check,omitempty"`
@@ -91,3 +92,19 @@ type NodeRequest struct {
 	IpAddress		string
 	Ports			[]int32
 }
+
+// Arguments appended to the command definition ones by the remote caller
+type CommandRequest struct {
+	Arguments []string			`yaml:"arguments,omitempty" json:"arguments,omitempty" xml:"argument,omitempty"`
+}
+
+// Outcome of a command execution, ExitCode is -1 when the process could not complete
+type CommandResult struct {
+	Name     string				`yaml:"name,omitempty" json:"name,omitempty" xml:"name,omitempty"`
+	Node     string				`yaml:"node,omitempty" json:"node,omitempty" xml:"node,omitempty"`
+	ExitCode int				`yaml:"exitCode" json:"exitCode" xml:"exit-code"`
+	Stdout   string				`yaml:"stdout,omitempty" json:"stdout,omitempty" xml:"stdout,omitempty"`
+	Stderr   string				`yaml:"stderr,omitempty" json:"stderr,omitempty" xml:"stderr,omitempty"`
+	TimedOut bool				`yaml:"timedOut,omitempty" json:"timedOut,omitempty" xml:"timed-out,omitempty"`
+	Duration time.Duration		`yaml:"duration,omitempty" json:"duration,omitempty" xml:"duration,omitempty"`
+}
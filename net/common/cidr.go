@@ -2,6 +2,7 @@ package common
 
 import (
 	"fmt"
+	"math"
 	"math/big"
 	"net"
 )
@@ -96,43 +97,142 @@ func AddressRange(network *net.IPNet) (net.IP, net.IP) {
 }
 
 // ListAddresses returns the list of all addresses in the given CIDR range.
+//
+// The whole list is kept in memory, so large ranges should be walked with
+// an AddressIterator instead.
 func ListAddresses(network *net.IPNet) ([]net.IP) {
 	var out []net.IP = make([]net.IP, 0)
-	// the first IP is easy
-	firstIP := network.IP
-	out = append(out, firstIP)
+	iterator := NewAddressIterator(network, false)
+	for ip, ok := iterator.Next(); ok; ip, ok = iterator.Next() {
+		out = append(out, ip)
+	}
+	return out
+}
 
-	// the last IP is the network address OR NOT the mask address
+
+// AddressCount returns the number of distinct host addresses within the given
+// CIDR range.
+//
+// Since the result is a uint64, ranges with more than 2^64 - 1 addresses (IPv6
+// prefixes shorter than /65) return math.MaxUint64: use AddressCountBig for
+// the exact value.
+func AddressCount(network *net.IPNet) uint64 {
 	prefixLen, bits := network.Mask.Size()
-	fmt.Printf("Cidr netmask prefixLen: %v\n", prefixLen)
-	fmt.Printf("Cidr netmask bits: %v\n", bits)
-	if prefixLen == bits {
-		// Easy! Just one IP address
-		return out
+	if bits-prefixLen >= 64 {
+		return math.MaxUint64
 	}
+	return 1 << (uint64(bits) - uint64(prefixLen))
+}
+
+// AddressCountBig returns the exact number of distinct host addresses within
+// the given CIDR range, for both IPv4 and IPv6 ranges.
+func AddressCountBig(network *net.IPNet) *big.Int {
+	prefixLen, bits := network.Mask.Size()
+	count := big.NewInt(1)
+	return count.Lsh(count, uint(bits-prefixLen))
+}
 
-	lastIpInt, bits := ipToInt(firstIP)
+// AddressIterator walks the addresses of a range lazily, in ascending order.
+type AddressIterator interface {
+	// Next returns the next address, or false when the range is over
+	Next() (net.IP, bool)
+	// Reset restarts the iteration from the first address
+	Reset()
+	// Count returns the number of addresses of a complete iteration
+	Count() *big.Int
+	// Shard splits the range in at most n contiguous iterators of balanced
+	// size, so the range can be walked by n workers
+	Shard(n int) []AddressIterator
+}
 
-	hostLen := uint(bits) - uint(prefixLen)
-	fmt.Printf("Host Lem: %v\n", hostLen)
+type addressIterator struct {
+	first   *big.Int
+	last    *big.Int
+	current *big.Int
+	bits    int
+}
 
-	var i uint64
-	for i= 1; i < AddressCount(network); i++ {
-		lastIpInt.Add(lastIpInt, big.NewInt(1))
-		out = append(out, intToIP(lastIpInt, bits))
+func (ai *addressIterator) Next() (net.IP, bool) {
+	if ai.current.Cmp(ai.last) > 0 {
+		return nil, false
 	}
-	return out//firstIP, intToIP(lastIPInt, bits)
+	ip := intToIP(ai.current, ai.bits)
+	ai.current = new(big.Int).Add(ai.current, big.NewInt(1))
+	return ip, true
 }
 
+func (ai *addressIterator) Reset() {
+	ai.current = new(big.Int).Set(ai.first)
+}
 
-// AddressCount returns the number of distinct host addresses within the given
-// CIDR range.
+func (ai *addressIterator) Count() *big.Int {
+	if ai.first.Cmp(ai.last) > 0 {
+		return big.NewInt(0)
+	}
+	count := new(big.Int).Sub(ai.last, ai.first)
+	return count.Add(count, big.NewInt(1))
+}
+
+func (ai *addressIterator) Shard(n int) []AddressIterator {
+	var out = make([]AddressIterator, 0)
+	total := ai.Count()
+	if n < 1 {
+		n = 1
+	}
+	shards := big.NewInt(int64(n))
+	if total.Cmp(shards) < 0 {
+		shards.Set(total)
+	}
+	if shards.Sign() == 0 {
+		return out
+	}
+	size, remainder := new(big.Int).QuoRem(total, shards, new(big.Int))
+	first := new(big.Int).Set(ai.first)
+	for i := int64(0); i < shards.Int64(); i++ {
+		length := new(big.Int).Set(size)
+		if big.NewInt(i).Cmp(remainder) < 0 {
+			length.Add(length, big.NewInt(1))
+		}
+		last := new(big.Int).Add(first, length)
+		last.Sub(last, big.NewInt(1))
+		out = append(out, newAddressIterator(first, last, ai.bits))
+		first = new(big.Int).Add(last, big.NewInt(1))
+	}
+	return out
+}
+
+func newAddressIterator(first *big.Int, last *big.Int, bits int) AddressIterator {
+	return &addressIterator{
+		first:   new(big.Int).Set(first),
+		last:    new(big.Int).Set(last),
+		current: new(big.Int).Set(first),
+		bits:    bits,
+	}
+}
+
+// NewAddressIterator returns an iterator over the addresses of the given CIDR
+// range, IPv4 or IPv6.
 //
-// Since the result is a uint64, this function returns meaningful information
-// only for IPv4 ranges and IPv6 ranges with a prefix size of at least 65.
-func AddressCount(network *net.IPNet) uint64 {
+// When skipNetworkAndBroadcast is true and the range contains more than two
+// addresses, the first (network) and the last (broadcast) addresses are not
+// returned.
+func NewAddressIterator(network *net.IPNet, skipNetworkAndBroadcast bool) AddressIterator {
 	prefixLen, bits := network.Mask.Size()
-	return 1 << (uint64(bits) - uint64(prefixLen))
+	ip := network.IP.Mask(network.Mask)
+	if ip == nil || bits == 0 {
+		// Non canonical mask, or mask not matching the address family
+		return newAddressIterator(big.NewInt(1), big.NewInt(0), 32)
+	}
+	first, bits := ipToInt(ip)
+	hostMask := big.NewInt(1)
+	hostMask.Lsh(hostMask, uint(bits-prefixLen))
+	hostMask.Sub(hostMask, big.NewInt(1))
+	last := new(big.Int).Or(first, hostMask)
+	if skipNetworkAndBroadcast && bits-prefixLen > 1 {
+		first.Add(first, big.NewInt(1))
+		last.Sub(last, big.NewInt(1))
+	}
+	return newAddressIterator(first, last, bits)
 }
 
 //VerifyNoOverlap takes a list subnets and supernet (CIDRBlock) and verifies
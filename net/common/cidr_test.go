@@ -2,6 +2,8 @@ package common
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"net"
 	"testing"
 )
@@ -98,3 +100,78 @@ func TestListAddresses(t *testing.T) {
 //	fmt.Printf("Last IP Addr: %v\n" , list[len(list)-1])
 //
 //}
+
+func TestAddressCountIPv6(t *testing.T) {
+	_, ipnet, err := net.ParseCIDR("2001:db8::/48")
+	if err != nil {
+		panic(err.Error())
+	}
+	if addressCount := AddressCount(ipnet); addressCount != math.MaxUint64 {
+		t.Fatalf("TestAddressCountIPv6 - net/common.AddressCount - Expected: %v but Given: %v", uint64(math.MaxUint64), addressCount)
+	}
+	expectedAddressCount := new(big.Int).Lsh(big.NewInt(1), 80)
+	if addressCount := AddressCountBig(ipnet); expectedAddressCount.Cmp(addressCount) != 0 {
+		t.Fatalf("TestAddressCountIPv6 - net/common.AddressCountBig - Expected: %v but Given: %v", expectedAddressCount, addressCount)
+	}
+}
+
+func TestAddressIterator(t *testing.T) {
+	_, ipnet, err := net.ParseCIDR("192.168.1.0/30")
+	if err != nil {
+		panic(err.Error())
+	}
+	iterator := NewAddressIterator(ipnet, true)
+	var addresses = make([]string, 0)
+	for ip, ok := iterator.Next(); ok; ip, ok = iterator.Next() {
+		addresses = append(addresses, ip.String())
+	}
+	if fmt.Sprintf("%v", addresses) != "[192.168.1.1 192.168.1.2]" {
+		t.Fatalf("TestAddressIterator - net/common.AddressIterator.Next - Expected: %v but Given: %v", "[192.168.1.1 192.168.1.2]", addresses)
+	}
+	iterator.Reset()
+	if ip, ok := iterator.Next(); !ok || ip.String() != "192.168.1.1" {
+		t.Fatalf("TestAddressIterator - net/common.AddressIterator.Reset - Expected: %v but Given: %v", "192.168.1.1", ip)
+	}
+
+	_, ipnet, err = net.ParseCIDR("2001:db8::/120")
+	if err != nil {
+		panic(err.Error())
+	}
+	iterator = NewAddressIterator(ipnet, false)
+	if count := iterator.Count(); count.Int64() != 256 {
+		t.Fatalf("TestAddressIterator - net/common.AddressIterator.Count - Expected: %v but Given: %v", 256, count)
+	}
+	var last net.IP
+	for ip, ok := iterator.Next(); ok; ip, ok = iterator.Next() {
+		last = ip
+	}
+	if last.String() != "2001:db8::ff" {
+		t.Fatalf("TestAddressIterator - net/common.AddressIterator.Next - Expected: %v but Given: %v", "2001:db8::ff", last)
+	}
+}
+
+func TestAddressIteratorShard(t *testing.T) {
+	_, ipnet, err := net.ParseCIDR("10.0.0.0/28")
+	if err != nil {
+		panic(err.Error())
+	}
+	shards := NewAddressIterator(ipnet, false).Shard(3)
+	var sizes = make([]int64, 0)
+	var seen = make(map[string]bool)
+	for _, shard := range shards {
+		sizes = append(sizes, shard.Count().Int64())
+		for ip, ok := shard.Next(); ok; ip, ok = shard.Next() {
+			seen[ip.String()] = true
+		}
+	}
+	if fmt.Sprintf("%v", sizes) != "[6 5 5]" || len(seen) != 16 {
+		t.Fatalf("TestAddressIteratorShard - net/common.AddressIterator.Shard - Expected: %v but Given: %v (%v distinct)", "[6 5 5]", sizes, len(seen))
+	}
+	_, ipnet, err = net.ParseCIDR("10.0.0.0/31")
+	if err != nil {
+		panic(err.Error())
+	}
+	if shards := NewAddressIterator(ipnet, false).Shard(4); len(shards) != 2 {
+		t.Fatalf("TestAddressIteratorShard - net/common.AddressIterator.Shard - Expected: %v but Given: %v", 2, len(shards))
+	}
+}
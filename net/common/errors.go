@@ -0,0 +1,134 @@
+package common
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/io"
+	"net/http"
+)
+
+const (
+	//RFC 7807 Problem Details Json format Mime Type
+	PROBLEM_JSON_MIME_TYPE MimeType = "application/problem+json"
+	//RFC 7807 Problem Details Xml format Mime Type
+	PROBLEM_XML_MIME_TYPE MimeType = "application/problem+xml"
+)
+
+// Returns the http status code of the error, internal server error when the error chain contains
+// no ApiError
+func StatusCode(err error) int {
+	var apiError *ApiError
+	if errors.As(err, &apiError) && apiError.Code >= 400 && apiError.Code <= 599 {
+		return apiError.Code
+	}
+	return http.StatusInternalServerError
+}
+
+// Error answer of the servers, rendered as RFC 7807 Problem Details. Err is the wrapped cause, not rendered
+type ApiError struct {
+	XMLName   xml.Name `yaml:"-" json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	Type      string   `yaml:"type" json:"type" xml:"type"`
+	Title     string   `yaml:"title" json:"title" xml:"title"`
+	Code      int      `yaml:"status" json:"status" xml:"status"`
+	Message   string   `yaml:"detail" json:"detail" xml:"detail"`
+	Instance  string   `yaml:"instance,omitempty" json:"instance,omitempty" xml:"instance,omitempty"`
+	Details   []string `yaml:"details,omitempty" json:"details,omitempty" xml:"details>detail,omitempty"`
+	RequestId string   `yaml:"request-id,omitempty" json:"requestId,omitempty" xml:"request-id,omitempty"`
+	Err       error    `yaml:"-" json:"-" xml:"-"`
+}
+
+func (ae *ApiError) Error() string {
+	if len(ae.Details) > 0 {
+		return fmt.Sprintf("%s, Details: %v", ae.Message, ae.Details)
+	}
+	return ae.Message
+}
+
+func (ae *ApiError) Unwrap() error {
+	return ae.Err
+}
+
+// Creates an api error with the given http status code
+func NewApiError(code int, message string, details ...string) *ApiError {
+	return &ApiError{
+		Type:    "about:blank",
+		Title:   http.StatusText(code),
+		Code:    code,
+		Message: message,
+		Details: details,
+	}
+}
+
+// Wraps the error in an api error with the given http status code, the error is rendered as detail
+func WrapApiError(code int, message string, err error) *ApiError {
+	apiError := NewApiError(code, message)
+	if err != nil {
+		apiError.Details = []string{err.Error()}
+		apiError.Err = err
+	}
+	return apiError
+}
+
+// Converts the error to an api error, using the status code of the ApiError in the error chain,
+// and completes it with the request path and id
+func ApiErrorFrom(err error, req *http.Request) *ApiError {
+	var apiError *ApiError
+	var out *ApiError
+	if errors.As(err, &apiError) {
+		copied := *apiError
+		out = &copied
+	} else {
+		out = NewApiError(http.StatusInternalServerError, err.Error())
+	}
+	if out.Code < 400 || out.Code > 599 {
+		out.Code = http.StatusInternalServerError
+		out.Title = http.StatusText(out.Code)
+	}
+	if "" == out.Type {
+		out.Type = "about:blank"
+	}
+	if req != nil {
+		if "" == out.Instance {
+			out.Instance = req.URL.Path
+		}
+		if "" == out.RequestId {
+			if requestId := req.Context().Value(ContextRequestId); requestId != nil {
+				out.RequestId = fmt.Sprintf("%v", requestId)
+			} else if sessionKey := req.Context().Value(ContextSessionKey); sessionKey != nil {
+				out.RequestId = fmt.Sprintf("%v", sessionKey)
+			}
+		}
+	}
+	return out
+}
+
+// Submits the error as Problem Details in the format of the Mime Type. In case the Mime Type is not
+// a structured one, the format is negotiated with the request Accept header, defaulting to Json
+func SubmitError(w http.ResponseWriter, req *http.Request, err error, mimeType MimeType) {
+	apiError := ApiErrorFrom(err, req)
+	format, ok := MimeTypeFormat(mimeType)
+	if !ok && req != nil {
+		if negotiated, found := NegotiateMimeType(req.Header.Get("Accept"), []MimeType{PROBLEM_JSON_MIME_TYPE, PROBLEM_XML_MIME_TYPE, YAML_MIME_TYPE}); found {
+			format, ok = MimeTypeFormat(negotiated)
+		}
+	}
+	if !ok {
+		format = io.ParserFormatJson
+	}
+	contentType := YAML_MIME_TYPE
+	switch format {
+	case io.ParserFormatJson:
+		contentType = PROBLEM_JSON_MIME_TYPE
+	case io.ParserFormatXml:
+		contentType = PROBLEM_XML_MIME_TYPE
+	}
+	data, errM := io.Marshall(apiError, format)
+	if errM != nil {
+		w.Header().Set("Content-Type", string(PLAIN_TEXT_MIME_TYPE))
+		SubmitFaiure(w, apiError.Code, apiError.Error())
+		return
+	}
+	w.Header().Set("Content-Type", string(contentType))
+	SubmitFaiure(w, apiError.Code, string(data))
+}
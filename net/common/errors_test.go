@@ -0,0 +1,66 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusCode(t *testing.T) {
+	var cases = []struct {
+		err      error
+		expected int
+	}{
+		{errors.New("plain"), http.StatusInternalServerError},
+		{NewApiError(http.StatusConflict, "conflict"), http.StatusConflict},
+		{WrapApiError(http.StatusBadRequest, "invalid", errors.New("cause")), http.StatusBadRequest},
+		{NewApiError(http.StatusNotFound, "missing"), http.StatusNotFound},
+		{NewApiError(http.StatusOK, "not an error code"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		if code := StatusCode(c.err); code != c.expected {
+			t.Fatalf("TestStatusCode - common.StatusCode(%v) - Expected: %v but Given: %v", c.err, c.expected, code)
+		}
+	}
+	cause := errors.New("cause")
+	if wrapped := fmt.Errorf("handler: %w", WrapApiError(http.StatusConflict, "conflict", cause)); !errors.Is(wrapped, cause) || StatusCode(wrapped) != http.StatusConflict {
+		t.Fatalf("TestStatusCode - common.WrapApiError - Expected: %v %v but Given: %v", cause, http.StatusConflict, wrapped)
+	}
+}
+
+func TestSubmitErrorProblemJson(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/nodes/7", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ContextRequestId, "request-7"))
+	recorder := httptest.NewRecorder()
+	SubmitError(recorder, req, WrapApiError(http.StatusBadRequest, "Invalid \"node\" request", errors.New("cause")), PLAIN_TEXT_MIME_TYPE)
+	var problem = ApiError{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("TestSubmitErrorProblemJson - json.Unmarshal - Expected: %v but Given: %v (%q)", nil, err, recorder.Body.String())
+	}
+	if recorder.Code != http.StatusBadRequest || recorder.Header().Get("Content-Type") != string(PROBLEM_JSON_MIME_TYPE) {
+		t.Fatalf("TestSubmitErrorProblemJson - common.SubmitError - Expected: %v but Given: %v %v", http.StatusBadRequest, recorder.Code, recorder.Header())
+	}
+	if problem.Code != http.StatusBadRequest || problem.Title != "Bad Request" || problem.Message != "Invalid \"node\" request" ||
+		problem.Instance != "/nodes/7" || problem.RequestId != "request-7" || len(problem.Details) != 1 || problem.Details[0] != "cause" {
+		t.Fatalf("TestSubmitErrorProblemJson - common.SubmitError - Expected: %v but Given: %+v", "problem details", problem)
+	}
+}
+
+func TestSubmitErrorProblemXml(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/nodes", nil)
+	req.Header.Set("Accept", "application/xml")
+	recorder := httptest.NewRecorder()
+	SubmitError(recorder, req, errors.New("failure <details>"), "")
+	var problem = ApiError{}
+	if err := xml.Unmarshal(recorder.Body.Bytes(), &problem); err != nil || problem.Message != "failure <details>" || problem.Code != http.StatusInternalServerError {
+		t.Fatalf("TestSubmitErrorProblemXml - common.SubmitError - Expected: %v but Given: %+v (%v)", "failure <details>", problem, err)
+	}
+	if recorder.Header().Get("Content-Type") != string(PROBLEM_XML_MIME_TYPE) {
+		t.Fatalf("TestSubmitErrorProblemXml - common.SubmitError - Expected: %v but Given: %v", PROBLEM_XML_MIME_TYPE, recorder.Header().Get("Content-Type"))
+	}
+}
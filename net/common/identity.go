@@ -0,0 +1,159 @@
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TLS client certificate authentication mode of the servers
+type ClientAuthMode string
+
+const (
+	// Client certificates are not requested
+	CLIENT_AUTH_NONE ClientAuthMode = "none"
+	// Client certificates are requested but not verified, the identity is reported as unverified
+	CLIENT_AUTH_REQUEST ClientAuthMode = "request"
+	// Client certificates are required and verified against the CA certificate
+	CLIENT_AUTH_REQUIRE ClientAuthMode = "require"
+	// Client certificates are optional and verified against the CA certificate when given
+	CLIENT_AUTH_VERIFY_IF_GIVEN ClientAuthMode = "verify-if-given"
+)
+
+// Returns the tls package client authentication type of the mode, an empty mode is CLIENT_AUTH_NONE
+func (m ClientAuthMode) TLSClientAuth() (tls.ClientAuthType, error) {
+	switch ClientAuthMode(strings.ToLower(string(m))) {
+	case "", CLIENT_AUTH_NONE:
+		return tls.NoClientCert, nil
+	case CLIENT_AUTH_REQUEST:
+		return tls.RequestClientCert, nil
+	case CLIENT_AUTH_REQUIRE:
+		return tls.RequireAndVerifyClientCert, nil
+	case CLIENT_AUTH_VERIFY_IF_GIVEN:
+		return tls.VerifyClientCertIfGiven, nil
+	default:
+		return tls.NoClientCert, errors.New(fmt.Sprintf("ClientAuthMode.TLSClientAuth - Unknown client authentication mode: %s", string(m)))
+	}
+}
+
+// Identity of the TLS client certificate, Verified reports the certificate chain has been verified
+// against the server CA certificate. SpiffeId is the first spiffe:// URI SAN
+type PeerIdentity struct {
+	Subject        string            `yaml:"subject" json:"subject" xml:"subject"`
+	CommonName     string            `yaml:"commonName" json:"commonName" xml:"common-name"`
+	DNSNames       []string          `yaml:"dnsNames,omitempty" json:"dnsNames,omitempty" xml:"dns-names>dns-name,omitempty"`
+	EmailAddresses []string          `yaml:"emailAddresses,omitempty" json:"emailAddresses,omitempty" xml:"email-addresses>email-address,omitempty"`
+	IPAddresses    []string          `yaml:"ipAddresses,omitempty" json:"ipAddresses,omitempty" xml:"ip-addresses>ip-address,omitempty"`
+	URIs           []string          `yaml:"uris,omitempty" json:"uris,omitempty" xml:"uris>uri,omitempty"`
+	SpiffeId       string            `yaml:"spiffeId,omitempty" json:"spiffeId,omitempty" xml:"spiffe-id,omitempty"`
+	Verified       bool              `yaml:"verified" json:"verified" xml:"verified"`
+	Certificate    *x509.Certificate `yaml:"-" json:"-" xml:"-"`
+}
+
+// Verifies if the name matches the subject, the common name or one of the SANs. A name ending with *
+// matches as prefix (e.g. spiffe://cluster.local/ns/prod/*)
+func (pi *PeerIdentity) Matches(name string) bool {
+	var match = func(value string) bool {
+		if strings.HasSuffix(name, "*") {
+			return strings.HasPrefix(value, strings.TrimSuffix(name, "*"))
+		}
+		return value == name
+	}
+	if "" == name {
+		return false
+	}
+	if match(pi.Subject) || match(pi.CommonName) {
+		return true
+	}
+	for _, list := range [][]string{pi.DNSNames, pi.EmailAddresses, pi.IPAddresses, pi.URIs} {
+		for _, value := range list {
+			if match(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Extracts the identity of the TLS connection client certificate, nil when no certificate has been given
+func PeerIdentityFromState(state *tls.ConnectionState) *PeerIdentity {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := state.PeerCertificates[0]
+	var identity = &PeerIdentity{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Verified:       len(state.VerifiedChains) > 0,
+		Certificate:    cert,
+	}
+	for _, ip := range cert.IPAddresses {
+		identity.IPAddresses = append(identity.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+		if "" == identity.SpiffeId && "spiffe" == uri.Scheme {
+			identity.SpiffeId = uri.String()
+		}
+	}
+	return identity
+}
+
+// Returns the request with the TLS client certificate identity in the ContextPeerIdentity context key,
+// the request is returned as is when no client certificate has been given
+func WithPeerIdentity(req *http.Request) *http.Request {
+	identity := PeerIdentityFromState(req.TLS)
+	if identity == nil {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), ContextPeerIdentity, identity))
+}
+
+// Returns the TLS client certificate identity of the request, if any
+func PeerIdentityOf(req *http.Request) (*PeerIdentity, bool) {
+	identity, ok := req.Context().Value(ContextPeerIdentity).(*PeerIdentity)
+	return identity, ok && identity != nil
+}
+
+// Authorization decision on the request client identity, identity is nil when no client certificate has been given
+type Authorizer func(identity *PeerIdentity, req *http.Request) bool
+
+// Authorizes the verified identities matching one of the names (see PeerIdentity.Matches)
+func AllowIdentities(names ...string) Authorizer {
+	return func(identity *PeerIdentity, req *http.Request) bool {
+		if identity == nil || !identity.Verified {
+			return false
+		}
+		for _, name := range names {
+			if identity.Matches(name) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Middleware answering 401 Unauthorized to requests without a verified client certificate and 403 Forbidden
+// to the identities refused by the authorizer. It is meant to be registered per path with UsePath
+func AuthorizeMiddleware(authorizer Authorizer) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			identity, _ := PeerIdentityOf(req)
+			if authorizer(identity, req) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			if identity == nil || !identity.Verified {
+				SubmitError(w, req, NewApiError(http.StatusUnauthorized, "Verified client certificate required"), "")
+				return
+			}
+			SubmitError(w, req, NewApiError(http.StatusForbidden, fmt.Sprintf("Identity not allowed: %s", identity.Subject)), "")
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func testPeerCertificate() *x509.Certificate {
+	spiffeId, _ := url.Parse("spiffe://cluster.local/ns/prod/sa/node")
+	return &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "node-1", Organization: []string{"cluster"}},
+		DNSNames:    []string{"node-1.cluster.local"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.7")},
+		URIs:        []*url.URL{spiffeId},
+	}
+}
+
+func TestPeerIdentityFromState(t *testing.T) {
+	cert := testPeerCertificate()
+	identity := PeerIdentityFromState(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}})
+	if identity == nil || !identity.Verified || identity.CommonName != "node-1" || identity.Subject != "CN=node-1,O=cluster" ||
+		identity.SpiffeId != "spiffe://cluster.local/ns/prod/sa/node" || identity.IPAddresses[0] != "10.0.0.7" {
+		t.Fatalf("TestPeerIdentityFromState - common.PeerIdentityFromState - Expected: %v but Given: %+v", "verified node-1 identity", identity)
+	}
+	if identity := PeerIdentityFromState(&tls.ConnectionState{}); identity != nil {
+		t.Fatalf("TestPeerIdentityFromState - common.PeerIdentityFromState - Expected: %v but Given: %+v", nil, identity)
+	}
+	var cases = []struct {
+		name     string
+		expected bool
+	}{
+		{"node-1", true},
+		{"CN=node-1,O=cluster", true},
+		{"node-1.cluster.local", true},
+		{"10.0.0.7", true},
+		{"spiffe://cluster.local/ns/prod/*", true},
+		{"spiffe://cluster.local/ns/dev/*", false},
+		{"node-2", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if matches := identity.Matches(c.name); matches != c.expected {
+			t.Fatalf("TestPeerIdentityFromState - PeerIdentity.Matches(%s) - Expected: %v but Given: %v", c.name, c.expected, matches)
+		}
+	}
+}
+
+func TestAuthorizeMiddleware(t *testing.T) {
+	cert := testPeerCertificate()
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		identity, _ := PeerIdentityOf(req)
+		w.Write([]byte(identity.CommonName))
+	}), AuthorizeMiddleware(AllowIdentities("spiffe://cluster.local/ns/prod/*")))
+	var cases = []struct {
+		state  *tls.ConnectionState
+		status int
+	}{
+		{nil, http.StatusUnauthorized},
+		{&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}, http.StatusUnauthorized},
+		{&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}, http.StatusOK},
+		{&tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "other"}}}, VerifiedChains: [][]*x509.Certificate{{cert}}}, http.StatusForbidden},
+	}
+	for idx, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/nodes", nil)
+		req.TLS = c.state
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, WithPeerIdentity(req))
+		if recorder.Code != c.status {
+			t.Fatalf("TestAuthorizeMiddleware - case %v - Expected: %v but Given: %v", idx, c.status, recorder.Code)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/nodes", nil)
+	if _, ok := PeerIdentityOf(req.WithContext(context.WithValue(req.Context(), ContextPeerIdentity, "node-1"))); ok {
+		t.Fatalf("TestAuthorizeMiddleware - common.PeerIdentityOf - Expected: %v but Given: %v", false, ok)
+	}
+}
+
+func TestClientAuthMode(t *testing.T) {
+	var cases = []struct {
+		mode     ClientAuthMode
+		expected tls.ClientAuthType
+	}{
+		{"", tls.NoClientCert},
+		{CLIENT_AUTH_REQUEST, tls.RequestClientCert},
+		{"Require", tls.RequireAndVerifyClientCert},
+		{CLIENT_AUTH_VERIFY_IF_GIVEN, tls.VerifyClientCertIfGiven},
+	}
+	for _, c := range cases {
+		if clientAuth, err := c.mode.TLSClientAuth(); err != nil || clientAuth != c.expected {
+			t.Fatalf("TestClientAuthMode - ClientAuthMode.TLSClientAuth(%s) - Expected: %v but Given: %v (%v)", c.mode, c.expected, clientAuth, err)
+		}
+	}
+	if _, err := ClientAuthMode("always").TLSClientAuth(); err == nil {
+		t.Fatalf("TestClientAuthMode - ClientAuthMode.TLSClientAuth(always) - Expected: %v but Given: %v", "error", err)
+	}
+}
@@ -0,0 +1,196 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/log"
+	"net/http"
+	"time"
+)
+
+var (
+	// Request Context Request Id
+	ContextRequestId = ContextKey("request-id")
+	// Response header reporting the request id
+	REQUEST_ID_HEADER string = "X-Request-Id"
+	// Response header reporting the elapsed time before the response headers are written
+	RESPONSE_TIME_HEADER string = "X-Response-Time"
+	// Request Context Trace Id, propagated by the clients to the called services
+	ContextTraceId = ContextKey("trace-id")
+	// Request header carrying the trace id across services
+	TRACE_ID_HEADER string = "X-Trace-Id"
+)
+
+// Http handler decorator, executed around the request handling
+type Middleware func(next http.Handler) http.Handler
+
+// Wraps the handler with the given middleware, the first middleware is the outermost one
+// and it is the first to be executed
+func Chain(handler http.Handler, middleware ...Middleware) http.Handler {
+	for idx := len(middleware) - 1; idx >= 0; idx-- {
+		if middleware[idx] != nil {
+			handler = middleware[idx](handler)
+		}
+	}
+	return handler
+}
+
+// Response writer recording the response status and size
+type statusWriter struct {
+	http.ResponseWriter
+	status       int
+	size         int64
+	beforeHeader func(header http.Header)
+}
+
+func (sw *statusWriter) WriteHeader(statusCode int) {
+	if sw.status != 0 {
+		return
+	}
+	sw.status = statusCode
+	if sw.beforeHeader != nil {
+		sw.beforeHeader(sw.ResponseWriter.Header())
+	}
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *statusWriter) Write(data []byte) (int, error) {
+	if sw.status == 0 {
+		sw.WriteHeader(http.StatusOK)
+	}
+	n, err := sw.ResponseWriter.Write(data)
+	sw.size += int64(n)
+	return n, err
+}
+
+func (sw *statusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+func (sw *statusWriter) statusCode() int {
+	if sw.status == 0 {
+		return http.StatusOK
+	}
+	return sw.status
+}
+
+// Logs method, path, status, size and duration of each request
+func LoggingMiddleware(logger log.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if logger == nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+			start := time.Now()
+			writer := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(writer, req)
+			logger.Infof("server: access: %s %s %s, status: %v, size: %v, duration: %s",
+				req.RemoteAddr, req.Method, req.URL.RequestURI(), writer.statusCode(), writer.size, time.Since(start))
+		})
+	}
+}
+
+// Recovers the handler panics, answering with an internal server error that reports the panic details
+func RecoveryMiddleware(logger log.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			writer := &statusWriter{ResponseWriter: w}
+			defer func() {
+				if r := recover(); r != nil {
+					if r == http.ErrAbortHandler {
+						panic(r)
+					}
+					var message string = fmt.Sprintf("server: recovery: Errors executing %s %s, Details: %v", req.Method, req.URL.Path, r)
+					if logger != nil {
+						logger.Error(message)
+					}
+					if writer.status == 0 {
+						SubmitError(writer, req, NewApiError(http.StatusInternalServerError, message), "")
+					}
+				}
+			}()
+			next.ServeHTTP(writer, req)
+		})
+	}
+}
+
+// Reports the request id, taken from the connection session key, in the response headers and
+// in the request context. Without a session key the request id header is used, or a new one is generated
+func RequestIdMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var requestId string
+			if sessionKey := req.Context().Value(ContextSessionKey); sessionKey != nil {
+				requestId = fmt.Sprintf("%v", sessionKey)
+			} else if header := req.Header.Get(REQUEST_ID_HEADER); "" != header {
+				requestId = header
+			} else {
+				requestId = GenerateSecureToken(16)
+			}
+			w.Header().Set(REQUEST_ID_HEADER, requestId)
+			next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), ContextRequestId, requestId)))
+		})
+	}
+}
+
+// Returns a context carrying the trace id, sent in the trace id header by the Rest and Api Clients
+// and by the discovery requests
+func WithTraceId(ctx context.Context, traceId string) context.Context {
+	return context.WithValue(ctx, ContextTraceId, traceId)
+}
+
+// Returns the trace id of the context, empty if not available
+func TraceIdOf(ctx context.Context) string {
+	if traceId, ok := ctx.Value(ContextTraceId).(string); ok {
+		return traceId
+	}
+	return ""
+}
+
+// Sets the trace id header of an outgoing request from the request context, when not already set
+func SetTraceIdHeader(req *http.Request) {
+	if traceId := TraceIdOf(req.Context()); "" != traceId && "" == req.Header.Get(TRACE_ID_HEADER) {
+		req.Header.Set(TRACE_ID_HEADER, traceId)
+	}
+}
+
+// Stores the trace id header in the request context, generating a new one when missing, and reports it
+// in the response headers, so the handlers can pass it on to the services they call
+func TraceIdMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			traceId := req.Header.Get(TRACE_ID_HEADER)
+			if "" == traceId {
+				traceId = GenerateSecureToken(16)
+			}
+			w.Header().Set(TRACE_ID_HEADER, traceId)
+			next.ServeHTTP(w, req.WithContext(WithTraceId(req.Context(), traceId)))
+		})
+	}
+}
+
+// Reports the time elapsed before writing the response headers
+func TimingMiddleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			writer := &statusWriter{
+				ResponseWriter: w,
+				beforeHeader: func(header http.Header) {
+					header.Set(RESPONSE_TIME_HEADER, time.Since(start).String())
+				},
+			}
+			next.ServeHTTP(writer, req)
+			if writer.status == 0 {
+				writer.WriteHeader(http.StatusOK)
+			}
+		})
+	}
+}
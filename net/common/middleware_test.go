@@ -0,0 +1,93 @@
+package common
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChainOrder(t *testing.T) {
+	var calls = make([]string, 0)
+	trace := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls = append(calls, "handler")
+	}), trace("first"), nil, trace("second"))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Join(calls, ",") != "first,second,handler" {
+		t.Fatalf("TestChainOrder - common.Chain - Expected: %v but Given: %v", "first,second,handler", calls)
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		panic("broken handler")
+	}), RecoveryMiddleware(nil))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/nodes", nil))
+	if recorder.Code != http.StatusInternalServerError || !strings.Contains(recorder.Body.String(), "GET /nodes, Details: broken handler") {
+		t.Fatalf("TestRecoveryMiddleware - common.RecoveryMiddleware - Expected: %v but Given: %v %q", http.StatusInternalServerError, recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestRequestIdMiddleware(t *testing.T) {
+	var requestId interface{}
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requestId = req.Context().Value(ContextRequestId)
+	}), RequestIdMiddleware())
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ContextSessionKey, "session-42"))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if header := recorder.Header().Get(REQUEST_ID_HEADER); header != "session-42" || requestId != "session-42" {
+		t.Fatalf("TestRequestIdMiddleware - common.RequestIdMiddleware - Expected: %v but Given: %v (%v)", "session-42", header, requestId)
+	}
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if header := recorder.Header().Get(REQUEST_ID_HEADER); len(header) != 32 {
+		t.Fatalf("TestRequestIdMiddleware - common.RequestIdMiddleware - Expected: %v but Given: %v", "generated id", header)
+	}
+}
+
+func TestTraceIdMiddleware(t *testing.T) {
+	var traceId string
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		traceId = TraceIdOf(req.Context())
+	}), TraceIdMiddleware())
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TRACE_ID_HEADER, "trace-7")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if header := recorder.Header().Get(TRACE_ID_HEADER); header != "trace-7" || traceId != "trace-7" {
+		t.Fatalf("TestTraceIdMiddleware - common.TraceIdMiddleware - Expected: %v but Given: %v (%v)", "trace-7", header, traceId)
+	}
+	outgoing := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(WithTraceId(context.Background(), traceId))
+	SetTraceIdHeader(outgoing)
+	if header := outgoing.Header.Get(TRACE_ID_HEADER); header != "trace-7" {
+		t.Fatalf("TestTraceIdMiddleware - common.SetTraceIdHeader - Expected: %v but Given: %v", "trace-7", header)
+	}
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if header := recorder.Header().Get(TRACE_ID_HEADER); len(header) != 32 || traceId != header {
+		t.Fatalf("TestTraceIdMiddleware - common.TraceIdMiddleware - Expected: %v but Given: %v (%v)", "generated id", header, traceId)
+	}
+}
+
+func TestTimingMiddleware(t *testing.T) {
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		SubmitFaiure(w, http.StatusAccepted, "accepted")
+	}), TimingMiddleware())
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	if recorder.Code != http.StatusAccepted || "" == recorder.Header().Get(RESPONSE_TIME_HEADER) {
+		t.Fatalf("TestTimingMiddleware - common.TimingMiddleware - Expected: %v but Given: %v %v", "timing header", recorder.Code, recorder.Header())
+	}
+}
@@ -0,0 +1,183 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Mime Types of the data serialised through io.Marshall, a route producing one of them produces all of them
+	STRUCTURED_MIME_TYPES []MimeType = []MimeType{JSON_MIME_TYPE, XML_MIME_TYPE, YAML_MIME_TYPE}
+)
+
+// Media type of the Mime Type, without parameters and in lower case
+func mediaType(mimeType string) string {
+	return strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+}
+
+// Returns the io.Marshall format of the Mime Type, accepting the common aliases
+func MimeTypeFormat(mimeType MimeType) (io.ParserFormat, bool) {
+	switch mediaType(string(mimeType)) {
+	case string(JSON_MIME_TYPE), "text/json", string(PROBLEM_JSON_MIME_TYPE):
+		return io.ParserFormatJson, true
+	case string(XML_MIME_TYPE), "text/xml", string(PROBLEM_XML_MIME_TYPE):
+		return io.ParserFormatXml, true
+	case string(YAML_MIME_TYPE), "application/yaml", "application/x-yaml", "text/x-yaml":
+		return io.ParserFormatYaml, true
+	default:
+		return "", false
+	}
+}
+
+// Returns the Mime Type of the io.Marshall format, the plain text Mime Type for unknown formats
+func FormatMimeType(format io.ParserFormat) MimeType {
+	switch io.ParserFormat(strings.ToUpper(string(format))) {
+	case io.ParserFormatJson:
+		return JSON_MIME_TYPE
+	case io.ParserFormatXml:
+		return XML_MIME_TYPE
+	case io.ParserFormatYaml:
+		return YAML_MIME_TYPE
+	default:
+		return PLAIN_TEXT_MIME_TYPE
+	}
+}
+
+// Reports whether the Mime Types are the same, or aliases of the same io.Marshall format
+func sameMimeType(mimeType MimeType, other MimeType) bool {
+	format, ok := MimeTypeFormat(mimeType)
+	otherFormat, otherOk := MimeTypeFormat(other)
+	if ok || otherOk {
+		return ok && otherOk && format == otherFormat
+	}
+	return mediaType(string(mimeType)) == mediaType(string(other))
+}
+
+// Returns the Mime Types a route producing the given one can answer with: the configured Mime Type
+// first, followed by the other structured Mime Types when it is a structured one
+func ProducibleMimeTypes(produces MimeType) []MimeType {
+	if "" == produces {
+		return make([]MimeType, 0)
+	}
+	var out = []MimeType{produces}
+	if _, ok := MimeTypeFormat(produces); ok {
+		for _, mimeType := range STRUCTURED_MIME_TYPES {
+			if !sameMimeType(mimeType, produces) {
+				out = append(out, mimeType)
+			}
+		}
+	}
+	return out
+}
+
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+// Quality of the Mime Type given by the most specific matching range, -1 when no range matches
+func acceptQuality(ranges []acceptRange, mimeType MimeType) float64 {
+	var quality float64 = -1
+	var specificity int = -1
+	for _, r := range ranges {
+		var level int
+		switch {
+		case "*/*" == r.mediaType:
+			level = 0
+		case strings.HasSuffix(r.mediaType, "/*"):
+			if !strings.HasPrefix(mediaType(string(mimeType)), strings.TrimSuffix(r.mediaType, "*")) {
+				continue
+			}
+			level = 1
+		default:
+			if !sameMimeType(MimeType(r.mediaType), mimeType) {
+				continue
+			}
+			level = 2
+		}
+		if level > specificity {
+			quality, specificity = r.quality, level
+		}
+	}
+	return quality
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges = make([]acceptRange, 0)
+	for _, item := range strings.Split(accept, ",") {
+		if "" == strings.TrimSpace(item) {
+			continue
+		}
+		media, params, err := mime.ParseMediaType(item)
+		if err != nil {
+			continue
+		}
+		var quality float64 = 1
+		if value, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(value, 64)
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: media, quality: quality})
+	}
+	return ranges
+}
+
+// Chooses among the available Mime Types the one with the highest quality in the Accept header,
+// preferring the first available in case of equal quality. An empty Accept header accepts
+// the first available Mime Type, while false is returned when no available Mime Type is acceptable
+func NegotiateMimeType(accept string, available []MimeType) (MimeType, bool) {
+	if len(available) == 0 {
+		return "", "" == strings.TrimSpace(accept)
+	}
+	if "" == strings.TrimSpace(accept) {
+		return available[0], true
+	}
+	ranges := parseAccept(accept)
+	var chosen MimeType
+	var best float64 = 0
+	for _, mimeType := range available {
+		if quality := acceptQuality(ranges, mimeType); quality > best {
+			chosen, best = mimeType, quality
+		}
+	}
+	return chosen, best > 0
+}
+
+// Checks the request Content-Type against the Mime Type consumed by a route and returns the Mime Type
+// of the request body. Requests without Content-Type and routes consuming any Mime Type are accepted
+func SupportsContentType(contentType string, consumes MimeType) (MimeType, bool) {
+	if "" == strings.TrimSpace(contentType) {
+		return consumes, true
+	}
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	if "" == consumes || "*/*" == mediaType(string(consumes)) || sameMimeType(MimeType(media), consumes) {
+		return MimeType(media), true
+	}
+	return "", false
+}
+
+// Serialises the data through io.Marshall in the format of the Mime Type and submits it to the client
+func SubmitData(w http.ResponseWriter, statusCode int, data interface{}, mimeType MimeType) error {
+	format, ok := MimeTypeFormat(mimeType)
+	if !ok {
+		return errors.New(fmt.Sprintf("common.SubmitData - Unsupported Mime Type: %s", mimeType))
+	}
+	out, err := io.Marshall(data, format)
+	if err != nil {
+		return errors.New(fmt.Sprintf("common.SubmitData - Error: %s", err))
+	}
+	w.Header().Set("Content-Type", string(mimeType))
+	w.WriteHeader(statusCode)
+	w.Write(out)
+	return nil
+}
@@ -0,0 +1,54 @@
+package common
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateMimeType(t *testing.T) {
+	var cases = []struct {
+		accept    string
+		available []MimeType
+		expected  MimeType
+		ok        bool
+	}{
+		{"", ProducibleMimeTypes(JSON_MIME_TYPE), JSON_MIME_TYPE, true},
+		{"*/*", ProducibleMimeTypes(JSON_MIME_TYPE), JSON_MIME_TYPE, true},
+		{"text/html, text/yaml;q=0.9", ProducibleMimeTypes(JSON_MIME_TYPE), YAML_MIME_TYPE, true},
+		{"application/json;q=0.5, application/xml", ProducibleMimeTypes(JSON_MIME_TYPE), XML_MIME_TYPE, true},
+		{"text/xml;q=0.8, */*;q=0.1", ProducibleMimeTypes(YAML_MIME_TYPE), XML_MIME_TYPE, true},
+		{"application/*, application/json;q=0", ProducibleMimeTypes(JSON_MIME_TYPE), XML_MIME_TYPE, true},
+		{"text/html", ProducibleMimeTypes(JSON_MIME_TYPE), "", false},
+		{"text/*", ProducibleMimeTypes(PLAIN_TEXT_MIME_TYPE), PLAIN_TEXT_MIME_TYPE, true},
+		{"application/json", ProducibleMimeTypes(PLAIN_TEXT_MIME_TYPE), "", false},
+	}
+	for _, c := range cases {
+		mimeType, ok := NegotiateMimeType(c.accept, c.available)
+		if mimeType != c.expected || ok != c.ok {
+			t.Fatalf("TestNegotiateMimeType - common.NegotiateMimeType(%q) - Expected: %v %v but Given: %v %v", c.accept, c.expected, c.ok, mimeType, ok)
+		}
+	}
+}
+
+func TestSupportsContentType(t *testing.T) {
+	if mimeType, ok := SupportsContentType("application/json; charset=utf-8", JSON_MIME_TYPE); !ok || mimeType != JSON_MIME_TYPE {
+		t.Fatalf("TestSupportsContentType - common.SupportsContentType - Expected: %v but Given: %v %v", JSON_MIME_TYPE, mimeType, ok)
+	}
+	if mimeType, ok := SupportsContentType("", YAML_MIME_TYPE); !ok || mimeType != YAML_MIME_TYPE {
+		t.Fatalf("TestSupportsContentType - common.SupportsContentType - Expected: %v but Given: %v %v", YAML_MIME_TYPE, mimeType, ok)
+	}
+	if _, ok := SupportsContentType("application/xml", JSON_MIME_TYPE); ok {
+		t.Fatalf("TestSupportsContentType - common.SupportsContentType - Expected: %v but Given: %v", false, ok)
+	}
+}
+
+func TestSubmitData(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	err := SubmitData(recorder, 201, map[string]string{"status": "OK"}, YAML_MIME_TYPE)
+	if err != nil || recorder.Code != 201 || recorder.Body.String() != "\nstatus: OK\n" || recorder.Header().Get("Content-Type") != string(YAML_MIME_TYPE) {
+		t.Fatalf("TestSubmitData - common.SubmitData - Expected: %v but Given: %v %q (%v)", "status: OK", recorder.Code, recorder.Body.String(), err)
+	}
+	if err := SubmitData(httptest.NewRecorder(), 200, "text", PLAIN_TEXT_MIME_TYPE); err == nil {
+		t.Fatalf("TestSubmitData - common.SubmitData - Expected: %v but Given: %v", "error", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"net/http"
+	"time"
 )
 
 const (
@@ -63,6 +64,10 @@ var (
 	ContextKeyAuthtoken = ContextKey("auth-token")
 	// Session Context Remote Address
 	ContextRemoteAddress = ContextKey("remote-address")
+	// Request Context TLS client certificate identity (*PeerIdentity)
+	ContextPeerIdentity = ContextKey("peer-identity")
+	// Request Context Path Parameters
+	ContextPathParams = ContextKey("path-params")
 )
 
 // Generate a Security Token of a given length
@@ -101,3 +106,19 @@ type ApiAction interface {
 	Run(Args ...interface{}) error
 }
 
+
+// Http servers timeouts, zero values keep the server package defaults
+type ServerTimeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+}
+
+// Http servers limits, zero values keep the net/http defaults
+type ServerLimits struct {
+	// Maximum size of the request headers
+	MaxHeaderBytes int
+	// Maximum size of the request body, larger bodies fail reading
+	MaxBodyBytes int64
+}
@@ -0,0 +1,439 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	cio "github.com/hellgate75/go-tcp-common/io"
+	"github.com/hellgate75/go-tcp-common/log"
+	apicommon "github.com/hellgate75/go-tcp-common/net/api/common"
+	apiserver "github.com/hellgate75/go-tcp-common/net/api/server"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	restcommon "github.com/hellgate75/go-tcp-common/net/rest/common"
+	restserver "github.com/hellgate75/go-tcp-common/net/rest/tls/server"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var restMethods = map[string]ncom.RestMethod{
+	string(ncom.REST_METHOD_GET):       ncom.REST_METHOD_GET,
+	string(ncom.REST_METHOD_POST):      ncom.REST_METHOD_POST,
+	string(ncom.REST_METHOD_POST_FORM): ncom.REST_METHOD_POST_FORM,
+	string(ncom.REST_METHOD_HEAD):      ncom.REST_METHOD_HEAD,
+	string(ncom.REST_METHOD_CONNECT):   ncom.REST_METHOD_CONNECT,
+	string(ncom.REST_METHOD_DELETE):    ncom.REST_METHOD_DELETE,
+	string(ncom.REST_METHOD_OPTIONS):   ncom.REST_METHOD_OPTIONS,
+	string(ncom.REST_METHOD_PATCH):     ncom.REST_METHOD_PATCH,
+	string(ncom.REST_METHOD_PUT):       ncom.REST_METHOD_PUT,
+	string(ncom.REST_METHOD_TRACE):     ncom.REST_METHOD_TRACE,
+}
+
+// Returns the parser format of the configuration file extension
+func FileFormat(filePath string) (cio.ParserFormat, error) {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		return cio.ParserFormatYaml, nil
+	case ".json":
+		return cio.ParserFormatJson, nil
+	case ".xml":
+		return cio.ParserFormatXml, nil
+	default:
+		return "", errors.New(fmt.Sprintf("ServerConfig.Load - Unsupported configuration file extension: %s", filePath))
+	}
+}
+
+// Loads the configuration from a Yaml, Json or Xml file, applies the environment variables
+// overrides having the given prefix (none in case of empty prefix) and validates the result
+func LoadServerConfig(filePath string, envPrefix string) (*ServerConfig, error) {
+	format, err := FileFormat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var config = ServerConfig{}
+	if _, err := cio.UnmashallFrom(filePath, &config, format); err != nil {
+		return nil, errors.New(fmt.Sprintf("ServerConfig.Load - Unable to load file %s, Details: %s", filePath, err))
+	}
+	if "" != envPrefix {
+		if err := config.ApplyEnvironment(envPrefix); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Overrides the configuration fields with the environment variables named after the upper case
+// field path, e.g. <prefix>_TLS_MIN_VERSION, <prefix>_TIMEOUTS_READ or <prefix>_ROUTES_0_ACTION for the
+// existing routes. Lists of values are comma separated
+func (sc *ServerConfig) ApplyEnvironment(prefix string) error {
+	return applyEnvironment(reflect.ValueOf(sc).Elem(), strings.ToUpper(prefix), "")
+}
+
+func envName(tag string) string {
+	return strings.ToUpper(strings.ReplaceAll(tag, "-", "_"))
+}
+
+func fieldPath(parent string, name string) string {
+	if "" == parent {
+		return name
+	}
+	return parent + "." + name
+}
+
+func applyEnvironment(value reflect.Value, variable string, field string) error {
+	switch value.Kind() {
+	case reflect.Struct:
+		for idx := 0; idx < value.NumField(); idx++ {
+			tag := strings.Split(value.Type().Field(idx).Tag.Get("yaml"), ",")[0]
+			if "" == tag || "-" == tag {
+				continue
+			}
+			if err := applyEnvironment(value.Field(idx), variable+"_"+envName(tag), fieldPath(field, tag)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Struct {
+			for idx := 0; idx < value.Len(); idx++ {
+				if err := applyEnvironment(value.Index(idx), fmt.Sprintf("%s_%v", variable, idx), fmt.Sprintf("%s[%v]", field, idx)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	text, ok := os.LookupEnv(variable)
+	if !ok {
+		return nil
+	}
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(text))
+		if err != nil {
+			return &FieldError{Field: field, Value: text, Message: fmt.Sprintf("Invalid boolean in environment variable %s", variable)}
+		}
+		value.SetBool(parsed)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(text), 10, value.Type().Bits())
+		if err != nil {
+			return &FieldError{Field: field, Value: text, Message: fmt.Sprintf("Invalid integer in environment variable %s", variable)}
+		}
+		value.SetInt(parsed)
+	case reflect.Slice:
+		var items = make([]string, 0)
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); "" != item {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	}
+	return nil
+}
+
+func parseDuration(field string, text string) (time.Duration, error) {
+	if "" == strings.TrimSpace(text) {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(strings.TrimSpace(text))
+	if err != nil {
+		return 0, &FieldError{Field: field, Value: text, Message: err.Error()}
+	}
+	if duration < 0 {
+		return 0, &FieldError{Field: field, Value: text, Message: "Negative duration"}
+	}
+	return duration, nil
+}
+
+func checkFile(field string, path string) error {
+	if "" == path {
+		return &FieldError{Field: field, Value: path, Message: "Missing file path"}
+	}
+	if _, err := os.Stat(path); err != nil {
+		return &FieldError{Field: field, Value: path, Message: err.Error()}
+	}
+	return nil
+}
+
+// Validates the configuration, the returned FieldError names the first invalid field
+func (sc *ServerConfig) Validate() error {
+	if sc.Port < 0 || sc.Port > 65535 {
+		return &FieldError{Field: "port", Value: sc.Port, Message: "Port must be in range 0-65535"}
+	}
+	if _, err := sc.ServerTimeouts(); err != nil {
+		return err
+	}
+	if sc.Limits.MaxHeaderBytes < 0 {
+		return &FieldError{Field: "limits.max-header-bytes", Value: sc.Limits.MaxHeaderBytes, Message: "Negative limit"}
+	}
+	if sc.Limits.MaxBodyBytes < 0 {
+		return &FieldError{Field: "limits.max-body-bytes", Value: sc.Limits.MaxBodyBytes, Message: "Negative limit"}
+	}
+	if sc.TLS.Enabled {
+		if len(sc.TLS.Certificates) == 0 {
+			return &FieldError{Field: "tls.certificates", Value: "[]", Message: "At least one certificate is required"}
+		}
+		for idx, certificate := range sc.TLS.Certificates {
+			if err := checkFile(fmt.Sprintf("tls.certificates[%v].cert", idx), certificate.Cert); err != nil {
+				return err
+			}
+			if err := checkFile(fmt.Sprintf("tls.certificates[%v].key", idx), certificate.Key); err != nil {
+				return err
+			}
+		}
+		if "" != sc.TLS.CaCertificate {
+			if err := checkFile("tls.ca-certificate", sc.TLS.CaCertificate); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := ncom.ClientAuthMode(sc.TLS.ClientAuth).TLSClientAuth(); err != nil {
+		return &FieldError{Field: "tls.client-auth", Value: sc.TLS.ClientAuth, Message: "Unknown client authentication, expected none, request, require or verify-if-given"}
+	}
+	if _, err := sc.TLSPolicy(); err != nil {
+		return err
+	}
+	var paths = make(map[string]bool)
+	for idx, route := range sc.Routes {
+		field := fmt.Sprintf("routes[%v]", idx)
+		if !strings.HasPrefix(route.Path, "/") {
+			return &FieldError{Field: field + ".path", Value: route.Path, Message: "Path must start with a slash"}
+		}
+		if paths[route.Path] {
+			return &FieldError{Field: field + ".path", Value: route.Path, Message: "Duplicate path"}
+		}
+		paths[route.Path] = true
+		if "" == route.Action {
+			return &FieldError{Field: field + ".action", Value: route.Action, Message: "Missing action name"}
+		}
+		for idxM, method := range route.Methods {
+			if _, ok := restMethods[strings.ToUpper(method)]; !ok {
+				return &FieldError{Field: fmt.Sprintf("%s.methods[%v]", field, idxM), Value: method, Message: "Unknown web method"}
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the configured server timeouts
+func (sc *ServerConfig) ServerTimeouts() (ncom.ServerTimeouts, error) {
+	var timeouts = ncom.ServerTimeouts{}
+	var err error
+	if timeouts.ReadHeader, err = parseDuration("timeouts.read-header", sc.Timeouts.ReadHeader); err != nil {
+		return timeouts, err
+	}
+	if timeouts.Read, err = parseDuration("timeouts.read", sc.Timeouts.Read); err != nil {
+		return timeouts, err
+	}
+	if timeouts.Write, err = parseDuration("timeouts.write", sc.Timeouts.Write); err != nil {
+		return timeouts, err
+	}
+	if timeouts.Idle, err = parseDuration("timeouts.idle", sc.Timeouts.Idle); err != nil {
+		return timeouts, err
+	}
+	return timeouts, nil
+}
+
+// Returns the configured server limits
+func (sc *ServerConfig) ServerLimits() ncom.ServerLimits {
+	return ncom.ServerLimits{
+		MaxHeaderBytes: sc.Limits.MaxHeaderBytes,
+		MaxBodyBytes:   sc.Limits.MaxBodyBytes,
+	}
+}
+
+// Returns the TLS policy of the configured preset, versions, cipher suites, curves, ALPN protocols
+// and session tickets, nil when no policy is configured and the server default applies
+func (sc *ServerConfig) TLSPolicy() (*restcommon.TLSPolicy, error) {
+	config := sc.TLS
+	if "" == config.Preset && "" == config.MinVersion && "" == config.MaxVersion && len(config.CipherSuites) == 0 &&
+		len(config.Curves) == 0 && len(config.ALPN) == 0 && !config.DisableSessionTickets {
+		return nil, nil
+	}
+	var policy = &restcommon.TLSPolicy{
+		Preset:                restcommon.TLSPreset(strings.ToLower(strings.TrimSpace(config.Preset))),
+		NextProtos:            config.ALPN,
+		DisableSessionTickets: config.DisableSessionTickets,
+	}
+	if err := (restcommon.TLSPolicy{Preset: policy.Preset}).Validate(); err != nil {
+		return nil, &FieldError{Field: "tls.preset", Value: config.Preset, Message: "Unknown TLS preset, expected modern, intermediate or legacy"}
+	}
+	if "" != config.MinVersion {
+		version, ok := restcommon.TLSVersion(config.MinVersion)
+		if !ok {
+			return nil, &FieldError{Field: "tls.min-version", Value: config.MinVersion, Message: "Unknown TLS version, expected 1.0, 1.1, 1.2 or 1.3"}
+		}
+		policy.MinVersion = version
+	}
+	if "" != config.MaxVersion {
+		version, ok := restcommon.TLSVersion(config.MaxVersion)
+		if !ok {
+			return nil, &FieldError{Field: "tls.max-version", Value: config.MaxVersion, Message: "Unknown TLS version, expected 1.0, 1.1, 1.2 or 1.3"}
+		}
+		policy.MaxVersion = version
+	}
+	for idx, name := range config.CipherSuites {
+		id, ok := restcommon.CipherSuite(name)
+		if !ok {
+			return nil, &FieldError{Field: fmt.Sprintf("tls.cipher-suites[%v]", idx), Value: name, Message: "Unknown cipher suite"}
+		}
+		policy.CipherSuites = append(policy.CipherSuites, id)
+	}
+	for idx, name := range config.Curves {
+		curve, ok := restcommon.Curve(name)
+		if !ok {
+			return nil, &FieldError{Field: fmt.Sprintf("tls.curves[%v]", idx), Value: name, Message: "Unknown curve, expected P256, P384, P521 or X25519"}
+		}
+		policy.Curves = append(policy.Curves, curve)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, &FieldError{Field: "tls.max-version", Value: config.MaxVersion, Message: "Maximum version lower than the minimum one"}
+	}
+	return policy, nil
+}
+
+func mimeTypeRef(mimeType string, fallback ncom.MimeType) *ncom.MimeType {
+	var out = ncom.MimeType(mimeType)
+	if "" == out {
+		out = fallback
+	}
+	return &out
+}
+
+// Returns nil for an empty Mime Type, so that any content type is accepted
+func optionalMimeTypeRef(mimeType string) *ncom.MimeType {
+	if "" == mimeType {
+		return nil
+	}
+	var out = ncom.MimeType(mimeType)
+	return &out
+}
+
+func routeMethods(route RouteConfig) []ncom.RestMethod {
+	var methods = make([]ncom.RestMethod, 0)
+	for _, method := range route.Methods {
+		methods = append(methods, restMethods[strings.ToUpper(method)])
+	}
+	if len(methods) == 0 {
+		methods = append(methods, ncom.REST_METHOD_GET)
+	}
+	return methods
+}
+
+// Creates an Api Server with the configured timeouts, limits, TLS policy and routes. Routes refer to the
+// actions by name, Api Server routes accept one web method only
+func NewApiServer(config *ServerConfig, actions map[string]ncom.ApiAction, logger log.Logger) (apicommon.ApiServer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	server := apiserver.NewApiServer(logger)
+	if err := configure(config, server); err != nil {
+		return nil, err
+	}
+	for idx, route := range config.Routes {
+		action, ok := actions[route.Action]
+		if !ok {
+			return nil, &FieldError{Field: fmt.Sprintf("routes[%v].action", idx), Value: route.Action, Message: "Unknown action name"}
+		}
+		methods := routeMethods(route)
+		if len(methods) > 1 {
+			return nil, &FieldError{Field: fmt.Sprintf("routes[%v].methods", idx), Value: route.Methods, Message: "Api Server routes accept a single web method"}
+		}
+		if !server.AddApiAction(route.Path, action, route.InternalAnswer, &methods[0], mimeTypeRef(route.Produces, ncom.JSON_MIME_TYPE), mimeTypeRef(route.Consumes, ncom.JSON_MIME_TYPE)) {
+			return nil, &FieldError{Field: fmt.Sprintf("routes[%v].path", idx), Value: route.Path, Message: "Unable to add the route"}
+		}
+		if len(route.Allow) > 0 {
+			server.UsePath(route.Path, ncom.AuthorizeMiddleware(ncom.AllowIdentities(route.Allow...)))
+		}
+	}
+	return server, nil
+}
+
+// Starts the Api Server on the configured address, in TLS mode when enabled. It blocks until the server stops
+func StartApiServer(server apicommon.ApiServer, config *ServerConfig) error {
+	if !config.TLS.Enabled {
+		return server.Start(config.Address, int64(config.Port))
+	}
+	var certificates = make([]restcommon.CertificateKeyPair, 0)
+	for _, certificate := range config.TLS.Certificates {
+		certificates = append(certificates, restcommon.CertificateKeyPair{Cert: certificate.Cert, Key: certificate.Key})
+	}
+	return server.StartTLS(config.Address, int64(config.Port), &apicommon.TLSConfig{
+		CaCertificate: config.TLS.CaCertificate,
+		Certificates:  certificates,
+		UseInsecure:   config.TLS.Insecure,
+		ClientAuth:    ncom.ClientAuthMode(config.TLS.ClientAuth),
+	})
+}
+
+// Creates a Rest Server with the configured timeouts, limits, TLS policy and routes. Routes refer to the
+// callbacks by name
+func NewRestServer(config *ServerConfig, callbacks map[string]restcommon.RestCallback, logger log.Logger) (restcommon.RestServer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	server := restserver.New(logger)
+	if err := configure(config, server); err != nil {
+		return nil, err
+	}
+	if err := server.SetClientAuth(ncom.ClientAuthMode(config.TLS.ClientAuth)); err != nil {
+		return nil, err
+	}
+	for idx, route := range config.Routes {
+		callback, ok := callbacks[route.Action]
+		if !ok {
+			return nil, &FieldError{Field: fmt.Sprintf("routes[%v].action", idx), Value: route.Action, Message: "Unknown action name"}
+		}
+		if !server.AddPath(route.Path, callback, optionalMimeTypeRef(route.Consumes), mimeTypeRef(route.Produces, ncom.PLAIN_TEXT_MIME_TYPE), routeMethods(route)) {
+			return nil, &FieldError{Field: fmt.Sprintf("routes[%v].path", idx), Value: route.Path, Message: "Unable to add the route"}
+		}
+		if len(route.Allow) > 0 {
+			server.UsePath(route.Path, ncom.AuthorizeMiddleware(ncom.AllowIdentities(route.Allow...)))
+		}
+	}
+	return server, nil
+}
+
+// Starts the Rest Server on the configured address, in TLS mode when enabled. It blocks until the server stops
+func StartRestServer(server restcommon.RestServer, config *ServerConfig) error {
+	if !config.TLS.Enabled {
+		return server.Start(config.Address, int32(config.Port))
+	}
+	var certificates = make([]restcommon.CertificateKeyPair, 0)
+	for _, certificate := range config.TLS.Certificates {
+		certificates = append(certificates, restcommon.CertificateKeyPair{Cert: certificate.Cert, Key: certificate.Key})
+	}
+	return server.StartTLS(config.Address, int32(config.Port), certificates, config.TLS.CaCertificate, config.TLS.Insecure)
+}
+
+// Server settings shared by the Api and the Rest Servers
+type configurable interface {
+	SetTimeouts(timeouts ncom.ServerTimeouts)
+	SetLimits(limits ncom.ServerLimits)
+	SetTLSPolicy(policy restcommon.TLSPolicy) error
+}
+
+func configure(config *ServerConfig, server configurable) error {
+	timeouts, err := config.ServerTimeouts()
+	if err != nil {
+		return err
+	}
+	policy, err := config.TLSPolicy()
+	if err != nil {
+		return err
+	}
+	server.SetTimeouts(timeouts)
+	server.SetLimits(config.ServerLimits())
+	if policy != nil {
+		return server.SetTLSPolicy(*policy)
+	}
+	return nil
+}
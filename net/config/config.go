@@ -0,0 +1,84 @@
+package config
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+var (
+	// Prefix of the environment variables overriding the configuration fields
+	DEFAULT_ENV_PREFIX string = "TCP_SERVER"
+)
+
+// Api or Rest Server configuration. Durations are expressed as Go duration strings (e.g. 30s, 5m)
+// and zero or empty values keep the server defaults
+type ServerConfig struct {
+	XMLName  xml.Name       `yaml:"-" json:"-" xml:"server"`
+	Address  string         `yaml:"address" json:"address" xml:"address"`
+	Port     int            `yaml:"port" json:"port" xml:"port"`
+	TLS      TLSConfig      `yaml:"tls" json:"tls" xml:"tls"`
+	Timeouts TimeoutsConfig `yaml:"timeouts" json:"timeouts" xml:"timeouts"`
+	Limits   LimitsConfig   `yaml:"limits" json:"limits" xml:"limits"`
+	Routes   []RouteConfig  `yaml:"routes" json:"routes" xml:"routes>route"`
+}
+
+// TLS material and policy. The client authentication is one of none (default), request, require or
+// verify-if-given, the client certificates are verified against the CA certificate. The preset is one of modern, intermediate (default) or legacy and the
+// other policy fields override it. TLS versions are expressed as 1.0, 1.1, 1.2 or 1.3, cipher suites
+// with their standard names (e.g. TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384) and curves as P256, P384, P521 or X25519
+type TLSConfig struct {
+	Enabled               bool                `yaml:"enabled" json:"enabled" xml:"enabled"`
+	CaCertificate         string              `yaml:"ca-certificate" json:"caCertificate" xml:"ca-certificate"`
+	Certificates          []CertificateConfig `yaml:"certificates" json:"certificates" xml:"certificates>certificate"`
+	Insecure              bool                `yaml:"insecure" json:"insecure" xml:"insecure"`
+	ClientAuth            string              `yaml:"client-auth" json:"clientAuth" xml:"client-auth"`
+	Preset                string              `yaml:"preset" json:"preset" xml:"preset"`
+	MinVersion            string              `yaml:"min-version" json:"minVersion" xml:"min-version"`
+	MaxVersion            string              `yaml:"max-version" json:"maxVersion" xml:"max-version"`
+	CipherSuites          []string            `yaml:"cipher-suites" json:"cipherSuites" xml:"cipher-suites>cipher-suite"`
+	Curves                []string            `yaml:"curves" json:"curves" xml:"curves>curve"`
+	ALPN                  []string            `yaml:"alpn" json:"alpn" xml:"alpn>protocol"`
+	DisableSessionTickets bool                `yaml:"disable-session-tickets" json:"disableSessionTickets" xml:"disable-session-tickets"`
+}
+
+// Certificate and key files pair
+type CertificateConfig struct {
+	Cert string `yaml:"cert" json:"cert" xml:"cert"`
+	Key  string `yaml:"key" json:"key" xml:"key"`
+}
+
+type TimeoutsConfig struct {
+	ReadHeader string `yaml:"read-header" json:"readHeader" xml:"read-header"`
+	Read       string `yaml:"read" json:"read" xml:"read"`
+	Write      string `yaml:"write" json:"write" xml:"write"`
+	Idle       string `yaml:"idle" json:"idle" xml:"idle"`
+}
+
+type LimitsConfig struct {
+	MaxHeaderBytes int   `yaml:"max-header-bytes" json:"maxHeaderBytes" xml:"max-header-bytes"`
+	MaxBodyBytes   int64 `yaml:"max-body-bytes" json:"maxBodyBytes" xml:"max-body-bytes"`
+}
+
+// Route mapping a path to a registered action name. Api Server routes accept a single method
+// and InternalAnswer reports the action writes the answer itself. When Allow is not empty only the
+// verified client certificate identities matching one of the names are authorized
+type RouteConfig struct {
+	Path           string   `yaml:"path" json:"path" xml:"path"`
+	Action         string   `yaml:"action" json:"action" xml:"action"`
+	Methods        []string `yaml:"methods" json:"methods" xml:"methods>method"`
+	Produces       string   `yaml:"produces" json:"produces" xml:"produces"`
+	Consumes       string   `yaml:"consumes" json:"consumes" xml:"consumes"`
+	InternalAnswer bool     `yaml:"internal-answer" json:"internalAnswer" xml:"internal-answer"`
+	Allow          []string `yaml:"allow" json:"allow" xml:"allow>identity"`
+}
+
+// Configuration error, reporting the configuration field path (e.g. routes[1].action)
+type FieldError struct {
+	Field   string
+	Value   interface{}
+	Message string
+}
+
+func (fe *FieldError) Error() string {
+	return fmt.Sprintf("ServerConfig - Invalid field %s: %v, Details: %s", fe.Field, fe.Value, fe.Message)
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"github.com/hellgate75/go-tcp-common/log"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	restcommon "github.com/hellgate75/go-tcp-common/net/rest/common"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const yamlConfig = `
+address: 127.0.0.1
+port: 8443
+tls:
+  preset: Legacy
+  min-version: "1.2"
+  alpn: [h2, http/1.1]
+  cipher-suites:
+    - TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384
+  curves: [P256, X25519]
+timeouts:
+  read: 30s
+  idle: 2m
+limits:
+  max-body-bytes: 8
+routes:
+  - path: /echo
+    action: echo
+    methods: [POST]
+  - path: /nodes/{id}
+    action: nodes
+    allow: [spiffe://cluster.local/*]
+`
+
+const jsonConfig = `{"address": "127.0.0.1", "port": 8080, "timeouts": {"readHeader": "5s"},
+	"routes": [{"path": "/echo", "action": "echo", "methods": ["POST"]}]}`
+
+const xmlConfig = `<server><address>127.0.0.1</address><port>8080</port><timeouts><read-header>5s</read-header></timeouts>
+	<routes><route><path>/echo</path><action>echo</action><methods><method>POST</method></methods></route></routes></server>`
+
+func writeConfig(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func fieldOf(err error) string {
+	var fieldError *FieldError
+	if errors.As(err, &fieldError) {
+		return fieldError.Field
+	}
+	return ""
+}
+
+func TestLoadServerConfig(t *testing.T) {
+	t.Setenv("TEST_SERVER_PORT", "9443")
+	t.Setenv("TEST_SERVER_TIMEOUTS_WRITE", "45s")
+	t.Setenv("TEST_SERVER_ROUTES_1_ACTION", "node")
+	t.Setenv("TEST_SERVER_TLS_CURVES", "P384, P521")
+	config, err := LoadServerConfig(writeConfig(t, "server.yaml", yamlConfig), "test_server")
+	if err != nil {
+		t.Fatalf("TestLoadServerConfig - config.LoadServerConfig - Expected: %v but Given: %v", nil, err)
+	}
+	if config.Port != 9443 || config.Routes[1].Action != "node" || config.Routes[0].Methods[0] != "POST" || config.Routes[1].Allow[0] != "spiffe://cluster.local/*" {
+		t.Fatalf("TestLoadServerConfig - config.LoadServerConfig - Expected: %v but Given: %+v", "environment overrides", config)
+	}
+	timeouts, _ := config.ServerTimeouts()
+	if timeouts.Read != 30*time.Second || timeouts.Write != 45*time.Second || timeouts.Idle != 2*time.Minute || timeouts.ReadHeader != 0 {
+		t.Fatalf("TestLoadServerConfig - ServerConfig.ServerTimeouts - Expected: %v but Given: %+v", "30s, 45s, 2m", timeouts)
+	}
+	policy, err := config.TLSPolicy()
+	if err != nil || policy.Preset != restcommon.TLS_PRESET_LEGACY || policy.MinVersion != tls.VersionTLS12 || len(policy.CipherSuites) != 1 ||
+		len(policy.Curves) != 2 || policy.Curves[0] != tls.CurveP384 || len(policy.NextProtos) != 2 {
+		t.Fatalf("TestLoadServerConfig - ServerConfig.TLSPolicy - Expected: %v but Given: %+v (%v)", "TLS policy", policy, err)
+	}
+	for _, c := range []struct{ name, content string }{{"server.json", jsonConfig}, {"server.xml", xmlConfig}} {
+		config, err := LoadServerConfig(writeConfig(t, c.name, c.content), "")
+		if err != nil || config.Port != 8080 || config.Timeouts.ReadHeader != "5s" || len(config.Routes) != 1 || config.Routes[0].Methods[0] != "POST" {
+			t.Fatalf("TestLoadServerConfig - config.LoadServerConfig(%s) - Expected: %v but Given: %+v (%v)", c.name, "loaded configuration", config, err)
+		}
+	}
+}
+
+func TestServerConfigValidation(t *testing.T) {
+	var cases = []struct {
+		content string
+		env     map[string]string
+		field   string
+	}{
+		{"port: 70000", nil, "port"},
+		{"timeouts:\n  read: soon", nil, "timeouts.read"},
+		{"tls:\n  enabled: true", nil, "tls.certificates"},
+		{"tls:\n  enabled: true\n  certificates:\n    - cert: /missing/server.pem\n      key: /missing/server.key", nil, "tls.certificates[0].cert"},
+		{"tls:\n  max-version: \"1.4\"", nil, "tls.max-version"},
+		{"tls:\n  preset: modern\n  max-version: \"1.2\"", nil, "tls.max-version"},
+		{"tls:\n  preset: strict", nil, "tls.preset"},
+		{"tls:\n  client-auth: always", nil, "tls.client-auth"},
+		{"tls:\n  cipher-suites: [TLS_FAKE]", nil, "tls.cipher-suites[0]"},
+		{"routes:\n  - path: /a\n    action: a\n    methods: [GET, FETCH]", nil, "routes[0].methods[1]"},
+		{"routes:\n  - path: a\n    action: a", nil, "routes[0].path"},
+		{"port: 80", map[string]string{"TEST_SERVER_TLS_ENABLED": "maybe"}, "tls.enabled"},
+	}
+	for _, c := range cases {
+		for key, value := range c.env {
+			os.Setenv(key, value)
+		}
+		_, err := LoadServerConfig(writeConfig(t, "server.yml", c.content), "TEST_SERVER")
+		for key := range c.env {
+			os.Unsetenv(key)
+		}
+		if field := fieldOf(err); field != c.field {
+			t.Fatalf("TestServerConfigValidation - config.LoadServerConfig(%q) - Expected: %v but Given: %v (%v)", c.content, c.field, field, err)
+		}
+	}
+}
+
+func TestNewRestServer(t *testing.T) {
+	config, err := LoadServerConfig(writeConfig(t, "server.yaml", yamlConfig), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo := func(w http.ResponseWriter, req *http.Request, path string, accepts ncom.MimeType, produces ncom.MimeType) {
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			ncom.SubmitError(w, req, ncom.WrapApiError(http.StatusRequestEntityTooLarge, "Body too large", err), "")
+			return
+		}
+		ncom.SubmitSuccess(w, string(data))
+	}
+	if _, err := NewRestServer(config, map[string]restcommon.RestCallback{"echo": echo}, nil); fieldOf(err) != "routes[1].action" {
+		t.Fatalf("TestNewRestServer - config.NewRestServer - Expected: %v but Given: %v", "routes[1].action", err)
+	}
+	server, err := NewRestServer(config, map[string]restcommon.RestCallback{"echo": echo, "nodes": echo}, log.NewLogger("config-test", log.FATAL))
+	if err != nil {
+		t.Fatalf("TestNewRestServer - config.NewRestServer - Expected: %v but Given: %v", nil, err)
+	}
+	var cases = []struct {
+		method      string
+		path        string
+		body        string
+		contentType string
+		status      int
+	}{
+		{http.MethodPost, "/echo", "12345678", "", http.StatusOK},
+		{http.MethodPost, "/echo", "{\"a\":1}", "application/json", http.StatusOK},
+		{http.MethodPost, "/echo", "123456789", "", http.StatusRequestEntityTooLarge},
+		{http.MethodGet, "/nodes/7", "", "", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		if "" != c.contentType {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		server.(http.Handler).ServeHTTP(recorder, req)
+		if recorder.Code != c.status {
+			t.Fatalf("TestNewRestServer - RestServer.ServeHTTP(%s %q) - Expected: %v but Given: %v", c.path, c.body, c.status, recorder.Code)
+		}
+	}
+}
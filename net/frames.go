@@ -0,0 +1,223 @@
+package netimages
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Frame Type, describes the content of a frame
+type FrameType byte
+
+// Frame Flags, bit mask of frame options
+type FrameFlags byte
+
+const (
+	// Size of the frame header: 4 bytes big-endian payload length, 1 byte frame type, 1 byte flags
+	FRAME_HEADER_SIZE int = 6
+
+	//Frame Type: Data frame
+	FRAME_TYPE_DATA FrameType = 0x01
+	//Frame Type: Control frame
+	FRAME_TYPE_CONTROL FrameType = 0x02
+	//Frame Type: Ping frame
+	FRAME_TYPE_PING FrameType = 0x03
+	//Frame Type: Pong frame
+	FRAME_TYPE_PONG FrameType = 0x04
+	//Frame Type: Close frame
+	FRAME_TYPE_CLOSE FrameType = 0x05
+
+	//Frame Flags: No flags
+	FRAME_FLAG_NONE FrameFlags = 0x00
+	//Frame Flags: Last frame of a sequence
+	FRAME_FLAG_LAST FrameFlags = 0x01
+	//Frame Flags: Frame payload contains an error message
+	FRAME_FLAG_ERROR FrameFlags = 0x02
+)
+
+var (
+	// Default maximum frame payload size (16 MiB)
+	DEFAULT_MAX_FRAME_SIZE uint32 = 16 * 1024 * 1024
+	// Error returned when a frame payload exceeds the maximum frame size
+	ErrFrameTooLarge = errors.New("net: frame exceeds maximum frame size")
+)
+
+// Single protocol frame
+type Frame struct {
+	Type    FrameType
+	Flags   FrameFlags
+	Payload []byte
+}
+
+// Verifies if a flag is set on the frame
+func (f *Frame) HasFlag(flag FrameFlags) bool {
+	return f.Flags&flag == flag
+}
+
+// String representation of the Frame
+func (f *Frame) String() string {
+	return fmt.Sprintf("Frame{Type: %v, Flags: %v, Size: %v}", f.Type, f.Flags, len(f.Payload))
+}
+
+// Reads length-prefixed frames from a connection
+type FrameReader interface {
+	// Read next frame, waiting without deadline
+	ReadFrame() (*Frame, error)
+	// Read next frame, failing if no complete frame arrives before the timeout
+	ReadFrameTimeout(timeout time.Duration) (*Frame, error)
+	// Maximum accepted frame payload size
+	MaxFrameSize() uint32
+}
+
+// Writes length-prefixed frames to a connection, safe for concurrent use
+type FrameWriter interface {
+	// Write a frame, waiting without deadline
+	WriteFrame(frame *Frame) error
+	// Write a frame, failing if it cannot be sent before the timeout
+	WriteFrameTimeout(frame *Frame, timeout time.Duration) error
+	// Maximum accepted frame payload size
+	MaxFrameSize() uint32
+}
+
+type frameReader struct {
+	sync.Mutex
+	conn    net.Conn
+	maxSize uint32
+	header  []byte
+}
+
+func (fr *frameReader) MaxFrameSize() uint32 {
+	return fr.maxSize
+}
+
+func (fr *frameReader) ReadFrame() (*Frame, error) {
+	fr.Lock()
+	defer fr.Unlock()
+	return fr.read()
+}
+
+func (fr *frameReader) ReadFrameTimeout(timeout time.Duration) (*Frame, error) {
+	fr.Lock()
+	defer fr.Unlock()
+	if err := fr.conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	defer fr.conn.SetReadDeadline(time.Time{})
+	return fr.read()
+}
+
+func (fr *frameReader) read() (*Frame, error) {
+	if _, err := io.ReadFull(fr.conn, fr.header); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(fr.header[0:4])
+	if size > fr.maxSize {
+		return nil, ErrFrameTooLarge
+	}
+	frame := &Frame{
+		Type:    FrameType(fr.header[4]),
+		Flags:   FrameFlags(fr.header[5]),
+		Payload: make([]byte, size),
+	}
+	if _, err := io.ReadFull(fr.conn, frame.Payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return frame, nil
+}
+
+type frameWriter struct {
+	sync.Mutex
+	conn    net.Conn
+	maxSize uint32
+}
+
+func (fw *frameWriter) MaxFrameSize() uint32 {
+	return fw.maxSize
+}
+
+func (fw *frameWriter) WriteFrame(frame *Frame) error {
+	fw.Lock()
+	defer fw.Unlock()
+	return fw.write(frame)
+}
+
+func (fw *frameWriter) WriteFrameTimeout(frame *Frame, timeout time.Duration) error {
+	fw.Lock()
+	defer fw.Unlock()
+	if err := fw.conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	defer fw.conn.SetWriteDeadline(time.Time{})
+	return fw.write(frame)
+}
+
+func (fw *frameWriter) write(frame *Frame) error {
+	if frame == nil {
+		return errors.New("net: nil frame")
+	}
+	if uint64(len(frame.Payload)) > uint64(fw.maxSize) {
+		return ErrFrameTooLarge
+	}
+	// Header and payload are sent in a single write, so concurrent
+	// writers on the same connection never interleave partial frames
+	buff := make([]byte, FRAME_HEADER_SIZE+len(frame.Payload))
+	binary.BigEndian.PutUint32(buff[0:4], uint32(len(frame.Payload)))
+	buff[4] = byte(frame.Type)
+	buff[5] = byte(frame.Flags)
+	copy(buff[FRAME_HEADER_SIZE:], frame.Payload)
+	_, err := fw.conn.Write(buff)
+	return err
+}
+
+// Creates a new frame reader on any connection, zero maxFrameSize means DEFAULT_MAX_FRAME_SIZE
+func NewFrameReader(conn net.Conn, maxFrameSize uint32) FrameReader {
+	if maxFrameSize == 0 {
+		maxFrameSize = DEFAULT_MAX_FRAME_SIZE
+	}
+	return &frameReader{
+		conn:    conn,
+		maxSize: maxFrameSize,
+		header:  make([]byte, FRAME_HEADER_SIZE),
+	}
+}
+
+// Creates a new frame writer on any connection, zero maxFrameSize means DEFAULT_MAX_FRAME_SIZE
+func NewFrameWriter(conn net.Conn, maxFrameSize uint32) FrameWriter {
+	if maxFrameSize == 0 {
+		maxFrameSize = DEFAULT_MAX_FRAME_SIZE
+	}
+	return &frameWriter{
+		conn:    conn,
+		maxSize: maxFrameSize,
+	}
+}
+
+// Write a binary payload as a single data frame
+func WriteFrame(value []byte, conn net.Conn) error {
+	return NewFrameWriter(conn, 0).WriteFrame(&Frame{
+		Type:    FRAME_TYPE_DATA,
+		Flags:   FRAME_FLAG_NONE,
+		Payload: value,
+	})
+}
+
+// Read the binary payload of the next frame, waiting until the timeout
+func ReadFrameTimeout(conn net.Conn, timeout time.Duration) ([]byte, error) {
+	frame, err := NewFrameReader(conn, 0).ReadFrameTimeout(timeout)
+	if err != nil {
+		return nil, err
+	}
+	return frame.Payload, nil
+}
+
+// Read the binary payload of the next frame, waiting until the DEFAULT_TIMEOUT
+func ReadFrame(conn net.Conn) ([]byte, error) {
+	return ReadFrameTimeout(conn, DEFAULT_TIMEOUT)
+}
@@ -0,0 +1,48 @@
+package netimages
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	payload := []byte{0x00, ' ', '\n', 0xff, ' ', 0x00}
+	go func() {
+		writer := NewFrameWriter(client, 0)
+		writer.WriteFrame(&Frame{Type: FRAME_TYPE_CONTROL, Flags: FRAME_FLAG_LAST, Payload: payload})
+		writer.WriteFrame(&Frame{Type: FRAME_TYPE_DATA, Payload: []byte{}})
+	}()
+	reader := NewFrameReader(server, 0)
+	frame, err := reader.ReadFrameTimeout(2 * time.Second)
+	if err != nil {
+		t.Fatalf("TestFrameRoundTrip - net.FrameReader.ReadFrameTimeout - Unexpected error: %s", err)
+	}
+	if frame.Type != FRAME_TYPE_CONTROL || !frame.HasFlag(FRAME_FLAG_LAST) {
+		t.Fatalf("TestFrameRoundTrip - net.FrameReader.ReadFrameTimeout - Expected control/last frame but Given: %s", frame)
+	}
+	if !bytes.Equal(payload, frame.Payload) {
+		t.Fatalf("TestFrameRoundTrip - net.FrameReader.ReadFrameTimeout - Expected payload: %v but Given: %v", payload, frame.Payload)
+	}
+	frame, err = reader.ReadFrameTimeout(2 * time.Second)
+	if err != nil || len(frame.Payload) != 0 {
+		t.Fatalf("TestFrameRoundTrip - net.FrameReader.ReadFrameTimeout - Expected empty frame but Given: %v, error: %v", frame, err)
+	}
+}
+
+func TestFrameMaxSize(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	if err := NewFrameWriter(client, 4).WriteFrame(&Frame{Type: FRAME_TYPE_DATA, Payload: []byte("12345")}); err != ErrFrameTooLarge {
+		t.Fatalf("TestFrameMaxSize - net.FrameWriter.WriteFrame - Expected: %v but Given: %v", ErrFrameTooLarge, err)
+	}
+	go NewFrameWriter(client, 0).WriteFrame(&Frame{Type: FRAME_TYPE_DATA, Payload: []byte("12345")})
+	if _, err := NewFrameReader(server, 4).ReadFrameTimeout(2 * time.Second); err != ErrFrameTooLarge {
+		t.Fatalf("TestFrameMaxSize - net.FrameReader.ReadFrameTimeout - Expected: %v but Given: %v", ErrFrameTooLarge, err)
+	}
+}
@@ -74,6 +74,9 @@ func ReadStringBuffer(buffSize int, conn *tls.Conn) (string, error) {
 	return ReadStringBufferTimeout(buffSize, conn, DEFAULT_TIMEOUT)
 }
 
+// Read a frame-size and a value from the connection.
+//
+// Deprecated: the frame size has no delimiter and the value is trimmed, use ReadFrameTimeout instead
 func ReadTimeout(conn *tls.Conn, timeout time.Duration) ([]byte, error) {
 	value, errX := readString(2048, conn, timeout)
 	if errX != nil {
@@ -86,6 +89,7 @@ func ReadTimeout(conn *tls.Conn, timeout time.Duration) ([]byte, error) {
 	return readBytes(size, conn, timeout)
 }
 
+// Deprecated: use ReadFrame instead
 func Read(conn *tls.Conn) ([]byte, error) {
 	return ReadTimeout(conn, DEFAULT_TIMEOUT)
 }
@@ -105,6 +109,9 @@ func WriteString(value string, conn *tls.Conn) (int, error) {
 	return writeString(value, conn)
 }
 
+// Write a frame-size and a value to the connection.
+//
+// Deprecated: the frame size has no delimiter and binary values are trimmed, use WriteFrame instead
 func Write(value []byte, conn *tls.Conn) (int, error) {
 	value = []byte(strings.TrimSpace(fmt.Sprintf("%s", string(value))))
 	n, err := writeString(fmt.Sprintf("%v", len(value)), conn)
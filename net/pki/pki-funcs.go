@@ -0,0 +1,255 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	apicommon "github.com/hellgate75/go-tcp-common/net/api/common"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"github.com/hellgate75/go-tcp-common/net/rest/common"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type certificateAuthority struct {
+	ca *Certificate
+}
+
+// Creates a Certificate Authority with a new self-signed root CA certificate
+func NewCertificateAuthority(request CertificateRequest) (CertificateAuthority, error) {
+	if request.Validity <= 0 {
+		request.Validity = DEFAULT_CA_VALIDITY
+	}
+	template, key, err := newTemplate(request)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("pki.NewCertificateAuthority - Invalid request, Details: %s", err))
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.MaxPathLenZero = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	ca, err := sign(template, template, key.Public(), key)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("pki.NewCertificateAuthority - Unable to sign the CA certificate, Details: %s", err))
+	}
+	ca.PrivateKey = key
+	ca.KeyPEM, err = encodeKey(key)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("pki.NewCertificateAuthority - Unable to encode the CA key, Details: %s", err))
+	}
+	return &certificateAuthority{ca: ca}, nil
+}
+
+// Loads a Certificate Authority from the PEM CA certificate and key files
+func LoadCertificateAuthority(certFile string, keyFile string) (CertificateAuthority, error) {
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("pki.LoadCertificateAuthority - Unable to load certificate: %s and key: %s, Details: %s", certFile, keyFile, err))
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("pki.LoadCertificateAuthority - Invalid certificate: %s, Details: %s", certFile, err))
+	}
+	if !cert.IsCA || cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return nil, errors.New(fmt.Sprintf("pki.LoadCertificateAuthority - Certificate: %s is not a CA certificate", certFile))
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("pki.LoadCertificateAuthority - Unsupported key: %s", keyFile))
+	}
+	certPEM, _ := ioutil.ReadFile(certFile)
+	keyPEM, _ := ioutil.ReadFile(keyFile)
+	return &certificateAuthority{ca: &Certificate{Certificate: cert, PrivateKey: key, CertPEM: certPEM, KeyPEM: keyPEM}}, nil
+}
+
+func (ca *certificateAuthority) Certificate() *Certificate {
+	return ca.ca
+}
+
+func (ca *certificateAuthority) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.ca.Certificate)
+	return pool
+}
+
+func (ca *certificateAuthority) IssueServer(request CertificateRequest) (*Certificate, error) {
+	return ca.issue(request, x509.ExtKeyUsageServerAuth)
+}
+
+func (ca *certificateAuthority) IssueClient(request CertificateRequest) (*Certificate, error) {
+	return ca.issue(request, x509.ExtKeyUsageClientAuth)
+}
+
+func (ca *certificateAuthority) IssueNode(request CertificateRequest) (*Certificate, error) {
+	return ca.issue(request, x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth)
+}
+
+func (ca *certificateAuthority) issue(request CertificateRequest, usages ...x509.ExtKeyUsage) (*Certificate, error) {
+	if request.Validity <= 0 {
+		request.Validity = DEFAULT_CERTIFICATE_VALIDITY
+	}
+	template, key, err := newTemplate(request)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("CertificateAuthority.Issue - Invalid request, Details: %s", err))
+	}
+	if template.NotAfter.After(ca.ca.Certificate.NotAfter) {
+		template.NotAfter = ca.ca.Certificate.NotAfter
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+	template.ExtKeyUsage = usages
+	cert, err := sign(template, ca.ca.Certificate, key.Public(), ca.ca.PrivateKey)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("CertificateAuthority.Issue - Unable to sign the certificate, Details: %s", err))
+	}
+	cert.PrivateKey = key
+	cert.KeyPEM, err = encodeKey(key)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("CertificateAuthority.Issue - Unable to encode the key, Details: %s", err))
+	}
+	return cert, nil
+}
+
+// Returns the certificate and key as tls Certificate
+func (c *Certificate) TLSCertificate() (tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(c.CertPEM, c.KeyPEM)
+	if err != nil {
+		return cert, err
+	}
+	cert.Leaf = c.Certificate
+	return cert, nil
+}
+
+// Writes the PEM certificate and key files, the key file is readable by the owner only
+func (c *Certificate) WriteFiles(certFile string, keyFile string) (common.CertificateKeyPair, error) {
+	var pair = common.CertificateKeyPair{Cert: certFile, Key: keyFile}
+	if err := ioutil.WriteFile(certFile, c.CertPEM, 0644); err != nil {
+		return pair, errors.New(fmt.Sprintf("Certificate.WriteFiles - Unable to write certificate: %s, Details: %s", certFile, err))
+	}
+	if "" != keyFile {
+		if err := ioutil.WriteFile(keyFile, c.KeyPEM, 0600); err != nil {
+			return pair, errors.New(fmt.Sprintf("Certificate.WriteFiles - Unable to write key: %s, Details: %s", keyFile, err))
+		}
+	}
+	return pair, nil
+}
+
+// Issues a node certificate for the request and writes it in the folder, as <common name>.pem and
+// <common name>.key, together with the CA certificate (ca.pem). It returns the Api Server and Client TLS
+// configuration of the node, requiring verified client certificates
+func NodeTLSConfig(ca CertificateAuthority, folder string, request CertificateRequest) (*apicommon.TLSConfig, error) {
+	cert, err := ca.IssueNode(request)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return nil, errors.New(fmt.Sprintf("pki.NodeTLSConfig - Unable to create folder: %s, Details: %s", folder, err))
+	}
+	var name = request.Subject.CommonName
+	if "" == name {
+		name = "node"
+	}
+	caFile := filepath.Join(folder, "ca.pem")
+	if _, err := ca.Certificate().WriteFiles(caFile, ""); err != nil {
+		return nil, err
+	}
+	pair, err := cert.WriteFiles(filepath.Join(folder, name+".pem"), filepath.Join(folder, name+".key"))
+	if err != nil {
+		return nil, err
+	}
+	return &apicommon.TLSConfig{
+		CaCertificate: caFile,
+		Certificates:  []common.CertificateKeyPair{pair},
+		ClientAuth:    ncom.CLIENT_AUTH_REQUIRE,
+	}, nil
+}
+
+// Creates the certificate template and the private key of a request
+func newTemplate(request CertificateRequest) (*x509.Certificate, crypto.Signer, error) {
+	key, err := newKey(request.KeyType)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	var template = &x509.Certificate{
+		SerialNumber:   serial,
+		Subject:        request.Subject,
+		NotBefore:      now.Add(-DEFAULT_CLOCK_SKEW),
+		NotAfter:       now.Add(request.Validity),
+		EmailAddresses: request.EmailAddresses,
+	}
+	for _, host := range request.Hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	for _, value := range request.URIs {
+		uri, err := url.Parse(value)
+		if err != nil || "" == uri.Scheme {
+			return nil, nil, errors.New(fmt.Sprintf("Invalid URI: %s", value))
+		}
+		template.URIs = append(template.URIs, uri)
+	}
+	return template, key, nil
+}
+
+func newKey(keyType KeyType) (crypto.Signer, error) {
+	if "" == keyType {
+		keyType = DEFAULT_KEY_TYPE
+	}
+	switch keyType {
+	case KEY_TYPE_ECDSA_P256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KEY_TYPE_ECDSA_P384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case KEY_TYPE_RSA_2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case KEY_TYPE_RSA_4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown key type: %s", keyType))
+	}
+}
+
+func sign(template *x509.Certificate, parent *x509.Certificate, publicKey crypto.PublicKey, signer crypto.Signer) (*Certificate, error) {
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, publicKey, signer)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &Certificate{
+		Certificate: cert,
+		CertPEM:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}, nil
+}
+
+func encodeKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
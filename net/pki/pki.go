@@ -0,0 +1,67 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"time"
+)
+
+// Private key algorithm of the generated certificates
+type KeyType string
+
+const (
+	// ECDSA key on the P-256 curve
+	KEY_TYPE_ECDSA_P256 KeyType = "ecdsa-p256"
+	// ECDSA key on the P-384 curve
+	KEY_TYPE_ECDSA_P384 KeyType = "ecdsa-p384"
+	// RSA 2048 bits key
+	KEY_TYPE_RSA_2048 KeyType = "rsa-2048"
+	// RSA 4096 bits key
+	KEY_TYPE_RSA_4096 KeyType = "rsa-4096"
+)
+
+var (
+	// Default key algorithm
+	DEFAULT_KEY_TYPE KeyType = KEY_TYPE_ECDSA_P256
+	// Default validity of the root CA certificates
+	DEFAULT_CA_VALIDITY time.Duration = 10 * 365 * 24 * time.Hour
+	// Default validity of the issued certificates
+	DEFAULT_CERTIFICATE_VALIDITY time.Duration = 365 * 24 * time.Hour
+	// Backdating of the certificates validity start, tolerating clock differences between hosts
+	DEFAULT_CLOCK_SKEW time.Duration = 5 * time.Minute
+)
+
+// Certificate request: Hosts are IP addresses or host names added as IP or DNS SANs, URIs are added
+// as URI SANs (e.g. spiffe://cluster.local/ns/prod/sa/node). Zero Validity and empty KeyType take
+// the package defaults
+type CertificateRequest struct {
+	Subject        pkix.Name
+	Hosts          []string
+	URIs           []string
+	EmailAddresses []string
+	Validity       time.Duration
+	KeyType        KeyType
+}
+
+// Certificate with its private key, in parsed and PEM formats
+type Certificate struct {
+	Certificate *x509.Certificate
+	PrivateKey  crypto.Signer
+	CertPEM     []byte
+	KeyPEM      []byte
+}
+
+// In-process Certificate Authority, issuing certificates signed by its root CA certificate
+type CertificateAuthority interface {
+	// Returns the CA certificate and key
+	Certificate() *Certificate
+	// Returns a pool containing the CA certificate
+	CertPool() *x509.CertPool
+	// Issues a certificate valid for server authentication
+	IssueServer(request CertificateRequest) (*Certificate, error)
+	// Issues a certificate valid for client authentication
+	IssueClient(request CertificateRequest) (*Certificate, error)
+	// Issues a certificate valid for both server and client authentication, as used by the cluster nodes
+	IssueNode(request CertificateRequest) (*Certificate, error)
+}
@@ -0,0 +1,122 @@
+package pki
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"github.com/hellgate75/go-tcp-common/net/rest/common"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCertificateAuthority(t *testing.T) {
+	ca, err := NewCertificateAuthority(CertificateRequest{Subject: pkix.Name{CommonName: "Test Root CA"}})
+	if err != nil {
+		t.Fatalf("TestCertificateAuthority - pki.NewCertificateAuthority - Expected: %v but Given: %v", nil, err)
+	}
+	serverCert, err := ca.IssueServer(CertificateRequest{Subject: pkix.Name{CommonName: "server"}, Hosts: []string{"127.0.0.1", "localhost"}, KeyType: KEY_TYPE_RSA_2048})
+	if err != nil {
+		t.Fatalf("TestCertificateAuthority - CertificateAuthority.IssueServer - Expected: %v but Given: %v", nil, err)
+	}
+	clientCert, err := ca.IssueClient(CertificateRequest{Subject: pkix.Name{CommonName: "client"}, URIs: []string{"spiffe://cluster.local/ns/prod/sa/client"}, Validity: time.Hour})
+	if err != nil {
+		t.Fatalf("TestCertificateAuthority - CertificateAuthority.IssueClient - Expected: %v but Given: %v", nil, err)
+	}
+	var cases = []struct {
+		cert     *Certificate
+		usage    x509.ExtKeyUsage
+		host     string
+		expected bool
+	}{
+		{serverCert, x509.ExtKeyUsageServerAuth, "localhost", true},
+		{serverCert, x509.ExtKeyUsageServerAuth, "127.0.0.1", true},
+		{serverCert, x509.ExtKeyUsageServerAuth, "example.com", false},
+		{serverCert, x509.ExtKeyUsageClientAuth, "", false},
+		{clientCert, x509.ExtKeyUsageClientAuth, "", true},
+		{clientCert, x509.ExtKeyUsageServerAuth, "", false},
+	}
+	for idx, c := range cases {
+		_, err := c.cert.Certificate.Verify(x509.VerifyOptions{Roots: ca.CertPool(), DNSName: c.host, KeyUsages: []x509.ExtKeyUsage{c.usage}})
+		if (err == nil) != c.expected {
+			t.Fatalf("TestCertificateAuthority - x509.Certificate.Verify - case %v - Expected: %v but Given: %v", idx, c.expected, err)
+		}
+	}
+
+	serverPair, _ := serverCert.TLSCertificate()
+	clientPair, err := clientCert.TLSCertificate()
+	if err != nil {
+		t.Fatalf("TestCertificateAuthority - Certificate.TLSCertificate - Expected: %v but Given: %v", nil, err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		identity, _ := ncom.PeerIdentityOf(ncom.WithPeerIdentity(req))
+		w.Write([]byte(identity.SpiffeId))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{serverPair}, ClientCAs: ca.CertPool(), ClientAuth: tls.RequireAndVerifyClientCert}
+	server.StartTLS()
+	defer server.Close()
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: ca.CertPool(), Certificates: []tls.Certificate{clientPair}}}}
+	defer client.CloseIdleConnections()
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("TestCertificateAuthority - http.Client.Get - Expected: %v but Given: %v", nil, err)
+	}
+	defer resp.Body.Close()
+	buffer := make([]byte, 128)
+	n, _ := resp.Body.Read(buffer)
+	if answer := string(buffer[:n]); answer != "spiffe://cluster.local/ns/prod/sa/client" {
+		t.Fatalf("TestCertificateAuthority - http.Client.Get - Expected: %v but Given: %v", "spiffe://cluster.local/ns/prod/sa/client", answer)
+	}
+}
+
+func TestLoadCertificateAuthority(t *testing.T) {
+	folder := t.TempDir()
+	ca, _ := NewCertificateAuthority(CertificateRequest{Subject: pkix.Name{CommonName: "Test Root CA"}, KeyType: KEY_TYPE_ECDSA_P384})
+	pair, err := ca.Certificate().WriteFiles(filepath.Join(folder, "ca.crt"), filepath.Join(folder, "ca.key"))
+	if err != nil {
+		t.Fatalf("TestLoadCertificateAuthority - Certificate.WriteFiles - Expected: %v but Given: %v", nil, err)
+	}
+	loaded, err := LoadCertificateAuthority(pair.Cert, pair.Key)
+	if err != nil {
+		t.Fatalf("TestLoadCertificateAuthority - pki.LoadCertificateAuthority - Expected: %v but Given: %v", nil, err)
+	}
+	cert, err := loaded.IssueClient(CertificateRequest{Subject: pkix.Name{CommonName: "client"}})
+	if err != nil {
+		t.Fatalf("TestLoadCertificateAuthority - CertificateAuthority.IssueClient - Expected: %v but Given: %v", nil, err)
+	}
+	if _, err := cert.Certificate.Verify(x509.VerifyOptions{Roots: ca.CertPool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Fatalf("TestLoadCertificateAuthority - x509.Certificate.Verify - Expected: %v but Given: %v", nil, err)
+	}
+	clientPair, _ := cert.WriteFiles(filepath.Join(folder, "client.pem"), filepath.Join(folder, "client.key"))
+	if _, err := LoadCertificateAuthority(clientPair.Cert, clientPair.Key); err == nil {
+		t.Fatalf("TestLoadCertificateAuthority - pki.LoadCertificateAuthority - Expected: %v but Given: %v", "error", err)
+	}
+	if _, err := ca.IssueServer(CertificateRequest{KeyType: "dsa"}); err == nil {
+		t.Fatalf("TestLoadCertificateAuthority - CertificateAuthority.IssueServer - Expected: %v but Given: %v", "error", err)
+	}
+	if _, err := ca.IssueServer(CertificateRequest{URIs: []string{"node-1"}}); err == nil {
+		t.Fatalf("TestLoadCertificateAuthority - CertificateAuthority.IssueServer - Expected: %v but Given: %v", "error", err)
+	}
+}
+
+func TestNodeTLSConfig(t *testing.T) {
+	ca, _ := NewCertificateAuthority(CertificateRequest{Subject: pkix.Name{CommonName: "Cluster CA"}})
+	config, err := NodeTLSConfig(ca, filepath.Join(t.TempDir(), "certs"), CertificateRequest{Subject: pkix.Name{CommonName: "node-1"}, Hosts: []string{"127.0.0.1"}})
+	if err != nil {
+		t.Fatalf("TestNodeTLSConfig - pki.NodeTLSConfig - Expected: %v but Given: %v", nil, err)
+	}
+	if config.ClientAuth != ncom.CLIENT_AUTH_REQUIRE || len(config.Certificates) != 1 || filepath.Base(config.Certificates[0].Cert) != "node-1.pem" {
+		t.Fatalf("TestNodeTLSConfig - pki.NodeTLSConfig - Expected: %v but Given: %+v", "node-1 mTLS configuration", config)
+	}
+	manager := common.NewCertificateManager(config.Certificates, config.CaCertificate, 0, nil)
+	if err := manager.Start(); err != nil {
+		t.Fatalf("TestNodeTLSConfig - CertificateManager.Start - Expected: %v but Given: %v", nil, err)
+	}
+	cert, _ := manager.GetCertificate(&tls.ClientHelloInfo{})
+	if _, err := cert.Leaf.Verify(x509.VerifyOptions{Roots: manager.CertPool(), KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}}); err != nil {
+		t.Fatalf("TestNodeTLSConfig - x509.Certificate.Verify - Expected: %v but Given: %v", nil, err)
+	}
+}
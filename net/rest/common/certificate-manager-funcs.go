@@ -0,0 +1,220 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+func (cm *certificateManager) Start() error {
+	if err := cm.Reload(); err != nil {
+		return err
+	}
+	cm.Lock()
+	defer cm.Unlock()
+	if cm.stop != nil || cm.interval <= 0 {
+		return nil
+	}
+	cm.stop = make(chan struct{})
+	go cm.watch(cm.stop)
+	return nil
+}
+
+func (cm *certificateManager) Stop() {
+	cm.Lock()
+	if cm.stop != nil {
+		close(cm.stop)
+		cm.stop = nil
+	}
+	cm.Unlock()
+}
+
+func (cm *certificateManager) Reload() error {
+	fingerprint := cm.filesFingerprint()
+	state, err := cm.load()
+	cm.Lock()
+	cm.fingerprint = fingerprint
+	if err == nil {
+		cm.state = state
+	}
+	cm.Unlock()
+	if err != nil {
+		if cm.logger != nil {
+			cm.logger.Errorf("CertificateManager.Reload - Certificates not reloaded, keeping the current ones, Details: %s", err)
+		}
+		return err
+	}
+	if cm.logger != nil {
+		cm.logger.Infof("CertificateManager.Reload - Certificates reloaded: %v certificate(s), ca certificate: <%s>", len(state.certificates), cm.caCertificate)
+	}
+	return nil
+}
+
+// Loads and validates the certificate / key pairs and the CA certificates
+func (cm *certificateManager) load() (*certificateState, error) {
+	var state = &certificateState{
+		certificates: make([]tls.Certificate, 0),
+	}
+	now := time.Now()
+	for _, pair := range cm.pairs {
+		if "" == pair.Cert || "" == pair.Key {
+			continue
+		}
+		cert, err := tls.LoadX509KeyPair(pair.Cert, pair.Key)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid certificate: %s and key: %s, Details: %s", pair.Cert, pair.Key, err))
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid certificate: %s, Details: %s", pair.Cert, err))
+		}
+		if now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+			return nil, errors.New(fmt.Sprintf("Certificate: %s not valid now, validity: %v - %v", pair.Cert, leaf.NotBefore, leaf.NotAfter))
+		}
+		cert.Leaf = leaf
+		state.certificates = append(state.certificates, cert)
+	}
+	if "" != cm.caCertificate {
+		caCert, err := ioutil.ReadFile(cm.caCertificate)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Unable to read ca certificate: %s, Details: %s", cm.caCertificate, err))
+		}
+		state.pool = x509.NewCertPool()
+		if !state.pool.AppendCertsFromPEM(caCert) {
+			return nil, errors.New(fmt.Sprintf("No certificates found in ca certificate: %s", cm.caCertificate))
+		}
+	}
+	return state, nil
+}
+
+// Modification time and size of the watched files
+func (cm *certificateManager) filesFingerprint() string {
+	var out = make([]string, 0)
+	var files = make([]string, 0)
+	for _, pair := range cm.pairs {
+		files = append(files, pair.Cert, pair.Key)
+	}
+	for _, file := range append(files, cm.caCertificate) {
+		if "" == file {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil {
+			out = append(out, fmt.Sprintf("%s:%v:%v", file, info.ModTime().UnixNano(), info.Size()))
+		} else {
+			out = append(out, file+":missing")
+		}
+	}
+	return strings.Join(out, "|")
+}
+
+// Reloads the files when their modification time or size change, until stop is closed
+func (cm *certificateManager) watch(stop chan struct{}) {
+	ticker := time.NewTicker(cm.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			cm.RLock()
+			fingerprint := cm.fingerprint
+			cm.RUnlock()
+			if fingerprint != cm.filesFingerprint() {
+				cm.Reload()
+			}
+		}
+	}
+}
+
+func (cm *certificateManager) current() *certificateState {
+	cm.RLock()
+	defer cm.RUnlock()
+	return cm.state
+}
+
+func (cm *certificateManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certificates := cm.current().certificates
+	if len(certificates) == 0 {
+		return nil, errors.New("CertificateManager.GetCertificate - No certificates available")
+	}
+	for idx := range certificates {
+		if hello != nil && hello.SupportsCertificate(&certificates[idx]) == nil {
+			return &certificates[idx], nil
+		}
+	}
+	return &certificates[0], nil
+}
+
+func (cm *certificateManager) GetClientCertificate(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	certificates := cm.current().certificates
+	if len(certificates) == 0 {
+		return &tls.Certificate{}, nil
+	}
+	for idx := range certificates {
+		if info != nil && info.SupportsCertificate(&certificates[idx]) == nil {
+			return &certificates[idx], nil
+		}
+	}
+	return &certificates[0], nil
+}
+
+// Returns the configuration of a TLS handshake, on top of the base configuration given to ServerConfig
+func (cm *certificateManager) GetConfigForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	cm.RLock()
+	base, state := cm.base, cm.state
+	cm.RUnlock()
+	var config *tls.Config
+	if base != nil {
+		config = base.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+	config.Certificates = nil
+	config.GetCertificate = cm.GetCertificate
+	config.GetConfigForClient = nil
+	if state.pool != nil {
+		config.ClientCAs = state.pool
+	}
+	return config, nil
+}
+
+func (cm *certificateManager) CertPool() *x509.CertPool {
+	return cm.current().pool
+}
+
+func (cm *certificateManager) ServerConfig(base *tls.Config) *tls.Config {
+	var config *tls.Config
+	if base != nil {
+		config = base.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+	config.Certificates = nil
+	config.GetCertificate = cm.GetCertificate
+	config.GetConfigForClient = nil
+	cm.Lock()
+	cm.base = config.Clone()
+	cm.Unlock()
+	config.GetConfigForClient = cm.GetConfigForClient
+	return config
+}
+
+func (cm *certificateManager) ClientConfig(base *tls.Config) *tls.Config {
+	var config *tls.Config
+	if base != nil {
+		config = base.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+	config.Certificates = nil
+	config.GetClientCertificate = cm.GetClientCertificate
+	if pool := cm.CertPool(); pool != nil {
+		config.RootCAs = pool
+	}
+	return config
+}
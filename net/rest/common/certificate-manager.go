@@ -0,0 +1,69 @@
+package common
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/hellgate75/go-tcp-common/log"
+	"sync"
+	"time"
+)
+
+var (
+	// Default interval between two checks of the certificate, key and CA files, zero or negative disables the watch
+	DEFAULT_CERTIFICATE_WATCH_INTERVAL time.Duration = 10 * time.Second
+)
+
+// Certificates Manager, it loads the certificate / key pairs and the CA certificate files and watches them
+// for changes. New files are validated before replacing the current ones, so the handshakes keep using the
+// last valid certificates until a valid rotation is complete. Reload results are reported to the logger
+type CertificateManager interface {
+	// Loads the files and starts watching them
+	Start() error
+	// Stops watching the files
+	Stop()
+	// Reloads the files, the current certificates are kept when the new ones are not valid
+	Reload() error
+	// Returns the certificate matching the client hello, to be used as tls.Config GetCertificate
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+	// Returns the certificate matching the server request, to be used as tls.Config GetClientCertificate
+	GetClientCertificate(info *tls.CertificateRequestInfo) (*tls.Certificate, error)
+	// Returns the configuration of a TLS handshake, to be used as tls.Config GetConfigForClient
+	GetConfigForClient(hello *tls.ClientHelloInfo) (*tls.Config, error)
+	// Returns the CA certificates pool, nil when no CA certificate file is given
+	CertPool() *x509.CertPool
+	// Returns a copy of the base configuration serving the current certificates and verifying the
+	// client certificates with the current CA certificates
+	ServerConfig(base *tls.Config) *tls.Config
+	// Returns a copy of the base configuration presenting the current client certificates
+	ClientConfig(base *tls.Config) *tls.Config
+}
+
+// Certificates and CA pool loaded together and replaced at once
+type certificateState struct {
+	certificates []tls.Certificate
+	pool         *x509.CertPool
+}
+
+type certificateManager struct {
+	sync.RWMutex
+	pairs         []CertificateKeyPair
+	caCertificate string
+	interval      time.Duration
+	logger        log.Logger
+	state         *certificateState
+	base          *tls.Config
+	fingerprint   string
+	stop          chan struct{}
+}
+
+// Creates a Certificates Manager of the certificate / key pairs and the CA certificate files (optional), the
+// files are checked for changes every interval
+func NewCertificateManager(pairs []CertificateKeyPair, caCertificate string, interval time.Duration, logger log.Logger) CertificateManager {
+	return &certificateManager{
+		pairs:         append([]CertificateKeyPair(nil), pairs...),
+		caCertificate: caCertificate,
+		interval:      interval,
+		logger:        logger,
+		state:         &certificateState{},
+	}
+}
@@ -0,0 +1,128 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/hellgate75/go-tcp-common/log"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testSerial int64 = 0
+
+// Writes a self-signed certificate and its key, valid from notBefore for a hour, and returns its serial number
+func writeTestCertificate(t *testing.T, pair CertificateKeyPair, notBefore time.Time) int64 {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSerial++
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(testSerial),
+		Subject:               pkix.Name{CommonName: "node"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	if err := ioutil.WriteFile(pair.Cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(pair.Key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return testSerial
+}
+
+func servedSerial(t *testing.T, manager CertificateManager) int64 {
+	cert, err := manager.GetCertificate(&tls.ClientHelloInfo{})
+	if err != nil {
+		t.Fatalf("servedSerial - CertificateManager.GetCertificate - Expected: %v but Given: %v", nil, err)
+	}
+	return cert.Leaf.SerialNumber.Int64()
+}
+
+func TestCertificateManagerReload(t *testing.T) {
+	folder := t.TempDir()
+	pair := CertificateKeyPair{Cert: filepath.Join(folder, "server.pem"), Key: filepath.Join(folder, "server.key")}
+	ca := CertificateKeyPair{Cert: filepath.Join(folder, "ca.pem"), Key: filepath.Join(folder, "ca.key")}
+	serial := writeTestCertificate(t, pair, time.Now().Add(-time.Minute))
+	writeTestCertificate(t, ca, time.Now().Add(-time.Minute))
+	manager := NewCertificateManager([]CertificateKeyPair{pair}, ca.Cert, 0, log.NewLogger("certificates-test", log.FATAL))
+	if err := manager.Start(); err != nil {
+		t.Fatalf("TestCertificateManagerReload - CertificateManager.Start - Expected: %v but Given: %v", nil, err)
+	}
+	defer manager.Stop()
+	if given := servedSerial(t, manager); given != serial {
+		t.Fatalf("TestCertificateManagerReload - CertificateManager.GetCertificate - Expected: %v but Given: %v", serial, given)
+	}
+	pool := manager.CertPool()
+
+	// Key of a different certificate: the rotation is refused
+	other := CertificateKeyPair{Cert: filepath.Join(folder, "other.pem"), Key: filepath.Join(folder, "other.key")}
+	writeTestCertificate(t, other, time.Now().Add(-time.Minute))
+	data, _ := ioutil.ReadFile(other.Key)
+	ioutil.WriteFile(pair.Key, data, 0600)
+	if err := manager.Reload(); err == nil {
+		t.Fatalf("TestCertificateManagerReload - CertificateManager.Reload - Expected: %v but Given: %v", "error", err)
+	}
+	if given := servedSerial(t, manager); given != serial {
+		t.Fatalf("TestCertificateManagerReload - CertificateManager.GetCertificate - Expected: %v but Given: %v", serial, given)
+	}
+
+	// Certificate not valid yet: the rotation is refused
+	writeTestCertificate(t, pair, time.Now().Add(time.Hour))
+	if err := manager.Reload(); err == nil {
+		t.Fatalf("TestCertificateManagerReload - CertificateManager.Reload - Expected: %v but Given: %v", "error", err)
+	}
+
+	serial = writeTestCertificate(t, pair, time.Now().Add(-time.Minute))
+	writeTestCertificate(t, ca, time.Now().Add(-time.Minute))
+	if err := manager.Reload(); err != nil {
+		t.Fatalf("TestCertificateManagerReload - CertificateManager.Reload - Expected: %v but Given: %v", nil, err)
+	}
+	if given := servedSerial(t, manager); given != serial {
+		t.Fatalf("TestCertificateManagerReload - CertificateManager.GetCertificate - Expected: %v but Given: %v", serial, given)
+	}
+	config := manager.ServerConfig(&tls.Config{ClientAuth: tls.RequireAndVerifyClientCert})
+	handshakeConfig, err := config.GetConfigForClient(&tls.ClientHelloInfo{})
+	if err != nil || handshakeConfig.ClientAuth != tls.RequireAndVerifyClientCert || handshakeConfig.ClientCAs == nil ||
+		handshakeConfig.ClientCAs.Equal(pool) || !handshakeConfig.ClientCAs.Equal(manager.CertPool()) {
+		t.Fatalf("TestCertificateManagerReload - CertificateManager.GetConfigForClient - Expected: %v but Given: %+v (%v)", "reloaded ca certificates", handshakeConfig, err)
+	}
+}
+
+func TestCertificateManagerWatch(t *testing.T) {
+	folder := t.TempDir()
+	pair := CertificateKeyPair{Cert: filepath.Join(folder, "client.pem"), Key: filepath.Join(folder, "client.key")}
+	writeTestCertificate(t, pair, time.Now().Add(-time.Minute))
+	manager := NewCertificateManager([]CertificateKeyPair{pair}, "", 10*time.Millisecond, nil)
+	if err := manager.Start(); err != nil {
+		t.Fatalf("TestCertificateManagerWatch - CertificateManager.Start - Expected: %v but Given: %v", nil, err)
+	}
+	defer manager.Stop()
+	serial := writeTestCertificate(t, pair, time.Now().Add(-2*time.Minute))
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		cert, _ := manager.ClientConfig(nil).GetClientCertificate(&tls.CertificateRequestInfo{})
+		if cert.Leaf != nil && cert.Leaf.SerialNumber.Int64() == serial {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("TestCertificateManagerWatch - CertificateManager.GetClientCertificate - Expected: %v but Given: %v", serial, "previous certificate")
+}
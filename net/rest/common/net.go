@@ -1,8 +1,11 @@
 package common
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/hellgate75/go-tcp-common/net/common"
+	"io"
 	"net/http"
 	"net/url"
 )
@@ -19,19 +22,50 @@ type RestClient interface {
 	Open() error
 	// Close connection and sign-off the server
 	Close() error
-	//Send a requerst to the connected server
+	//Send a requerst to the connected server, the accepts Mime Type is used as body Content-Type. Non 2xx
+	// status codes are returned with the response body and an error
 	Request(protocol common.RestProtocol, path string, method common.RestMethod, accepts *common.MimeType, body *[]byte, values *url.Values) (int, []byte, error)
+	// Sends a request as Request does, bounded by the context deadline and cancellation
+	RequestContext(ctx context.Context, protocol common.RestProtocol, path string, method common.RestMethod, accepts *common.MimeType, body *[]byte, values *url.Values) (int, []byte, error)
+	// Sends a request as RequestContext does, with a body stream of size bytes (zero or negative for chunked transfer)
+	// and returning the response body as stream, to be closed by the caller when not nil
+	RequestStream(ctx context.Context, protocol common.RestProtocol, path string, method common.RestMethod, accepts *common.MimeType, body io.Reader, size int64, values *url.Values) (int, io.ReadCloser, error)
+	// Creates a request builder for the path of the connected server, allowing any web method, headers and
+	// query values, and returning status code, headers and body of the response
+	NewRequest(path string) Request
 	// Returns information about server connectivity state
 	IsConnected() bool
+	// Returns the authenticated TLS connection opened with the server, nil if not connected
+	Connection() *tls.Conn
+	// Sets the TLS policy (versions, cipher suites, curves, ALPN) used when the connection is opened
+	SetTLSPolicy(policy TLSPolicy) error
+	// Sets the retry, backoff and circuit breaker policy of the requests, used when the connection is opened
+	SetResiliencePolicy(policy ResiliencePolicy) error
 }
 
-// Generic Rest Callback function for handling pattern request
+// Generic Rest Callback function for handling pattern request, it receives the Mime Type of the request
+// body and the Mime Type negotiated with the client Accept header
 type RestCallback func(w http.ResponseWriter, req *http.Request, path string, accepts common.MimeType, produces common.MimeType) ()
 
 // Generic Rest Server interface
 type RestServer interface {
 	AddPath(path string, callback RestCallback, accepts *common.MimeType, produces *common.MimeType, allowedMethods []common.RestMethod) bool
 	AddRootPath(callback RestCallback, accepts *common.MimeType, produces *common.MimeType, allowedMethods []common.RestMethod) bool
+	// Appends middleware executed around every request, the first one is the outermost
+	Use(middleware ...common.Middleware)
+	// Appends middleware executed around the callback of a registered path template
+	UsePath(path string, middleware ...common.Middleware) bool
+	// Sets the http server timeouts, zero values keep the defaults
+	SetTimeouts(timeouts common.ServerTimeouts)
+	// Sets the http server header and body size limits
+	SetLimits(limits common.ServerLimits)
+	// Sets the base TLS configuration (versions, cipher suites, curves), the certificates are loaded on TLS start
+	ConfigureTLS(config *tls.Config)
+	// Sets the base TLS configuration from a TLS policy, the certificates are loaded on TLS start
+	SetTLSPolicy(policy TLSPolicy) error
+	// Sets the client certificate authentication mode, the client certificates are verified against the
+	// CA certificate given to StartTLS
+	SetClientAuth(mode common.ClientAuthMode) error
 	StartTLS(hostOrIpAddress string, port int32, certs []CertificateKeyPair, CaCertificate string, insecure bool) error
 	Start(hostOrIpAddress string, port int32) error
 	Stop() error
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/common"
+	"net/http"
+	"strconv"
+)
+
+// Path parameters extracted from the request path by the server path templates
+type PathParams map[string]string
+
+// Returns the path parameters of a request, empty when the matched path has no parameters
+func PathParamsFrom(req *http.Request) PathParams {
+	if params, ok := req.Context().Value(common.ContextPathParams).(PathParams); ok {
+		return params
+	}
+	return PathParams{}
+}
+
+// Returns a shallow copy of the request carrying the given path parameters
+func WithPathParams(req *http.Request, params PathParams) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), common.ContextPathParams, params))
+}
+
+// Returns a parameter value, reporting false if the parameter is not available
+func (pp PathParams) Get(name string) (string, bool) {
+	value, ok := pp[name]
+	return value, ok
+}
+
+// Returns a parameter as integer
+func (pp PathParams) Int(name string) (int64, error) {
+	value, err := pp.required(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Returns a parameter as float
+func (pp PathParams) Float(name string) (float64, error) {
+	value, err := pp.required(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// Returns a parameter as boolean
+func (pp PathParams) Bool(name string) (bool, error) {
+	value, err := pp.required(name)
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(value)
+}
+
+func (pp PathParams) required(name string) (string, error) {
+	value, ok := pp[name]
+	if !ok {
+		return "", errors.New(fmt.Sprintf("Path parameter not available: %s", name))
+	}
+	return value, nil
+}
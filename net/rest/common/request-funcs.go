@@ -0,0 +1,230 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/common"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+type request struct {
+	ctx      context.Context
+	doer     Doer
+	host     string
+	path     string
+	method   common.RestMethod
+	protocol common.RestProtocol
+	header   http.Header
+	query    url.Values
+	body     []byte
+	reader   io.Reader
+	size     int64
+	form     url.Values
+	upload   ProgressListener
+	download ProgressListener
+	newHash  func() hash.Hash
+	checksum string
+}
+
+// Creates a request builder for the path of the host (address:port), executed by the doer
+func NewRequest(doer Doer, host string, path string) Request {
+	return &request{
+		ctx:      context.Background(),
+		doer:     doer,
+		host:     host,
+		path:     path,
+		method:   common.REST_METHOD_GET,
+		protocol: common.REST_PROTOCOL_HTTPS,
+		header:   make(http.Header),
+		query:    make(url.Values),
+	}
+}
+
+func (r *request) Method(method common.RestMethod) Request {
+	r.method = method
+	return r
+}
+
+func (r *request) Protocol(protocol common.RestProtocol) Request {
+	r.protocol = protocol
+	return r
+}
+
+func (r *request) Header(name string, value string) Request {
+	r.header.Add(name, value)
+	return r
+}
+
+func (r *request) Accept(mimeType common.MimeType) Request {
+	r.header.Set("Accept", string(mimeType))
+	return r
+}
+
+func (r *request) ContentType(mimeType common.MimeType) Request {
+	r.header.Set("Content-Type", string(mimeType))
+	return r
+}
+
+func (r *request) Body(body []byte) Request {
+	r.body = body
+	r.reader = nil
+	return r
+}
+
+func (r *request) BodyReader(body io.Reader, size int64) Request {
+	r.reader = body
+	r.size = size
+	r.body = nil
+	return r
+}
+
+func (r *request) Query(name string, value string) Request {
+	r.query.Add(name, value)
+	return r
+}
+
+func (r *request) Form(values url.Values) Request {
+	r.form = values
+	return r
+}
+
+func (r *request) Context(ctx context.Context) Request {
+	if ctx != nil {
+		r.ctx = ctx
+	}
+	return r
+}
+
+func (r *request) UploadProgress(listener ProgressListener) Request {
+	r.upload = listener
+	return r
+}
+
+func (r *request) DownloadProgress(listener ProgressListener) Request {
+	r.download = listener
+	return r
+}
+
+func (r *request) Checksum(newHash func() hash.Hash, expected string) Request {
+	r.newHash = newHash
+	r.checksum = expected
+	return r
+}
+
+func (r *request) Do() (*Response, error) {
+	resp, err := r.send("Request.Do")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Request.Do - Error reading body, Status: %v, Details: %s", resp.StatusCode, err))
+	}
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       data,
+	}, nil
+}
+
+func (r *request) Stream() (*StreamResponse, error) {
+	resp, err := r.send("Request.Stream")
+	if err != nil {
+		return nil, err
+	}
+	return &StreamResponse{
+		StatusCode:    resp.StatusCode,
+		Status:        resp.Status,
+		Header:        resp.Header,
+		ContentLength: resp.ContentLength,
+		Body:          resp.Body,
+	}, nil
+}
+
+// Sends the request, the response body reads through the download progress and checksum readers
+func (r *request) send(caller string) (*http.Response, error) {
+	if r.doer == nil {
+		return nil, errors.New(fmt.Sprintf("%s - Client not connected", caller))
+	}
+	var method = string(r.method)
+	if common.REST_METHOD_POST_FORM == r.method {
+		method = http.MethodPost
+	}
+	requestUrl, err := url.Parse(r.path)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Request.Do - Invalid path: %s, Details: %s", r.path, err))
+	}
+	requestUrl.Scheme = string(r.protocol)
+	requestUrl.Host = r.host
+	query := requestUrl.Query()
+	for name, values := range r.query {
+		query[name] = append(query[name], values...)
+	}
+	requestUrl.RawQuery = query.Encode()
+	var data []byte = nil
+	if r.form != nil {
+		data = []byte(r.form.Encode())
+		if "" == r.header.Get("Content-Type") {
+			r.header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else if r.body != nil {
+		data = r.body
+	}
+	var body io.Reader = nil
+	var size int64 = 0
+	if data != nil {
+		body = bytes.NewReader(data)
+		size = int64(len(data))
+	} else if r.reader != nil {
+		body = r.reader
+		size = r.size
+		if size <= 0 {
+			size = -1
+		}
+	}
+	if body != nil && r.upload != nil {
+		body = &progressReader{reader: body, total: size, listener: r.upload}
+	}
+	req, err := http.NewRequestWithContext(r.ctx, method, requestUrl.String(), body)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s - Invalid request, Details: %s", caller, err))
+	}
+	if body != nil {
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+		req.ContentLength = size
+		if data != nil && r.upload != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(&progressReader{reader: bytes.NewReader(data), total: size, listener: r.upload}), nil
+			}
+		}
+	}
+	for name, values := range r.header {
+		req.Header[name] = values
+	}
+	common.SetTraceIdHeader(req)
+	resp, err := r.doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	var reader io.Reader = resp.Body
+	if r.newHash != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		reader = &checksumReader{reader: reader, hash: r.newHash(), expected: r.checksum}
+	}
+	if r.download != nil {
+		reader = &progressReader{reader: reader, total: resp.ContentLength, listener: r.download}
+	}
+	if reader != resp.Body {
+		resp.Body = &streamBody{Reader: reader, closer: resp.Body}
+	}
+	return resp, nil
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"context"
+	"github.com/hellgate75/go-tcp-common/net/common"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Executes the http requests of a Request builder, as *http.Client does
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Http request builder, shared by the Rest and Api Clients. Setters return the same builder so calls can
+// be chained, e.g.: client.NewRequest("/users").Method(common.REST_METHOD_PUT).Header("X-Id", "1").Body(data).Do()
+type Request interface {
+	// Sets the web method, default: GET. POST_FORM sends a POST with the form values as body
+	Method(method common.RestMethod) Request
+	// Sets the protocol, default: https
+	Protocol(protocol common.RestProtocol) Request
+	// Adds a request header value
+	Header(name string, value string) Request
+	// Sets the Accept header
+	Accept(mimeType common.MimeType) Request
+	// Sets the Content-Type header of the body
+	ContentType(mimeType common.MimeType) Request
+	// Sets the request body
+	Body(body []byte) Request
+	// Sets the request body as stream of size bytes, a zero or negative size is an unknown length and sends
+	// the body with chunked transfer encoding. Stream bodies are read once, so the requests are not retried
+	BodyReader(body io.Reader, size int64) Request
+	// Adds a query string value
+	Query(name string, value string) Request
+	// Sets the form values, sent url encoded as request body
+	Form(values url.Values) Request
+	// Sets the request context, bounding the request with its deadline and cancellation. The context
+	// trace id is sent in the trace id header
+	Context(ctx context.Context) Request
+	// Sets the listener of the request body upload progress
+	UploadProgress(listener ProgressListener) Request
+	// Sets the listener of the response body download progress
+	DownloadProgress(listener ProgressListener) Request
+	// Sets the hex encoded checksum of the 2xx response bodies, computed with the newHash hash function
+	// (e.g. sha256.New). A different body checksum fails the body read at its end with ErrChecksumMismatch
+	Checksum(newHash func() hash.Hash, expected string) Request
+	// Sends the request and returns the response, whatever status code it has
+	Do() (*Response, error)
+	// Sends the request and returns the response with the body as stream, whatever status code it has.
+	// The response body must be closed by the caller
+	Stream() (*StreamResponse, error)
+}
+
+// Http response, with the fully read body
+type Response struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+// Returns true for the 2xx status codes
+func (r *Response) IsSuccess() bool {
+	return r.StatusCode >= 200 && r.StatusCode < 300
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/common"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		w.Header().Set("X-Method", req.Method)
+		switch req.URL.Path {
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprintf(w, "%s|%s|%s|%s|%s", req.Header.Get("Accept"), req.Header.Get("Content-Type"),
+			req.Header.Get("X-Id"), req.URL.RawQuery, body)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	newRequest := func(path string) Request {
+		return NewRequest(server.Client(), host, path).Protocol(common.REST_PROTOCOL_HTTP)
+	}
+	var cases = []struct {
+		request Request
+		status  int
+		method  string
+		body    string
+	}{
+		{newRequest("/"), 200, "GET", "||||"},
+		{newRequest("/created").Method(common.REST_METHOD_PUT).Accept(common.JSON_MIME_TYPE).ContentType(common.JSON_MIME_TYPE).
+			Header("X-Id", "7").Body([]byte(`{"id":7}`)), 201, "PUT", `application/json|application/json|7||{"id":7}`},
+		{newRequest("/missing?a=1").Method(common.REST_METHOD_PATCH).Query("b", "2"), 404, "PATCH", "|||a=1&b=2|"},
+		{newRequest("/empty").Method(common.REST_METHOD_DELETE), 204, "DELETE", ""},
+		{newRequest("/").Method(common.REST_METHOD_POST_FORM).Form(url.Values{"name": {"node"}}), 200, "POST",
+			"|application/x-www-form-urlencoded|||name=node"},
+		{newRequest("/").Method(common.REST_METHOD_OPTIONS), 200, "OPTIONS", "||||"},
+		{newRequest("/").Method(common.REST_METHOD_HEAD), 200, "HEAD", ""},
+	}
+	for idx, c := range cases {
+		response, err := c.request.Do()
+		if err != nil {
+			t.Fatalf("TestRequest - Request.Do - case %v - Expected: %v but Given: %v", idx, nil, err)
+		}
+		if response.StatusCode != c.status || response.Header.Get("X-Method") != c.method || string(response.Body) != c.body {
+			t.Fatalf("TestRequest - Request.Do - case %v - Expected: %v %v %q but Given: %v %v %q", idx, c.status, c.method,
+				c.body, response.StatusCode, response.Header.Get("X-Method"), response.Body)
+		}
+		if response.IsSuccess() != (c.status < 300) {
+			t.Fatalf("TestRequest - Response.IsSuccess - case %v - Expected: %v but Given: %v", idx, c.status < 300, response.IsSuccess())
+		}
+	}
+	if _, err := NewRequest(nil, host, "/").Do(); err == nil {
+		t.Fatalf("TestRequest - Request.Do - Expected: %v but Given: %v", "error", err)
+	}
+}
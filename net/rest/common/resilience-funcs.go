@@ -0,0 +1,261 @@
+package common
+
+import (
+	"github.com/hellgate75/go-tcp-common/net/common"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type circuit struct {
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+type resilientTransport struct {
+	sync.Mutex
+	next     http.RoundTripper
+	retry    *RetryPolicy
+	breaker  *CircuitBreakerPolicy
+	listener ResilienceListener
+	circuits map[string]*circuit
+}
+
+// Wraps the round tripper with the retries and the per host circuit breaker of the policy, the next
+// round tripper is returned as is when the policy is empty
+func NewResilientTransport(next http.RoundTripper, policy ResiliencePolicy) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if policy.Retry == nil && policy.CircuitBreaker == nil && policy.Listener == nil {
+		return next
+	}
+	var transport = &resilientTransport{
+		next:     next,
+		listener: policy.Listener,
+		circuits: make(map[string]*circuit),
+	}
+	if policy.Retry != nil {
+		retry := *policy.Retry
+		if retry.MaxAttempts == 0 {
+			retry.MaxAttempts = DEFAULT_RETRY_ATTEMPTS
+		}
+		if retry.InitialBackoff == 0 {
+			retry.InitialBackoff = DEFAULT_RETRY_INITIAL_BACKOFF
+		}
+		if retry.MaxBackoff == 0 {
+			retry.MaxBackoff = DEFAULT_RETRY_MAX_BACKOFF
+		}
+		if retry.Multiplier == 0 {
+			retry.Multiplier = DEFAULT_RETRY_MULTIPLIER
+		}
+		if retry.Jitter == 0 {
+			retry.Jitter = DEFAULT_RETRY_JITTER
+		}
+		if retry.Methods == nil {
+			retry.Methods = DEFAULT_RETRY_METHODS
+		}
+		if retry.StatusCodes == nil {
+			retry.StatusCodes = DEFAULT_RETRY_STATUS_CODES
+		}
+		transport.retry = &retry
+	}
+	if policy.CircuitBreaker != nil {
+		breaker := *policy.CircuitBreaker
+		if breaker.FailureThreshold == 0 {
+			breaker.FailureThreshold = DEFAULT_CIRCUIT_FAILURE_THRESHOLD
+		}
+		if breaker.OpenTimeout == 0 {
+			breaker.OpenTimeout = DEFAULT_CIRCUIT_OPEN_TIMEOUT
+		}
+		if breaker.HalfOpenRequests == 0 {
+			breaker.HalfOpenRequests = DEFAULT_CIRCUIT_HALF_OPEN_REQUESTS
+		}
+		transport.breaker = &breaker
+	}
+	return transport
+}
+
+func (rt *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	attempts := 1
+	if rt.retry != nil && rt.retryMethod(req.Method) && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil) {
+		attempts = rt.retry.MaxAttempts
+	}
+	for attempt := 1; ; attempt++ {
+		if !rt.allow(host) {
+			rt.emit(ResilienceEvent{Type: RESILIENCE_EVENT_REJECTED, Host: host, Method: req.Method, Attempt: attempt, Err: ErrCircuitOpen})
+			return nil, ErrCircuitOpen
+		}
+		outgoing := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				rt.release(host)
+				return nil, err
+			}
+			outgoing = req.Clone(req.Context())
+			outgoing.Body = body
+		}
+		start := time.Now()
+		resp, err := rt.next.RoundTrip(outgoing)
+		var status int = 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		if req.Context().Err() != nil {
+			// Cancelled or expired by the caller, the outcome does not tell the host health
+			rt.release(host)
+		} else {
+			rt.record(host, err != nil || status >= 500)
+		}
+		rt.emit(ResilienceEvent{Type: RESILIENCE_EVENT_ATTEMPT, Host: host, Method: req.Method, Attempt: attempt,
+			StatusCode: status, Err: err, Duration: time.Since(start)})
+		if attempt >= attempts || req.Context().Err() != nil || !rt.retryResult(status, err) {
+			return resp, err
+		}
+		backoff := rt.backoff(attempt)
+		rt.emit(ResilienceEvent{Type: RESILIENCE_EVENT_RETRY, Host: host, Method: req.Method, Attempt: attempt,
+			StatusCode: status, Err: err, Backoff: backoff})
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (rt *resilientTransport) CloseIdleConnections() {
+	if closer, ok := rt.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+func (rt *resilientTransport) retryMethod(method string) bool {
+	for _, retryMethod := range rt.retry.Methods {
+		if string(retryMethod) == method || (common.REST_METHOD_POST_FORM == retryMethod && http.MethodPost == method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rt *resilientTransport) retryResult(status int, err error) bool {
+	if err != nil {
+		return err != ErrCircuitOpen
+	}
+	for _, code := range rt.retry.StatusCodes {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Exponential backoff of the attempt, varied by the jitter fraction
+func (rt *resilientTransport) backoff(attempt int) time.Duration {
+	delay := float64(rt.retry.InitialBackoff) * math.Pow(rt.retry.Multiplier, float64(attempt-1))
+	if delay > float64(rt.retry.MaxBackoff) {
+		delay = float64(rt.retry.MaxBackoff)
+	}
+	if rt.retry.Jitter > 0 {
+		delay = delay * (1 + rt.retry.Jitter*(2*rand.Float64()-1))
+	}
+	return time.Duration(delay)
+}
+
+// Checks the host circuit allows the request, moving an expired open circuit to half-open
+func (rt *resilientTransport) allow(host string) bool {
+	if rt.breaker == nil {
+		return true
+	}
+	rt.Lock()
+	c, ok := rt.circuits[host]
+	if !ok {
+		c = &circuit{state: CIRCUIT_CLOSED}
+		rt.circuits[host] = c
+	}
+	var changed bool = false
+	if CIRCUIT_OPEN == c.state && time.Since(c.openedAt) >= rt.breaker.OpenTimeout {
+		c.state = CIRCUIT_HALF_OPEN
+		c.probes = 0
+		changed = true
+	}
+	var allowed bool = true
+	switch c.state {
+	case CIRCUIT_OPEN:
+		allowed = false
+	case CIRCUIT_HALF_OPEN:
+		allowed = c.probes < rt.breaker.HalfOpenRequests
+		if allowed {
+			c.probes++
+		}
+	}
+	rt.Unlock()
+	if changed {
+		rt.emit(ResilienceEvent{Type: RESILIENCE_EVENT_CIRCUIT, Host: host, Circuit: CIRCUIT_HALF_OPEN})
+	}
+	return allowed
+}
+
+// Releases the half-open probe taken by a request never sent, so that another request can probe the host
+func (rt *resilientTransport) release(host string) {
+	if rt.breaker == nil {
+		return
+	}
+	rt.Lock()
+	if c := rt.circuits[host]; CIRCUIT_HALF_OPEN == c.state && c.probes > 0 {
+		c.probes--
+	}
+	rt.Unlock()
+}
+
+// Records the outcome of a request in the host circuit
+func (rt *resilientTransport) record(host string, failed bool) {
+	if rt.breaker == nil {
+		return
+	}
+	rt.Lock()
+	c := rt.circuits[host]
+	var previous = c.state
+	switch c.state {
+	case CIRCUIT_CLOSED:
+		if !failed {
+			c.failures = 0
+		} else if c.failures++; c.failures >= rt.breaker.FailureThreshold {
+			c.state = CIRCUIT_OPEN
+			c.openedAt = time.Now()
+		}
+	case CIRCUIT_HALF_OPEN:
+		if failed {
+			c.state = CIRCUIT_OPEN
+			c.openedAt = time.Now()
+		} else {
+			c.state = CIRCUIT_CLOSED
+			c.failures = 0
+		}
+	}
+	var state = c.state
+	rt.Unlock()
+	if state != previous {
+		rt.emit(ResilienceEvent{Type: RESILIENCE_EVENT_CIRCUIT, Host: host, Circuit: state})
+	}
+}
+
+func (rt *resilientTransport) emit(event ResilienceEvent) {
+	if rt.listener != nil {
+		rt.listener(event)
+	}
+}
@@ -0,0 +1,142 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/common"
+	"net/http"
+	"time"
+)
+
+// Circuit breaker state of a host
+type CircuitState int
+
+const (
+	// Requests are sent, consecutive failures are counted
+	CIRCUIT_CLOSED CircuitState = iota
+	// Requests are refused until the open timeout expires
+	CIRCUIT_OPEN
+	// A limited number of probe requests is sent: a success closes the circuit, a failure opens it again
+	CIRCUIT_HALF_OPEN
+)
+
+func (cs CircuitState) String() string {
+	switch cs {
+	case CIRCUIT_CLOSED:
+		return "closed"
+	case CIRCUIT_OPEN:
+		return "open"
+	case CIRCUIT_HALF_OPEN:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(cs))
+}
+
+// Resilience event type
+type ResilienceEventType int
+
+const (
+	// Request attempt completed, with its status code or error and duration
+	RESILIENCE_EVENT_ATTEMPT ResilienceEventType = iota
+	// Failed attempt retried after the backoff
+	RESILIENCE_EVENT_RETRY
+	// Request refused by an open circuit
+	RESILIENCE_EVENT_REJECTED
+	// Circuit state change of a host
+	RESILIENCE_EVENT_CIRCUIT
+)
+
+var (
+	// Default number of attempts of a request, the first included
+	DEFAULT_RETRY_ATTEMPTS int = 3
+	// Default delay before the first retry
+	DEFAULT_RETRY_INITIAL_BACKOFF time.Duration = 100 * time.Millisecond
+	// Default maximum delay between retries
+	DEFAULT_RETRY_MAX_BACKOFF time.Duration = 5 * time.Second
+	// Default backoff growth factor between retries
+	DEFAULT_RETRY_MULTIPLIER float64 = 2
+	// Default backoff random variation, as fraction of the delay
+	DEFAULT_RETRY_JITTER float64 = 0.2
+	// Default idempotent web methods that are retried
+	DEFAULT_RETRY_METHODS = []common.RestMethod{common.REST_METHOD_GET, common.REST_METHOD_HEAD, common.REST_METHOD_PUT}
+	// Default response status codes that are retried
+	DEFAULT_RETRY_STATUS_CODES = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	// Default consecutive failures opening the circuit of a host
+	DEFAULT_CIRCUIT_FAILURE_THRESHOLD int = 5
+	// Default time a circuit stays open before the half-open probing
+	DEFAULT_CIRCUIT_OPEN_TIMEOUT time.Duration = 30 * time.Second
+	// Default number of probe requests of a half-open circuit
+	DEFAULT_CIRCUIT_HALF_OPEN_REQUESTS int = 1
+	// Error returned for the requests refused by an open circuit
+	ErrCircuitOpen = errors.New("rest: circuit breaker open")
+)
+
+// Retry policy, zero values take the package defaults while a negative Jitter disables the backoff variation.
+// Requests are retried on transport errors and on the retry status codes, only for the retry methods and when
+// the request body can be sent again. The backoff grows from InitialBackoff by Multiplier up to MaxBackoff,
+// varied randomly by the Jitter fraction
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         float64
+	Methods        []common.RestMethod
+	StatusCodes    []int
+}
+
+// Per host circuit breaker policy, zero values take the package defaults. Transport errors and 5xx
+// responses are failures: FailureThreshold consecutive failures open the circuit for OpenTimeout,
+// then HalfOpenRequests probe requests decide whether it closes or opens again
+type CircuitBreakerPolicy struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	HalfOpenRequests int
+}
+
+// Resilience event, reported to the policy listener for metrics collection
+type ResilienceEvent struct {
+	Type       ResilienceEventType
+	Host       string
+	Method     string
+	Attempt    int
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+	Backoff    time.Duration
+	Circuit    CircuitState
+}
+
+// Listener of the resilience events, called synchronously by the requests
+type ResilienceListener func(event ResilienceEvent)
+
+// Resilience policy of the Rest and Api Clients: nil Retry disables the retries and nil CircuitBreaker
+// disables the circuit breaker
+type ResiliencePolicy struct {
+	Retry          *RetryPolicy
+	CircuitBreaker *CircuitBreakerPolicy
+	Listener       ResilienceListener
+}
+
+// Verifies the policy values
+func (rp ResiliencePolicy) Validate() error {
+	if rp.Retry != nil {
+		retry := rp.Retry
+		if retry.MaxAttempts < 0 || retry.InitialBackoff < 0 || retry.MaxBackoff < 0 {
+			return errors.New(fmt.Sprintf("ResiliencePolicy.Validate - Negative retry attempts or backoff: %+v", *retry))
+		}
+		if retry.Multiplier != 0 && retry.Multiplier < 1 {
+			return errors.New(fmt.Sprintf("ResiliencePolicy.Validate - Retry multiplier lower than 1: %v", retry.Multiplier))
+		}
+		if retry.Jitter > 1 {
+			return errors.New(fmt.Sprintf("ResiliencePolicy.Validate - Retry jitter greater than 1: %v", retry.Jitter))
+		}
+	}
+	if rp.CircuitBreaker != nil {
+		breaker := rp.CircuitBreaker
+		if breaker.FailureThreshold < 0 || breaker.OpenTimeout < 0 || breaker.HalfOpenRequests < 0 {
+			return errors.New(fmt.Sprintf("ResiliencePolicy.Validate - Negative circuit breaker values: %+v", *breaker))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/common"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type eventRecorder struct {
+	sync.Mutex
+	events []ResilienceEvent
+}
+
+func (er *eventRecorder) listen(event ResilienceEvent) {
+	er.Lock()
+	er.events = append(er.events, event)
+	er.Unlock()
+}
+
+func (er *eventRecorder) count(eventType ResilienceEventType) int {
+	er.Lock()
+	defer er.Unlock()
+	var count = 0
+	for _, event := range er.events {
+		if event.Type == eventType {
+			count++
+		}
+	}
+	return count
+}
+
+func (er *eventRecorder) circuits() []CircuitState {
+	er.Lock()
+	defer er.Unlock()
+	var out = make([]CircuitState, 0)
+	for _, event := range er.events {
+		if event.Type == RESILIENCE_EVENT_CIRCUIT {
+			out = append(out, event.Circuit)
+		}
+	}
+	return out
+}
+
+func TestResilientTransportRetry(t *testing.T) {
+	var calls int32 = 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		if atomic.AddInt32(&calls, 1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+	recorder := &eventRecorder{}
+	client := &http.Client{Transport: NewResilientTransport(server.Client().Transport, ResiliencePolicy{
+		Retry:    &RetryPolicy{InitialBackoff: time.Millisecond, Jitter: 0.5},
+		Listener: recorder.listen,
+	})}
+	var cases = []struct {
+		method   string
+		status   int
+		attempts int32
+	}{
+		{http.MethodGet, http.StatusOK, 3},
+		{http.MethodPut, http.StatusOK, 3},
+		{http.MethodPost, http.StatusServiceUnavailable, 1},
+	}
+	for idx, c := range cases {
+		atomic.StoreInt32(&calls, 0)
+		req, _ := http.NewRequest(c.method, server.URL, bytes.NewBufferString("payload"))
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("TestResilientTransportRetry - http.Client.Do - case %v - Expected: %v but Given: %v", idx, nil, err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != c.status || string(body) != "payload" || atomic.LoadInt32(&calls) != c.attempts {
+			t.Fatalf("TestResilientTransportRetry - http.Client.Do - case %v - Expected: %v %v but Given: %v %v (%q)", idx,
+				c.status, c.attempts, resp.StatusCode, atomic.LoadInt32(&calls), body)
+		}
+	}
+	if attempts, retries := recorder.count(RESILIENCE_EVENT_ATTEMPT), recorder.count(RESILIENCE_EVENT_RETRY); attempts != 7 || retries != 4 {
+		t.Fatalf("TestResilientTransportRetry - ResilienceListener - Expected: %v %v but Given: %v %v", 7, 4, attempts, retries)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	slow := &http.Client{Transport: NewResilientTransport(server.Client().Transport, ResiliencePolicy{
+		Retry: &RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Hour},
+	})}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	start := time.Now()
+	if _, err := slow.Do(req); !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second {
+		t.Fatalf("TestResilientTransportRetry - http.Client.Do - Expected: %v but Given: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestResilientTransportCircuitBreaker(t *testing.T) {
+	var failing int32 = 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	recorder := &eventRecorder{}
+	client := &http.Client{Transport: NewResilientTransport(server.Client().Transport, ResiliencePolicy{
+		CircuitBreaker: &CircuitBreakerPolicy{FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond},
+		Listener:       recorder.listen,
+	})}
+	request := func() (int, error) {
+		response, err := NewRequest(client, server.Listener.Addr().String(), "/").Protocol(common.REST_PROTOCOL_HTTP).Do()
+		if err != nil {
+			return 0, err
+		}
+		return response.StatusCode, nil
+	}
+	for i := 0; i < 2; i++ {
+		if status, err := request(); status != http.StatusInternalServerError {
+			t.Fatalf("TestResilientTransportCircuitBreaker - Request.Do - Expected: %v but Given: %v (%v)", http.StatusInternalServerError, status, err)
+		}
+	}
+	if _, err := request(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("TestResilientTransportCircuitBreaker - Request.Do - Expected: %v but Given: %v", ErrCircuitOpen, err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if status, err := request(); status != http.StatusInternalServerError {
+		t.Fatalf("TestResilientTransportCircuitBreaker - Request.Do - Expected: %v but Given: %v (%v)", http.StatusInternalServerError, status, err)
+	}
+	atomic.StoreInt32(&failing, 0)
+	time.Sleep(60 * time.Millisecond)
+	if status, err := request(); status != http.StatusOK {
+		t.Fatalf("TestResilientTransportCircuitBreaker - Request.Do - Expected: %v but Given: %v (%v)", http.StatusOK, status, err)
+	}
+	expected := []CircuitState{CIRCUIT_OPEN, CIRCUIT_HALF_OPEN, CIRCUIT_OPEN, CIRCUIT_HALF_OPEN, CIRCUIT_CLOSED}
+	if given := recorder.circuits(); fmt.Sprint(given) != fmt.Sprint(expected) {
+		t.Fatalf("TestResilientTransportCircuitBreaker - ResilienceListener - Expected: %v but Given: %v", expected, given)
+	}
+	if rejected := recorder.count(RESILIENCE_EVENT_REJECTED); rejected != 1 {
+		t.Fatalf("TestResilientTransportCircuitBreaker - ResilienceListener - Expected: %v but Given: %v", 1, rejected)
+	}
+
+	atomic.StoreInt32(&failing, 1)
+	probing := &http.Client{Transport: NewResilientTransport(server.Client().Transport, ResiliencePolicy{
+		Retry:          &RetryPolicy{MaxAttempts: 2, InitialBackoff: 20 * time.Millisecond, StatusCodes: []int{http.StatusInternalServerError}},
+		CircuitBreaker: &CircuitBreakerPolicy{FailureThreshold: 1, OpenTimeout: 10 * time.Millisecond, HalfOpenRequests: 1},
+	})}
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.GetBody = func() (io.ReadCloser, error) {
+		return nil, errors.New("body unavailable")
+	}
+	if _, err := probing.Do(req); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("TestResilientTransportCircuitBreaker - Request.GetBody - Expected: %v but Given: %v", "body unavailable", err)
+	}
+	atomic.StoreInt32(&failing, 0)
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	if response, err := probing.Do(req); err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("TestResilientTransportCircuitBreaker - Request.Do - Expected: %v but Given: %v (%v)", http.StatusOK, response, err)
+	} else {
+		response.Body.Close()
+	}
+}
+
+func TestResilientTransportCallerCancel(t *testing.T) {
+	var failing int32 = 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if req.URL.Path == "/slow" {
+			select {
+			case <-req.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}))
+	defer server.Close()
+	recorder := &eventRecorder{}
+	client := &http.Client{Transport: NewResilientTransport(server.Client().Transport, ResiliencePolicy{
+		CircuitBreaker: &CircuitBreakerPolicy{FailureThreshold: 1, OpenTimeout: 20 * time.Millisecond, HalfOpenRequests: 1},
+		Listener:       recorder.listen,
+	})}
+	slow := func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/slow", nil)
+		_, err := client.Do(req)
+		return err
+	}
+	get := func() (int, error) {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	if err := slow(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("TestResilientTransportCallerCancel - http.Client.Do - Expected: %v but Given: %v", context.DeadlineExceeded, err)
+	}
+	if status, err := get(); status != http.StatusOK || len(recorder.circuits()) != 0 {
+		t.Fatalf("TestResilientTransportCallerCancel - http.Client.Do - Expected: %v but Given: %v %v (%v)", "closed circuit", status, recorder.circuits(), err)
+	}
+
+	atomic.StoreInt32(&failing, 1)
+	get()
+	atomic.StoreInt32(&failing, 0)
+	time.Sleep(30 * time.Millisecond)
+	if err := slow(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("TestResilientTransportCallerCancel - http.Client.Do - Expected: %v but Given: %v", context.DeadlineExceeded, err)
+	}
+	if status, err := get(); status != http.StatusOK {
+		t.Fatalf("TestResilientTransportCallerCancel - http.Client.Do - Expected: %v but Given: %v (%v)", http.StatusOK, status, err)
+	}
+	expected := []CircuitState{CIRCUIT_OPEN, CIRCUIT_HALF_OPEN, CIRCUIT_CLOSED}
+	if given := recorder.circuits(); fmt.Sprint(given) != fmt.Sprint(expected) {
+		t.Fatalf("TestResilientTransportCallerCancel - ResilienceListener - Expected: %v but Given: %v", expected, given)
+	}
+}
+
+func TestResiliencePolicyValidate(t *testing.T) {
+	var cases = []struct {
+		policy ResiliencePolicy
+		valid  bool
+	}{
+		{ResiliencePolicy{}, true},
+		{ResiliencePolicy{Retry: &RetryPolicy{}, CircuitBreaker: &CircuitBreakerPolicy{}}, true},
+		{ResiliencePolicy{Retry: &RetryPolicy{MaxAttempts: -1}}, false},
+		{ResiliencePolicy{Retry: &RetryPolicy{Multiplier: 0.5}}, false},
+		{ResiliencePolicy{Retry: &RetryPolicy{Jitter: 1.5}}, false},
+		{ResiliencePolicy{CircuitBreaker: &CircuitBreakerPolicy{OpenTimeout: -time.Second}}, false},
+	}
+	for idx, c := range cases {
+		if err := c.policy.Validate(); (err == nil) != c.valid {
+			t.Fatalf("TestResiliencePolicyValidate - ResiliencePolicy.Validate - case %v - Expected: %v but Given: %v", idx, c.valid, err)
+		}
+	}
+	transport := NewResilientTransport(nil, ResiliencePolicy{Retry: &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond, Jitter: -1}}).(*resilientTransport)
+	jittered := NewResilientTransport(nil, ResiliencePolicy{Retry: &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}}).(*resilientTransport)
+	for attempt, expected := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond} {
+		if given := transport.backoff(attempt + 1); given != expected {
+			t.Fatalf("TestResiliencePolicyValidate - resilientTransport.backoff - Expected: %v but Given: %v", expected, given)
+		}
+		variation := time.Duration(float64(expected) * DEFAULT_RETRY_JITTER)
+		if given := jittered.backoff(attempt + 1); given < expected-variation || given > expected+variation {
+			t.Fatalf("TestResiliencePolicyValidate - resilientTransport.backoff - Expected: %v +/- %v but Given: %v", expected, variation, given)
+		}
+	}
+}
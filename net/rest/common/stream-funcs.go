@@ -0,0 +1,53 @@
+package common
+
+import (
+	"encoding/hex"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Reader reporting the transferred bytes to the listener
+type progressReader struct {
+	reader      io.Reader
+	total       int64
+	transferred int64
+	done        bool
+	listener    ProgressListener
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.reader.Read(p)
+	pr.transferred += int64(n)
+	if n > 0 || (err == io.EOF && !pr.done) {
+		pr.done = err == io.EOF
+		pr.listener(ProgressEvent{Transferred: pr.transferred, Total: pr.total, Done: pr.done})
+	}
+	return n, err
+}
+
+// Reader hashing the stream and verifying the hex encoded digest at the end of it
+type checksumReader struct {
+	reader   io.Reader
+	hash     hash.Hash
+	expected string
+}
+
+func (cr *checksumReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.hash.Write(p[:n])
+	if err == io.EOF && !strings.EqualFold(hex.EncodeToString(cr.hash.Sum(nil)), cr.expected) {
+		return n, ErrChecksumMismatch
+	}
+	return n, err
+}
+
+// Response body reading through the wrapping readers and closing the original body
+type streamBody struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (sb *streamBody) Close() error {
+	return sb.closer.Close()
+}
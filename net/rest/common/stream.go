@@ -0,0 +1,37 @@
+package common
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+var (
+	// Error returned at the end of a response stream whose checksum differs from the expected one
+	ErrChecksumMismatch = errors.New("rest: checksum mismatch")
+)
+
+// Progress of a body transfer, Total is -1 when the body size is unknown
+type ProgressEvent struct {
+	Transferred int64
+	Total       int64
+	Done        bool
+}
+
+// Listener of the body transfer progress, called synchronously by the body reads
+type ProgressListener func(event ProgressEvent)
+
+// Http response with the body as stream, the Body must be closed by the caller. ContentLength is -1
+// when the body size is unknown (e.g. chunked transfer)
+type StreamResponse struct {
+	StatusCode    int
+	Status        string
+	Header        http.Header
+	ContentLength int64
+	Body          io.ReadCloser
+}
+
+// Returns true for the 2xx status codes
+func (sr *StreamResponse) IsSuccess() bool {
+	return sr.StatusCode >= 200 && sr.StatusCode < 300
+}
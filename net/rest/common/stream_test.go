@@ -0,0 +1,118 @@
+package common
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/common"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type progressRecorder struct {
+	events []ProgressEvent
+}
+
+func (pr *progressRecorder) listen(event ProgressEvent) {
+	pr.events = append(pr.events, event)
+}
+
+func (pr *progressRecorder) last() ProgressEvent {
+	if len(pr.events) == 0 {
+		return ProgressEvent{}
+	}
+	return pr.events[len(pr.events)-1]
+}
+
+func TestRequestStream(t *testing.T) {
+	artifact := bytes.Repeat([]byte("0123456789"), 10000)
+	sum := sha256.Sum256(artifact)
+	checksum := hex.EncodeToString(sum[:])
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/upload":
+			body, _ := ioutil.ReadAll(req.Body)
+			fmt.Fprintf(w, "%v|%v|%v", req.ContentLength, req.TransferEncoding, len(body))
+		case "/download":
+			w.Header().Set("Content-Length", fmt.Sprint(len(artifact)))
+			w.Write(artifact)
+		case "/logs":
+			flusher := w.(http.Flusher)
+			for i := 0; i < 3; i++ {
+				fmt.Fprintf(w, "line %d\n", i)
+				flusher.Flush()
+			}
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	newRequest := func(path string) Request {
+		return NewRequest(server.Client(), host, path).Protocol(common.REST_PROTOCOL_HTTP)
+	}
+
+	var cases = []struct {
+		size   int64
+		total  int64
+		answer string
+	}{
+		{int64(len(artifact)), int64(len(artifact)), fmt.Sprintf("%v|[]|%v", len(artifact), len(artifact))},
+		{-1, -1, fmt.Sprintf("-1|[chunked]|%v", len(artifact))},
+		{0, -1, fmt.Sprintf("-1|[chunked]|%v", len(artifact))},
+	}
+	for idx, c := range cases {
+		upload := &progressRecorder{}
+		response, err := newRequest("/upload").Method(common.REST_METHOD_PUT).
+			BodyReader(bytes.NewReader(artifact), c.size).UploadProgress(upload.listen).Do()
+		if err != nil || string(response.Body) != c.answer {
+			t.Fatalf("TestRequestStream - Request.BodyReader - case %v - Expected: %v but Given: %+v (%v)", idx, c.answer, response, err)
+		}
+		if expected := (ProgressEvent{Transferred: int64(len(artifact)), Total: c.total, Done: true}); upload.last() != expected {
+			t.Fatalf("TestRequestStream - Request.UploadProgress - case %v - Expected: %+v but Given: %+v", idx, expected, upload.last())
+		}
+	}
+
+	download := &progressRecorder{}
+	stream, err := newRequest("/download").DownloadProgress(download.listen).Checksum(sha256.New, strings.ToUpper(checksum)).Stream()
+	if err != nil || !stream.IsSuccess() || stream.ContentLength != int64(len(artifact)) {
+		t.Fatalf("TestRequestStream - Request.Stream - Expected: %v but Given: %+v (%v)", len(artifact), stream, err)
+	}
+	copied, err := io.Copy(ioutil.Discard, stream.Body)
+	stream.Body.Close()
+	if err != nil || copied != int64(len(artifact)) {
+		t.Fatalf("TestRequestStream - StreamResponse.Body - Expected: %v but Given: %v (%v)", len(artifact), copied, err)
+	}
+	if expected := (ProgressEvent{Transferred: copied, Total: copied, Done: true}); download.last() != expected || len(download.events) < 2 {
+		t.Fatalf("TestRequestStream - Request.DownloadProgress - Expected: %+v but Given: %+v", expected, download.events)
+	}
+
+	stream, _ = newRequest("/download").Checksum(sha256.New, "00").Stream()
+	_, err = io.Copy(ioutil.Discard, stream.Body)
+	stream.Body.Close()
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("TestRequestStream - Request.Checksum - Expected: %v but Given: %v", ErrChecksumMismatch, err)
+	}
+	if _, err := newRequest("/download").Checksum(sha256.New, "00").Do(); err == nil {
+		t.Fatalf("TestRequestStream - Request.Checksum - Expected: %v but Given: %v", ErrChecksumMismatch, err)
+	}
+
+	logs := &progressRecorder{}
+	stream, err = newRequest("/logs").DownloadProgress(logs.listen).Stream()
+	if err != nil || stream.ContentLength != -1 {
+		t.Fatalf("TestRequestStream - Request.Stream - Expected: %v but Given: %+v (%v)", -1, stream, err)
+	}
+	lines, _ := ioutil.ReadAll(stream.Body)
+	stream.Body.Close()
+	if expected := "line 0\nline 1\nline 2\n"; string(lines) != expected || logs.last().Total != -1 || !logs.last().Done {
+		t.Fatalf("TestRequestStream - StreamResponse.Body - Expected: %q but Given: %q %+v", expected, lines, logs.last())
+	}
+
+	if _, err := NewRequest(nil, host, "/").Stream(); err == nil {
+		t.Fatalf("TestRequestStream - Request.Stream - Expected: %v but Given: %v", "not connected error", err)
+	}
+}
@@ -0,0 +1,180 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TLS policy preset name
+type TLSPreset string
+
+const (
+	// TLS 1.3 only
+	TLS_PRESET_MODERN TLSPreset = "modern"
+	// TLS 1.2 and 1.3, forward secret AEAD cipher suites only
+	TLS_PRESET_INTERMEDIATE TLSPreset = "intermediate"
+	// TLS 1.0 to 1.3, adding CBC and RSA key exchange cipher suites for old clients
+	TLS_PRESET_LEGACY TLSPreset = "legacy"
+)
+
+var (
+	// Default TLS policy of the Rest and Api Servers and Clients
+	DEFAULT_TLS_POLICY TLSPolicy = TLSPolicy{Preset: TLS_PRESET_INTERMEDIATE}
+)
+
+var intermediateCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+var tlsPresets = map[TLSPreset]TLSPolicy{
+	TLS_PRESET_MODERN: {
+		MinVersion: tls.VersionTLS13,
+		Curves:     []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	},
+	TLS_PRESET_INTERMEDIATE: {
+		MinVersion:   tls.VersionTLS12,
+		CipherSuites: intermediateCipherSuites,
+		Curves:       []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	},
+	TLS_PRESET_LEGACY: {
+		MinVersion: tls.VersionTLS10,
+		CipherSuites: append(append([]uint16{}, intermediateCipherSuites...),
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+		),
+		Curves: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521},
+	},
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+	"X25519": tls.X25519,
+}
+
+// TLS versions, cipher suites, curves, ALPN protocols and session tickets policy. Empty values keep
+// the preset settings (intermediate when no preset is given). Cipher suites apply to TLS 1.0 - 1.2 only,
+// the TLS 1.3 ones are not configurable
+type TLSPolicy struct {
+	Preset                TLSPreset
+	MinVersion            uint16
+	MaxVersion            uint16
+	CipherSuites          []uint16
+	Curves                []tls.CurveID
+	NextProtos            []string
+	DisableSessionTickets bool
+}
+
+// Returns a new TLS configuration with the policy settings
+func (p TLSPolicy) Config() (*tls.Config, error) {
+	var config = &tls.Config{
+		PreferServerCipherSuites: true,
+		ClientSessionCache:       tls.NewLRUClientSessionCache(256),
+		Rand:                     rand.Reader,
+		Renegotiation:            tls.RenegotiateNever,
+	}
+	if err := p.Apply(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Applies the policy settings to the given TLS configuration, leaving the certificates untouched
+func (p TLSPolicy) Apply(config *tls.Config) error {
+	var preset = p.Preset
+	if "" == preset {
+		preset = TLS_PRESET_INTERMEDIATE
+	}
+	base, ok := tlsPresets[preset]
+	if !ok {
+		return errors.New(fmt.Sprintf("TLSPolicy.Apply - Unknown TLS preset: %s", p.Preset))
+	}
+	var minVersion = base.MinVersion
+	if p.MinVersion != 0 {
+		minVersion = p.MinVersion
+	}
+	if p.MaxVersion != 0 && p.MaxVersion < minVersion {
+		return errors.New(fmt.Sprintf("TLSPolicy.Apply - Maximum version %s lower than the minimum one %s", tls.VersionName(p.MaxVersion), tls.VersionName(minVersion)))
+	}
+	var suites = base.CipherSuites
+	if len(p.CipherSuites) > 0 {
+		suites = p.CipherSuites
+	}
+	var curves = base.Curves
+	if len(p.Curves) > 0 {
+		curves = p.Curves
+	}
+	config.MinVersion = minVersion
+	config.MaxVersion = p.MaxVersion
+	config.CipherSuites = append([]uint16(nil), suites...)
+	config.CurvePreferences = append([]tls.CurveID(nil), curves...)
+	if len(p.NextProtos) > 0 {
+		config.NextProtos = append([]string(nil), p.NextProtos...)
+	}
+	config.SessionTicketsDisabled = p.DisableSessionTickets
+	return nil
+}
+
+// Verifies the policy preset and versions
+func (p TLSPolicy) Validate() error {
+	return p.Apply(&tls.Config{})
+}
+
+// Returns the TLS version of a name in the format 1.0, 1.1, 1.2 or 1.3
+func TLSVersion(name string) (uint16, bool) {
+	version, ok := tlsVersions[strings.TrimSpace(name)]
+	return version, ok
+}
+
+// Returns the cipher suite of a standard name (e.g. TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384)
+func CipherSuite(name string) (uint16, bool) {
+	name = strings.TrimSpace(name)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// Returns the curve of a name in the format P256, P384, P521 or X25519
+func Curve(name string) (tls.CurveID, bool) {
+	curve, ok := tlsCurves[strings.ToUpper(strings.TrimSpace(name))]
+	return curve, ok
+}
+
+// Returns the http.Server TLSNextProto value for a TLS configuration: nil, enabling HTTP/2, when
+// the h2 protocol is listed in the ALPN protocols, otherwise an empty map restricting the server to HTTP/1.1
+func TLSNextProto(config *tls.Config) map[string]func(*http.Server, *tls.Conn, http.Handler) {
+	if config != nil {
+		for _, protocol := range config.NextProtos {
+			if "h2" == protocol {
+				return nil
+			}
+		}
+	}
+	return make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0)
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTLSPolicyConfig(t *testing.T) {
+	config, err := TLSPolicy{Preset: TLS_PRESET_LEGACY, MinVersion: tls.VersionTLS12, Curves: []tls.CurveID{tls.X25519},
+		NextProtos: []string{"h2"}, DisableSessionTickets: true}.Config()
+	if err != nil {
+		t.Fatalf("TestTLSPolicyConfig - TLSPolicy.Config - Expected: %v but Given: %v", nil, err)
+	}
+	if config.MinVersion != tls.VersionTLS12 || len(config.CipherSuites) != 14 || len(config.CurvePreferences) != 1 ||
+		!config.SessionTicketsDisabled || TLSNextProto(config) != nil {
+		t.Fatalf("TestTLSPolicyConfig - TLSPolicy.Config - Expected: %v but Given: %+v", "legacy suites with overrides", config)
+	}
+	config, _ = DEFAULT_TLS_POLICY.Config()
+	for _, suite := range config.CipherSuites {
+		if name := tls.CipherSuiteName(suite); !strings.Contains(name, "GCM") && !strings.Contains(name, "CHACHA20") {
+			t.Fatalf("TestTLSPolicyConfig - TLSPolicy.Config - Expected: %v but Given: %v", "AEAD cipher suites", name)
+		}
+	}
+	if config.MinVersion != tls.VersionTLS12 || TLSNextProto(config) == nil {
+		t.Fatalf("TestTLSPolicyConfig - TLSPolicy.Config - Expected: %v but Given: %+v", "intermediate policy", config)
+	}
+	var invalid = []TLSPolicy{
+		{Preset: "strict"},
+		{Preset: TLS_PRESET_MODERN, MaxVersion: tls.VersionTLS12},
+		{MinVersion: tls.VersionTLS13, MaxVersion: tls.VersionTLS11},
+	}
+	for _, policy := range invalid {
+		if err := policy.Validate(); err == nil {
+			t.Fatalf("TestTLSPolicyConfig - TLSPolicy.Validate(%+v) - Expected: %v but Given: %v", policy, "error", err)
+		}
+	}
+}
+
+func TestTLSPolicyHandshake(t *testing.T) {
+	var cases = []struct {
+		server  TLSPolicy
+		client  TLSPolicy
+		version uint16
+	}{
+		{TLSPolicy{Preset: TLS_PRESET_INTERMEDIATE}, DEFAULT_TLS_POLICY, tls.VersionTLS13},
+		{TLSPolicy{Preset: TLS_PRESET_INTERMEDIATE}, TLSPolicy{MaxVersion: tls.VersionTLS12}, tls.VersionTLS12},
+		{TLSPolicy{Preset: TLS_PRESET_MODERN}, TLSPolicy{MaxVersion: tls.VersionTLS12}, 0},
+		{TLSPolicy{Preset: TLS_PRESET_INTERMEDIATE}, TLSPolicy{Preset: TLS_PRESET_LEGACY, MaxVersion: tls.VersionTLS12,
+			CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA}}, 0},
+		{TLSPolicy{Preset: TLS_PRESET_LEGACY}, TLSPolicy{Preset: TLS_PRESET_LEGACY, MaxVersion: tls.VersionTLS12,
+			CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA}}, tls.VersionTLS12},
+	}
+	for idx, c := range cases {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+		config, err := c.server.Config()
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.TLS = config
+		server.StartTLS()
+		clientConfig := &tls.Config{InsecureSkipVerify: true}
+		if err := c.client.Apply(clientConfig); err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+		resp, err := client.Get(server.URL)
+		var version uint16
+		if err == nil {
+			version = resp.TLS.Version
+			resp.Body.Close()
+		}
+		client.CloseIdleConnections()
+		server.Close()
+		if version != c.version {
+			t.Fatalf("TestTLSPolicyHandshake - case %v - Expected: %v but Given: %v (%v)", idx, tls.VersionName(c.version), tls.VersionName(version), err)
+		}
+	}
+}
+
+func TestTLSPolicyNames(t *testing.T) {
+	if version, ok := TLSVersion(" 1.3"); !ok || version != tls.VersionTLS13 {
+		t.Fatalf("TestTLSPolicyNames - common.TLSVersion - Expected: %v but Given: %v", tls.VersionTLS13, version)
+	}
+	if suite, ok := CipherSuite("TLS_RSA_WITH_AES_128_CBC_SHA"); !ok || suite != tls.TLS_RSA_WITH_AES_128_CBC_SHA {
+		t.Fatalf("TestTLSPolicyNames - common.CipherSuite - Expected: %v but Given: %v", tls.TLS_RSA_WITH_AES_128_CBC_SHA, suite)
+	}
+	if curve, ok := Curve("x25519"); !ok || curve != tls.X25519 {
+		t.Fatalf("TestTLSPolicyNames - common.Curve - Expected: %v but Given: %v", tls.X25519, curve)
+	}
+	if _, ok := Curve("P224"); ok {
+		t.Fatalf("TestTLSPolicyNames - common.Curve - Expected: %v but Given: %v", false, ok)
+	}
+}
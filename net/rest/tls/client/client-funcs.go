@@ -1,62 +1,112 @@
 package client
 
 import (
-	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	rcom "github.com/hellgate75/go-tcp-common/net/rest/common"
 	"net/url"
 )
 
 func (rc *restClient) Request(protocol ncom.RestProtocol, path string, method ncom.RestMethod, accepts *ncom.MimeType, body *[]byte, values *url.Values) (int, []byte, error) {
-	var html *http.Response = nil
-	var err error = nil
-	var status int = 0
-	defer func(){
-		if r := recover(); r != nil {
-			err = errors.New(fmt.Sprintf("RestClient.Connect, Error: %s", r))
-			status = 500
-		}
-	}()
-	requestUrl := fmt.Sprintf("%s://%s:%s%s", string(protocol), rc.IpAddress, rc.Port, path)
-	if ncom.REST_METHOD_GET == method {
-		html,err = rc.client.Get(requestUrl)
-	} else if ncom.REST_METHOD_POST == method {
-		html,err = rc.client.Post(requestUrl, string(*accepts), bytes.NewBuffer(*body))
-	} else if ncom.REST_METHOD_POST_FORM == method {
-		html,err = rc.client.PostForm(requestUrl, *values)
-	} else if ncom.REST_METHOD_HEAD == method {
-		html,err = rc.client.Head(requestUrl)
-	} else {
-		return status, []byte{}, errors.New(fmt.Sprintf("Unavailable Method: %s!!", method))
-	}
-	if err!=nil {
-		return status, []byte{}, err
-	}
-	if html.StatusCode != 200 {
-		return html.StatusCode, []byte{}, errors.New(fmt.Sprintf("Status Code: %v, Message: %s", html.StatusCode, html.Status))
-	}
-	defer html.Body.Close()
-	output, err := ioutil.ReadAll(html.Body)
+	return rc.RequestContext(context.Background(), protocol, path, method, accepts, body, values)
+}
+
+func (rc *restClient) RequestContext(ctx context.Context, protocol ncom.RestProtocol, path string, method ncom.RestMethod, accepts *ncom.MimeType, body *[]byte, values *url.Values) (int, []byte, error) {
+	request := rc.request(ctx, protocol, path, method, accepts, values)
+	if body != nil {
+		request.Body(*body)
+	}
+	response, err := request.Do()
 	if err != nil {
-		rc.logger.Errorf("Status: %v", html.StatusCode)
-		rc.logger.Errorf("Error reading body: %v", err)
-		return status, []byte{}, err
+		return 0, []byte{}, err
+	}
+	rc.logger.Debugf("Status: %v", response.StatusCode)
+	if !response.IsSuccess() {
+		return response.StatusCode, response.Body, errors.New(fmt.Sprintf("Status Code: %v, Message: %s", response.StatusCode, response.Status))
+	}
+	return response.StatusCode, response.Body, nil
+}
+
+func (rc *restClient) RequestStream(ctx context.Context, protocol ncom.RestProtocol, path string, method ncom.RestMethod, accepts *ncom.MimeType, body io.Reader, size int64, values *url.Values) (int, io.ReadCloser, error) {
+	request := rc.request(ctx, protocol, path, method, accepts, values)
+	if body != nil {
+		request.BodyReader(body, size)
+	}
+	response, err := request.Stream()
+	if err != nil {
+		return 0, nil, err
+	}
+	rc.logger.Debugf("Status: %v", response.StatusCode)
+	if !response.IsSuccess() {
+		return response.StatusCode, response.Body, errors.New(fmt.Sprintf("Status Code: %v, Message: %s", response.StatusCode, response.Status))
+	}
+	return response.StatusCode, response.Body, nil
+}
+
+// Creates the request builder of the Request calls, the values are sent as form for POST_FORM and as query otherwise
+func (rc *restClient) request(ctx context.Context, protocol ncom.RestProtocol, path string, method ncom.RestMethod, accepts *ncom.MimeType, values *url.Values) rcom.Request {
+	request := rc.NewRequest(path).Context(ctx).Protocol(protocol).Method(method)
+	if accepts != nil {
+		request.ContentType(*accepts)
+	}
+	if values != nil {
+		if ncom.REST_METHOD_POST_FORM == method {
+			request.Form(*values)
+		} else {
+			for name, list := range *values {
+				for _, value := range list {
+					request.Query(name, value)
+				}
+			}
+		}
 	}
-	rc.logger.Debugf("Status: %v", html.StatusCode)
-	status = html.StatusCode
-	return status, output, err
+	return request
+}
+
+func (rc *restClient) NewRequest(path string) rcom.Request {
+	var doer rcom.Doer = nil
+	if rc.client != nil {
+		doer = rc.client
+	}
+	return rcom.NewRequest(doer, fmt.Sprintf("%s:%s", rc.IpAddress, rc.Port), path)
 }
 
 func (rc *restClient) IsConnected() bool {
 	return rc.client != nil
 }
 
+func (rc *restClient) Connection() *tls.Conn {
+	return rc.conn
+}
+
+func (rc *restClient) SetTLSPolicy(policy rcom.TLSPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	rc.policy = policy
+	return nil
+}
+
+func (rc *restClient) SetResiliencePolicy(policy rcom.ResiliencePolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	rc.resilience = policy
+	return nil
+}
+
 func (rc *restClient) Close() error {
+	if rc.certificates != nil {
+		rc.certificates.Stop()
+		rc.certificates = nil
+	}
 	if rc.client != nil {
 		rc.client.CloseIdleConnections()
 		err := rc.conn.Close()
@@ -79,6 +129,9 @@ func (rc *restClient) Open() error {
 	}
 	
 	var config *tls.Config = &tls.Config{}
+	if err := rc.policy.Apply(config); err != nil {
+		return err
+	}
 	if "" != rc.CaCert {
 		rc.logger.Debugf("client: using ca cert: <%s>", rc.CaCert)
 		caCert, err := ioutil.ReadFile(rc.CaCert)
@@ -97,19 +150,18 @@ func (rc *restClient) Open() error {
 	}
 	if rc.Cert != nil && "" != rc.Cert.Key &&  "" != rc.Cert.Cert {
 		rc.logger.Debugf("client: using client key: <%s>, cert: <%s> ", rc.Cert.Key, rc.Cert.Cert)
-		rc.logger.Debugf("client: using client key: <%s>, cert: <%s> ", rc.Cert.Key, rc.Cert.Cert)
-		cert, err := tls.LoadX509KeyPair(rc.Cert.Cert, rc.Cert.Key)
-		if err != nil {
+		certificates := rcom.NewCertificateManager([]rcom.CertificateKeyPair{*rc.Cert}, "", rcom.DEFAULT_CERTIFICATE_WATCH_INTERVAL, rc.logger)
+		if err := certificates.Start(); err != nil {
 			rc.logger.Errorf("client: Unable to load key : %s and certificate: %s", rc.Cert.Key, rc.Cert.Cert)
-			rc.logger.Fatalf("client: loadkeys: %s", err.Error())
-		} else {
-			config.Certificates=[]tls.Certificate{cert}
+			return err
 		}
+		rc.certificates = certificates
+		config = certificates.ClientConfig(config)
 	}
 	rc.client = &http.Client{
-		Transport: &http.Transport{
+		Transport: rcom.NewResilientTransport(&http.Transport{
 			TLSClientConfig: config,
-		},
+		}, rc.resilience),
 	}
 	service := fmt.Sprintf("%s:%s", rc.IpAddress, rc.Port)
 	rc.logger.Debugf("Connecting to service: %s", service)
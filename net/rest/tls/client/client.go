@@ -16,6 +16,9 @@ type restClient struct {
 	client          *http.Client
 	conn            *tls.Conn
 	logger          log.Logger
+	policy          rcom.TLSPolicy
+	resilience      rcom.ResiliencePolicy
+	certificates    rcom.CertificateManager
 }
 
 func NewWithCertificate(cert string, key string, ipAddress string, port string, logger log.Logger) rcom.RestClient {
@@ -31,6 +34,7 @@ func NewWithCertificate(cert string, key string, ipAddress string, port string,
 		conn: nil,
 		CaCert: "",
 		useInsecure: false,
+		policy: rcom.DEFAULT_TLS_POLICY,
 	}
 }
 
@@ -44,5 +48,6 @@ func NewWithCaCertificate(caCert string, ipAddress string, port string, logger l
 		conn: nil,
 		CaCert: caCert,
 		useInsecure: true,
+		policy: rcom.DEFAULT_TLS_POLICY,
 	}
 }
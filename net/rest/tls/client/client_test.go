@@ -0,0 +1,96 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/common"
+	"github.com/hellgate75/go-tcp-common/net/pki"
+	rcom "github.com/hellgate75/go-tcp-common/net/rest/common"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRestClientRequest(t *testing.T) {
+	ca, _ := pki.NewCertificateAuthority(pki.CertificateRequest{Subject: pkix.Name{CommonName: "Test Root CA"}})
+	cert, _ := ca.IssueServer(pki.CertificateRequest{Hosts: []string{"127.0.0.1"}})
+	pair, _ := cert.TLSCertificate()
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	ca.Certificate().WriteFiles(caFile, "")
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		w.Header().Set("Location", "/items/1")
+		w.Header().Set(common.TRACE_ID_HEADER, req.Header.Get(common.TRACE_ID_HEADER))
+		switch req.Method {
+		case http.MethodPut:
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusConflict)
+		}
+		w.Write(append([]byte(req.Method+" "), body...))
+	}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{pair}}
+	server.StartTLS()
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	client := NewWithCaCertificate(caFile, host, port, log.NewLogger("client-test", log.FATAL))
+	if _, err := client.NewRequest("/items").Do(); err == nil {
+		t.Fatalf("TestRestClientRequest - RestClient.NewRequest - Expected: %v but Given: %v", "not connected error", err)
+	}
+	if err := client.SetResiliencePolicy(rcom.ResiliencePolicy{Retry: &rcom.RetryPolicy{Jitter: 2}}); err == nil {
+		t.Fatalf("TestRestClientRequest - RestClient.SetResiliencePolicy - Expected: %v but Given: %v", "error", err)
+	}
+	var attempts int32 = 0
+	client.SetResiliencePolicy(rcom.ResiliencePolicy{Listener: func(event rcom.ResilienceEvent) {
+		if event.Type == rcom.RESILIENCE_EVENT_ATTEMPT {
+			atomic.AddInt32(&attempts, 1)
+		}
+	}})
+	if err := client.Open(); err != nil {
+		t.Fatalf("TestRestClientRequest - RestClient.Open - Expected: %v but Given: %v", nil, err)
+	}
+	defer client.Close()
+
+	mime := common.JSON_MIME_TYPE
+	body := []byte("{}")
+	status, answer, err := client.Request(common.REST_PROTOCOL_HTTPS, "/items", common.REST_METHOD_PUT, &mime, &body, nil)
+	if err != nil || status != http.StatusCreated || string(answer) != "PUT {}" {
+		t.Fatalf("TestRestClientRequest - RestClient.Request - Expected: %v %v but Given: %v %q (%v)", http.StatusCreated, "PUT {}", status, answer, err)
+	}
+	status, answer, err = client.Request(common.REST_PROTOCOL_HTTPS, "/items/1", common.REST_METHOD_DELETE, nil, nil, nil)
+	if err == nil || status != http.StatusConflict || string(answer) != "DELETE " {
+		t.Fatalf("TestRestClientRequest - RestClient.Request - Expected: %v %q but Given: %v %q (%v)", http.StatusConflict, "DELETE ", status, answer, err)
+	}
+	response, err := client.NewRequest("/items").Method(common.REST_METHOD_PUT).Body([]byte("{}")).Do()
+	if err != nil || response.StatusCode != http.StatusCreated || response.Header.Get("Location") != "/items/1" {
+		t.Fatalf("TestRestClientRequest - RestClient.NewRequest - Expected: %v %v but Given: %+v (%v)", http.StatusCreated, "/items/1", response, err)
+	}
+	response, err = client.NewRequest("/items").Context(common.WithTraceId(context.Background(), "trace-3")).Do()
+	if err != nil || response.Header.Get(common.TRACE_ID_HEADER) != "trace-3" {
+		t.Fatalf("TestRestClientRequest - Request.Context - Expected: %v but Given: %+v (%v)", "trace-3", response, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := client.RequestContext(ctx, common.REST_PROTOCOL_HTTPS, "/items", common.REST_METHOD_GET, nil, nil, nil); err == nil {
+		t.Fatalf("TestRestClientRequest - RestClient.RequestContext - Expected: %v but Given: %v", context.Canceled, err)
+	}
+	status, stream, err := client.RequestStream(context.Background(), common.REST_PROTOCOL_HTTPS, "/items", common.REST_METHOD_PUT, &mime, strings.NewReader("{}"), -1, nil)
+	if err != nil || status != http.StatusCreated {
+		t.Fatalf("TestRestClientRequest - RestClient.RequestStream - Expected: %v but Given: %v (%v)", http.StatusCreated, status, err)
+	}
+	answer, _ = ioutil.ReadAll(stream)
+	stream.Close()
+	if string(answer) != "PUT {}" {
+		t.Fatalf("TestRestClientRequest - RestClient.RequestStream - Expected: %v but Given: %q", "PUT {}", answer)
+	}
+	if given := atomic.LoadInt32(&attempts); given != 6 {
+		t.Fatalf("TestRestClientRequest - ResilienceListener - Expected: %v but Given: %v", 6, given)
+	}
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/rest/common"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pair common.CertificateKeyPair
+}
+
+// Creates a certificate signed by the parent one (self-signed when parent is nil) and writes it in the folder
+func newTestCertificate(t *testing.T, folder string, name string, parent *testCertificate, template *x509.Certificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	var signerCert, signerKey = template, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	pair := common.CertificateKeyPair{Cert: filepath.Join(folder, name+".pem"), Key: filepath.Join(folder, name+".key")}
+	if err := ioutil.WriteFile(pair.Cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(pair.Key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{cert: cert, key: key, pair: pair}
+}
+
+func newClientCertificate(t *testing.T, folder string, name string, ca *testCertificate, spiffeId string) *testCertificate {
+	uri, _ := url.Parse(spiffeId)
+	return newTestCertificate(t, folder, name, ca, &x509.Certificate{
+		URIs:        []*url.URL{uri},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+}
+
+func freePort(t *testing.T) int32 {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return int32(listener.Addr().(*net.TCPAddr).Port)
+}
+
+// Waits until the server has been created and accepts connections
+func waitServing(t *testing.T, rs *restServer, address string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		rs.RLock()
+		running := rs.server != nil
+		rs.RUnlock()
+		if running {
+			if conn, err := net.Dial("tcp", address); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("waitServing - Server %s not listening", address)
+}
+
+func TestMutualTLS(t *testing.T) {
+	folder := t.TempDir()
+	ca := newTestCertificate(t, folder, "ca", nil, &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	serverCert := newTestCertificate(t, folder, "server", ca, &x509.Certificate{
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	prodCert := newClientCertificate(t, folder, "prod", ca, "spiffe://cluster.local/ns/prod/sa/node")
+	devCert := newClientCertificate(t, folder, "dev", ca, "spiffe://cluster.local/ns/dev/sa/node")
+	foreignCA := newTestCertificate(t, folder, "foreign-ca", nil, &x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	foreignCert := newClientCertificate(t, folder, "foreign", foreignCA, "spiffe://cluster.local/ns/prod/sa/node")
+
+	server := New(log.NewLogger("mtls-test", log.FATAL))
+	if err := server.SetClientAuth("sometimes"); err == nil {
+		t.Fatalf("TestMutualTLS - RestServer.SetClientAuth - Expected: %v but Given: %v", "error", err)
+	}
+	if err := server.SetClientAuth(ncom.CLIENT_AUTH_VERIFY_IF_GIVEN); err != nil {
+		t.Fatalf("TestMutualTLS - RestServer.SetClientAuth - Expected: %v but Given: %v", nil, err)
+	}
+	mimeType := ncom.PLAIN_TEXT_MIME_TYPE
+	whoami := func(w http.ResponseWriter, req *http.Request, path string, accepts ncom.MimeType, produces ncom.MimeType) {
+		var name = "anonymous"
+		if identity, ok := ncom.PeerIdentityOf(req); ok {
+			name = identity.SpiffeId
+		}
+		ncom.SubmitSuccess(w, name)
+	}
+	server.AddPath("/public", whoami, &mimeType, &mimeType, []ncom.RestMethod{ncom.REST_METHOD_GET})
+	server.AddPath("/nodes", whoami, &mimeType, &mimeType, []ncom.RestMethod{ncom.REST_METHOD_GET})
+	server.UsePath("/nodes", ncom.AuthorizeMiddleware(ncom.AllowIdentities("spiffe://cluster.local/ns/prod/*")))
+	port := freePort(t)
+	go server.StartTLS("127.0.0.1", port, []common.CertificateKeyPair{serverCert.pair}, ca.pair.Cert, false)
+	defer server.Stop()
+	address := fmt.Sprintf("127.0.0.1:%v", port)
+	waitServing(t, server.(*restServer), address)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	var cases = []struct {
+		client *testCertificate
+		path   string
+		status int
+		answer string
+	}{
+		{nil, "/public", http.StatusOK, "anonymous"},
+		{nil, "/nodes", http.StatusUnauthorized, ""},
+		{prodCert, "/nodes", http.StatusOK, "spiffe://cluster.local/ns/prod/sa/node"},
+		{devCert, "/nodes", http.StatusForbidden, ""},
+		{devCert, "/public", http.StatusOK, "spiffe://cluster.local/ns/dev/sa/node"},
+		{foreignCert, "/public", 0, ""},
+	}
+	for idx, c := range cases {
+		config := &tls.Config{RootCAs: roots}
+		if c.client != nil {
+			pair, err := tls.LoadX509KeyPair(c.client.pair.Cert, c.client.pair.Key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return &pair, nil
+			}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}, Timeout: 5 * time.Second}
+		var status int
+		var answer string
+		resp, err := client.Get(fmt.Sprintf("https://%s%s", address, c.path))
+		if err == nil {
+			status = resp.StatusCode
+			data, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			answer = string(data)
+		}
+		client.CloseIdleConnections()
+		if status != c.status || (c.answer != "" && answer != c.answer) {
+			t.Fatalf("TestMutualTLS - case %v - Expected: %v %q but Given: %v %q (%v)", idx, c.status, c.answer, status, answer, err)
+		}
+	}
+
+	// Rotated server certificate served to the new connections without restart
+	rotated := newTestCertificate(t, folder, "server", ca, &x509.Certificate{
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	rs := server.(*restServer)
+	rs.RLock()
+	certificates := rs.certificates
+	rs.RUnlock()
+	if err := certificates.Reload(); err != nil {
+		t.Fatalf("TestMutualTLS - CertificateManager.Reload - Expected: %v but Given: %v", nil, err)
+	}
+	conn, err := tls.Dial("tcp", address, &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatalf("TestMutualTLS - tls.Dial - Expected: %v but Given: %v", nil, err)
+	}
+	defer conn.Close()
+	if serial := conn.ConnectionState().PeerCertificates[0].SerialNumber; serial.Cmp(rotated.cert.SerialNumber) != 0 {
+		t.Fatalf("TestMutualTLS - tls.Dial - Expected: %v but Given: %v", rotated.cert.SerialNumber, serial)
+	}
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"github.com/hellgate75/go-tcp-common/net/rest/common"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type segmentKind byte
+
+// Segment kinds, in increasing priority order
+const (
+	segmentCatchAll segmentKind = iota
+	segmentWildcard
+	segmentParam
+	segmentTypedParam
+	segmentStatic
+)
+
+var (
+	// Patterns of the typed path parameters, other types are used as regular expressions
+	PATH_PARAMETER_TYPES map[string]string = map[string]string{
+		"int":   `[-+]?[0-9]+`,
+		"float": `[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?`,
+		"bool":  `1|t|T|TRUE|true|True|0|f|F|FALSE|false|False`,
+		"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+		"alpha": `[a-zA-Z]+`,
+	}
+)
+
+type segment struct {
+	kind    segmentKind
+	value   string
+	pattern *regexp.Regexp
+}
+
+// Path template: static segments, {name} and {name:type} parameters, * single segment wildcards
+// and a final {name...} catch-all. Templates ending with a slash match the whole sub-tree
+type route struct {
+	template   string
+	segments   []segment
+	subtree    bool
+	order      int
+	handler    *common.HandlerStruct
+	middleware []ncom.Middleware
+}
+
+func (r *route) match(parts []string) (map[string]string, bool) {
+	var params = make(map[string]string)
+	for idx, seg := range r.segments {
+		if seg.kind == segmentCatchAll {
+			params[seg.value] = strings.Join(parts[idx:], "/")
+			return params, true
+		}
+		if idx >= len(parts) {
+			return nil, false
+		}
+		part := parts[idx]
+		switch seg.kind {
+		case segmentStatic:
+			if part != seg.value {
+				return nil, false
+			}
+		case segmentWildcard:
+			if "" == part {
+				return nil, false
+			}
+		case segmentParam, segmentTypedParam:
+			if "" == part || (seg.pattern != nil && !seg.pattern.MatchString(part)) {
+				return nil, false
+			}
+			params[seg.value] = part
+		}
+	}
+	if len(parts) > len(r.segments) && !r.subtree {
+		return nil, false
+	}
+	return params, true
+}
+
+// Reports whether the route is more specific than the other one
+func (r *route) precedes(other *route) bool {
+	for idx := 0; idx < len(r.segments) && idx < len(other.segments); idx++ {
+		if r.segments[idx].kind != other.segments[idx].kind {
+			return r.segments[idx].kind > other.segments[idx].kind
+		}
+	}
+	if len(r.segments) != len(other.segments) {
+		return len(r.segments) > len(other.segments)
+	}
+	if r.subtree != other.subtree {
+		return !r.subtree
+	}
+	return r.order < other.order
+}
+
+func splitPath(path string) []string {
+	path = strings.TrimPrefix(path, "/")
+	if "" == path {
+		return make([]string, 0)
+	}
+	return strings.Split(path, "/")
+}
+
+func parseRoute(template string) (*route, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, errors.New(fmt.Sprintf("Path template must start with a slash: <%s>", template))
+	}
+	var r = &route{
+		template: template,
+		segments: make([]segment, 0),
+		subtree:  strings.HasSuffix(template, "/"),
+	}
+	parts := splitPath(strings.TrimSuffix(template, "/"))
+	var names = make(map[string]bool)
+	for idx, part := range parts {
+		var seg segment
+		switch {
+		case "*" == part:
+			seg = segment{kind: segmentWildcard}
+		case strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}"):
+			name := part[1 : len(part)-1]
+			var kind string
+			if pos := strings.Index(name, ":"); pos >= 0 {
+				kind = name[pos+1:]
+				name = name[:pos]
+			}
+			if strings.HasSuffix(name, "...") && "" == kind {
+				if idx != len(parts)-1 || r.subtree {
+					return nil, errors.New(fmt.Sprintf("Catch-all parameter must be the last segment: <%s>", template))
+				}
+				name = strings.TrimSuffix(name, "...")
+				seg = segment{kind: segmentCatchAll, value: name}
+			} else if "" == kind || "string" == kind {
+				seg = segment{kind: segmentParam, value: name}
+			} else {
+				expression, ok := PATH_PARAMETER_TYPES[kind]
+				if !ok {
+					expression = kind
+				}
+				pattern, err := regexp.Compile("^(?:" + expression + ")$")
+				if err != nil {
+					return nil, errors.New(fmt.Sprintf("Invalid parameter %s type <%s>, Details: %s", name, kind, err))
+				}
+				seg = segment{kind: segmentTypedParam, value: name, pattern: pattern}
+			}
+			if "" == name || names[name] {
+				return nil, errors.New(fmt.Sprintf("Empty or duplicate parameter name in template: <%s>", template))
+			}
+			names[name] = true
+		case strings.ContainsAny(part, "{}*"):
+			return nil, errors.New(fmt.Sprintf("Invalid segment <%s> in template: <%s>", part, template))
+		default:
+			seg = segment{kind: segmentStatic, value: part}
+		}
+		r.segments = append(r.segments, seg)
+	}
+	return r, nil
+}
+
+// Routes table, sorted by priority
+type router struct {
+	sync.RWMutex
+	routes []*route
+	count  int
+}
+
+func (rt *router) add(r *route) bool {
+	rt.Lock()
+	defer rt.Unlock()
+	for _, existing := range rt.routes {
+		if existing.template == r.template {
+			return false
+		}
+	}
+	r.order = rt.count
+	rt.count++
+	rt.routes = append(rt.routes, r)
+	sort.SliceStable(rt.routes, func(i, j int) bool {
+		return rt.routes[i].precedes(rt.routes[j])
+	})
+	return true
+}
+
+// Finds the highest priority route matching the path and allowing the method. In case a route matches
+// the path only, it is returned with allowed set to false: sub-tree routes are not used as fallback
+// for the paths matched by a complete template
+func (rt *router) match(path string, method string) (r *route, params map[string]string, allowed bool) {
+	parts := splitPath(path)
+	rt.RLock()
+	defer rt.RUnlock()
+	for _, candidate := range rt.routes {
+		values, ok := candidate.match(parts)
+		if !ok {
+			continue
+		}
+		if candidate.subtree && r != nil && !r.subtree {
+			break
+		}
+		if candidate.allows(method) {
+			return candidate, values, true
+		}
+		if r == nil {
+			r, params = candidate, values
+		}
+	}
+	return r, params, false
+}
+
+// Appends the middleware to the route with the given template
+func (rt *router) use(template string, middleware ...ncom.Middleware) bool {
+	rt.Lock()
+	defer rt.Unlock()
+	for _, r := range rt.routes {
+		if r.template == template {
+			var chain = make([]ncom.Middleware, 0, len(r.middleware)+len(middleware))
+			r.middleware = append(append(chain, r.middleware...), middleware...)
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the middleware of the route
+func (rt *router) middleware(r *route) []ncom.Middleware {
+	rt.RLock()
+	defer rt.RUnlock()
+	return r.middleware
+}
+
+func (r *route) allows(method string) bool {
+	for _, allowed := range r.handler.Methods {
+		if string(allowed) == method {
+			return true
+		}
+	}
+	return false
+}
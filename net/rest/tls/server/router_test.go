@@ -0,0 +1,181 @@
+package server
+
+import (
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/rest/common"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func addEcho(t *testing.T, server common.RestServer, path string, methods ...ncom.RestMethod) {
+	mimeType := ncom.PLAIN_TEXT_MIME_TYPE
+	if len(methods) == 0 {
+		methods = []ncom.RestMethod{ncom.REST_METHOD_GET}
+	}
+	ok := server.AddPath(path, func(w http.ResponseWriter, req *http.Request, path string, accepts ncom.MimeType, produces ncom.MimeType) {
+		params := common.PathParamsFrom(req)
+		w.Write([]byte(fmt.Sprintf("%s %v", req.URL.Query().Get("route"), map[string]string(params))))
+	}, &mimeType, &mimeType, methods)
+	if !ok {
+		t.Fatalf("TestRouter - RestServer.AddPath - Expected: %v but Given: %v", true, ok)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	server := New(log.NewLogger("router-test", log.FATAL))
+	addEcho(t, server, "/nodes/{id:int}")
+	addEcho(t, server, "/nodes/{name}")
+	addEcho(t, server, "/nodes/leader")
+	addEcho(t, server, "/nodes/{id:int}/services/*")
+	addEcho(t, server, "/files/{path...}")
+	addEcho(t, server, "/static/")
+	addEcho(t, server, "/items/{code:[a-z]{3}}", ncom.REST_METHOD_POST)
+	addEcho(t, server, "/flags/{on:bool}")
+	addEcho(t, server, "/")
+	if server.AddPath("/nodes/{id:int}", nil, nil, nil, nil) {
+		t.Fatalf("TestRouter - RestServer.AddPath - Expected: %v but Given: %v", "duplicate refused", true)
+	}
+	if server.AddPath("/bad/{rest...}/tail", nil, nil, nil, nil) {
+		t.Fatalf("TestRouter - RestServer.AddPath - Expected: %v but Given: %v", "invalid template refused", true)
+	}
+	handler := server.(http.Handler)
+	var cases = []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{http.MethodGet, "/nodes/42", http.StatusOK, " map[id:42]"},
+		{http.MethodGet, "/nodes/node-a", http.StatusOK, " map[name:node-a]"},
+		{http.MethodGet, "/nodes/leader", http.StatusOK, " map[]"},
+		{http.MethodGet, "/nodes/42/services/api", http.StatusOK, " map[id:42]"},
+		{http.MethodGet, "/files/a/b/c.txt", http.StatusOK, " map[path:a/b/c.txt]"},
+		{http.MethodGet, "/static/css/site.css", http.StatusOK, " map[]"},
+		{http.MethodPost, "/items/abc", http.StatusOK, " map[code:abc]"},
+		{http.MethodGet, "/items/abc", http.StatusMethodNotAllowed, ""},
+		{http.MethodGet, "/flags/True", http.StatusOK, " map[on:True]"},
+		{http.MethodGet, "/flags/tRuE", http.StatusOK, " map[]"},
+		{http.MethodGet, "/unknown/path", http.StatusOK, " map[]"},
+	}
+	for _, c := range cases {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(c.method, c.path, nil))
+		body, _ := ioutil.ReadAll(recorder.Body)
+		if recorder.Code != c.status || (c.status == http.StatusOK && string(body) != c.body) {
+			t.Fatalf("TestRouter - RestServer.ServeHTTP(%s %s) - Expected: %v %q but Given: %v %q", c.method, c.path, c.status, c.body, recorder.Code, string(body))
+		}
+	}
+}
+
+func TestRouterNotFound(t *testing.T) {
+	server := New(log.NewLogger("router-test", log.FATAL))
+	addEcho(t, server, "/nodes/{id:uuid}")
+	recorder := httptest.NewRecorder()
+	server.(http.Handler).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/nodes/42", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("TestRouterNotFound - RestServer.ServeHTTP - Expected: %v but Given: %v", http.StatusNotFound, recorder.Code)
+	}
+}
+
+func TestPathParams(t *testing.T) {
+	req := common.WithPathParams(httptest.NewRequest(http.MethodGet, "/", nil), common.PathParams{"id": "42", "on": "true"})
+	params := common.PathParamsFrom(req)
+	if id, err := params.Int("id"); err != nil || id != 42 {
+		t.Fatalf("TestPathParams - PathParams.Int - Expected: %v but Given: %v (%v)", 42, id, err)
+	}
+	if on, err := params.Bool("on"); err != nil || !on {
+		t.Fatalf("TestPathParams - PathParams.Bool - Expected: %v but Given: %v (%v)", true, on, err)
+	}
+	for _, value := range []string{"1", "t", "T", "TRUE", "true", "True"} {
+		flag := common.WithPathParams(httptest.NewRequest(http.MethodGet, "/", nil), common.PathParams{"on": value})
+		if on, err := common.PathParamsFrom(flag).Bool("on"); err != nil || !on || !regexp.MustCompile("^(?:" + PATH_PARAMETER_TYPES["bool"] + ")$").MatchString(value) {
+			t.Fatalf("TestPathParams - PathParams.Bool(%s) - Expected: %v but Given: %v (%v)", value, true, on, err)
+		}
+	}
+	if _, err := params.Float("missing"); err == nil {
+		t.Fatalf("TestPathParams - PathParams.Float - Expected: %v but Given: %v", "error", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	server := New(log.NewLogger("router-test", log.FATAL))
+	addEcho(t, server, "/nodes/{id:int}")
+	addEcho(t, server, "/panic")
+	var calls = make([]string, 0)
+	trace := func(name string) ncom.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	server.Use(ncom.RecoveryMiddleware(nil), trace("global"))
+	if !server.UsePath("/nodes/{id:int}", trace("route")) {
+		t.Fatalf("TestMiddleware - RestServer.UsePath - Expected: %v but Given: %v", true, false)
+	}
+	if server.UsePath("/missing", trace("route")) {
+		t.Fatalf("TestMiddleware - RestServer.UsePath - Expected: %v but Given: %v", false, true)
+	}
+	server.UsePath("/panic", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			panic("route failure")
+		})
+	})
+	handler := server.(http.Handler)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/nodes/7", nil))
+	if recorder.Code != http.StatusOK || fmt.Sprintf("%v", calls) != "[global route]" || recorder.Body.String() != " map[id:7]" {
+		t.Fatalf("TestMiddleware - RestServer.ServeHTTP - Expected: %v but Given: %v %v %q", "[global route]", recorder.Code, calls, recorder.Body.String())
+	}
+	calls = calls[:0]
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	if recorder.Code != http.StatusNotFound || fmt.Sprintf("%v", calls) != "[global]" {
+		t.Fatalf("TestMiddleware - RestServer.ServeHTTP - Expected: %v but Given: %v %v", "[global]", recorder.Code, calls)
+	}
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("TestMiddleware - RestServer.ServeHTTP - Expected: %v but Given: %v", http.StatusInternalServerError, recorder.Code)
+	}
+}
+
+func TestContentNegotiation(t *testing.T) {
+	server := New(log.NewLogger("router-test", log.FATAL))
+	consumes := ncom.JSON_MIME_TYPE
+	produces := ncom.JSON_MIME_TYPE
+	server.AddPath("/data", func(w http.ResponseWriter, req *http.Request, path string, accepts ncom.MimeType, produces ncom.MimeType) {
+		ncom.SubmitData(w, http.StatusOK, map[string]string{"accepts": string(accepts)}, produces)
+	}, &consumes, &produces, []ncom.RestMethod{ncom.REST_METHOD_POST})
+	var cases = []struct {
+		contentType string
+		accept      string
+		status      int
+		body        string
+	}{
+		{"application/json", "application/xml;q=0.5, text/yaml", http.StatusOK, "\naccepts: application/json\n"},
+		{"", "", http.StatusOK, "\n{\"accepts\":\"application/json\"}"},
+		{"text/plain", "", http.StatusUnsupportedMediaType, ""},
+		{"application/json", "text/html", http.StatusNotAcceptable, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/data", nil)
+		if "" != c.contentType {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		if "" != c.accept {
+			req.Header.Set("Accept", c.accept)
+		}
+		recorder := httptest.NewRecorder()
+		server.(http.Handler).ServeHTTP(recorder, req)
+		if recorder.Code != c.status || (c.status == http.StatusOK && recorder.Body.String() != c.body) {
+			t.Fatalf("TestContentNegotiation - RestServer.ServeHTTP(%q, %q) - Expected: %v %q but Given: %v %q", c.contentType, c.accept, c.status, c.body, recorder.Code, recorder.Body.String())
+		}
+	}
+}
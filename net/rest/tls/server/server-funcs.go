@@ -9,79 +9,190 @@ import (
 	"github.com/hellgate75/go-tcp-common/net/rest/common"
 	ncom "github.com/hellgate75/go-tcp-common/net/common"
 	"github.com/satori/go.uuid"
-	"io/ioutil"
 	"net"
 	"net/http"
 	"time"
 )
 
 func (rs *restServer) AddPath(path string, callback common.RestCallback, accepts *ncom.MimeType, produces *ncom.MimeType, allowedMethods []ncom.RestMethod) bool {
-	var state bool = false
-	defer func() {
+	r, err := parseRoute(path)
+	if err != nil {
+		if rs.logger != nil {
+			rs.logger.Errorf("server: add-path: Invalid path template: %s, Details: %s", path, err)
+		}
+		return false
+	}
+	handler := common.HandlerStruct{
+		Handler: &callback,
+		Consumes: accepts,
+		Produces: produces,
+		Path: path,
+		Methods: allowedMethods,
+	}
+	r.handler = &handler
+	if ! rs.router.add(r) {
+		return false
+	}
+	if rs.logger != nil {
+		rs.logger.Debugf("server: add-path: Adding Path: %s", handler)
+	}
+	rs.Lock()
+	rs.paths[path] = &handler
+	rs.Unlock()
+	return true
+}
+
+// Appends global middleware, executed in the given order around every request
+func (rs *restServer) Use(middleware ...ncom.Middleware) {
+	rs.Lock()
+	var chain = make([]ncom.Middleware, 0, len(rs.middleware)+len(middleware))
+	rs.middleware = append(append(chain, rs.middleware...), middleware...)
+	rs.Unlock()
+}
+
+// Appends middleware executed, after the global ones, around the callback of the given path template
+func (rs *restServer) UsePath(path string, middleware ...ncom.Middleware) bool {
+	if ! rs.router.use(path, middleware...) {
+		if rs.logger != nil {
+			rs.logger.Warnf("server: use-path: Unavailable path template: %s", path)
+		}
+		return false
+	}
+	return true
+}
+
+// Sets the http server timeouts, applied on the next server start
+func (rs *restServer) SetTimeouts(timeouts ncom.ServerTimeouts) {
+	rs.Lock()
+	rs.timeouts = timeouts
+	rs.Unlock()
+}
+
+// Sets the http server limits, the body size limit applies immediately
+func (rs *restServer) SetLimits(limits ncom.ServerLimits) {
+	rs.Lock()
+	rs.limits = limits
+	rs.Unlock()
+}
+
+// Sets the base TLS configuration (versions, cipher suites, curves) completed with the
+// certificates on the next TLS server start, nil restores the default configuration
+func (rs *restServer) ConfigureTLS(config *tls.Config) {
+	rs.Lock()
+	if config != nil {
+		rs.config = config.Clone()
+	} else {
+		rs.config = defaultTLSConfig()
+	}
+	rs.Unlock()
+}
+
+// Sets the TLS client certificate authentication mode of the next TLS server start, the client
+// certificates are verified against the CA certificate given to StartTLS
+func (rs *restServer) SetClientAuth(mode ncom.ClientAuthMode) error {
+	if _, err := mode.TLSClientAuth(); err != nil {
+		return err
+	}
+	rs.Lock()
+	rs.clientAuth = mode
+	rs.Unlock()
+	return nil
+}
+
+// Sets the base TLS configuration from the TLS policy, completed with the certificates on the next TLS server start
+func (rs *restServer) SetTLSPolicy(policy common.TLSPolicy) error {
+	config, err := policy.Config()
+	if err != nil {
+		return err
+	}
+	rs.ConfigureTLS(config)
+	return nil
+}
+
+// Returns the timeouts replacing the zero values with the package defaults, it requires the server lock
+func (rs *restServer) serverTimeouts() ncom.ServerTimeouts {
+	timeouts := rs.timeouts
+	if timeouts.ReadHeader <= 0 {
+		timeouts.ReadHeader = DEFAULT_HEADER_READ_TIMEOUT
+	}
+	if timeouts.Read <= 0 {
+		timeouts.Read = DEFAULT_READ_TIMEOUT
+	}
+	if timeouts.Write <= 0 {
+		timeouts.Write = DEFAULT_WRITE_TIMEOUT
+	}
+	if timeouts.Idle <= 0 {
+		timeouts.Idle = DEFAULT_IDLE_TIMEOUT
+	}
+	return timeouts
+}
+
+// Executes the global middleware chain around the requests dispatch
+func (rs *restServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rs.RLock()
+	middleware := rs.middleware
+	maxBodyBytes := rs.limits.MaxBodyBytes
+	rs.RUnlock()
+	if maxBodyBytes > 0 && req.Body != nil {
+		req.Body = http.MaxBytesReader(w, req.Body, maxBodyBytes)
+	}
+	ncom.Chain(http.HandlerFunc(rs.dispatch), middleware...).ServeHTTP(w, ncom.WithPeerIdentity(req))
+}
+
+// Dispatches the requests to the highest priority path template matching the request path
+func (rs *restServer) dispatch(w http.ResponseWriter, req *http.Request) {
+	defer func(){
 		if r := recover(); r != nil {
-			if rs.logger != nil {
-				rs.logger.Errorf("server: add-path: Errors dueing TLS Rest Server Start up, Details: %v", r)
-			}
-			rs.RUnlock()
-			state = false
+			ncom.SubmitError(w, req, ncom.NewApiError(http.StatusInternalServerError, fmt.Sprintf("Error: %v", r)), "")
+			return
 		}
 	}()
-	if _, ok := rs.paths[path]; !ok {
-		rs.RLock()
-		handler := common.HandlerStruct{
-			Handler: &callback,
-			Consumes: accepts,
-			Produces: produces,
-			Path: path,
-			Methods: allowedMethods,
+	var path string = req.URL.Path
+	r, params, allowed := rs.router.match(path, req.Method)
+	if r == nil {
+		ncom.SubmitError(w, req, ncom.NewApiError(http.StatusNotFound, fmt.Sprintf("Path not found: %s", path)), "")
+		return
+	}
+	handlerStruct := r.handler
+	if rs.logger != nil {
+		rs.logger.Debugf("server: exec-path: Requested Path: %s, Method: %s, matching template: %s", path, req.Method, r.template)
+	}
+	if ! allowed {
+		var message string = fmt.Sprintf("Web Method (path: %s): %s, not matching with available %v", path, req.Method, handlerStruct.Methods)
+		if rs.logger != nil {
+			rs.logger.Warnf("server: exec-path: Required %s", message)
 		}
-		rs.logger.Debugf("server: add-path: Adding Path: %s", handler)
-		rs.paths[path] = &handler
-		handlerFunc := func(w http.ResponseWriter, req *http.Request)() {
-			defer func(){
-				if r := recover(); r != nil {
-					ncom.SubmitFaiure(w, http.StatusInternalServerError, fmt.Sprintf("Error: %v", r))
-					return
-				}
-			}()
-			var path string = req.URL.Path
-			rs.logger.Debugf("server: exec-path: Requested Path: %s", path)
-			if handlerStruct, ok := rs.paths[path]; ok {
-				var matching bool = false
-				var requiredWebMethod string = req.Method
-				rs.logger.Debugf("server: exec-path: Requested Method: %s", requiredWebMethod)
-				rs.logger.Debugf("server: exec-path: Available Path %s Methods: %v", path, handlerStruct.Methods)
-				for _, wm := range handlerStruct.Methods {
-					if string(wm) == requiredWebMethod {
-						matching = true
-					}
-				}
-				rs.logger.Debugf("server: exec-path: Fount in List: %v", matching)
-				rs.logger.Warnf("server: exec-path: Client accepts: %v", req.Header.Get("Accept"))
-				rs.logger.Warnf("server: exec-path: Client content: %v", req.Header.Get("Content-Type"))
-				//if handlerStruct.Produces == nil || handlerStruct.Produces !=
-				if ! matching {
-					var message string = fmt.Sprintf("Web Method (path: %s): %s, not matching with available %v", path, requiredWebMethod, handlerStruct.Methods)
-					rs.logger.Warnf(fmt.Sprintf("server: exec-path: Required " + message))
-					ncom.SubmitFaiure(w, http.StatusMethodNotAllowed, message)
-					return
-				}
-				rs.logger.Warnf("server: exec-path: Calling path: %s, func: %v", path, handlerStruct.Handler != nil)
-				if handlerStruct.Handler != nil {
-					(*handlerStruct.Handler)(w, req, path, *(*handlerStruct).Consumes, *(*handlerStruct).Consumes)
-				} else {
-					rs.logger.Warnf("server: exec-path: Unavailable Handler for path: %s", path)
-				}
-			} else {
-				ncom.SubmitFaiure(w, http.StatusNotFound, "NOT_FOUND")
-				return
-			}
+		ncom.SubmitError(w, req, ncom.NewApiError(http.StatusMethodNotAllowed, message), "")
+		return
+	}
+	var consumes, produces ncom.MimeType
+	if handlerStruct.Consumes != nil {
+		consumes = *handlerStruct.Consumes
+	}
+	if handlerStruct.Produces != nil {
+		produces = *handlerStruct.Produces
+	}
+	contentType, ok := ncom.SupportsContentType(req.Header.Get("Content-Type"), consumes)
+	if ! ok {
+		ncom.SubmitError(w, req, ncom.NewApiError(http.StatusUnsupportedMediaType, fmt.Sprintf("Content Type (path: %s): %s, not matching with available %s", path, req.Header.Get("Content-Type"), consumes)), "")
+		return
+	}
+	if "" != produces {
+		available := ncom.ProducibleMimeTypes(produces)
+		if produces, ok = ncom.NegotiateMimeType(req.Header.Get("Accept"), available); !ok {
+			ncom.SubmitError(w, req, ncom.NewApiError(http.StatusNotAcceptable, fmt.Sprintf("Accept (path: %s): %s, not matching with available %v", path, req.Header.Get("Accept"), available)), "")
+			return
 		}
-		rs.HandleFunc(path, handlerFunc)
-		rs.RUnlock()
-		state = true
+		w.Header().Set("Content-Type", string(produces))
+	}
+	if handlerStruct.Handler != nil {
+		callback := *handlerStruct.Handler
+		ncom.Chain(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			callback(w, req, path, contentType, produces)
+		}), rs.router.middleware(r)...).ServeHTTP(w, common.WithPathParams(req, common.PathParams(params)))
+	} else if rs.logger != nil {
+		rs.logger.Warnf("server: exec-path: Unavailable Handler for path: %s", path)
 	}
-	return state
 }
 
 func (rs *restServer) AddRootPath(callback common.RestCallback, accepts *ncom.MimeType, produces *ncom.MimeType, allowedMethods []ncom.RestMethod) bool {
@@ -119,57 +230,25 @@ func (rs *restServer) StartTLS(hostOrIpAddress string, port int32, certs []commo
 		return errors.New(fmt.Sprintf("server: start : tls: Server already started in %s mode!!", mode))
 	}
 	rs.config.InsecureSkipVerify = insecure
-	if "" != CaCertificate {
-		if rs.logger != nil {
-			rs.logger.Debugf("server: using ca cert: <%s>", CaCertificate)
-		}
-		caCert, err := ioutil.ReadFile(CaCertificate)
-		if err != nil {
-			if rs.logger != nil {
-				rs.logger.Errorf("server: using ca cert: <%s>, details: %s", caCert, err.Error())
-			}
-		} else {
-			caCertPool := x509.NewCertPool()
-			if ok := caCertPool.AppendCertsFromPEM(caCert); !ok {
-				if rs.logger != nil {
-					rs.logger.Error("No certs appended, using system certificates only")
-					rs.logger.Fatalf("server: loadkeys: %s", err.Error())
-				}
-			} else {
-				rs.config.RootCAs= caCertPool
-			}
-		}
+	rs.config.ClientAuth, err = rs.clientAuth.TLSClientAuth()
+	if err != nil {
+		return err
 	}
-	if certs != nil && len(certs) > 0 {
-		var crts []tls.Certificate = make([]tls.Certificate, 0)
-		for _, config := range certs {
-			if "" != config.Key &&  "" != config.Cert {
-				rs.logger.Debugf("api: server: using server key: <%s>, cert: <%s> ", config.Key, config.Cert)
-				cert, err := tls.LoadX509KeyPair(config.Cert, config.Key)
-				if err != nil {
-					rs.logger.Errorf("api: server: Unable to load key : %s and certificate: %s", config.Key, config.Cert)
-					rs.logger.Fatalf("api: server: loadkeys: %s", err.Error())
-				} else {
-					crts = append(crts, cert)
-				}
-			}
-		}
-		rs.config.Certificates=crts
+	if rs.logger != nil {
+		rs.logger.Debugf("server: using ca cert: <%s>, certificates: %v", CaCertificate, certs)
 	}
-	var cert string
-	var key string
-	if len(certs) > 0 {
-		cert = certs[0].Cert
-		key = certs[0].Key
-	} else {
-		cert = ""
-		key = ""
+	certificates := common.NewCertificateManager(certs, CaCertificate, common.DEFAULT_CERTIFICATE_WATCH_INTERVAL, rs.logger)
+	if err = certificates.Start(); err != nil {
+		return err
 	}
+	rs.certificates = certificates
+	tlsConfig := certificates.ServerConfig(rs.config)
 
 	if rs.handlerFunc == nil {
+		timeouts := rs.serverTimeouts()
 		rs.server = &http.Server{
 			Addr: fmt.Sprintf("%s:%v", hostOrIpAddress, port),
-			TLSConfig: rs.config,
+			TLSConfig: tlsConfig,
 			Handler: rs,
 			ConnContext: func(ctx context.Context, c net.Conn) context.Context{
 				ctx = context.WithValue(ctx, ncom.ContextRemoteAddress, c.RemoteAddr())
@@ -184,15 +263,17 @@ func (rs *restServer) StartTLS(hostOrIpAddress string, port int32, certs []commo
 				ctx = context.WithValue(ctx, ncom.ContextKeyAuthtoken, ncom.GenerateSecureToken(64))
 				return ctx
 			},
-			TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
-			ReadTimeout: DEFAULT_READ_TIMEOUT,
-			ReadHeaderTimeout: DEFAULT_HEADER_READ_TIMEOUT,
-			WriteTimeout: DEFAULT_WRITE_TIMEOUT,
-			IdleTimeout: DEFAULT_IDLE_TIMEOUT,
+			TLSNextProto: common.TLSNextProto(rs.config),
+			ReadTimeout: timeouts.Read,
+			ReadHeaderTimeout: timeouts.ReadHeader,
+			WriteTimeout: timeouts.Write,
+			IdleTimeout: timeouts.Idle,
+			MaxHeaderBytes: rs.limits.MaxHeaderBytes,
 		}
 		rs.Unlock()
 		locked = false
-		err = rs.server.ListenAndServeTLS(cert, key)
+		err = rs.server.ListenAndServeTLS("", "")
+		certificates.Stop()
 		if err != nil && "http: Server closed" != err.Error() {
 			if rs.logger != nil {
 				rs.logger.Errorf("server: start : tls: Error: %s", err)
@@ -209,7 +290,7 @@ func (rs *restServer) StartTLS(hostOrIpAddress string, port int32, certs []commo
 		}
 
 		var list net.Listener
-		list, err = tls.Listen("tcp", service, rs.config)
+		list, err = tls.Listen("tcp", service, tlsConfig)
 		if err != nil {
 			rs.logger.Fatalf("server: listen:  Error: %s", err)
 			if rs.listener != nil {
@@ -306,6 +387,7 @@ func (rs *restServer) Start(hostOrIpAddress string, port int32) error {
 		return errors.New(fmt.Sprintf("server: start : simple: Server already started in %s mode!!", mode))
 	}
 	if rs.handlerFunc == nil {
+		timeouts := rs.serverTimeouts()
 		rs.server = &http.Server{
 			Addr: fmt.Sprintf("%s:%v", hostOrIpAddress, port),
 			TLSConfig: rs.config,
@@ -324,10 +406,11 @@ func (rs *restServer) Start(hostOrIpAddress string, port int32) error {
 				return ctx
 			},
 			TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler), 0),
-			ReadTimeout: DEFAULT_READ_TIMEOUT,
-			ReadHeaderTimeout: DEFAULT_HEADER_READ_TIMEOUT,
-			WriteTimeout: DEFAULT_WRITE_TIMEOUT,
-			IdleTimeout: DEFAULT_IDLE_TIMEOUT,
+			ReadTimeout: timeouts.Read,
+			ReadHeaderTimeout: timeouts.ReadHeader,
+			WriteTimeout: timeouts.Write,
+			IdleTimeout: timeouts.Idle,
+			MaxHeaderBytes: rs.limits.MaxHeaderBytes,
 		}
 		rs.Unlock()
 		locked = false
@@ -400,6 +483,7 @@ func (rs *restServer) Start(hostOrIpAddress string, port int32) error {
 
 func (rs *restServer) Stop() error {
 	if rs.IsRunning() {
+		rs.stopCertificates()
 		if rs.server != nil {
 			defer func() {
 				rs.server = nil
@@ -422,6 +506,7 @@ func (rs *restServer) Stop() error {
 
 func (rs *restServer) Shutdown() error {
 	if rs.IsRunning() {
+		rs.stopCertificates()
 		if rs.server != nil {
 			defer func() {
 				rs.server = nil
@@ -440,6 +525,16 @@ func (rs *restServer) Shutdown() error {
 	return nil
 }
 
+// Stops watching the certificate files of the TLS server
+func (rs *restServer) stopCertificates() {
+	rs.Lock()
+	if rs.certificates != nil {
+		rs.certificates.Stop()
+		rs.certificates = nil
+	}
+	rs.Unlock()
+}
+
 func (rs *restServer) IsRunning() bool {
 	return rs.server != nil || rs.listener != nil
 }
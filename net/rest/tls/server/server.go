@@ -1,9 +1,9 @@
 package server
 
 import (
-	"crypto/rand"
 	"crypto/tls"
 	"github.com/hellgate75/go-tcp-common/log"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
 	"github.com/hellgate75/go-tcp-common/net/rest/common"
 	"net"
 	"net/http"
@@ -21,7 +21,7 @@ type TLSHandleFunc func(*tls.Conn, common.RestServer)()
 
 type restServer struct {
 	sync.RWMutex
-	http.ServeMux
+	router      router
 	server     *http.Server
 	config      *tls.Config
 	paths       map[string]*common.HandlerStruct
@@ -30,6 +30,11 @@ type restServer struct {
 	handlerFunc TLSHandleFunc
 	listener	*net.Listener
 	conn		[]*tls.Conn
+	middleware  []ncom.Middleware
+	timeouts    ncom.ServerTimeouts
+	limits      ncom.ServerLimits
+	clientAuth  ncom.ClientAuthMode
+	certificates common.CertificateManager
 }
 
 var (
@@ -39,28 +44,20 @@ var (
 	DEFAULT_IDLE_TIMEOUT time.Duration = 600 * time.Second
 )
 
+// Default base TLS configuration of the servers
+func defaultTLSConfig() *tls.Config {
+	config, _ := common.DEFAULT_TLS_POLICY.Config()
+	return config
+}
+
 func New(logger log.Logger) common.RestServer {
 	return NewHandleFunc(nil, logger)
 }
 
 
 func NewHandleFunc(handleFunc TLSHandleFunc, logger log.Logger) common.RestServer {
-	tlsCfg := &tls.Config{
-		MinVersion:               tls.VersionTLS12,
-		CurvePreferences:         []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
-		PreferServerCipherSuites: true,
-		CipherSuites: []uint16{
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-		},
-		ClientSessionCache: tls.NewLRUClientSessionCache(256),
-		Rand: rand.Reader,
-		Renegotiation: tls.RenegotiateNever,
-	}
 	return &restServer {
-		config:     	tlsCfg,
+		config:     	defaultTLSConfig(),
 		server:     	nil,
 		paths:      	make(map[string]*common.HandlerStruct),
 		tlsMode:    	false,
@@ -0,0 +1,419 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/log"
+	cnet "github.com/hellgate75/go-tcp-common/net"
+	ncom "github.com/hellgate75/go-tcp-common/net/common"
+	"github.com/hellgate75/go-tcp-common/net/rest/common"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Request payload: id (8 bytes) | remaining timeout nanoseconds (8 bytes, 0 = none) | method length (2 bytes) | method | body.
+// The timeout is relative, so the clock skew between the hosts does not move the call deadline
+func encodeRequest(id uint64, timeout time.Duration, method string, body []byte) ([]byte, error) {
+	if len(method) > MAX_METHOD_NAME_SIZE {
+		return nil, errors.New(fmt.Sprintf("rpc: method name too long: %v bytes, maximum: %v", len(method), MAX_METHOD_NAME_SIZE))
+	}
+	buff := make([]byte, 18+len(method)+len(body))
+	binary.BigEndian.PutUint64(buff[0:8], id)
+	if timeout > 0 {
+		binary.BigEndian.PutUint64(buff[8:16], uint64(timeout))
+	}
+	binary.BigEndian.PutUint16(buff[16:18], uint16(len(method)))
+	copy(buff[18:], method)
+	copy(buff[18+len(method):], body)
+	return buff, nil
+}
+
+// Decodes a request payload, the id is returned whenever the payload carries it, so the malformed
+// requests can be answered with an error
+func decodeRequest(payload []byte) (uint64, time.Duration, string, []byte, error) {
+	if len(payload) < 8 {
+		return 0, 0, "", nil, errors.New("rpc: malformed request frame")
+	}
+	id := binary.BigEndian.Uint64(payload[0:8])
+	if len(payload) < 18 {
+		return id, 0, "", nil, errors.New("rpc: malformed request frame")
+	}
+	timeout := time.Duration(binary.BigEndian.Uint64(payload[8:16]))
+	if timeout < 0 {
+		return id, 0, "", nil, errors.New("rpc: malformed request timeout")
+	}
+	size := int(binary.BigEndian.Uint16(payload[16:18]))
+	if len(payload) < 18+size {
+		return id, timeout, "", nil, errors.New("rpc: malformed request method")
+	}
+	return id, timeout, string(payload[18 : 18+size]), payload[18+size:], nil
+}
+
+// Response and cancel payload: id (8 bytes) | body
+func encodeId(id uint64, body []byte) []byte {
+	buff := make([]byte, 8+len(body))
+	binary.BigEndian.PutUint64(buff[0:8], id)
+	copy(buff[8:], body)
+	return buff
+}
+
+func decodeId(payload []byte) (uint64, []byte, error) {
+	if len(payload) < 8 {
+		return 0, nil, errors.New("rpc: malformed frame id")
+	}
+	return binary.BigEndian.Uint64(payload[0:8]), payload[8:], nil
+}
+
+type callResult struct {
+	frame *cnet.Frame
+	err   error
+}
+
+type callRef struct {
+	method string
+	result chan callResult
+}
+
+type rpcClient struct {
+	sync.Mutex
+	conn    net.Conn
+	reader  cnet.FrameReader
+	writer  cnet.FrameWriter
+	lastId  uint64
+	pending map[uint64]*callRef
+	closed  bool
+	err     error
+	logger  log.Logger
+}
+
+func (rc *rpcClient) Call(ctx context.Context, method string, payload []byte) ([]byte, error) {
+	if "" == method {
+		return nil, errors.New("rpc: empty method name")
+	}
+	var timeout time.Duration = 0
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		if timeout = time.Until(deadline); timeout <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+	}
+	id := atomic.AddUint64(&rc.lastId, 1)
+	request, err := encodeRequest(id, timeout, method, payload)
+	if err != nil {
+		return nil, err
+	}
+	call := &callRef{
+		method: method,
+		result: make(chan callResult, 1),
+	}
+	rc.Lock()
+	if rc.closed {
+		err := rc.err
+		rc.Unlock()
+		if err == nil {
+			err = ErrClientClosed
+		}
+		return nil, err
+	}
+	rc.pending[id] = call
+	rc.Unlock()
+	frame := &cnet.Frame{
+		Type:    RPC_FRAME_REQUEST,
+		Payload: request,
+	}
+	if hasDeadline {
+		err = rc.writer.WriteFrameTimeout(frame, time.Until(deadline))
+	} else {
+		err = rc.writer.WriteFrame(frame)
+	}
+	if err != nil {
+		rc.remove(id)
+		if errN, ok := err.(net.Error); ok && errN.Timeout() {
+			// A partially written frame breaks the stream framing, the connection cannot be used anymore
+			rc.conn.Close()
+			rc.fail(err)
+			return nil, context.DeadlineExceeded
+		}
+		return nil, err
+	}
+	select {
+	case result := <-call.result:
+		if result.err != nil {
+			return nil, result.err
+		}
+		if result.frame.HasFlag(cnet.FRAME_FLAG_ERROR) {
+			return nil, &RemoteError{Method: method, Message: string(result.frame.Payload)}
+		}
+		return result.frame.Payload, nil
+	case <-ctx.Done():
+		if rc.remove(id) {
+			errC := rc.writer.WriteFrameTimeout(&cnet.Frame{
+				Type:    RPC_FRAME_CANCEL,
+				Payload: encodeId(id, nil),
+			}, DEFAULT_CANCEL_TIMEOUT)
+			if errC != nil {
+				if rc.logger != nil {
+					rc.logger.Warnf("rpc: client: unable to send cancel for request #%v, details: %s", id, errC)
+				}
+				if errN, ok := errC.(net.Error); ok && errN.Timeout() {
+					rc.conn.Close()
+					rc.fail(errC)
+				}
+			}
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (rc *rpcClient) CallTimeout(method string, payload []byte, timeout time.Duration) ([]byte, error) {
+	if timeout <= 0 {
+		timeout = DEFAULT_CALL_TIMEOUT
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return rc.Call(ctx, method, payload)
+}
+
+func (rc *rpcClient) Close() error {
+	rc.Lock()
+	if rc.closed {
+		rc.Unlock()
+		return nil
+	}
+	rc.Unlock()
+	err := rc.conn.Close()
+	rc.fail(ErrClientClosed)
+	return err
+}
+
+func (rc *rpcClient) IsClosed() bool {
+	rc.Lock()
+	defer rc.Unlock()
+	return rc.closed
+}
+
+func (rc *rpcClient) remove(id uint64) bool {
+	rc.Lock()
+	defer rc.Unlock()
+	if _, ok := rc.pending[id]; ok {
+		delete(rc.pending, id)
+		return true
+	}
+	return false
+}
+
+// Close the client, reporting the given error to all pending calls
+func (rc *rpcClient) fail(err error) {
+	rc.Lock()
+	defer rc.Unlock()
+	if rc.closed {
+		return
+	}
+	rc.closed = true
+	rc.err = err
+	for id, call := range rc.pending {
+		call.result <- callResult{err: err}
+		delete(rc.pending, id)
+	}
+}
+
+func (rc *rpcClient) readLoop() {
+	for {
+		frame, err := rc.reader.ReadFrame()
+		if err != nil {
+			if err == io.EOF {
+				err = ErrClientClosed
+			}
+			rc.fail(err)
+			return
+		}
+		if frame.Type != RPC_FRAME_RESPONSE {
+			if rc.logger != nil {
+				rc.logger.Warnf("rpc: client: unexpected frame type: %v", frame.Type)
+			}
+			continue
+		}
+		id, body, err := decodeId(frame.Payload)
+		if err != nil {
+			if rc.logger != nil {
+				rc.logger.Warnf("rpc: client: %s", err)
+			}
+			continue
+		}
+		rc.Lock()
+		call, ok := rc.pending[id]
+		if ok {
+			delete(rc.pending, id)
+		}
+		rc.Unlock()
+		if ok {
+			frame.Payload = body
+			call.result <- callResult{frame: frame}
+		}
+	}
+}
+
+type rpcServer struct {
+	sync.RWMutex
+	handlers map[string]Handler
+	logger   log.Logger
+}
+
+func (rs *rpcServer) Handle(method string, handler Handler) bool {
+	if "" == method || handler == nil {
+		return false
+	}
+	rs.Lock()
+	defer rs.Unlock()
+	if _, ok := rs.handlers[method]; ok {
+		return false
+	}
+	rs.handlers[method] = handler
+	return true
+}
+
+func (rs *rpcServer) ServeTLS(conn *tls.Conn, server common.RestServer) {
+	if err := rs.Serve(conn); err != nil && rs.logger != nil {
+		rs.logger.Errorf("rpc: server: connection from %v closed, details: %s", conn.RemoteAddr(), err)
+	}
+}
+
+func (rs *rpcServer) Serve(conn net.Conn) error {
+	reader := cnet.NewFrameReader(conn, 0)
+	writer := cnet.NewFrameWriter(conn, 0)
+	var mutex sync.Mutex
+	var inFlight = make(map[uint64]context.CancelFunc)
+	baseCtx, cancelAll := context.WithCancel(context.WithValue(context.Background(), ncom.ContextRemoteAddress, conn.RemoteAddr()))
+	defer cancelAll()
+	answerError := func(id uint64, err error) {
+		answer := &cnet.Frame{
+			Type:    RPC_FRAME_RESPONSE,
+			Flags:   cnet.FRAME_FLAG_ERROR,
+			Payload: encodeId(id, []byte(err.Error())),
+		}
+		if errW := writer.WriteFrame(answer); errW != nil && rs.logger != nil {
+			rs.logger.Errorf("rpc: server: unable to answer request #%v, details: %s", id, errW)
+		}
+	}
+	for {
+		frame, err := reader.ReadFrame()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		switch frame.Type {
+		case RPC_FRAME_REQUEST:
+			id, timeout, method, body, errR := decodeRequest(frame.Payload)
+			if errR != nil {
+				if rs.logger != nil {
+					rs.logger.Warnf("rpc: server: %s", errR)
+				}
+				if len(frame.Payload) >= 8 {
+					answerError(id, errR)
+				}
+				continue
+			}
+			var ctx context.Context
+			var cancel context.CancelFunc
+			if timeout == 0 {
+				ctx, cancel = context.WithCancel(baseCtx)
+			} else {
+				ctx, cancel = context.WithTimeout(baseCtx, timeout)
+			}
+			mutex.Lock()
+			_, duplicate := inFlight[id]
+			if !duplicate {
+				inFlight[id] = cancel
+			}
+			mutex.Unlock()
+			if duplicate {
+				// The running call keeps its id, so that it can still be cancelled
+				cancel()
+				answerError(id, errors.New(fmt.Sprintf("request id already in flight: %v", id)))
+				continue
+			}
+			go func() {
+				defer func() {
+					mutex.Lock()
+					delete(inFlight, id)
+					mutex.Unlock()
+					cancel()
+				}()
+				result, errH := rs.dispatch(ctx, method, body)
+				if ctx.Err() != nil {
+					// Cancelled or expired on the client side as well: nobody waits for the answer
+					return
+				}
+				answer := &cnet.Frame{
+					Type:    RPC_FRAME_RESPONSE,
+					Payload: encodeId(id, result),
+				}
+				if errH != nil {
+					answer.Flags = cnet.FRAME_FLAG_ERROR
+					answer.Payload = encodeId(id, []byte(errH.Error()))
+				}
+				if errW := writer.WriteFrame(answer); errW != nil && rs.logger != nil {
+					rs.logger.Errorf("rpc: server: unable to answer request #%v, details: %s", id, errW)
+				}
+			}()
+		case RPC_FRAME_CANCEL:
+			id, _, errR := decodeId(frame.Payload)
+			if errR != nil {
+				continue
+			}
+			mutex.Lock()
+			if cancel, ok := inFlight[id]; ok {
+				cancel()
+			}
+			mutex.Unlock()
+		default:
+			if rs.logger != nil {
+				rs.logger.Warnf("rpc: server: unexpected frame type: %v", frame.Type)
+			}
+		}
+	}
+}
+
+func (rs *rpcServer) dispatch(ctx context.Context, method string, body []byte) (result []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprintf("panic in method %s: %v", method, r))
+		}
+	}()
+	rs.RLock()
+	handler, ok := rs.handlers[method]
+	rs.RUnlock()
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown method: %s", method))
+	}
+	return handler(ctx, method, body)
+}
+
+// Creates a new RPC client on an open connection, the client owns the connection from now on
+func NewClient(conn net.Conn, logger log.Logger) Client {
+	client := &rpcClient{
+		conn:    conn,
+		reader:  cnet.NewFrameReader(conn, 0),
+		writer:  cnet.NewFrameWriter(conn, 0),
+		pending: make(map[uint64]*callRef),
+		logger:  logger,
+	}
+	go client.readLoop()
+	return client
+}
+
+// Creates a new RPC server, without registered methods
+func NewServer(logger log.Logger) Server {
+	return &rpcServer{
+		handlers: make(map[string]Handler),
+		logger:   logger,
+	}
+}
@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	cnet "github.com/hellgate75/go-tcp-common/net"
+	"github.com/hellgate75/go-tcp-common/net/rest/common"
+	"net"
+	"time"
+)
+
+const (
+	//RPC Frame Type: Request frame
+	RPC_FRAME_REQUEST cnet.FrameType = 0x10
+	//RPC Frame Type: Response frame (error responses carry the FRAME_FLAG_ERROR flag)
+	RPC_FRAME_RESPONSE cnet.FrameType = 0x11
+	//RPC Frame Type: Cancel request frame
+	RPC_FRAME_CANCEL cnet.FrameType = 0x12
+	//Maximum method name size in bytes
+	MAX_METHOD_NAME_SIZE int = 65535
+)
+
+var (
+	// Default timeout used by CallTimeout when a zero timeout is given
+	DEFAULT_CALL_TIMEOUT time.Duration = 30 * time.Second
+	// Timeout of the cancel frame sent when a call context is done
+	DEFAULT_CANCEL_TIMEOUT time.Duration = time.Second
+	// Error returned by calls on a closed client
+	ErrClientClosed = errors.New("rpc: client closed")
+)
+
+// Remote procedure handler, it receives the call context (cancelled on client request or deadline) and the request payload
+type Handler func(ctx context.Context, method string, payload []byte) ([]byte, error)
+
+// Error reported by the remote handler
+type RemoteError struct {
+	Method  string
+	Message string
+}
+
+func (re *RemoteError) Error() string {
+	return fmt.Sprintf("rpc: remote method %s failed: %s", re.Method, re.Message)
+}
+
+// RPC Client, sends many concurrent requests over a single connection
+type Client interface {
+	// Call a remote method, waiting for the answer until the context is done
+	Call(ctx context.Context, method string, payload []byte) ([]byte, error)
+	// Call a remote method, waiting for the answer until the timeout expires
+	CallTimeout(method string, payload []byte, timeout time.Duration) ([]byte, error)
+	// Close the client and the underlying connection
+	Close() error
+	// Returns information about the client state
+	IsClosed() bool
+}
+
+// RPC Server, dispatches requests coming from connections to the registered handlers
+type Server interface {
+	// Register a handler for a method name, returns false if the method is already registered
+	Handle(method string, handler Handler) bool
+	// Serve requests on a connection, until it is closed
+	Serve(conn net.Conn) error
+	// Serve requests on a TLS connection, compatible with the net/rest/tls/server.TLSHandleFunc signature
+	ServeTLS(conn *tls.Conn, server common.RestServer)
+}
@@ -0,0 +1,181 @@
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	cnet "github.com/hellgate75/go-tcp-common/net"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newPipe() (Client, func()) {
+	clientConn, serverConn := net.Pipe()
+	server := NewServer(nil)
+	server.Handle("echo", func(ctx context.Context, method string, payload []byte) ([]byte, error) {
+		return payload, nil
+	})
+	server.Handle("deadline", func(ctx context.Context, method string, payload []byte) ([]byte, error) {
+		deadline, _ := ctx.Deadline()
+		return []byte(time.Until(deadline).String()), nil
+	})
+	server.Handle("wait", func(ctx context.Context, method string, payload []byte) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	go server.Serve(serverConn)
+	client := NewClient(clientConn, nil)
+	return client, func() {
+		client.Close()
+		serverConn.Close()
+	}
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	client, closer := newPipe()
+	defer closer()
+	var group sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		group.Add(1)
+		go func(i int) {
+			defer group.Done()
+			expected := fmt.Sprintf("message-%v", i)
+			answer, err := client.CallTimeout("echo", []byte(expected), 5*time.Second)
+			if err != nil || string(answer) != expected {
+				t.Errorf("TestConcurrentCalls - rpc.Client.Call - Expected: %s but Given: %s, error: %v", expected, answer, err)
+			}
+		}(i)
+	}
+	group.Wait()
+}
+
+func TestCallErrors(t *testing.T) {
+	client, closer := newPipe()
+	defer closer()
+	if _, err := client.CallTimeout("missing", nil, 5*time.Second); err == nil {
+		t.Fatal("TestCallErrors - rpc.Client.Call - Expected remote error for unknown method")
+	} else if _, ok := err.(*RemoteError); !ok {
+		t.Fatalf("TestCallErrors - rpc.Client.Call - Expected RemoteError but Given: %T", err)
+	}
+	if _, err := client.CallTimeout("wait", nil, 100*time.Millisecond); err != context.DeadlineExceeded {
+		t.Fatalf("TestCallErrors - rpc.Client.Call - Expected: %v but Given: %v", context.DeadlineExceeded, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := client.Call(ctx, "wait", nil); err != context.Canceled {
+		t.Fatalf("TestCallErrors - rpc.Client.Call - Expected: %v but Given: %v", context.Canceled, err)
+	}
+	if answer, err := client.CallTimeout("echo", []byte("still alive"), 5*time.Second); err != nil || string(answer) != "still alive" {
+		t.Fatalf("TestCallErrors - rpc.Client.Call - Unexpected answer after cancellation: %s, error: %v", answer, err)
+	}
+	client.Close()
+	if _, err := client.CallTimeout("echo", nil, time.Second); err != ErrClientClosed {
+		t.Fatalf("TestCallErrors - rpc.Client.Call - Expected: %v but Given: %v", ErrClientClosed, err)
+	}
+}
+
+func TestRequestFrames(t *testing.T) {
+	payload, err := encodeRequest(7, 50*time.Millisecond, "echo", []byte("body"))
+	if err != nil {
+		t.Fatalf("TestRequestFrames - rpc.encodeRequest - Expected: %v but Given: %v", nil, err)
+	}
+	id, timeout, method, body, err := decodeRequest(payload)
+	if err != nil || id != 7 || timeout != 50*time.Millisecond || method != "echo" || string(body) != "body" {
+		t.Fatalf("TestRequestFrames - rpc.decodeRequest - Expected: %v %v %v %v but Given: %v %v %v %q (%v)", 7, 50*time.Millisecond, "echo", "body", id, timeout, method, body, err)
+	}
+	if _, err := encodeRequest(8, 0, strings.Repeat("m", MAX_METHOD_NAME_SIZE+1), nil); err == nil {
+		t.Fatalf("TestRequestFrames - rpc.encodeRequest - Expected: %v but Given: %v", "method name too long", err)
+	}
+
+	client, closer := newPipe()
+	defer closer()
+	if _, err := client.CallTimeout(strings.Repeat("m", MAX_METHOD_NAME_SIZE+1), nil, time.Second); err == nil || client.IsClosed() {
+		t.Fatalf("TestRequestFrames - rpc.Client.Call - Expected: %v but Given: %v", "method name too long", err)
+	}
+	answer, err := client.CallTimeout("deadline", nil, time.Second)
+	if err != nil {
+		t.Fatalf("TestRequestFrames - rpc.Client.Call - Expected: %v but Given: %v", nil, err)
+	}
+	if remaining, _ := time.ParseDuration(string(answer)); remaining <= 0 || remaining > time.Second {
+		t.Fatalf("TestRequestFrames - rpc.Client.Call - Expected: %v but Given: %s", "remaining timeout within 1s", answer)
+	}
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go NewServer(nil).Serve(serverConn)
+	writer := cnet.NewFrameWriter(clientConn, 0)
+	reader := cnet.NewFrameReader(clientConn, 0)
+	malformed := make([]byte, 12)
+	binary.BigEndian.PutUint64(malformed[0:8], 42)
+	writer.WriteFrame(&cnet.Frame{Type: RPC_FRAME_REQUEST, Payload: malformed})
+	frame, err := reader.ReadFrameTimeout(5 * time.Second)
+	if err != nil || !frame.HasFlag(cnet.FRAME_FLAG_ERROR) {
+		t.Fatalf("TestRequestFrames - rpc.Server.Serve - Expected: %v but Given: %v (%v)", "error response", frame, err)
+	}
+	if id, _, _ := decodeId(frame.Payload); id != 42 {
+		t.Fatalf("TestRequestFrames - rpc.Server.Serve - Expected: %v but Given: %v", 42, id)
+	}
+}
+
+func TestBlockedWrite(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	client := NewClient(clientConn, nil)
+	defer client.Close()
+	// Nobody reads the server side of the pipe, so the request writes block
+	var group sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			if _, err := client.CallTimeout("echo", []byte("blocked"), 100*time.Millisecond); err == nil {
+				t.Errorf("TestBlockedWrite - rpc.Client.Call - Expected: %v but Given: %v", "error", err)
+			}
+		}()
+	}
+	group.Wait()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("TestBlockedWrite - rpc.Client.Call - Expected: %v but Given: %v", "calls bounded by the deadline", elapsed)
+	}
+	if !client.IsClosed() {
+		t.Fatalf("TestBlockedWrite - rpc.Client.IsClosed - Expected: %v but Given: %v", true, false)
+	}
+}
+
+func TestDuplicateRequestId(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	var cancelled = make(chan struct{})
+	server := NewServer(nil)
+	server.Handle("wait", func(ctx context.Context, method string, payload []byte) ([]byte, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	})
+	go server.Serve(serverConn)
+	writer := cnet.NewFrameWriter(clientConn, 0)
+	reader := cnet.NewFrameReader(clientConn, 0)
+	request, _ := encodeRequest(42, 0, "wait", nil)
+	writer.WriteFrame(&cnet.Frame{Type: RPC_FRAME_REQUEST, Payload: request})
+	writer.WriteFrame(&cnet.Frame{Type: RPC_FRAME_REQUEST, Payload: request})
+	frame, err := reader.ReadFrameTimeout(5 * time.Second)
+	if err != nil || !frame.HasFlag(cnet.FRAME_FLAG_ERROR) {
+		t.Fatalf("TestDuplicateRequestId - rpc.Server.Serve - Expected: %v but Given: %v (%v)", "error response", frame, err)
+	}
+	if id, _, _ := decodeId(frame.Payload); id != 42 {
+		t.Fatalf("TestDuplicateRequestId - rpc.Server.Serve - Expected: %v but Given: %v", 42, id)
+	}
+	writer.WriteFrame(&cnet.Frame{Type: RPC_FRAME_CANCEL, Payload: encodeId(42, nil)})
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("TestDuplicateRequestId - rpc.Server.Serve - Expected: %v but Given: %v", "first call cancelled", "still running")
+	}
+}
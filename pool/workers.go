@@ -0,0 +1,62 @@
+package pool
+
+import (
+	"context"
+	"github.com/hellgate75/go-tcp-common/log"
+	"sync"
+)
+
+// Bounded pool of goroutines running short lived tasks
+type WorkerPool interface {
+	// Run a task as soon as a worker is free, blocking until then or until the context is done
+	Submit(ctx context.Context, task func()) error
+	// Wait for the completion of all submitted tasks
+	Wait()
+	// Maximum number of tasks running together
+	Size() int
+}
+
+type workerPool struct {
+	slots  chan struct{}
+	group  sync.WaitGroup
+	logger log.Logger
+}
+
+func (wp *workerPool) Submit(ctx context.Context, task func()) error {
+	select {
+	case wp.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	wp.group.Add(1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil && wp.logger != nil {
+				wp.logger.Errorf("pool.WorkerPool - Task failure, Details: %v", r)
+			}
+			<-wp.slots
+			wp.group.Done()
+		}()
+		task()
+	}()
+	return nil
+}
+
+func (wp *workerPool) Wait() {
+	wp.group.Wait()
+}
+
+func (wp *workerPool) Size() int {
+	return cap(wp.slots)
+}
+
+// Creates a worker pool running at most size tasks together, size lower than 1 means a single worker
+func NewWorkerPool(size int, logger log.Logger) WorkerPool {
+	if size < 1 {
+		size = 1
+	}
+	return &workerPool{
+		slots:  make(chan struct{}, size),
+		logger: logger,
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/x509/pkix"
 	"github.com/hellgate75/go-deploy/types/module"
 	"github.com/hellgate75/go-tcp-common/io/streams"
 	"github.com/hellgate75/go-tcp-common/log"
@@ -8,10 +9,13 @@ import (
 	common2 "github.com/hellgate75/go-tcp-common/net/api/common"
 	"github.com/hellgate75/go-tcp-common/net/api/server"
 	"github.com/hellgate75/go-tcp-common/net/common"
+	"github.com/hellgate75/go-tcp-common/net/pki"
 	common3 "github.com/hellgate75/go-tcp-common/net/rest/common"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -51,13 +55,26 @@ func main() {
 		os.Exit(5)
 	}
 	apiServer.AddApiStream("/sessionIds", stream, &method, &mimeType3, &mimeType3)
+	// Certificates are created in-process, no external tooling is required
+	folder, _ := ioutil.TempDir("", "certs")
+	defer os.RemoveAll(folder)
+	ca, error2 := pki.NewCertificateAuthority(pki.CertificateRequest{Subject: pkix.Name{CommonName: "Sample Root CA"}})
+	if error2 != nil {
+		serverLogger.Fatalf("Unable to create the CA, Details: %s", error2)
+		os.Exit(6)
+	}
+	ca.Certificate().WriteFiles(filepath.Join(folder, "ca.crt"), "")
+	serverCert, _ := ca.IssueServer(pki.CertificateRequest{Subject: pkix.Name{CommonName: "localhost"}, Hosts: []string{ipAddress, "localhost"}})
+	clientCert, _ := ca.IssueClient(pki.CertificateRequest{Subject: pkix.Name{CommonName: "api-client"}})
+	serverCert.WriteFiles(filepath.Join(folder, "server.pem"), filepath.Join(folder, "server.key"))
+	clientCert.WriteFiles(filepath.Join(folder, "client.pem"), filepath.Join(folder, "client.key"))
 	go func(){
 		err := apiServer.StartTLS("", port, &common2.TLSConfig{
-			CaCertificate: "certs/ca.crt",
+			CaCertificate: filepath.Join(folder, "ca.crt"),
 			Certificates: []common3.CertificateKeyPair{
 				common3.CertificateKeyPair{
-					Cert: "certs/server.pem",
-					Key: "certs/server.key",
+					Cert: filepath.Join(folder, "server.pem"),
+					Key: filepath.Join(folder, "server.key"),
 				},
 			},
 			UseInsecure: true,
@@ -76,11 +93,11 @@ func main() {
 	}()
 	go func(){
 		err := apiClient.ConnectTSL(ipAddress, port, &common2.TLSConfig{
-			CaCertificate: "certs/ca.crt",
+			CaCertificate: filepath.Join(folder, "ca.crt"),
 			Certificates: []common3.CertificateKeyPair{
 				common3.CertificateKeyPair{
-					Cert: "certs/client.pem",
-					Key: "certs/client.key",
+					Cert: filepath.Join(folder, "client.pem"),
+					Key: filepath.Join(folder, "client.key"),
 				},
 			},
 			UseInsecure: true,
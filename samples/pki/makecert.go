@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/x509/pkix"
+	"flag"
+	"fmt"
+	"github.com/hellgate75/go-tcp-common/net/pki"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Creates the sample certificates (ca.crt, server.pem / server.key, client.pem / client.key)
+// without any external tooling, e.g.:
+// go run samples/pki/makecert.go -cn localhost -hosts 127.0.0.1,localhost -out certs
+func main() {
+	var cn, hosts, out, organization, email string
+	flag.StringVar(&cn, "cn", "localhost", "Common name of the server and client certificates")
+	flag.StringVar(&hosts, "hosts", "127.0.0.1,localhost", "Comma separated IP addresses and host names of the server certificate")
+	flag.StringVar(&organization, "organization", "My Organization", "Name of your organization")
+	flag.StringVar(&email, "email", "", "E-mail address of the client certificate")
+	flag.StringVar(&out, "out", "certs", "Output folder")
+	flag.Parse()
+	if err := os.MkdirAll(out, 0700); err != nil {
+		fmt.Printf("Unable to create folder: %s, Details: %s\n", out, err)
+		os.Exit(1)
+	}
+	subject := pkix.Name{CommonName: cn, Organization: []string{organization}}
+	ca, err := pki.NewCertificateAuthority(pki.CertificateRequest{Subject: pkix.Name{CommonName: organization + " Root CA", Organization: []string{organization}}})
+	if err != nil {
+		fmt.Printf("Unable to create the CA, Details: %s\n", err)
+		os.Exit(2)
+	}
+	server, err := ca.IssueServer(pki.CertificateRequest{Subject: subject, Hosts: strings.Split(hosts, ",")})
+	if err != nil {
+		fmt.Printf("Unable to issue the server certificate, Details: %s\n", err)
+		os.Exit(3)
+	}
+	var emails []string
+	if "" != email {
+		emails = []string{email}
+	}
+	client, err := ca.IssueClient(pki.CertificateRequest{Subject: subject, EmailAddresses: emails})
+	if err != nil {
+		fmt.Printf("Unable to issue the client certificate, Details: %s\n", err)
+		os.Exit(4)
+	}
+	for _, file := range []struct {
+		cert *pki.Certificate
+		name string
+	}{{ca.Certificate(), "ca"}, {server, "server"}, {client, "client"}} {
+		certFile := filepath.Join(out, file.name+".pem")
+		if file.name == "ca" {
+			certFile = filepath.Join(out, "ca.crt")
+		}
+		if _, err := file.cert.WriteFiles(certFile, filepath.Join(out, file.name+".key")); err != nil {
+			fmt.Println(err)
+			os.Exit(5)
+		}
+		fmt.Printf("Created certificate: %s\n", certFile)
+	}
+}
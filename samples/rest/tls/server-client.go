@@ -1,14 +1,18 @@
 package main
 
 import (
+	"crypto/x509/pkix"
 	"fmt"
 	"github.com/hellgate75/go-tcp-common/net/common"
 	common2 "github.com/hellgate75/go-tcp-common/net/rest/common"
 	"github.com/hellgate75/go-tcp-common/net/rest/tls/client"
 	"github.com/hellgate75/go-tcp-common/log"
+	"github.com/hellgate75/go-tcp-common/net/pki"
 	"github.com/hellgate75/go-tcp-common/net/rest/tls/server"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -16,9 +20,26 @@ func main() {
 	var port int32 = 40990
 	var serverLogger log.Logger = log.NewLogger("test rest server", log.DEBUG)
 	var clientLogger log.Logger = log.NewLogger("test rest client", log.DEBUG)
-	caCert := "certs\\ca.crt"
-	serverCert := "certs\\server.pem"
-	serverKey := "certs\\server.key"
+	folder, _ := ioutil.TempDir("", "certs")
+	defer os.RemoveAll(folder)
+	caCert := filepath.Join(folder, "ca.crt")
+	serverCert := filepath.Join(folder, "server.pem")
+	serverKey := filepath.Join(folder, "server.key")
+	// Certificates are created in-process, no external tooling is required
+	ca, err := pki.NewCertificateAuthority(pki.CertificateRequest{Subject: pkix.Name{CommonName: "Sample Root CA"}})
+	if err != nil {
+		serverLogger.Errorf("Unable to create the CA: %s", err)
+		os.Exit(4)
+	}
+	ca.Certificate().WriteFiles(caCert, "")
+	cert, err := ca.IssueServer(pki.CertificateRequest{Subject: pkix.Name{CommonName: "localhost"}, Hosts: []string{"127.0.0.1", "localhost"}})
+	if err == nil {
+		_, err = cert.WriteFiles(serverCert, serverKey)
+	}
+	if err != nil {
+		serverLogger.Errorf("Unable to create the server certificate: %s", err)
+		os.Exit(4)
+	}
 	server := server.New(serverLogger)
 	client := client.NewWithCaCertificate(caCert, "127.0.0.1", fmt.Sprintf("%v", port), clientLogger)
 	mime := common.PLAIN_TEXT_MIME_TYPE